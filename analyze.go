@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// What a puzzle's clues look like: how many there are and how they are spread over its rows, columns,
+// blocks and numbers, which symmetries their pattern has, and notes on anything about them that makes
+// the puzzle hard for the annealer. Blocks is left out for puzzles without blocks, like Latin squares.
+type puzzleAnalysis struct {
+	Puzzle     int      `json:"puzzle"`
+	Clues      int      `json:"clues"`
+	Squares    int      `json:"squares"`
+	Density    float64  `json:"density"`
+	Rows       []int    `json:"rows"`
+	Columns    []int    `json:"columns"`
+	Blocks     []int    `json:"blocks,omitempty"`
+	Numbers    []int    `json:"numbers"`
+	Symmetries []string `json:"symmetries"`
+	Notes      []string `json:"notes,omitempty"`
+}
+
+// The symmetries a pattern of clues can have, each as where it moves square (r, c) of a puzzle of the
+// given size. The quarter turn is checked before the half turn, which it implies.
+var clueSymmetries = []struct {
+	name string
+	move func(r int, c int, n int) (int, int)
+}{
+	{"90° rotation", func(r, c, n int) (int, int) { return c, n - 1 - r }},
+	{"180° rotation", func(r, c, n int) (int, int) { return n - 1 - r, n - 1 - c }},
+	{"horizontal mirror", func(r, c, n int) (int, int) { return n - 1 - r, c }},
+	{"vertical mirror", func(r, c, n int) (int, int) { return r, n - 1 - c }},
+	{"diagonal mirror", func(r, c, n int) (int, int) { return c, r }},
+	{"anti-diagonal mirror", func(r, c, n int) (int, int) { return n - 1 - c, n - 1 - r }},
+}
+
+// Returns the symmetries of the pattern of clues of a puzzle (which squares are given, whatever their
+// numbers), as named by clueSymmetries. A pattern with all of them is "full", one with none is "none",
+// and the half turn isn't listed separately when the quarter turn is.
+func symmetryClass(puzzle [][]int) []string {
+
+	n := len(puzzle)
+	var found []string
+	for _, symmetry := range clueSymmetries {
+		symmetric := true
+		for r := 0; r < n && symmetric; r++ {
+			for c := 0; c < n; c++ {
+				r2, c2 := symmetry.move(r, c, n)
+				if (puzzle[r][c] > 0) != (puzzle[r2][c2] > 0) {
+					symmetric = false
+					break
+				}
+			}
+		}
+		if symmetric {
+			found = append(found, symmetry.name)
+		}
+	}
+
+	switch {
+	case len(found) == len(clueSymmetries):
+		return []string{"full"}
+	case len(found) == 0:
+		return []string{"none"}
+	case found[0] == clueSymmetries[0].name && len(found) > 1 && found[1] == clueSymmetries[1].name:
+		return append(found[:1], found[2:]...)
+	}
+
+	return found
+}
+
+// Analyses the clues of a puzzle with the given blocks (nil when it has none) and numbers 1 to
+// solver.NumberCount. Blocked squares, like the gaps of a samurai puzzle, aren't counted as squares.
+func analyzePuzzle(puzzle [][]int, blocks [][]solver.Cell, numberCount int) (a puzzleAnalysis) {
+
+	n := len(puzzle)
+	a.Rows, a.Columns, a.Numbers = make([]int, n), make([]int, n), make([]int, numberCount)
+	for r := range puzzle {
+		for c, number := range puzzle[r] {
+			if number == solver.BlockedSquare {
+				continue
+			}
+			a.Squares++
+			if number <= 0 {
+				continue
+			}
+			a.Clues++
+			a.Rows[r]++
+			a.Columns[c]++
+			if number <= numberCount {
+				a.Numbers[number-1]++
+			}
+		}
+	}
+	for _, block := range blocks {
+		count := 0
+		for _, cell := range block {
+			if puzzle[cell.Row][cell.Col] > 0 {
+				count++
+			}
+		}
+		a.Blocks = append(a.Blocks, count)
+	}
+	if a.Squares > 0 {
+		a.Density = float64(a.Clues) / float64(a.Squares)
+	}
+	a.Symmetries = symmetryClass(puzzle)
+
+	// The notes are the things that leave the annealer with the most freedom and the least to go on
+	var missing []string
+	for number, count := range a.Numbers {
+		if count == 0 {
+			missing = append(missing, fmt.Sprint(number+1))
+		}
+	}
+	if len(missing) > 1 {
+		a.Notes = append(a.Notes, fmt.Sprintf("the numbers %s are never given, so the puzzle has more than one solution (they can be swapped)", strings.Join(missing, ", ")))
+	} else if len(missing) == 1 {
+		a.Notes = append(a.Notes, fmt.Sprintf("the number %s is never given, so nothing pins down where it goes until the rest are placed", missing[0]))
+	}
+	if n == 9 && a.Squares == 81 && a.Clues < 17 {
+		a.Notes = append(a.Notes, fmt.Sprintf("%d clues is fewer than the 17 any 9x9 puzzle with only one solution needs", a.Clues))
+	}
+	// The rows and columns of a puzzle with blocked squares cross its gaps, so only its blocks count
+	blocked := a.Squares < n*n
+	for _, house := range []struct {
+		name   string
+		counts []int
+	}{{"row", a.Rows}, {"column", a.Columns}, {"block", a.Blocks}} {
+		var empty []string
+		for i, count := range house.counts {
+			if count == 0 && !(blocked && house.name != "block") {
+				empty = append(empty, fmt.Sprint(i+1))
+			}
+		}
+		if len(empty) == 1 {
+			a.Notes = append(a.Notes, fmt.Sprintf("%s %s has no clues", house.name, empty[0]))
+		} else if len(empty) > 1 {
+			a.Notes = append(a.Notes, fmt.Sprintf("%ss %s have no clues", house.name, strings.Join(empty, ", ")))
+		}
+	}
+
+	return a
+}
+
+// Writes the analysis of a puzzle for reading: a line summing it up, then the clues of every row,
+// column, block and number, and any notes.
+func (a puzzleAnalysis) write(out io.Writer) {
+
+	counts := func(counts []int) string {
+		fields := make([]string, len(counts))
+		for i, count := range counts {
+			fields[i] = fmt.Sprint(count)
+		}
+		return strings.Join(fields, " ")
+	}
+
+	fmt.Fprintf(out, "%d: %d clues of %d squares (%.1f%%), symmetry: %s\n", a.Puzzle, a.Clues, a.Squares, 100*a.Density, strings.Join(a.Symmetries, ", "))
+	fmt.Fprintf(out, "  rows     %s\n", counts(a.Rows))
+	fmt.Fprintf(out, "  columns  %s\n", counts(a.Columns))
+	if len(a.Blocks) > 0 {
+		fmt.Fprintf(out, "  blocks   %s\n", counts(a.Blocks))
+	}
+	fmt.Fprintf(out, "  numbers  %s\n", counts(a.Numbers))
+	for _, note := range a.Notes {
+		fmt.Fprintf(out, "  note: %s\n", note)
+	}
+}
+
+// The analyze subcommand. Reports the clue count and density of every puzzle in a file (or the one on
+// -l), how the clues are spread over the rows, columns, blocks and numbers, the symmetries of their
+// pattern, and notes on what makes the puzzle hard for the annealer.
+func analyzeCommand(args []string) error {
+
+	flags := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm, ss or csv). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which decides whether the puzzle has blocks ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file of puzzles to analyse (- reads standard input)")
+	linePtr := flags.Int("l", 0, "The line of the one puzzle to analyse (or the grid number in sdk and ss files). Every puzzle in the file is analysed when left out or 0")
+	formatPtr := flags.String("format", "text", "The format of the report (text, or json for an array of one object per puzzle)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("d", *dimPtr)
+	if err != nil {
+		return err
+	}
+	if _, err := variantConstraints(*variantPtr, blockXDim, blockYDim); err != nil {
+		return err
+	}
+	if *formatPtr != "text" && *formatPtr != "json" {
+		return flagErrorf("unknown report format %q for -format (expected text or json)", *formatPtr)
+	}
+	line := 0
+	if *linePtr != 0 {
+		if line, err = checkPositiveInt("l", *linePtr); err != nil {
+			return err
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	var blockMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		blockMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	var analyses []puzzleAnalysis
+	if line > 0 {
+		puzzle, regionMap, _, _, err := readPuzzle(inFile, *inputModePtr, line, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return err
+		}
+		if regionMap == nil && *inputModePtr != "samurai" {
+			regionMap = blockMap
+		}
+		analysis := analyzePuzzle(puzzle, solver.RegionsFromMap(regionMap), blockXDim*blockYDim)
+		analysis.Puzzle = line
+		analyses = append(analyses, analysis)
+	} else {
+		puzzles, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return err
+		}
+		for i, puzzle := range puzzles {
+			analysis := analyzePuzzle(puzzle, solver.RegionsFromMap(blockMap), blockXDim*blockYDim)
+			analysis.Puzzle = i + 1
+			analyses = append(analyses, analysis)
+		}
+	}
+
+	if *formatPtr == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return inputError(encoder.Encode(analyses))
+	}
+
+	for _, analysis := range analyses {
+		analysis.write(os.Stdout)
+	}
+
+	return nil
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// The results of a batch of puzzles, gathered as each is solved for the summary after the last one: how
+// long each took, how many were solved after each number of retries, the final costs of those that
+// weren't solved, and the same again for each difficulty the puzzles were labelled with. Timeouts counts
+// the puzzles given up on at the -per-puzzle-timeout, which is only kept for the whole batch.
+type batchResults struct {
+	times         []time.Duration
+	solved        int
+	solvedByRetry []int
+	failureCosts  []float64
+	timeouts      int
+	difficulties  map[string]*batchResults
+}
+
+// The summary of a batch of puzzles, as written after the last one. Times are in seconds, SolvedByRetry
+// counts the puzzles solved after each number of retries when any needed one, TimedOut those that ran out
+// of their -per-puzzle-timeout, and Difficulties breaks the batch down by difficulty when its puzzles
+// were labelled with one.
+type batchSummary struct {
+	Puzzles         int                 `json:"puzzles"`
+	Solved          int                 `json:"solved"`
+	SolvedByRetry   []int               `json:"solved_by_retry,omitempty"`
+	SolveRate       float64             `json:"solve_rate"`
+	MeanSeconds     float64             `json:"mean_seconds"`
+	MedianSeconds   float64             `json:"median_seconds"`
+	P90Seconds      float64             `json:"p90_seconds"`
+	P99Seconds      float64             `json:"p99_seconds"`
+	MeanFailureCost float64             `json:"mean_failure_cost,omitempty"`
+	TimedOut        int                 `json:"timed_out,omitempty"`
+	Difficulties    []difficultySummary `json:"difficulties,omitempty"`
+}
+
+// The summary of the puzzles of a batch labelled with one difficulty.
+type difficultySummary struct {
+	Difficulty string `json:"difficulty"`
+	batchSummary
+}
+
+// Returns the bucket a puzzle labelled with the given difficulty is summarised in: numeric difficulties
+// (like the ratings of many datasets) are rounded down to a whole number so that there are a handful of
+// buckets, and anything else is its own.
+func difficultyBucket(difficulty string) string {
+
+	if rating, err := strconv.ParseFloat(difficulty, 64); err == nil && !math.IsNaN(rating) && !math.IsInf(rating, 0) {
+		return strconv.Itoa(int(math.Floor(rating)))
+	}
+
+	return difficulty
+}
+
+// Adds the result of one puzzle: how long it took, whether it was solved (and after how many retries)
+// and the cost it ended on, and its difficulty, or "" when it wasn't labelled with one.
+func (b *batchResults) add(elapsed time.Duration, solved bool, retries int, cost float64, difficulty string) {
+
+	b.times = append(b.times, elapsed)
+	if solved {
+		b.solved++
+		for len(b.solvedByRetry) <= retries {
+			b.solvedByRetry = append(b.solvedByRetry, 0)
+		}
+		b.solvedByRetry[retries]++
+	} else {
+		b.failureCosts = append(b.failureCosts, cost)
+	}
+
+	if difficulty == "" {
+		return
+	}
+	if b.difficulties == nil {
+		b.difficulties = map[string]*batchResults{}
+	}
+	bucket := difficultyBucket(difficulty)
+	if b.difficulties[bucket] == nil {
+		b.difficulties[bucket] = &batchResults{}
+	}
+	b.difficulties[bucket].add(elapsed, solved, retries, cost, "")
+}
+
+// Sums up the results. Difficulties are listed in order, numerically when they are numbers.
+func (b *batchResults) summary() (s batchSummary) {
+
+	s.Puzzles, s.Solved, s.TimedOut = len(b.times), b.solved, b.timeouts
+	if s.Puzzles == 0 {
+		return s
+	}
+	if len(b.solvedByRetry) > 1 {
+		s.SolvedByRetry = b.solvedByRetry
+	}
+
+	sorted := append([]time.Duration(nil), b.times...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	var total time.Duration
+	for _, t := range sorted {
+		total += t
+	}
+
+	s.SolveRate = float64(s.Solved) / float64(s.Puzzles)
+	s.MeanSeconds = total.Seconds() / float64(s.Puzzles)
+	s.MedianSeconds = tuneResult{times: sorted}.medianTime().Seconds()
+	s.P90Seconds = percentile(sorted, 0.9).Seconds()
+	s.P99Seconds = percentile(sorted, 0.99).Seconds()
+	for _, cost := range b.failureCosts {
+		s.MeanFailureCost += cost / float64(len(b.failureCosts))
+	}
+
+	for difficulty, results := range b.difficulties {
+		s.Difficulties = append(s.Difficulties, difficultySummary{difficulty, results.summary()})
+	}
+	sort.Slice(s.Difficulties, func(i, j int) bool {
+		a, errA := strconv.Atoi(s.Difficulties[i].Difficulty)
+		b, errB := strconv.Atoi(s.Difficulties[j].Difficulty)
+		if errA == nil && errB == nil {
+			return a < b
+		}
+		return s.Difficulties[i].Difficulty < s.Difficulties[j].Difficulty
+	})
+
+	return s
+}
+
+// Writes the summary of a batch in the given format: text for a table to read, or json for a JSON
+// object.
+func writeBatchSummary(out io.Writer, s batchSummary, format string) error {
+
+	if format == "json" {
+		return json.NewEncoder(out).Encode(s)
+	}
+
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "\nsolved\t%d of %d (%.1f%%)\n", s.Solved, s.Puzzles, 100*s.SolveRate)
+	if len(s.SolvedByRetry) > 0 {
+		counts := make([]string, len(s.SolvedByRetry))
+		for retries, count := range s.SolvedByRetry {
+			counts[retries] = fmt.Sprintf("%d on retry %d", count, retries)
+		}
+		counts[0] = fmt.Sprintf("%d first time", s.SolvedByRetry[0])
+		fmt.Fprintf(w, "solved by attempt\t%s\n", strings.Join(counts, ", "))
+	}
+	fmt.Fprintf(w, "mean\t%s\n", seconds(s.MeanSeconds))
+	fmt.Fprintf(w, "median\t%s\n", seconds(s.MedianSeconds))
+	fmt.Fprintf(w, "p90 / p99\t%s / %s\n", seconds(s.P90Seconds), seconds(s.P99Seconds))
+	if s.Solved < s.Puzzles {
+		fmt.Fprintf(w, "mean cost of failures\t%.4g\n", s.MeanFailureCost)
+	}
+	if s.TimedOut > 0 {
+		fmt.Fprintf(w, "timed out\t%d\n", s.TimedOut)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(s.Difficulties) == 0 {
+		return nil
+	}
+
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "\ndifficulty\tpuzzles\tsolved\tmedian\tp90")
+	for _, d := range s.Difficulties {
+		fmt.Fprintf(w, "%s\t%d\t%.1f%%\t%s\t%s\n", d.Difficulty, d.Puzzles, 100*d.SolveRate, seconds(d.MedianSeconds), seconds(d.P90Seconds))
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The summary of a benchmark, as written by the bench subcommand. Times are in seconds.
+type benchReport struct {
+	Params              string  `json:"params"`
+	Puzzles             int     `json:"puzzles"`
+	Runs                int     `json:"runs"`
+	Solved              int     `json:"solved"`
+	SolveRate           float64 `json:"solve_rate"`
+	MeanSeconds         float64 `json:"mean_seconds"`
+	MedianSeconds       float64 `json:"median_seconds"`
+	P95Seconds          float64 `json:"p95_seconds"`
+	MinSeconds          float64 `json:"min_seconds"`
+	MaxSeconds          float64 `json:"max_seconds"`
+	IterationsPerSecond float64 `json:"iterations_per_second"`
+}
+
+// Returns the value below which the given fraction of the sorted times fall, by the nearest rank.
+func percentile(sorted []time.Duration, fraction float64) time.Duration {
+
+	rank := int(math.Ceil(fraction*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+
+	return sorted[rank]
+}
+
+// Solves every puzzle runs times in turn with the same options, one run at a time so that the runs
+// don't slow each other down, and sums up how long they took. The report's Params describe the options.
+// Iterations are counted across every annealer.
+func runBenchmark(params string, options solver.Options, puzzles []tunePuzzle, runs int) benchReport {
+
+	report := benchReport{Params: params, Puzzles: len(puzzles)}
+	var times []time.Duration
+	var total time.Duration
+	var iterations int64
+
+	for _, puzzle := range puzzles {
+		for run := 0; run < runs; run++ {
+			start := time.Now()
+			_, solved, stats := solver.Search(puzzle.puzzle, puzzle.constraints, options)
+			elapsed := time.Since(start)
+
+			report.Runs++
+			if solved {
+				report.Solved++
+			}
+			times = append(times, elapsed)
+			total += elapsed
+			iterations += stats.Iterations
+		}
+	}
+
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	report.SolveRate = float64(report.Solved) / float64(report.Runs)
+	report.MeanSeconds = total.Seconds() / float64(report.Runs)
+	report.MedianSeconds = tuneResult{times: times}.medianTime().Seconds()
+	report.P95Seconds = percentile(times, 0.95).Seconds()
+	report.MinSeconds = times[0].Seconds()
+	report.MaxSeconds = times[len(times)-1].Seconds()
+	report.IterationsPerSecond = float64(iterations) / total.Seconds()
+
+	return report
+}
+
+// Writes the benchmark summary for reading.
+func writeBenchReport(report benchReport) {
+
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "parameters\t%s\n", report.Params)
+	fmt.Fprintf(out, "runs\t%d (%d puzzles)\n", report.Runs, report.Puzzles)
+	fmt.Fprintf(out, "solved\t%d (%.1f%%)\n", report.Solved, 100*report.SolveRate)
+	fmt.Fprintf(out, "mean\t%s\n", seconds(report.MeanSeconds))
+	fmt.Fprintf(out, "median\t%s\n", seconds(report.MedianSeconds))
+	fmt.Fprintf(out, "p95\t%s\n", seconds(report.P95Seconds))
+	fmt.Fprintf(out, "min / max\t%s / %s\n", seconds(report.MinSeconds), seconds(report.MaxSeconds))
+	fmt.Fprintf(out, "iterations/s\t%.0f\n", report.IterationsPerSecond)
+}
+
+// Reads the puzzles to benchmark on from a file: the one on the given line (or grid), or every puzzle in
+// the file when line is 0. Puzzles whose clues conflict are skipped with a warning, since they would only
+// measure how long it takes to give up, and it is an error for none to be left.
+func readSolvablePuzzles(filename string, mode string, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int, variantName string) (solvable []tunePuzzle, e error) {
+
+	variant, err := variantConstraints(variantName, blockXDim, blockYDim)
+	if err != nil {
+		return nil, err
+	}
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+
+	var blockMap [][]int
+	if solver.HasBlocks(variantName) {
+		blockMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	var puzzles []tunePuzzle
+
+	if line > 0 {
+		puzzle, regionMap, cages, extra, err := readPuzzle(inFile, mode, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return nil, err
+		}
+		if regionMap == nil {
+			regionMap = blockMap
+		}
+		var constraints []solver.Constraint
+		if mode == "samurai" {
+			constraints = append(samuraiConstraints(blockXDim), variant...)
+		} else {
+			constraints = solver.PuzzleConstraints(len(puzzle), regionMap, append(variant, extra...), cages)
+		}
+		puzzles = append(puzzles, tunePuzzle{puzzle, constraints})
+	} else {
+		all, err := readAllPuzzles(inFile, mode, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return nil, err
+		}
+		for _, puzzle := range all {
+			puzzles = append(puzzles, tunePuzzle{puzzle, solver.PuzzleConstraints(len(puzzle), blockMap, variant, nil)})
+		}
+	}
+
+	for n, puzzle := range puzzles {
+		if conflicts := findClueConflicts(puzzle.puzzle, puzzle.constraints); len(conflicts) > 0 {
+			slog.Warn("skipping a puzzle whose clues conflict, so it has no solution", "puzzle", n+1, "conflict", conflicts[0].String())
+			continue
+		}
+		solvable = append(solvable, puzzle)
+	}
+	if len(solvable) == 0 {
+		return nil, puzzleErrorf("there are no puzzles to benchmark on in %s", filename)
+	}
+
+	return solvable, nil
+}
+
+// The bench subcommand. Anneals a puzzle (or every puzzle of a file) a number of times with fixed
+// parameters and reports the solve rate, the spread of solve times and the iteration throughput, for
+// measuring the effect of changes to the solver.
+func benchCommand(args []string) error {
+
+	flags := flag.NewFlagSet("bench", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm, ss or csv). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "puzzles.txt", "The file of puzzles to benchmark on (- reads standard input)")
+	linePtr := flags.Int("l", 0, "The line of the one puzzle to benchmark on (or the grid number in sdk and ss files). Every puzzle in the file is used when left out or 0")
+	addAnnealingFlags(flags)
+	runsPtr := flags.String("n", "10", "The number of times each puzzle is annealed")
+	pprofPtr := flags.String("pprof", "", "An address (eg. :6060) to serve net/http/pprof profiles and expvar counters on while running")
+	formatPtr := flags.String("format", "text", "The format of the report (text, or json)")
+	flags.String("preset", "", "A named bundle of -t, -c, -i, -s and -a tuned for the puzzle size given by -d ("+presetNames()+"). Those flags override it when they are given too")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	params, paramsErr := annealingOptions(flags)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	runs, runsErr := parsePositiveInt("n", *runsPtr)
+
+	for _, err := range []error{paramsErr, dimErr, runsErr} {
+		if err != nil {
+			return err
+		}
+	}
+	warnAnnealerCount(params.annealers, nil)
+
+	if *pprofPtr != "" {
+		if err := startDebugServer(*pprofPtr); err != nil {
+			return err
+		}
+	}
+
+	if *formatPtr != "text" && *formatPtr != "json" {
+		return flagErrorf("unknown report format %q for -format (expected text or json)", *formatPtr)
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	line := 0
+	if *linePtr != 0 {
+		if line, err = checkPositiveInt("l", *linePtr); err != nil {
+			return err
+		}
+	}
+	solvable, err := readSolvablePuzzles(*filePtr, *inputModePtr, line, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim, *variantPtr)
+	if err != nil {
+		return err
+	}
+
+	report := runBenchmark(params.String(), params.options(), solvable, runs)
+
+	if *formatPtr == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return inputError(encoder.Encode(report))
+	}
+
+	writeBenchReport(report)
+	return nil
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The margin around the pages of a book, the gap between the puzzles on a page, the height of the
+// caption above each puzzle and of the heading above them all, and the puzzles on each page of
+// solutions, in points (1/72 of an inch) but for the last.
+const (
+	bookMargin          = 48
+	bookGap             = 24
+	bookCaption         = 18
+	bookHeading         = 40
+	bookSolutionsToPage = 12
+)
+
+// The paper sizes a book can be laid out on, in points, with the names CSS gives them.
+var bookPapers = map[string]struct {
+	width, height float64
+	css           string
+}{
+	"a4":     {595.28, 841.89, "A4"},
+	"letter": {612, 792, "letter"},
+}
+
+// What is drawn on the pages of a book, in points from the top left of the page. Grey runs from 0 for
+// black to 1 for white, and centred text is centred on x rather than starting at it.
+type bookCanvas interface {
+	line(x1 float64, y1 float64, x2 float64, y2 float64, width float64, grey float64)
+	text(x float64, y float64, size float64, bold bool, centred bool, grey float64, s string)
+}
+
+// A puzzle or a solution laid out on a page of a book: its caption, the grid (with the clues of the
+// original puzzle drawn in bold), and where its top left corner goes and how big it is.
+type bookItem struct {
+	caption  string
+	grid     [][]int
+	original [][]int
+	x, y     float64
+	size     float64
+}
+
+// A page of a book: its heading, its number at the foot of the page, and the puzzles on it.
+type bookPage struct {
+	heading string
+	number  int
+	items   []bookItem
+}
+
+// Returns the columns and rows of puzzles a page with the given number of them is laid out in.
+func bookGrid(perPage int) (columns int, rows int) {
+
+	columns = 1
+	if perPage > 8 {
+		columns = 3
+	} else if perPage > 2 {
+		columns = 2
+	}
+
+	return columns, (perPage + columns - 1) / columns
+}
+
+// Lays out the pages of a book on paper of the given size: the puzzles perPage to a page under the
+// title, then their solutions bookSolutionsToPage to a page under "Solutions". Each puzzle is captioned
+// with its number and grade, and each solution with the number of its puzzle.
+func layOutBook(title string, puzzles [][][]int, solutions [][][]int, grades []string, perPage int, width float64, height float64) (pages []bookPage) {
+
+	layOut := func(heading string, count int, perPage int, item func(i int) bookItem) {
+		columns, rows := bookGrid(perPage)
+		areaWidth := width - 2*bookMargin
+		areaHeight := height - 2*bookMargin - bookHeading
+		cellWidth := (areaWidth - float64(columns-1)*bookGap) / float64(columns)
+		cellHeight := (areaHeight - float64(rows-1)*bookGap) / float64(rows)
+		size := math.Min(cellWidth, cellHeight-bookCaption)
+
+		for first := 0; first < count; first += perPage {
+			page := bookPage{heading: heading, number: len(pages) + 1}
+			for i := first; i < count && i < first+perPage; i++ {
+				column, row := (i-first)%columns, (i-first)/columns
+				it := item(i)
+				it.x = bookMargin + float64(column)*(cellWidth+bookGap) + (cellWidth-size)/2
+				it.y = bookMargin + bookHeading + float64(row)*(cellHeight+bookGap) + bookCaption
+				it.size = size
+				page.items = append(page.items, it)
+			}
+			pages = append(pages, page)
+		}
+	}
+
+	layOut(title, len(puzzles), perPage, func(i int) bookItem {
+		return bookItem{caption: fmt.Sprintf("Puzzle %d (%s)", i+1, grades[i]), grid: puzzles[i], original: puzzles[i]}
+	})
+	layOut("Solutions", len(solutions), bookSolutionsToPage, func(i int) bookItem {
+		return bookItem{caption: fmt.Sprintf("Solution %d", i+1), grid: solutions[i], original: puzzles[i]}
+	})
+
+	return pages
+}
+
+// Draws a page of a book, of the given size, on a canvas.
+func drawBookPage(canvas bookCanvas, page bookPage, regionMap [][]int, symbols string, width float64, height float64) {
+
+	canvas.text(bookMargin, bookMargin+20, 20, true, false, 0, page.heading)
+	canvas.text(width/2, height-bookMargin/2, 10, false, true, 0.3, fmt.Sprint(page.number))
+
+	for _, item := range page.items {
+		canvas.text(item.x, item.y-6, 11, false, false, 0.2, item.caption)
+
+		cell := item.size / float64(len(item.grid))
+		var thick [][4]float64
+		for r := range item.grid {
+			for c, number := range item.grid[r] {
+				if number == solver.BlockedSquare {
+					continue
+				}
+				x, y := item.x+float64(c)*cell, item.y+float64(r)*cell
+
+				// Thin edges are drawn by the square above or to the left of them, and thick ones over
+				// them once every thin one is
+				top, left, bottom, right := squareEdges(item.grid, regionMap, r, c)
+				for _, edge := range []struct {
+					thick          bool
+					x1, y1, x2, y2 float64
+				}{
+					{top, x, y, x + cell, y},
+					{left, x, y, x, y + cell},
+					{bottom, x, y + cell, x + cell, y + cell},
+					{right, x + cell, y, x + cell, y + cell},
+				} {
+					if edge.thick {
+						thick = append(thick, [4]float64{edge.x1, edge.y1, edge.x2, edge.y2})
+					} else if edge.x1 == x && edge.y1 == y {
+						canvas.line(edge.x1, edge.y1, edge.x2, edge.y2, 0.5, 0.55)
+					}
+				}
+
+				if number > 0 {
+					clue := item.original[r][c] > 0
+					grey := 0.0
+					if !clue {
+						grey = 0.35
+					}
+					size := cell * 0.6
+					canvas.text(x+cell/2, y+cell/2+size*0.36, size, clue, true, grey, solver.SymbolText(symbols, number))
+				}
+			}
+		}
+		for _, edge := range thick {
+			canvas.line(edge[0], edge[1], edge[2], edge[3], 2, 0)
+		}
+	}
+}
+
+// A canvas that draws a page as the content stream of a PDF page, whose origin is at the bottom left.
+// The regular and bold fonts are the standard Helvetica fonts /F1 and /F2.
+type pdfCanvas struct {
+	height float64
+	b      strings.Builder
+}
+
+func (p *pdfCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, width float64, grey float64) {
+	fmt.Fprintf(&p.b, "%.2f G %.2f w 2 J %.2f %.2f m %.2f %.2f l S\n", grey, width, x1, p.height-y1, x2, p.height-y2)
+}
+
+func (p *pdfCanvas) text(x float64, y float64, size float64, bold bool, centred bool, grey float64, s string) {
+
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	if centred {
+		x -= helveticaWidth(s, size) / 2
+	}
+	// The fonts are in the Windows encoding, which is Latin-1 for the letters of most European languages
+	escaped := make([]byte, 0, len(s))
+	for _, r := range s {
+		switch {
+		case r == '\\' || r == '(' || r == ')':
+			escaped = append(escaped, '\\', byte(r))
+		case r < ' ' || r > 0xff:
+			escaped = append(escaped, '?')
+		default:
+			escaped = append(escaped, byte(r))
+		}
+	}
+	fmt.Fprintf(&p.b, "BT %.2f g /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", grey, font, size, x, p.height-y, escaped)
+}
+
+// Returns roughly how wide text is in Helvetica of the given size, for centring it: exactly for the
+// digits, which are all 0.556 of the size across in both the regular and bold faces, and near enough for
+// the letters of the symbols of bigger puzzles.
+func helveticaWidth(s string, size float64) (width float64) {
+
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			width += 0.556 * size
+		} else {
+			width += 0.667 * size
+		}
+	}
+
+	return width
+}
+
+// Writes the pages of a book as a PDF document of the given page size, with every page's drawing as a
+// stream of its own.
+func writeBookPDF(w io.Writer, pages []bookPage, regionMap [][]int, symbols string, width float64, height float64) error {
+
+	// Objects 1 to 4 are the catalog, the page tree and the fonts; each page is then followed by its
+	// contents
+	var objects []string
+	var kids []string
+	for i, page := range pages {
+		canvas := &pdfCanvas{height: height}
+		drawBookPage(canvas, page, regionMap, symbols, width, height)
+		contents := canvas.b.String()
+		kids = append(kids, fmt.Sprintf("%d 0 R", 5+2*i))
+		objects = append(objects,
+			fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", width, height, 6+2*i),
+			fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(contents), contents))
+	}
+	objects = append([]string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>",
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>",
+	}, objects...)
+
+	var b strings.Builder
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// A canvas that draws a page as SVG elements.
+type svgCanvas struct {
+	b strings.Builder
+}
+
+func (s *svgCanvas) line(x1 float64, y1 float64, x2 float64, y2 float64, width float64, grey float64) {
+	fmt.Fprintf(&s.b, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f" stroke-linecap="square"/>`+"\n", x1, y1, x2, y2, svgGrey(grey), width)
+}
+
+func (s *svgCanvas) text(x float64, y float64, size float64, bold bool, centred bool, grey float64, text string) {
+
+	attributes := ""
+	if bold {
+		attributes += ` font-weight="bold"`
+	}
+	if centred {
+		attributes += ` text-anchor="middle"`
+	}
+	fmt.Fprintf(&s.b, `<text x="%.2f" y="%.2f" font-size="%.2f" fill="%s"%s>%s</text>`+"\n", x, y, size, svgGrey(grey), attributes, html.EscapeString(text))
+}
+
+// Returns an SVG colour for a grey from 0 for black to 1 for white.
+func svgGrey(grey float64) string {
+	level := int(math.Round(255 * grey))
+	return fmt.Sprintf("rgb(%d,%d,%d)", level, level, level)
+}
+
+// Writes the pages of a book as an HTML document to print, each page an SVG image the size of the
+// paper, named by the CSS page size, with a page break after it.
+func writeBookHTML(w io.Writer, title string, pages []bookPage, regionMap [][]int, symbols string, width float64, height float64, paper string) error {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	fmt.Fprintf(&b, "<style>\n@page { size: %s; margin: 0 }\nbody { margin: 0 }\n", paper)
+	fmt.Fprintf(&b, ".page { width: %.2fpt; height: %.2fpt; page-break-after: always }\n.page svg { display: block; width: 100%%; height: 100%% }\n</style>\n</head>\n<body>\n", width, height)
+	for _, page := range pages {
+		canvas := &svgCanvas{}
+		drawBookPage(canvas, page, regionMap, symbols, width, height)
+		fmt.Fprintf(&b, "<div class=\"page\">\n<svg xmlns=\"http://www.w3.org/2000/svg\" viewBox=\"0 0 %.2f %.2f\" font-family=\"Helvetica, Arial, sans-serif\">\n", width, height)
+		fmt.Fprintf(&b, "<rect width=\"%.2f\" height=\"%.2f\" fill=\"white\"/>\n%s</svg>\n</div>\n", width, height, canvas.b.String())
+	}
+	b.WriteString("</body>\n</html>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// The book subcommand. Generates puzzles with a unique solution at the grades asked for, and lays them
+// out in a printable PDF or HTML document with their solutions at the back.
+func bookCommand(args []string) error {
+
+	flags := flag.NewFlagSet("book", flag.ContinueOnError)
+	countPtr := flags.String("n", "12", "The number of puzzles in the book")
+	difficultyPtr := flags.String("difficulty", "", "The grades of the puzzles, as for generate -difficulty, comma separated to share the puzzles out between them in order, eg. easy,medium,hard (any grade when left out)")
+	attemptsPtr := flags.Int("attempts", 100, "The most puzzles generated for each one in the book before giving up")
+	cluesPtr := flags.Int("clues", 0, "Stop emptying squares once only this many clues are left (0 empties as many as keep the solution unique)")
+	symmetryPtr := flags.String("symmetry", "none", "The symmetry the pattern of clues keeps (none, rotational, quarter-turn, horizontal, vertical, diagonal or anti-diagonal)")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	symbolsPtr := flags.String("symbols", "", "The symbols drawn for the numbers 1, 2, 3... Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	seedPtr := flags.Int64("seed", 0, "The seed the puzzles are generated with, for making the same book again on any machine (0 picks one at random)")
+	titlePtr := flags.String("title", "Sudoku", "The heading of the pages of puzzles")
+	perPagePtr := flags.Int("per-page", 6, "The puzzles on each page, from 1 to 12 (the solutions are 12 to a page)")
+	paperPtr := flags.String("paper", "a4", "The paper size (a4 or letter)")
+	outPtr := flags.String("o", "", "The .pdf or .html file to write the book to")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	count, countErr := parsePositiveInt("n", *countPtr)
+
+	for _, err := range []error{dimErr, countErr} {
+		if err != nil {
+			return err
+		}
+	}
+	puzzleDim := blockXDim * blockYDim
+
+	if *cluesPtr < 0 || *cluesPtr > puzzleDim*puzzleDim {
+		return flagErrorf("invalid value %d for -clues: must be between 0 and %d", *cluesPtr, puzzleDim*puzzleDim)
+	}
+	if *attemptsPtr < 1 {
+		return flagErrorf("invalid value %d for -attempts: must be at least 1", *attemptsPtr)
+	}
+	if *perPagePtr < 1 || *perPagePtr > bookSolutionsToPage {
+		return flagErrorf("invalid value %d for -per-page: must be between 1 and %d", *perPagePtr, bookSolutionsToPage)
+	}
+	paper, ok := bookPapers[*paperPtr]
+	if !ok {
+		return flagErrorf("unknown paper size %q for -paper (expected a4 or letter)", *paperPtr)
+	}
+	format := strings.ToLower(filepath.Ext(*outPtr))
+	if *outPtr == "" {
+		return flagErrorf("the file to write the book to must be given with -o")
+	}
+	if format != ".pdf" && format != ".html" {
+		return flagErrorf("invalid value %q for -o: books are written as .pdf or .html files", *outPtr)
+	}
+	symmetry, err := generatorSymmetry(*symmetryPtr)
+	if err != nil {
+		return err
+	}
+
+	// The puzzles are shared out between the bands in order, the first bands taking any left over
+	type band struct{ lowest, highest int }
+	bands := []band{{0, len(difficulties) - 1}}
+	if *difficultyPtr != "" {
+		bands = nil
+		for _, text := range strings.Split(*difficultyPtr, ",") {
+			lowest, highest, err := parseDifficultyBand(strings.TrimSpace(text))
+			if err != nil {
+				return err
+			}
+			bands = append(bands, band{lowest, highest})
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, "", puzzleDim)
+	if err != nil {
+		return err
+	}
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+	constraints := solver.PuzzleConstraints(puzzleDim, regionMap, variant, nil)
+
+	seed := *seedPtr
+	if seed == 0 {
+		seed = solver.FreshSeed()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	var puzzles, solutions [][][]int
+	var grades []string
+	for i, b := range bands {
+		share := count / len(bands)
+		if i < count%len(bands) {
+			share++
+		}
+		for j := 0; j < share; j++ {
+			puzzle, solution, grade, err := generateInBand(puzzleDim, constraints, *cluesPtr, symmetry, b.lowest, b.highest, *attemptsPtr, rng)
+			if err != nil {
+				return fmt.Errorf("puzzle %d: %w", len(puzzles)+1, err)
+			}
+			puzzles, solutions, grades = append(puzzles, puzzle), append(solutions, solution), append(grades, grade)
+		}
+	}
+
+	pages := layOutBook(*titlePtr, puzzles, solutions, grades, *perPagePtr, paper.width, paper.height)
+
+	file, err := os.Create(*outPtr)
+	if err != nil {
+		return inputError(err)
+	}
+	defer file.Close()
+
+	if format == ".pdf" {
+		err = writeBookPDF(file, pages, regionMap, symbols, paper.width, paper.height)
+	} else {
+		err = writeBookHTML(file, *titlePtr, pages, regionMap, symbols, paper.width, paper.height, paper.css)
+	}
+
+	return inputError(err)
+}
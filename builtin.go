@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The sample puzzles built into the program, one file of one-line puzzles per set: easy, medium, hard
+// and evil 9x9 puzzles as rated by the rate command, and a few puzzles of each block size (2x2, 2x3, 3x3
+// and 4x4). Each file's header gives the size of its puzzles, so they need no -d.
+//
+//go:embed samples
+var sampleFiles embed.FS
+
+// The prefix of the file names that read the built in sample puzzles instead of a file.
+const builtinPrefix = "builtin:"
+
+// Returns the names of all the sets of sample puzzles, sorted and comma separated for use in messages.
+func sampleNames() string {
+
+	entries, _ := sampleFiles.ReadDir("samples")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".txt"))
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Opens the sample puzzles named after the builtin: prefix: a whole set like "hard", or one puzzle of a
+// set by its number, like "hard-3" for the third hard puzzle.
+func openSample(name string) (io.ReadCloser, error) {
+
+	set, number := name, 0
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		n, err := strconv.Atoi(name[i+1:])
+		if err != nil || n < 1 {
+			return nil, inputError(fmt.Errorf("invalid sample puzzle %q: the number after the - must be a positive integer", builtinPrefix+name))
+		}
+		set, number = name[:i], n
+	}
+
+	data, err := sampleFiles.ReadFile(path.Join("samples", set+".txt"))
+	if err != nil || set == "" {
+		return nil, inputError(fmt.Errorf("unknown sample puzzles %q (expected one of %s)", builtinPrefix+set, sampleNames()))
+	}
+	if number == 0 {
+		return io.NopCloser(strings.NewReader(string(data))), nil
+	}
+
+	// The puzzle is read along with the header of its set, which gives its dimensions
+	var header strings.Builder
+	scanner := bufio.NewScanner(strings.NewReader(string(data)))
+	count := 0
+	for scanner.Scan() {
+		if isComment(scanner.Text()) {
+			if count == 0 {
+				header.WriteString(scanner.Text() + "\n")
+			}
+			continue
+		}
+		if count++; count == number {
+			return io.NopCloser(strings.NewReader(header.String() + scanner.Text() + "\n")), nil
+		}
+	}
+
+	return nil, puzzleErrorf("%s%s has %d puzzles, so there is no puzzle %d", builtinPrefix, set, count, number)
+}
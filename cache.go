@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A cache of the solutions of puzzles solved before, keyed by puzzleHash, so that a puzzle seen again is
+// answered straight away instead of being annealed. The cache is kept in memory and, when it has a file,
+// every solution is also appended to the file as a line of JSON and read back in when the cache is
+// opened again. Puzzles are only keyed by their squares, so a cached solution is checked against the
+// constraints of the puzzle being looked up (which may be of another variant) before it is used. It is
+// safe to use from many goroutines at once.
+type solutionCache struct {
+	mutex     sync.Mutex
+	solutions map[string][][]int
+	file      *os.File
+}
+
+// A line of a cache file.
+type cacheEntry struct {
+	Hash     string  `json:"hash"`
+	Solution [][]int `json:"solution"`
+}
+
+// Opens a solution cache, reading in the solutions already in the file and appending new ones to it. An
+// empty filename keeps the cache in memory alone.
+func openSolutionCache(filename string) (*solutionCache, error) {
+
+	c := &solutionCache{solutions: map[string][][]int{}}
+	if filename == "" {
+		return c, nil
+	}
+
+	file, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, inputError(err)
+	}
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var entry cacheEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			file.Close()
+			return nil, inputError(fmt.Errorf("line %d of the cache %s: %v", line, filename, err))
+		}
+		c.solutions[entry.Hash] = entry.Solution
+	}
+	if err := scanner.Err(); err != nil {
+		file.Close()
+		return nil, inputError(err)
+	}
+
+	c.file = file
+	return c, nil
+}
+
+// Returns the cached solution of a puzzle, if there is one that keeps the puzzle's clues and breaks none
+// of its constraints. A nil cache holds nothing.
+func (c *solutionCache) lookup(puzzle [][]int, constraints []solver.Constraint) (solution [][]int, found bool) {
+
+	if c == nil {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	solution, found = c.solutions[solver.PuzzleHash(puzzle)]
+	c.mutex.Unlock()
+
+	if !found || len(solution) != len(puzzle) {
+		return nil, false
+	}
+	for r := range solution {
+		if len(solution[r]) != len(puzzle[r]) {
+			return nil, false
+		}
+	}
+	if len(findAlteredClues(solution, puzzle)) > 0 || solver.CostFunction(solution, constraints) != 0 {
+		return nil, false
+	}
+
+	return solver.CopyPuzzle(solution), true
+}
+
+// Caches the solution of a puzzle, appending it to the cache's file if it has one.
+func (c *solutionCache) store(puzzle [][]int, solution [][]int) error {
+
+	entry := cacheEntry{solver.PuzzleHash(puzzle), solver.CopyPuzzle(solution)}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.solutions[entry.Hash] = entry.Solution
+	if c.file == nil {
+		return nil
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = c.file.Write(append(line, '\n'))
+	return err
+}
+
+// Closes the cache's file, if it has one.
+func (c *solutionCache) Close() error {
+	if c.file == nil {
+		return nil
+	}
+	return c.file.Close()
+}
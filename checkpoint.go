@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Writes a checkpoint to a file, in the format its extension picks (see solver.CheckpointFormat). It is
+// written to a temporary file first and then moved into place, so a crash while writing leaves the last
+// checkpoint as it was.
+func writeCheckpoint(filename string, s solver.SolverState) error {
+
+	data, err := s.MarshalState(solver.CheckpointFormat(filename))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filename+".tmp", data, 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(filename+".tmp", filename)
+}
+
+// Reads a checkpoint written by writeCheckpoint, in either format.
+func readCheckpoint(filename string) (s solver.SolverState, e error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return s, inputError(err)
+	}
+
+	if s, err = solver.UnmarshalState(data); err != nil {
+		return s, inputError(fmt.Errorf("%s is not a checkpoint: %v", filename, err))
+	}
+	return s, nil
+}
+
+// Returns a state function for anneal (see Options.OnState) that saves a checkpoint of the run to
+// filename every interval, counting from elapsed (the time a resumed run had already spent), and a stop
+// channel for anneal. When the program is interrupted (by Ctrl-C or SIGTERM) a last checkpoint is saved
+// after the step in progress and the stop channel is closed, so the run can be resumed from exactly where
+// it stopped.
+func checkpointReporter(filename string, interval time.Duration, elapsed time.Duration) (func(solver.SolverState), <-chan struct{}) {
+
+	interrupted := make(chan os.Signal, 1)
+	signal.Notify(interrupted, os.Interrupt, syscall.SIGTERM)
+	stop := make(chan struct{})
+	stopped := false
+
+	lastCheckpoint := elapsed
+
+	return func(state solver.SolverState) {
+
+		shutdown := false
+		select {
+		case <-interrupted:
+			shutdown = true
+		default:
+		}
+
+		if stopped || (!shutdown && state.Elapsed-lastCheckpoint < interval) {
+			return
+		}
+		lastCheckpoint = state.Elapsed
+
+		if err := writeCheckpoint(filename, state); err != nil {
+			slog.Error("saving a checkpoint", "file", filename, "error", err)
+		}
+
+		if shutdown {
+			slog.Info("saved a checkpoint; carry on with -resume", "file", filename, "step", state.Step)
+			signal.Stop(interrupted)
+			stopped = true
+			close(stop)
+		}
+	}, stop
+}
@@ -0,0 +1,384 @@
+// Package client talks to the HTTP API of a sudokuAnnealing server (sudokuAnnealing serve), so Go
+// programs can solve, generate and rate puzzles on a deployed solver without building the requests
+// themselves. Puzzles go both ways in the one-line format, with . for empty squares. The types follow the
+// JSON of the API, which the server describes at /openapi.json.
+//
+//	c := client.New("http://localhost:8080")
+//	result, err := c.Solve(ctx, client.SolveRequest{Puzzle: puzzle, TimeoutSeconds: 10})
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The header the client sends its API token in.
+const tokenHeader = "X-API-Token"
+
+// A client of a solver server. BaseURL is where the server is served from, eg. http://localhost:8080,
+// and Token the API token sent with every request, for servers run with -tokens. HTTPClient makes the
+// requests, http.DefaultClient when nil; its timeout (if any) cuts short solves that take longer.
+type Client struct {
+	BaseURL    string
+	Token      string
+	HTTPClient *http.Client
+}
+
+// Returns a client of the server at the base URL, without a token.
+func New(baseURL string) *Client {
+	return &Client{BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// A request to solve a puzzle. Only the puzzle is needed; the rest take the server's defaults when left
+// at zero. The markings of variants (Even, Odd, Greater, the sandwich clues, Thermometers and Arrows)
+// are written as the solve command's JSON puzzles write them, eg. "r1c1" for a square.
+type SolveRequest struct {
+	Puzzle          string     `json:"puzzle"`
+	Dim             string     `json:"dim,omitempty"`
+	Variant         string     `json:"variant,omitempty"`
+	Temperature     float64    `json:"temperature,omitempty"`
+	CoolingRate     float64    `json:"cooling_rate,omitempty"`
+	Iterations      int        `json:"iterations,omitempty"`
+	Swaps           int        `json:"swaps,omitempty"`
+	Annealers       int        `json:"annealers,omitempty"`
+	TimeoutSeconds  float64    `json:"timeout_seconds,omitempty"`
+	Even            []string   `json:"even,omitempty"`
+	Odd             []string   `json:"odd,omitempty"`
+	Greater         []string   `json:"greater,omitempty"`
+	SandwichRows    []*int     `json:"sandwich_rows,omitempty"`
+	SandwichColumns []*int     `json:"sandwich_columns,omitempty"`
+	Thermometers    [][]string `json:"thermometers,omitempty"`
+	Arrows          [][]string `json:"arrows,omitempty"`
+}
+
+// The result of a solve. The solution is the best candidate when the puzzle wasn't solved, and Cached
+// says it came from the server's cache rather than annealing.
+type SolveResponse struct {
+	Solved   bool    `json:"solved"`
+	TimedOut bool    `json:"timed_out"`
+	Cached   bool    `json:"cached,omitempty"`
+	Solution string  `json:"solution"`
+	Cost     float64 `json:"cost"`
+	Seconds  float64 `json:"seconds"`
+}
+
+// The progress of a solve after a cooling step, with the candidate solution of lowest cost so far.
+type ProgressEvent struct {
+	Step           int     `json:"step"`
+	Temperature    float64 `json:"temperature"`
+	BestCost       float64 `json:"best_cost"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	Elapsed        float64 `json:"elapsed_seconds"`
+	Candidate      string  `json:"candidate"`
+}
+
+// A request to generate a puzzle, all of it optional: a standard 9x9 puzzle of any grade is generated
+// when it is left empty. Difficulty is a grade (easy, medium, hard, expert or evil) or a band of them, eg.
+// medium-expert.
+type GenerateRequest struct {
+	Dim        string `json:"dim,omitempty"`
+	Variant    string `json:"variant,omitempty"`
+	Difficulty string `json:"difficulty,omitempty"`
+	Clues      int    `json:"clues,omitempty"`
+	Symmetry   string `json:"symmetry,omitempty"`
+	Attempts   int    `json:"attempts,omitempty"`
+	Seed       int64  `json:"seed,omitempty"`
+}
+
+// A generated puzzle, with its solution and grade, and the seed that generates it again.
+type GenerateResponse struct {
+	Puzzle     string `json:"puzzle"`
+	Solution   string `json:"solution"`
+	Difficulty string `json:"difficulty"`
+	Seed       int64  `json:"seed"`
+}
+
+// A request to rate how hard a puzzle is to solve by hand.
+type RateRequest struct {
+	Puzzle  string `json:"puzzle"`
+	Dim     string `json:"dim,omitempty"`
+	Variant string `json:"variant,omitempty"`
+}
+
+// The rating of a puzzle: its grade, the hardest technique it needs (unless it is evil or invalid) and
+// the number of steps the techniques took.
+type RateResponse struct {
+	Difficulty string `json:"difficulty"`
+	Hardest    string `json:"hardest,omitempty"`
+	Steps      int    `json:"steps"`
+}
+
+// A job of the server's job queue. Status is queued, running, done or cancelled, and Result is set once
+// the job finishes.
+type Job struct {
+	ID       string         `json:"id"`
+	Status   string         `json:"status"`
+	Request  SolveRequest   `json:"request"`
+	Token    string         `json:"token,omitempty"`
+	Created  time.Time      `json:"created"`
+	Started  *time.Time     `json:"started,omitempty"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Progress *ProgressEvent `json:"progress,omitempty"`
+	Result   *SolveResponse `json:"result,omitempty"`
+}
+
+// A daily puzzle, with its solution when it was asked for with the server's daily key.
+type DailyPuzzle struct {
+	Date       string `json:"date"`
+	Difficulty string `json:"difficulty"`
+	Puzzle     string `json:"puzzle"`
+	Solution   string `json:"solution,omitempty"`
+}
+
+// An error answered by the server: its HTTP status and message, and for a client over the server's rate
+// limit or a full job queue, how long to wait before trying again.
+type Error struct {
+	StatusCode int
+	Message    string
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Sends a request to the server with the body (if it isn't nil) as JSON, returning the response when it
+// has the expected status, and otherwise an *Error with the server's message.
+func (c *Client) send(ctx context.Context, method string, path string, body interface{}, header http.Header, status ...int) (*http.Response, error) {
+
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range header {
+		request.Header[key] = values
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		request.Header.Set(tokenHeader, c.Token)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, expected := range status {
+		if response.StatusCode == expected {
+			return response, nil
+		}
+	}
+	defer response.Body.Close()
+
+	apiErr := &Error{StatusCode: response.StatusCode}
+	var message struct {
+		Error string `json:"error"`
+	}
+	if data, _ := io.ReadAll(response.Body); json.Unmarshal(data, &message) == nil && message.Error != "" {
+		apiErr.Message = message.Error
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if seconds, err := strconv.Atoi(response.Header.Get("Retry-After")); err == nil {
+		apiErr.RetryAfter = time.Duration(seconds) * time.Second
+	}
+
+	return nil, apiErr
+}
+
+// Sends a request to the server and reads the JSON it answers with into result.
+func (c *Client) call(ctx context.Context, method string, path string, body interface{}, header http.Header, result interface{}, status ...int) error {
+
+	response, err := c.send(ctx, method, path, body, header, status...)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+// Solves a puzzle, waiting for the solve to finish.
+func (c *Client) Solve(ctx context.Context, request SolveRequest) (*SolveResponse, error) {
+
+	var result SolveResponse
+	if err := c.call(ctx, http.MethodPost, "/solve", request, nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Generates a puzzle with a unique solution.
+func (c *Client) Generate(ctx context.Context, request GenerateRequest) (*GenerateResponse, error) {
+
+	var result GenerateResponse
+	if err := c.call(ctx, http.MethodPost, "/generate", request, nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Rates how hard a puzzle is to solve by hand.
+func (c *Client) Rate(ctx context.Context, request RateRequest) (*RateResponse, error) {
+
+	var result RateResponse
+	if err := c.call(ctx, http.MethodPost, "/rate", request, nil, &result, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+// Solves a puzzle in the background on the server, calling progress (if it isn't nil) with the progress
+// the server streams after each cooling step, and returns the result once the solve finishes. A progress
+// function slower than the solve skips to the latest step. Cancelling the context stops the stream, but
+// not the solve on the server.
+func (c *Client) StreamProgress(ctx context.Context, request SolveRequest, progress func(ProgressEvent)) (*SolveResponse, error) {
+
+	var started struct {
+		ID     string `json:"id"`
+		Events string `json:"events"`
+	}
+	if err := c.call(ctx, http.MethodPost, "/solves", request, nil, &started, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+
+	response, err := c.send(ctx, http.MethodGet, "/solves/"+url.PathEscape(started.ID)+"/events", nil, http.Header{"Accept": {"text/event-stream"}}, http.StatusOK)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	// Each server-sent event is an event line and a data line, ended by a blank line
+	var event, data string
+	scanner := bufio.NewScanner(response.Body)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if field, value, ok := strings.Cut(line, ": "); ok {
+			switch field {
+			case "event":
+				event = value
+			case "data":
+				data = value
+			}
+			continue
+		}
+		if line != "" {
+			continue
+		}
+
+		switch event {
+		case "progress":
+			var p ProgressEvent
+			if err := json.Unmarshal([]byte(data), &p); err != nil {
+				return nil, err
+			}
+			if progress != nil {
+				progress(p)
+			}
+		case "result":
+			var result SolveResponse
+			if err := json.Unmarshal([]byte(data), &result); err != nil {
+				return nil, err
+			}
+			return &result, nil
+		}
+		event, data = "", ""
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return nil, io.ErrUnexpectedEOF
+}
+
+// Queues a puzzle as a job on the server's job queue, for puzzles too big to wait for. The job's Status
+// and Result are fetched with Job.
+func (c *Client) SubmitJob(ctx context.Context, request SolveRequest) (*Job, error) {
+
+	var job Job
+	if err := c.call(ctx, http.MethodPost, "/jobs", request, nil, &job, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Returns a job of the server's job queue, with its status, latest progress and result.
+func (c *Client) Job(ctx context.Context, id string) (*Job, error) {
+
+	var job Job
+	if err := c.call(ctx, http.MethodGet, "/jobs/"+url.PathEscape(id), nil, nil, &job, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Cancels a job of the server's job queue, or deletes it once it has finished. A running job is still
+// running when this returns, and is cancelled once its solve stops.
+func (c *Client) CancelJob(ctx context.Context, id string) (*Job, error) {
+
+	var job Job
+	if err := c.call(ctx, http.MethodDelete, "/jobs/"+url.PathEscape(id), nil, nil, &job, http.StatusOK, http.StatusAccepted); err != nil {
+		return nil, err
+	}
+
+	return &job, nil
+}
+
+// Returns the daily puzzle of a grade for a date (YYYY-MM-DD, in UTC, no more than a week ago). Either
+// may be empty, for a medium puzzle and today's. With the server's daily key the solution is returned
+// as well.
+func (c *Client) Daily(ctx context.Context, difficulty string, date string, dailyKey string) (*DailyPuzzle, error) {
+
+	query := url.Values{}
+	if difficulty != "" {
+		query.Set("difficulty", difficulty)
+	}
+	if date != "" {
+		query.Set("date", date)
+	}
+	path, header := "/daily", http.Header{}
+	if dailyKey != "" {
+		path = "/daily/solution"
+		header.Set("Authorization", "Bearer "+dailyKey)
+	}
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	var daily DailyPuzzle
+	if err := c.call(ctx, http.MethodGet, path, nil, header, &daily, http.StatusOK); err != nil {
+		return nil, err
+	}
+
+	return &daily, nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// ANSI escape sequences used to colour and redraw the printed puzzles.
+const (
+	ansiReset   = "\x1b[0m"
+	ansiBold    = "\x1b[1m"
+	ansiRed     = "\x1b[31m"
+	ansiCyan    = "\x1b[36m"
+	ansiLineUp  = "\x1b[%dA"
+	ansiClearLn = "\x1b[2K"
+)
+
+// Returns a decorate function for printPuzzle that shows the clues of the original puzzle in bold, the
+// numbers filled in by the annealer in cyan, and any number that clashes with another in a row, column,
+// block or other region in red. Also returns how many cells clash.
+func cellColours(originalPuzzle [][]int, puzzle [][]int, constraints []solver.Constraint) (decorate func(r int, c int, text string) string, conflictCount int) {
+
+	conflicting := make(map[solver.Cell]bool)
+	for _, conflict := range findClueConflicts(puzzle, constraints) {
+		for _, cell := range conflict.cells {
+			conflicting[cell] = true
+		}
+	}
+
+	return func(r int, c int, text string) string {
+		switch {
+		case originalPuzzle[r][c] > 0:
+			return ansiBold + text + ansiReset
+		case conflicting[solver.Cell{Row: r, Col: c}]:
+			return ansiRed + text + ansiReset
+		}
+		return ansiCyan + text + ansiReset
+	}, len(conflicting)
+}
+
+// Reports whether puzzles printed to standard output should be coloured: only when it is a terminal, and
+// the NO_COLOR environment variable (https://no-color.org) isn't set.
+func stdoutIsColourTerminal() bool {
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+
+	info, err := os.Stdout.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// A subcommand: its name, what it does in a line for the list of commands, and the function that parses
+// its flags (the arguments after its name) and runs it.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
+}
+
+// Every subcommand, in the order help lists them. Filled in by init, since help refers to it.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"solve", "Solve a puzzle, or every puzzle of a dataset, by simulated annealing", solveCommand},
+		{"generate", "Generate new puzzles with a unique solution", generateCommand},
+		{"minimize", "Take out the clues of puzzles for as long as their solutions stay unique", minimizeCommand},
+		{"book", "Generate a printable book of puzzles with their solutions at the back", bookCommand},
+		{"rate", "Rate how hard puzzles are to solve by hand", rateCommand},
+		{"analyze", "Report how the clues of puzzles are spread and which symmetries they have", analyzeCommand},
+		{"explain", "Solve a puzzle by human techniques, explaining every step", explainCommand},
+		{"hint", "Reveal the number in one empty square of a puzzle", hintCommand},
+		{"play", "Play a puzzle in the terminal", playCommand},
+		{"verify", "Check a completed grid against the rules and the original puzzle", verifyCommand},
+		{"convert", "Rewrite puzzles in another file format", convertCommand},
+		{"export", "Write a puzzle as a SAT (DIMACS CNF) or exact cover problem, or read a SAT model back", exportCommand},
+		{"transform", "Transform puzzles by the symmetries of sudoku, or find their canonical forms", transformCommand},
+		{"render", "Draw a puzzle as an SVG or PNG image", renderCommand},
+		{"stats", "Summarise the results kept by -results", statsCommand},
+		{"bench", "Benchmark the solver on puzzles with fixed parameters", benchCommand},
+		{"compare", "Compare the search algorithms' solve rates and times on puzzles", compareCommand},
+		{"tune", "Search for the best annealing parameters", tuneCommand},
+		{"serve", "Run the solver as an HTTP service", serveCommand},
+		{"help", "List the commands, or show the flags of one", helpCommand},
+	}
+}
+
+// Returns the names of the subcommands, separated by commas, for error messages.
+func commandNames() string {
+
+	names := make([]string, len(commands))
+	for i, c := range commands {
+		names[i] = c.name
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Runs the subcommand named by the first argument with the rest of the arguments. When the first
+// argument is a flag, or there are no arguments, they are all solve's flags, as they were before there
+// were subcommands. Any other first argument is an unknown command, such as a misspelt one.
+func runCommand(args []string) error {
+
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] {
+				return c.run(args[1:])
+			}
+		}
+		if !strings.HasPrefix(args[0], "-") {
+			return flagErrorf("unknown command %q (expected one of %s)", args[0], commandNames())
+		}
+	}
+
+	return solveCommand(args)
+}
+
+// The help subcommand. Lists every subcommand, or with a subcommand's name shows its flags.
+func helpCommand(args []string) error {
+
+	if len(args) > 0 {
+		for _, c := range commands {
+			if c.name == args[0] && c.name != "help" {
+				return c.run([]string{"-h"})
+			}
+		}
+		return flagErrorf("unknown command %q (expected one of %s)", args[0], commandNames())
+	}
+
+	fmt.Printf("Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Printf("  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Printf("\nWithout a command the flags are solve's. %s help COMMAND shows the flags of a command.\n", os.Args[0])
+
+	return nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The benchmark of one search algorithm, as written by the compare subcommand.
+type compareReport struct {
+	Algorithm string `json:"algorithm"`
+	benchReport
+}
+
+// Parses the value of the -algos flag: a comma separated list of search algorithms, each one of
+// algorithms and none given twice.
+func parseAlgorithmList(text string) (names []string, e error) {
+
+	seen := map[string]bool{}
+	for _, field := range strings.Split(text, ",") {
+		name := strings.TrimSpace(field)
+		if solver.Algorithms[name] == nil {
+			return nil, flagErrorf("unknown algorithm %q for -algos (expected one of %s)", name, solver.AlgorithmNames())
+		}
+		if seen[name] {
+			return nil, flagErrorf("invalid value %q for -algos: %s is given twice", text, name)
+		}
+		seen[name] = true
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+// Writes the comparison as a table for reading, one row per algorithm in the order they were run.
+func writeCompareTable(reports []compareReport) {
+
+	seconds := func(s float64) time.Duration {
+		return time.Duration(s * float64(time.Second)).Round(time.Microsecond)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	fmt.Fprintln(out, "algorithm\tsolved\tmean\tmedian\tp95\tmin\tmax")
+	for _, r := range reports {
+		fmt.Fprintf(out, "%s\t%d/%d (%.1f%%)\t%s\t%s\t%s\t%s\t%s\n", r.Algorithm, r.Solved, r.Runs, 100*r.SolveRate,
+			seconds(r.MeanSeconds), seconds(r.MedianSeconds), seconds(r.P95Seconds), seconds(r.MinSeconds), seconds(r.MaxSeconds))
+	}
+}
+
+// Writes the comparison as CSV with a header row, one row per algorithm. Times are in seconds.
+func writeCompareCSV(reports []compareReport) error {
+
+	out := csv.NewWriter(os.Stdout)
+	out.Write([]string{"algorithm", "runs", "solved", "solve_rate", "mean_seconds", "median_seconds", "p95_seconds", "min_seconds", "max_seconds"})
+
+	format := func(f float64) string {
+		return strconv.FormatFloat(f, 'g', 6, 64)
+	}
+	for _, r := range reports {
+		out.Write([]string{r.Algorithm, strconv.Itoa(r.Runs), strconv.Itoa(r.Solved), format(r.SolveRate),
+			format(r.MeanSeconds), format(r.MedianSeconds), format(r.P95Seconds), format(r.MinSeconds), format(r.MaxSeconds)})
+	}
+	out.Flush()
+
+	return inputError(out.Error())
+}
+
+// The compare subcommand. Runs each of a set of search algorithms over the same puzzles the same number
+// of times, with the same annealing parameters and a time limit on every run, and reports their solve
+// rates and the spread of their solve times side by side.
+func compareCommand(args []string) error {
+
+	flags := flag.NewFlagSet("compare", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm, ss or csv). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "puzzles.txt", "The file of puzzles to compare the algorithms on (- reads standard input)")
+	linePtr := flags.Int("l", 0, "The line of the one puzzle to compare the algorithms on (or the grid number in sdk and ss files). Every puzzle in the file is used when left out or 0")
+	algosPtr := flags.String("algos", "", "A comma separated list of the search algorithms to compare, in the order to run and list them ("+solver.AlgorithmNames()+"). All of them when left out")
+	addAnnealingFlags(flags)
+	runsPtr := flags.String("n", "5", "The number of times each algorithm solves each puzzle")
+	timeoutPtr := flags.Duration("timeout", 10*time.Second, "The longest each run may take before it counts as unsolved")
+	formatPtr := flags.String("format", "text", "The format of the report (text, csv, or json)")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	params, paramsErr := annealingOptions(flags)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	runs, runsErr := parsePositiveInt("n", *runsPtr)
+
+	for _, err := range []error{paramsErr, dimErr, runsErr} {
+		if err != nil {
+			return err
+		}
+	}
+	warnAnnealerCount(params.annealers, nil)
+
+	if *timeoutPtr <= 0 {
+		return flagErrorf("invalid value %q for -timeout: must be greater than 0", timeoutPtr.String())
+	}
+	if *formatPtr != "text" && *formatPtr != "csv" && *formatPtr != "json" {
+		return flagErrorf("unknown report format %q for -format (expected text, csv or json)", *formatPtr)
+	}
+
+	var names []string
+	if *algosPtr == "" {
+		for name := range solver.Algorithms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	} else {
+		var err error
+		if names, err = parseAlgorithmList(*algosPtr); err != nil {
+			return err
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	line := 0
+	if *linePtr != 0 {
+		if line, err = checkPositiveInt("l", *linePtr); err != nil {
+			return err
+		}
+	}
+	solvable, err := readSolvablePuzzles(*filePtr, *inputModePtr, line, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim, *variantPtr)
+	if err != nil {
+		return err
+	}
+
+	var reports []compareReport
+	for _, name := range names {
+		options := params.options()
+		options.Algorithm, options.Timeout = name, *timeoutPtr
+		reports = append(reports, compareReport{name, runBenchmark(params.String(), options, solvable, runs)})
+	}
+
+	switch *formatPtr {
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return inputError(encoder.Encode(reports))
+	case "csv":
+		return writeCompareCSV(reports)
+	}
+
+	writeCompareTable(reports)
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"os"
+	"strings"
+)
+
+// The help text of the -config flag of the commands that take one.
+const configUsage = `A file of flag settings, one "name: value" or "name = value" per line (so a flat YAML or TOML file), which flags given on the command line override`
+
+// A single setting read from a config file, with the line it was on.
+type configSetting struct {
+	line  int
+	name  string
+	value string
+}
+
+// Reads the settings of a config file. Each line sets a flag, by its name (with or without a leading -),
+// to a value, separated by ":" or "=". Values may be quoted, and blank lines and lines starting with # are
+// skipped, so a flat YAML or TOML file of settings can be read as is.
+func readConfig(filename string) (settings []configSetting, e error) {
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+
+	scanner := bufio.NewScanner(inFile)
+	for line := 1; scanner.Scan(); line++ {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		separator := strings.IndexAny(text, ":=")
+		if separator < 0 {
+			return nil, flagErrorf("line %d of %s: settings are given as name: value or name = value, not %q", line, filename, text)
+		}
+
+		name := strings.TrimLeft(strings.TrimSpace(text[:separator]), "-")
+		value := strings.TrimSpace(text[separator+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		settings = append(settings, configSetting{line, name, value})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, inputError(err)
+	}
+
+	return settings, nil
+}
+
+// Sets the flags named in a config file that weren't given on the command line, so the command line
+// always wins. Unknown names are an error, so misspelt settings don't go unnoticed.
+func applyConfig(flags *flag.FlagSet, filename string) error {
+
+	settings, err := readConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	for _, setting := range settings {
+		if setting.name == "config" || flags.Lookup(setting.name) == nil {
+			return flagErrorf("line %d of %s: %q isn't a flag of this command", setting.line, filename, setting.name)
+		}
+		if given[setting.name] {
+			continue
+		}
+		if err := flags.Set(setting.name, setting.value); err != nil {
+			return flagErrorf("line %d of %s: invalid value %q for %s: %v", setting.line, filename, setting.value, setting.name, err)
+		}
+	}
+
+	return nil
+}
+
+// The prefix of the environment variables that set flags, eg. SUDOKU_ADDR for -addr.
+const environmentPrefix = "SUDOKU_"
+
+// Returns the environment variable that sets a flag: its name in upper case, with dashes turned into
+// underscores, after environmentPrefix (eg. SUDOKU_CACHE_FILE for -cache-file).
+func environmentVariable(flagName string) string {
+	return environmentPrefix + strings.ToUpper(strings.Replace(flagName, "-", "_", -1))
+}
+
+// Sets the flags that weren't given on the command line from their environment variables, for
+// configuring the server and the solver in containers. Every flag of every command has one.
+func applyEnvironment(flags *flag.FlagSet) error {
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	var err error
+	flags.VisitAll(func(f *flag.Flag) {
+		value, ok := os.LookupEnv(environmentVariable(f.Name))
+		if !ok || given[f.Name] || err != nil {
+			return
+		}
+		if setErr := flags.Set(f.Name, value); setErr != nil {
+			err = flagErrorf("invalid value %q for %s: %v", value, environmentVariable(f.Name), setErr)
+		}
+	})
+
+	return err
+}
+
+// Returns the -config file given to a flag set, if it has the flag and it was given.
+func configFile(flags *flag.FlagSet) string {
+
+	if f := flags.Lookup("config"); f != nil {
+		return f.Value.String()
+	}
+
+	return ""
+}
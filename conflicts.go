@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns a short name for a constraint, used when breaking the cost of a puzzle down by constraint.
+func constraintName(constraint solver.Constraint) string {
+
+	switch c := constraint.(type) {
+	case solver.UniqueConstraint:
+		return c.Kind + "s"
+	case solver.CageConstraint:
+		return "cages"
+	case fmt.Stringer:
+		return c.String()
+	}
+
+	return fmt.Sprintf("%T", constraint)
+}
+
+// Writes where the remaining cost of an unsolved candidate comes from: the cost of each constraint with
+// every broken region listed beneath it, followed by the candidate with only the cells whose numbers
+// clash with another left in, so the overlay shows exactly where the annealer got stuck.
+func writeConflictReport(w io.Writer, candidate [][]int, constraints []solver.Constraint, regionMap [][]int, extraRegions [][]solver.Cell, symbols string, decorate func(r int, c int, text string) string) {
+
+	fmt.Fprintln(w, "Remaining cost by constraint:")
+	for _, constraint := range constraints {
+		cost := constraint.Cost(candidate)
+		if cost == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "  %s: %v\n", constraintName(constraint), cost)
+		for _, line := range regionSummaries(findViolations(candidate, []solver.Constraint{constraint})) {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+
+	overlay := make([][]int, len(candidate))
+	for r := range candidate {
+		overlay[r] = make([]int, len(candidate[r]))
+		for c := range overlay[r] {
+			if candidate[r][c] == solver.BlockedSquare {
+				overlay[r][c] = solver.BlockedSquare
+			}
+		}
+	}
+	for _, conflict := range findClueConflicts(candidate, constraints) {
+		for _, cell := range conflict.cells {
+			overlay[cell.Row][cell.Col] = candidate[cell.Row][cell.Col]
+		}
+	}
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Cells with clashing numbers:")
+	fmt.Fprint(w, formatPuzzle(overlay, regionMap, extraRegions, symbols, decorate))
+}
+
+// Summarises violations one line per region, eg. "row 3: repeats 5, 8; missing 1, 7", rather than one
+// line per number. Violations other than repeated or missing numbers are kept as they are.
+func regionSummaries(violations []fmt.Stringer) (lines []string) {
+
+	type region struct {
+		name              string
+		repeated, missing []string
+	}
+	var regions []*region
+	byName := make(map[string]*region)
+
+	for _, violation := range violations {
+		v, ok := violation.(constraintViolation)
+		if !ok {
+			lines = append(lines, violation.String())
+			continue
+		}
+
+		name := fmt.Sprintf("%s %d", v.kind, v.index)
+		if byName[name] == nil {
+			byName[name] = &region{name: name}
+			regions = append(regions, byName[name])
+		}
+		if v.count == 0 {
+			byName[name].missing = append(byName[name].missing, fmt.Sprint(v.number))
+		} else {
+			byName[name].repeated = append(byName[name].repeated, fmt.Sprint(v.number))
+		}
+	}
+
+	for _, r := range regions {
+		var parts []string
+		if len(r.repeated) > 0 {
+			parts = append(parts, "repeats "+strings.Join(r.repeated, ", "))
+		}
+		if len(r.missing) > 0 {
+			parts = append(parts, "missing "+strings.Join(r.missing, ", "))
+		}
+		lines = append(lines, r.name+": "+strings.Join(parts, "; "))
+	}
+
+	return lines
+}
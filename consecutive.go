@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Read in a consecutive sudoku from the selected line. The line holds the puzzle in the one-line format
+// followed by its markers, all separated by semicolons. Each marker sits between two squares side by
+// side or one above the other, written as their 1-based cells separated by a comma, eg:
+//
+//	.................................................................................;r1c1,r1c2;r4c5,r5c5
+//
+// The squares of every marked pair must hold consecutive numbers, and as in consecutive sudoku every pair
+// of neighbours that does is marked.
+func readInConsecutive(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, markers [][2]solver.Cell, e error) {
+
+	puzzle, markerTexts, e := readInOneLineExtras(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	for _, markerText := range markerTexts {
+		marker, err := parseMarker(markerText, blockXDim*blockYDim)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		markers = append(markers, marker)
+	}
+
+	return puzzle, markers, nil
+}
+
+// Parse a single marker of the form "r1c1,r1c2".
+func parseMarker(text string, puzzleDim int) (marker [2]solver.Cell, e error) {
+
+	cellTexts := strings.Split(strings.TrimSpace(text), ",")
+	if len(cellTexts) != 2 {
+		return marker, puzzleErrorf("marker %q should be between two squares, eg. r1c1,r1c2", text)
+	}
+
+	for i, cellText := range cellTexts {
+		var row, col int
+		if _, err := fmt.Sscanf(strings.TrimSpace(cellText), "r%dc%d", &row, &col); err != nil {
+			return marker, puzzleErrorf("marker %q has an invalid cell %q", text, cellText)
+		}
+		if row < 1 || row > puzzleDim || col < 1 || col > puzzleDim {
+			return marker, puzzleErrorf("marker %q has cell %q outside of the puzzle", text, cellText)
+		}
+		marker[i] = solver.Cell{Row: row - 1, Col: col - 1}
+	}
+
+	if abs(marker[0].Row-marker[1].Row)+abs(marker[0].Col-marker[1].Col) != 1 {
+		return marker, puzzleErrorf("marker %q isn't between two squares side by side or one above the other", text)
+	}
+
+	return marker, nil
+}
+
+// Returns the constraints of the markers of a consecutive sudoku of the given dimension: the squares of
+// every marked pair hold consecutive numbers, and those of every unmarked pair of neighbours don't.
+// There are none for a puzzle without markers.
+func markerConstraints(puzzleDim int, markers [][2]solver.Cell) []solver.Constraint {
+
+	if len(markers) == 0 {
+		return nil
+	}
+
+	marked := make(map[[2]solver.Cell]bool)
+	for _, marker := range markers {
+		marked[marker] = true
+		marked[[2]solver.Cell{marker[1], marker[0]}] = true
+	}
+
+	var unmarked [][2]solver.Cell
+	for _, pair := range solver.MovePairs(puzzleDim, solver.OrthogonalMoves) {
+		if !marked[pair] {
+			unmarked = append(unmarked, pair)
+		}
+	}
+
+	return []solver.Constraint{solver.PairConstraint{Kind: "consecutive marker", Rule: solver.PairsConsecutive, Pairs: markers}, solver.PairConstraint{Kind: "non-consecutive", Rule: solver.PairsNotConsecutive, Pairs: unmarked}}
+}
@@ -0,0 +1,359 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The formats that puzzles can be written in, as listed in error messages.
+const outputFormatNames = "one-line, grid, sdk, json, csv, latex or html"
+
+// Returns an error unless the output format is one that puzzles can be written in.
+func checkOutputFormat(name string, format string) error {
+
+	switch format {
+	case "one-line", "grid", "sdk", "json", "csv", "latex", "html":
+		return nil
+	}
+
+	return flagErrorf("unknown output format %q for -%s (expected %s)", format, name, outputFormatNames)
+}
+
+// How a puzzle is written out: the block dimensions used to lay out grids, the symbols for each number
+// (or plain numbers for an empty set), the delimiter between squares and the text of an empty square.
+type puzzleWriter struct {
+	format     string
+	blockXDim  int
+	blockYDim  int
+	symbols    string
+	delimiter  string
+	emptyValue string
+}
+
+// Returns the text of a single square.
+func (w puzzleWriter) square(number int) string {
+	if number > 0 {
+		return solver.SymbolText(w.symbols, number)
+	}
+	return w.emptyValue
+}
+
+// Returns the puzzle as a single line of squares.
+func (w puzzleWriter) oneLine(puzzle [][]int) string {
+
+	squares := make([]string, 0, len(puzzle)*len(puzzle))
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			squares = append(squares, w.square(puzzle[r][c]))
+		}
+	}
+
+	return strings.Join(squares, w.delimiter)
+}
+
+// Writes the header that comes before the first puzzle, if the format has one.
+func (w puzzleWriter) writeHeader(out io.Writer) error {
+
+	if w.format == "csv" {
+		_, err := fmt.Fprintln(out, "puzzle")
+		return err
+	}
+
+	return nil
+}
+
+// Writes a single puzzle in the writer's format:
+//
+//	one-line  the puzzle on one line
+//	grid      one row per line, with | between blocks and -+- lines between bands of blocks
+//	sdk       one row per line with no separators, as read by SadMan Sudoku
+//	json      a JSON object per line holding the block dimensions, the one-line puzzle and its rows
+//	csv       a CSV row holding the one-line puzzle
+//	latex     a LaTeX tabular with lines between blocks, for worksheets
+//	html      an HTML table styled inline with thick borders between blocks, for web pages
+//
+// The LaTeX and HTML formats leave empty squares blank rather than writing the empty square character.
+func (w puzzleWriter) write(out io.Writer, puzzle [][]int) (e error) {
+
+	switch w.format {
+
+	case "one-line":
+		_, e = fmt.Fprintln(out, w.oneLine(puzzle))
+
+	case "csv":
+		writer := csv.NewWriter(out)
+		writer.Write([]string{w.oneLine(puzzle)})
+		writer.Flush()
+		e = writer.Error()
+
+	case "json":
+		record := struct {
+			Dim    string  `json:"dim"`
+			Puzzle string  `json:"puzzle"`
+			Grid   [][]int `json:"grid"`
+		}{fmt.Sprintf("%dx%d", w.blockXDim, w.blockYDim), w.oneLine(puzzle), puzzle}
+		e = json.NewEncoder(out).Encode(record)
+
+	case "sdk", "grid":
+		for r := range puzzle {
+			if w.format == "grid" && r > 0 && r%w.blockYDim == 0 {
+				bands := make([]string, len(puzzle)/w.blockXDim)
+				for i := range bands {
+					bands[i] = strings.Repeat("-", w.blockXDim*(len(w.square(len(puzzle)))+len(w.delimiter))-len(w.delimiter))
+				}
+				if _, e = fmt.Fprintln(out, strings.Join(bands, "+")); e != nil {
+					return e
+				}
+			}
+
+			squares := make([]string, 0, len(puzzle[r]))
+			for c := range puzzle[r] {
+				if w.format == "grid" && c > 0 && c%w.blockXDim == 0 {
+					squares[len(squares)-1] += "|"
+				}
+				squares = append(squares, w.square(puzzle[r][c]))
+			}
+			if _, e = fmt.Fprintln(out, strings.Join(squares, w.delimiter)); e != nil {
+				return e
+			}
+		}
+
+		// Grids are separated by a blank line
+		_, e = fmt.Fprintln(out)
+
+	case "latex":
+		_, e = io.WriteString(out, w.latex(puzzle))
+
+	case "html":
+		_, e = io.WriteString(out, w.html(puzzle))
+
+	default:
+		e = fmt.Errorf("unknown output format %q (expected %s)", w.format, outputFormatNames)
+	}
+
+	return e
+}
+
+// Returns the puzzle as a LaTeX tabular, with a vertical line between every band of blocks across and
+// an \hline between every band of blocks down. Grids are separated by a blank line.
+func (w puzzleWriter) latex(puzzle [][]int) string {
+
+	columns := make([]string, len(puzzle)/w.blockXDim)
+	for i := range columns {
+		columns[i] = strings.Repeat("c", w.blockXDim)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\\begin{tabular}{|%s|}\n\\hline\n", strings.Join(columns, "|"))
+
+	for r := range puzzle {
+		squares := make([]string, len(puzzle[r]))
+		for c := range puzzle[r] {
+			if puzzle[r][c] > 0 {
+				squares[c] = solver.SymbolText(w.symbols, puzzle[r][c])
+			}
+		}
+		fmt.Fprintf(&b, "%s \\\\\n", strings.Join(squares, " & "))
+		if (r+1)%w.blockYDim == 0 {
+			b.WriteString("\\hline\n")
+		}
+	}
+
+	b.WriteString("\\end{tabular}\n\n")
+	return b.String()
+}
+
+// Returns the puzzle as an HTML table. Its styles are written inline so that it can be pasted into a
+// page on its own. Grids are separated by a blank line.
+func (w puzzleWriter) html(puzzle [][]int) string {
+
+	var b strings.Builder
+	b.WriteString(`<table style="border-collapse: collapse; border: 3px solid black; font-family: sans-serif">` + "\n")
+
+	for r := range puzzle {
+		b.WriteString("<tr>")
+		for c := range puzzle[r] {
+			style := "width: 2em; height: 2em; text-align: center; border: 1px solid #888"
+			if c < len(puzzle)-1 && (c+1)%w.blockXDim == 0 {
+				style += "; border-right: 3px solid black"
+			}
+			if r < len(puzzle)-1 && (r+1)%w.blockYDim == 0 {
+				style += "; border-bottom: 3px solid black"
+			}
+			text := ""
+			if puzzle[r][c] > 0 {
+				text = solver.SymbolText(w.symbols, puzzle[r][c])
+			}
+			fmt.Fprintf(&b, `<td style="%s">%s</td>`, style, text)
+		}
+		b.WriteString("</tr>\n")
+	}
+
+	b.WriteString("</table>\n\n")
+	return b.String()
+}
+
+// Reads every puzzle from the input in the given mode. The line based modes (one-line and sdm) skip
+// blank lines and lines of the wrong length, csv reads the puzzle column of every row, and the grid
+// modes (sdk and ss) read every grid in the file.
+func readAllPuzzles(r io.Reader, mode string, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzles [][][]int, e error) {
+
+	puzzleDim := blockXDim * blockYDim
+
+	switch mode {
+
+	case "one-line", "sdm":
+		scanner := bufio.NewScanner(r)
+		scanner.Split(scanPuzzleLines)
+		for scanner.Scan() {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" || len(strings.Split(text, delimiter)) != puzzleDim*puzzleDim {
+				continue
+			}
+			puzzle, err := readInOneLine(strings.NewReader(text), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+			if err != nil {
+				return nil, err
+			}
+			puzzles = append(puzzles, puzzle)
+		}
+		return puzzles, inputError(scanner.Err())
+
+	case "csv":
+		reader := csv.NewReader(r)
+		reader.FieldsPerRecord = -1
+		reader.Comment = '#'
+		for {
+			record, err := reader.Read()
+			if err == io.EOF {
+				return puzzles, nil
+			}
+			if err != nil {
+				return nil, puzzleErrorf("%v", err)
+			}
+			// Header rows and anything else that isn't a puzzle are skipped
+			if len(record) == 0 || !datasetFieldFits(record[0], delimiter, puzzleDim) {
+				continue
+			}
+			puzzle, err := readInOneLine(strings.NewReader(record[0]), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+			if err != nil {
+				return nil, err
+			}
+			puzzles = append(puzzles, puzzle)
+		}
+
+	case "sdk", "ss":
+		input, err := io.ReadAll(r)
+		if err != nil {
+			return nil, inputError(err)
+		}
+		for n := 1; ; n++ {
+			puzzle, err := readInGrid(strings.NewReader(string(input)), n, emptyValue, symbols, blockXDim, blockYDim)
+			if err != nil {
+				if n == 1 {
+					return nil, err
+				}
+				return puzzles, nil
+			}
+			puzzles = append(puzzles, puzzle)
+		}
+	}
+
+	return nil, flagErrorf("puzzles can't be converted from the %q input mode (expected one-line, sdm, sdk, ss or csv)", mode)
+}
+
+// The convert subcommand. Reads every puzzle in a file in any supported input format and writes them
+// out in another format without solving them, optionally changing the block layout, symbols, delimiter
+// and empty square character along the way.
+func convertCommand(args []string) error {
+
+	flags := flag.NewFlagSet("convert", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, sdm, sdk, ss or csv). Detected from the file extension (.csv, .sdk, .sdm or .ss) when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the input")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... in the input when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file to convert (- reads standard input)")
+	formatPtr := flags.String("to", "one-line", "The output format ("+outputFormatNames+")")
+	outDimPtr := flags.String("to-d", "", "The block dimensions used to lay out grid output. Defaults to -d")
+	outDelimiterPtr := flags.String("to-del", "", "The delimeter written between squares")
+	outEmptyValuePtr := flags.String("to-e", ".", "The character written for empty squares")
+	outSymbolsPtr := flags.String("to-symbols", "", "The symbols written for the numbers 1, 2, 3... Defaults to 1-9 then A-Z for puzzles bigger than 9x9 when squares aren't delimited")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("d", *dimPtr)
+	if err != nil {
+		return err
+	}
+
+	if err := checkOutputFormat("to", *formatPtr); err != nil {
+		return err
+	}
+
+	writer := puzzleWriter{format: *formatPtr, blockXDim: blockXDim, blockYDim: blockYDim, delimiter: *outDelimiterPtr, emptyValue: *outEmptyValuePtr}
+
+	// The output may lay the same squares out in blocks of a different shape
+	if *outDimPtr != "" {
+		writer.blockXDim, writer.blockYDim, err = parseBlockDim("to-d", *outDimPtr)
+		if err != nil {
+			return err
+		}
+		if writer.blockXDim*writer.blockYDim != blockXDim*blockYDim {
+			return flagErrorf("blocks of %s can't lay out a puzzle with blocks of %s", *outDimPtr, *dimPtr)
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	writer.symbols, err = puzzleSymbols(*outSymbolsPtr, *outDelimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	// Datasets are only read in csv mode when asked for while solving, but are detected here
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	puzzles, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if err := writer.writeHeader(out); err != nil {
+		return inputError(err)
+	}
+
+	for _, puzzle := range puzzles {
+		if err := writer.write(out, puzzle); err != nil {
+			return inputError(err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The most puzzles generated for a daily puzzle before giving up on one of the grade asked for, and how
+// many days of daily puzzles the server keeps once generated.
+const (
+	dailyAttempts = 1000
+	dailyKeepDays = 7
+)
+
+// A daily puzzle: the puzzle of one grade for one day (in UTC, as YYYY-MM-DD), in the one-line format.
+// The solution is only sent to clients with the server's daily key.
+type dailyPuzzle struct {
+	Date       string `json:"date"`
+	Difficulty string `json:"difficulty"`
+	Puzzle     string `json:"puzzle"`
+	Solution   string `json:"solution,omitempty"`
+}
+
+// Returns the seed of the daily puzzle of a grade for a date, so that every server (and every run of one)
+// generates the same puzzle for them.
+func dailySeed(date string, difficulty string) int64 {
+
+	h := fnv.New64a()
+	fmt.Fprintf(h, "daily %s %s", date, difficulty)
+
+	return int64(h.Sum64())
+}
+
+// Generates the daily puzzle of a grade for a date: a standard 9x9 puzzle with its clues in a half turn
+// symmetry, like those of most newspapers, generated until one is rated the grade.
+func generateDailyPuzzle(date string, difficulty string) (daily dailyPuzzle, e error) {
+
+	rank := difficultyRank(difficulty)
+	constraints := solver.PuzzleConstraints(9, solver.BlockRegionMap(3, 3), nil, nil)
+	symmetry, _ := generatorSymmetry("rotational")
+	rng := rand.New(rand.NewSource(dailySeed(date, difficulty)))
+
+	puzzle, solution, _, err := generateInBand(9, constraints, 0, symmetry, rank, rank, dailyAttempts, rng)
+	if err != nil {
+		return daily, err
+	}
+
+	writer := puzzleWriter{emptyValue: "."}
+	return dailyPuzzle{date, difficulty, writer.oneLine(puzzle), writer.oneLine(solution)}, nil
+}
+
+// Returns the date of the oldest daily puzzle the server serves, dailyKeepDays before today.
+func oldestDailyDate(today time.Time) string {
+	return today.AddDate(0, 0, -dailyKeepDays).Format(time.DateOnly)
+}
+
+// Returns the daily puzzle of a grade for a date no older than oldestDailyDate, generating it the first
+// time it is asked for and keeping it until it is too old to be served.
+func (s *solveServer) dailyPuzzle(date string, difficulty string, today time.Time) (dailyPuzzle, error) {
+
+	key := date + " " + difficulty
+
+	// Puzzles are generated one at a time, so that one asked for by many clients at once is only
+	// generated once
+	s.dailyMutex.Lock()
+	defer s.dailyMutex.Unlock()
+
+	if daily, ok := s.daily[key]; ok {
+		return daily, nil
+	}
+	daily, err := generateDailyPuzzle(date, difficulty)
+	if err != nil {
+		return daily, err
+	}
+
+	oldest := oldestDailyDate(today)
+	for key, kept := range s.daily {
+		if kept.Date < oldest {
+			delete(s.daily, key)
+		}
+	}
+	s.daily[key] = daily
+
+	return daily, nil
+}
+
+// Handles GET /daily, which returns the daily puzzle, and GET /daily/solution, which returns it along
+// with its solution to clients that send the server's daily key as a bearer token. The grade is given
+// by ?difficulty= (medium when left out) and the day by ?date=YYYY-MM-DD (today, in UTC, when left out);
+// days still to come have no puzzle yet, and days more than dailyKeepDays ago have none any more.
+func (s *solveServer) handleDaily(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("daily puzzles are fetched with GET"))
+		return
+	}
+
+	solution := false
+	switch strings.Trim(r.URL.Path, "/") {
+	case "daily":
+	case "daily/solution":
+		solution = true
+	default:
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("there is nothing at %s (the daily puzzle is at /daily, and its solution at /daily/solution)", r.URL.Path))
+		return
+	}
+
+	if solution {
+		if s.dailyKey == "" {
+			writeJSONError(w, http.StatusNotFound, errors.New("the solutions of daily puzzles are only served when the server has a -daily-key"))
+			return
+		}
+		token, hasToken := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !hasToken || subtle.ConstantTimeCompare([]byte(token), []byte(s.dailyKey)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="daily"`)
+			writeJSONError(w, http.StatusUnauthorized, errors.New("the solution of a daily puzzle needs the server's daily key as a bearer token"))
+			return
+		}
+	}
+
+	difficulty := r.URL.Query().Get("difficulty")
+	if difficulty == "" {
+		difficulty = "medium"
+	}
+	if difficultyRank(difficulty) < 0 {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("unknown difficulty %q (expected one of %s)", difficulty, strings.Join(difficulties, ", ")))
+		return
+	}
+
+	today := time.Now().UTC()
+	date := r.URL.Query().Get("date")
+	if date == "" {
+		date = today.Format(time.DateOnly)
+	}
+	if _, err := time.Parse(time.DateOnly, date); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Errorf("invalid date %q (expected YYYY-MM-DD)", date))
+		return
+	}
+	if date > today.Format(time.DateOnly) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("there is no daily puzzle for %s yet", date))
+		return
+	}
+	if date < oldestDailyDate(today) {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("there is no daily puzzle for %s any more (only the last %d days are served)", date, dailyKeepDays))
+		return
+	}
+
+	daily, err := s.dailyPuzzle(date, difficulty, today)
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	if !solution {
+		daily.Solution = ""
+	}
+
+	writeJSON(w, http.StatusOK, daily)
+}
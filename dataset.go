@@ -0,0 +1,229 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Solves every puzzle of a CSV dataset whose rows are puzzle,solution pairs (the common Kaggle format)
+// starting from the given line, and checks each result against the known solution. A header row is
+// skipped automatically. Each row is reported as it finishes, followed by a summary, and in training
+// mode (when training is set) every row is instead recorded in the training log, saying whether the
+// result matched the known solution. Every result is also recorded in the results store when results is
+// set. When out is set, every result is also written to it as a line, in the same
+// order as the dataset. Every puzzle is solved with the given options, their cooling steps reported to
+// progress, unless the cache (when it isn't nil) holds its solution, and new solutions are cached. Each
+// result is added to batch, along with the row's difficulty when the header names a difficulty or rating
+// column. Puzzles that aren't solved are retried by the retry policy, and those that run out of its time
+// for a puzzle are recorded with the lowest cost they reached. Each puzzle's solve is seeded in
+// turn from the run's seed, so the whole dataset can be solved again the same way. Returns the number of
+// rows that did not match.
+func solveDataset(r io.Reader, firstLine int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int, regionMap [][]int, variant []solver.Constraint, options solver.Options, retry retryPolicy, cache *solutionCache, training *trainingLog, results *resultsStore, out io.Writer, writer puzzleWriter, progress func(solver.AnnealProgress), batch *batchResults) (mismatches int) {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+
+	rows, solved, matched := 0, 0, 0
+	difficultyColumn := -1
+	seeds := rand.New(rand.NewSource(randomSeed))
+
+	// Start puzzle at line 1 (more user friendly)
+	for lineCounter := 1; ; lineCounter++ {
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			fmt.Println(err)
+			mismatches++
+			break
+		}
+
+		if lineCounter < firstLine {
+			continue
+		}
+
+		if len(record) < 2 {
+			fmt.Printf("line %d: expected puzzle,solution but found %d field(s)\n", lineCounter, len(record))
+			mismatches++
+			continue
+		}
+
+		if !datasetFieldFits(record[0], delimiter, blockXDim*blockYDim) {
+			// The first row of most datasets names the columns
+			if lineCounter == 1 {
+				for i, name := range record {
+					if name := strings.ToLower(strings.TrimSpace(name)); name == "difficulty" || name == "rating" {
+						difficultyColumn = i
+					}
+				}
+				continue
+			}
+			fmt.Printf("line %d: the puzzle does not have %d squares\n", lineCounter, blockXDim*blockYDim*blockXDim*blockYDim)
+			mismatches++
+			continue
+		}
+
+		if !datasetFieldFits(record[1], delimiter, blockXDim*blockYDim) {
+			fmt.Printf("line %d: the solution does not have %d squares\n", lineCounter, blockXDim*blockYDim*blockXDim*blockYDim)
+			mismatches++
+			continue
+		}
+
+		puzzle, err := readInOneLine(strings.NewReader(record[0]), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			fmt.Printf("line %d: %v\n", lineCounter, err)
+			mismatches++
+			continue
+		}
+
+		solution, err := readInOneLine(strings.NewReader(record[1]), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			fmt.Printf("line %d: %v\n", lineCounter, err)
+			mismatches++
+			continue
+		}
+
+		constraints := solver.PuzzleConstraints(len(puzzle), regionMap, variant, nil)
+
+		if conflicts := findClueConflicts(puzzle, constraints); len(conflicts) > 0 {
+			fmt.Printf("line %d: the clues conflict, so the puzzle has no solution (%v)\n", lineCounter, conflicts[0])
+			mismatches++
+			continue
+		}
+
+		rows++
+		start := time.Now()
+		counter, steps := stepCounter()
+		solvedPuzzle, cached := cache.lookup(puzzle, constraints)
+		successfullySolved := cached
+		retries := 0
+		timedOut := false
+		finalCost := 0.0
+		if !cached {
+			puzzleOptions := options
+			puzzleOptions.OnCoolingStep, puzzleOptions.Seed = solver.CombineProgress(progress, counter), seeds.Int63()
+			puzzleOptions, bestCost := trackBestCost(puzzleOptions)
+			var stats solver.Stats
+			solvedPuzzle, successfullySolved, stats = retry.search(puzzle, constraints, puzzleOptions)
+			retries = stats.Restarts
+			finalCost = solver.CostFunction(solvedPuzzle, constraints)
+			if timedOut = !successfullySolved && retry.outOfTime(start); timedOut {
+				finalCost = math.Min(finalCost, *bestCost)
+			}
+			if successfullySolved && cache != nil {
+				if err := cache.store(puzzle, solvedPuzzle); err != nil {
+					slog.Error("caching a solution", "error", err)
+				}
+			}
+		}
+
+		elapsed := time.Since(start)
+		matches := successfullySolved && samePuzzle(solvedPuzzle, solution)
+
+		difficulty := ""
+		if difficultyColumn >= 0 && difficultyColumn < len(record) {
+			difficulty = strings.TrimSpace(record[difficultyColumn])
+		}
+		batch.add(elapsed, successfullySolved, retries, finalCost, difficulty)
+		if timedOut {
+			batch.timeouts++
+		}
+
+		if successfullySolved {
+			solved++
+		}
+		if matches {
+			matched++
+		} else {
+			mismatches++
+		}
+
+		if out != nil {
+			if err := writeSolution(out, writer, puzzle, solvedPuzzle, successfullySolved); err != nil {
+				fmt.Println(err)
+				mismatches++
+				break
+			}
+		}
+
+		result := trainingRecord{lineCounter, options.Temperature, options.CoolingRate, options.Iterations, options.Swaps, options.Annealers, successfullySolved, elapsed.Seconds(), &matches,
+			solver.PuzzleHash(puzzle), finalCost, *steps * options.Iterations, retries, randomSeed}
+
+		if results != nil {
+			if err := results.write(result); err != nil {
+				fmt.Println(err)
+				mismatches++
+				break
+			}
+		}
+
+		timing := elapsed.String()
+		if retries > 0 {
+			timing += fmt.Sprintf(", retry %d", retries)
+		}
+
+		if training != nil {
+			if err := training.write(result); err != nil {
+				fmt.Println(err)
+				mismatches++
+				break
+			}
+		} else if matches && cached {
+			fmt.Printf("line %d: cached, matches the known solution (%s)\n", lineCounter, timing)
+		} else if matches {
+			fmt.Printf("line %d: solved, matches the known solution (%s)\n", lineCounter, timing)
+		} else if cached {
+			fmt.Printf("line %d: cached, but differs from the known solution (%s)\n", lineCounter, timing)
+		} else if successfullySolved {
+			fmt.Printf("line %d: solved, but differs from the known solution (%s)\n", lineCounter, timing)
+		} else if timedOut {
+			fmt.Printf("line %d: timed out, best cost %v (%s)\n", lineCounter, finalCost, timing)
+		} else {
+			fmt.Printf("line %d: no solution found, cost at end %v (%s)\n", lineCounter, finalCost, timing)
+		}
+	}
+
+	if training == nil {
+		fmt.Printf("\n%d of %d puzzles solved, %d matched the known solution\n", solved, rows, matched)
+	}
+
+	return mismatches
+}
+
+// Reports whether a dataset field has exactly one square for every square of the puzzle. This catches
+// header rows and truncated puzzles before readInOneLine indexes past the end of them.
+func datasetFieldFits(field string, delimiter string, puzzleDim int) bool {
+	return len(strings.Split(field, delimiter)) == puzzleDim*puzzleDim
+}
+
+// Reports whether two puzzles hold the same numbers in every square.
+func samePuzzle(a [][]int, b [][]int) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if len(a[i]) != len(b[i]) {
+			return false
+		}
+		for j := range a[i] {
+			if a[i][j] != b[i][j] {
+				return false
+			}
+		}
+	}
+
+	return true
+}
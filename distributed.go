@@ -0,0 +1,479 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// How often an idle worker asks the coordinator for work, and how often a busy one checks whether the
+// puzzle it is annealing has already been solved by another replica.
+const (
+	workerPollInterval   = time.Second
+	workerCancelInterval = 5 * time.Second
+)
+
+// How long a worker keeps trying to reach a coordinator that isn't answering before giving up, and how
+// long a coordinator keeps telling workers the batch is finished before it exits.
+const (
+	workerRetryTime      = 30 * time.Second
+	coordinatorLingering = 2 * workerCancelInterval
+)
+
+// One puzzle of a distributed batch. Each of its replicas anneals it on a worker with its own part of
+// the temperature ladder, and the puzzle is finished once any replica solves it or every replica has
+// given up.
+type distributedTask struct {
+	line        int
+	puzzle      [][]int
+	constraints []solver.Constraint
+	request     solveRequest
+	leases      []time.Time // When each replica's worker is presumed lost, or zero while it isn't handed out
+	finished    []bool
+	result      *solveResponse
+	solution    [][]int // The candidate of the best result
+	worker      string
+	elapsed     time.Duration
+}
+
+// Reports whether the puzzle needs no more annealing.
+func (t *distributedTask) done() bool {
+
+	if t.result != nil && t.result.Solved {
+		return true
+	}
+	for _, finished := range t.finished {
+		if !finished {
+			return false
+		}
+	}
+	return true
+}
+
+// A unit of work handed to a worker: one replica of one puzzle.
+type workAssignment struct {
+	ID      string       `json:"id"`
+	Request solveRequest `json:"request"`
+}
+
+// The coordinator of a distributed batch, which hands puzzles out to the workers that ask for them and
+// gathers their results. Its server checks the API tokens of the workers, when it has any, and the
+// candidates they send back are read with its symbols and block dimensions.
+type coordinator struct {
+	mutex     sync.Mutex
+	tasks     []*distributedTask
+	server    *solveServer
+	symbols   string
+	blockXDim int
+	blockYDim int
+	timeout   time.Duration
+	start     time.Time
+	remaining int
+	finished  chan struct{}
+}
+
+// Hands out the next replica that no worker has, or whose worker has been gone so long it is presumed
+// lost. ok is false when there is nothing to hand out.
+func (c *coordinator) nextAssignment() (assignment workAssignment, ok bool) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+	for i, task := range c.tasks {
+		if task.done() {
+			continue
+		}
+		for replica, lease := range task.leases {
+			if task.finished[replica] || now.Before(lease) {
+				continue
+			}
+			// A lost worker's replica is handed out again once it has had its timeout and then some
+			task.leases[replica] = now.Add(c.timeout + time.Minute)
+			request := task.request
+			request.Temperature = replicaTemperature(request.Temperature, replica, len(task.leases))
+			return workAssignment{fmt.Sprintf("%d.%d", i, replica), request}, true
+		}
+	}
+	return assignment, false
+}
+
+// Returns the base temperature of one of a puzzle's replicas. The replicas' ladders interleave, each
+// starting a fraction of a doubling above the last, so that together they cover the same range of
+// temperatures as one solve more finely.
+func replicaTemperature(baseTemperature float64, replica int, replicas int) float64 {
+	return baseTemperature * math.Pow(2, float64(replica)/float64(replicas))
+}
+
+// Reads the puzzle and replica from an assignment id.
+func (c *coordinator) task(id string) (task *distributedTask, replica int, err error) {
+
+	var i int
+	if _, err := fmt.Sscanf(id, "%d.%d", &i, &replica); err != nil || i < 0 || i >= len(c.tasks) || replica < 0 || replica >= len(c.tasks[i].leases) {
+		return nil, 0, fmt.Errorf("there is no work %q", id)
+	}
+	return c.tasks[i], replica, nil
+}
+
+// Checks a replica's result against its puzzle rather than taking the worker's word for it: the
+// candidate must keep every clue of the puzzle, and its cost is worked out again, so that the result is
+// solved only when the candidate is a solution. Returns the result with its cost worked out, and its
+// candidate.
+func (c *coordinator) checkResult(task *distributedTask, response solveResponse) (solveResponse, [][]int, error) {
+
+	candidate, err := readInOneLine(strings.NewReader(response.Solution), 1, "", ".", c.symbols, c.blockXDim, c.blockYDim)
+	if err != nil {
+		return response, nil, fmt.Errorf("the candidate can't be read: %w", err)
+	}
+	for row := range task.puzzle {
+		for column, clue := range task.puzzle[row] {
+			if clue != 0 && candidate[row][column] != clue {
+				return response, nil, fmt.Errorf("the candidate changes the clue in row %d, column %d", row+1, column+1)
+			}
+		}
+	}
+
+	cost := solver.CostFunction(candidate, task.constraints)
+	if response.Solved && cost != 0 {
+		return response, nil, fmt.Errorf("the candidate is reported solved, but its cost is %v", cost)
+	}
+	response.Solved, response.Cost = cost == 0, cost
+
+	return response, candidate, nil
+}
+
+// Records a replica's result, keeping the best result of each puzzle, and reports the puzzle once it is
+// finished. Results that don't stand up to checkResult are turned away, and their replicas are handed out
+// again once their workers are presumed lost.
+func (c *coordinator) report(id string, worker string, response solveResponse) (found bool, e error) {
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	task, replica, err := c.task(id)
+	if err != nil {
+		return false, err
+	}
+	// Replicas still running when their puzzle was finished report too late to matter
+	if task.done() {
+		return true, nil
+	}
+
+	response, candidate, err := c.checkResult(task, response)
+	if err != nil {
+		return true, fmt.Errorf("the result of work %s from %s: %w", id, worker, err)
+	}
+
+	task.finished[replica] = true
+	if task.result == nil || response.Solved || (!task.result.Solved && response.Cost < task.result.Cost) {
+		task.result, task.solution, task.worker = &response, candidate, worker
+	}
+
+	if task.done() {
+		task.elapsed = time.Since(c.start)
+		if task.result.Solved {
+			fmt.Printf("line %d: solved by %s (%s)\n", task.line, task.worker, task.elapsed)
+		} else {
+			fmt.Printf("line %d: no solution found, cost at end %v (%s)\n", task.line, task.result.Cost, task.elapsed)
+		}
+		c.remaining--
+		if c.remaining == 0 {
+			close(c.finished)
+		}
+	}
+	return true, nil
+}
+
+// Handles POST /work, which hands a worker a replica to anneal: 200 with the assignment, 204 when there
+// is nothing to hand out for now, or 410 once the batch is finished. POST /work/ID reports a replica's
+// result, turning away one that doesn't check out with 422, and GET /work/ID says whether its puzzle is
+// already finished. When the coordinator has API tokens, workers must send one of them.
+func (c *coordinator) handleWork(w http.ResponseWriter, r *http.Request) {
+
+	if _, ok := c.server.authenticate(w, r); !ok {
+		return
+	}
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/work"), "/")
+
+	if id == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("work is handed out by POSTing to /work"))
+			return
+		}
+		select {
+		case <-c.finished:
+			writeJSONError(w, http.StatusGone, errors.New("the batch is finished"))
+			return
+		default:
+		}
+		assignment, ok := c.nextAssignment()
+		if !ok {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		writeJSON(w, http.StatusOK, assignment)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		var response solveResponse
+		if err := json.NewDecoder(r.Body).Decode(&response); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+		if found, err := c.report(id, r.RemoteAddr, response); !found {
+			writeJSONError(w, http.StatusNotFound, err)
+			return
+		} else if err != nil {
+			slog.Warn("turning away a result", "error", err)
+			writeJSONError(w, http.StatusUnprocessableEntity, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	c.mutex.Lock()
+	task, _, err := c.task(id)
+	done := err == nil && task.done()
+	c.mutex.Unlock()
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"done": done})
+}
+
+// Runs a distributed batch: every puzzle of a one-line puzzle file from the given line on is handed out
+// to the workers that connect to addr, each as the given number of replicas, until every puzzle is
+// solved or has no replicas left to try. Workers must send one of the API tokens, unless there are none.
+// Each puzzle is reported as it finishes, and when out is set every result is written to it in the same
+// order as the file. Every result is added to batch. Returns the number of puzzles that were not solved.
+func runCoordinator(addr string, tokens []apiToken, r io.Reader, firstLine int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int, variant string,
+	params annealParams, replicas int, timeout time.Duration, out io.Writer, writer puzzleWriter, batch *batchResults) (unsolved int, e error) {
+
+	// Puzzles are sent to the workers the way the HTTP API reads them, whatever their symbols
+	requestSymbols, err := puzzleSymbols("", "", blockXDim*blockYDim)
+	if err != nil {
+		return 0, err
+	}
+	requestWriter := puzzleWriter{symbols: requestSymbols, emptyValue: "."}
+	checker := &solveServer{maxTimeout: timeout, tokens: tokens, metrics: newServerMetrics()}
+
+	c := &coordinator{server: checker, symbols: requestSymbols, blockXDim: blockXDim, blockYDim: blockYDim, timeout: timeout, finished: make(chan struct{})}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanPuzzleLines)
+	for lineCounter := 1; scanner.Scan(); lineCounter++ {
+		if lineCounter < firstLine || strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		puzzle, err := readInOneLine(strings.NewReader(scanner.Text()), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", lineCounter, err)
+		}
+
+		request := solveRequest{
+			Puzzle:         requestWriter.oneLine(puzzle),
+			Dim:            fmt.Sprintf("%dx%d", blockXDim, blockYDim),
+			Variant:        variant,
+			Temperature:    params.temperature,
+			CoolingRate:    params.coolingRate,
+			Iterations:     params.iterations,
+			Swaps:          params.swaps,
+			Annealers:      params.annealers,
+			TimeoutSeconds: timeout.Seconds(),
+		}
+		// Puzzles the workers would turn away are caught before any of them start
+		p, err := checker.readRequest(request)
+		if err != nil {
+			return 0, fmt.Errorf("line %d: %w", lineCounter, err)
+		}
+
+		c.tasks = append(c.tasks, &distributedTask{line: lineCounter, puzzle: puzzle, constraints: p.constraints, request: request, leases: make([]time.Time, replicas), finished: make([]bool, replicas)})
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, inputError(err)
+	}
+	if len(c.tasks) == 0 {
+		return 0, puzzleErrorf("there are no puzzles to hand out from line %d on", firstLine)
+	}
+	c.remaining = len(c.tasks)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/work", c.handleWork)
+	mux.HandleFunc("/work/", c.handleWork)
+	server := &http.Server{Addr: addr, Handler: mux}
+
+	serveErr := make(chan error, 1)
+	go func() { serveErr <- server.ListenAndServe() }()
+
+	slog.Info("coordinating", "puzzles", len(c.tasks), "addr", addr)
+	c.start = time.Now()
+
+	select {
+	case err := <-serveErr:
+		return 0, inputError(err)
+	case <-c.finished:
+	}
+
+	// Workers still asking for work are told the batch is finished before the coordinator goes away
+	time.Sleep(coordinatorLingering)
+	server.Close()
+
+	solved := 0
+	for _, task := range c.tasks {
+		if task.result.Solved {
+			solved++
+		} else {
+			unsolved++
+		}
+		batch.add(task.elapsed, task.result.Solved, 0, task.result.Cost, "")
+
+		if out != nil {
+			solution := task.puzzle
+			if task.result.Solved {
+				solution = task.solution
+			}
+			if err := writeSolution(out, writer, task.puzzle, solution, task.result.Solved); err != nil {
+				return unsolved, inputError(err)
+			}
+		}
+	}
+
+	fmt.Printf("\n%d of %d puzzles solved in %s\n", solved, len(c.tasks), time.Since(c.start))
+
+	return unsolved, nil
+}
+
+// Sends a request to the coordinator, with the worker's API token when it has one.
+func coordinatorRequest(method string, url string, token string, body []byte) (*http.Response, error) {
+
+	request, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		request.Header.Set(tokenHeader, token)
+	}
+
+	return http.DefaultClient.Do(request)
+}
+
+// Runs a worker, which asks the coordinator for replicas to anneal until it says the batch is finished,
+// sending it the API token when it isn't empty. A worker started before its coordinator, or cut off from
+// it, keeps trying for a while before giving up.
+func runWorker(coordinatorURL string, token string) error {
+
+	coordinatorURL = strings.TrimSuffix(coordinatorURL, "/")
+	if !strings.Contains(coordinatorURL, "://") {
+		coordinatorURL = "http://" + coordinatorURL
+	}
+
+	// Any solve the coordinator asks for is allowed; it sets the timeout of each
+	server := &solveServer{maxTimeout: 24 * time.Hour, metrics: newServerMetrics()}
+	lastContact := time.Now()
+
+	for {
+		response, err := coordinatorRequest(http.MethodPost, coordinatorURL+"/work", token, nil)
+		if err != nil {
+			if time.Since(lastContact) > workerRetryTime {
+				return inputError(err)
+			}
+			time.Sleep(workerPollInterval)
+			continue
+		}
+		lastContact = time.Now()
+
+		var assignment workAssignment
+		status := response.StatusCode
+		if status == http.StatusOK {
+			err = json.NewDecoder(response.Body).Decode(&assignment)
+		}
+		response.Body.Close()
+
+		switch {
+		case status == http.StatusGone:
+			return nil
+		case status == http.StatusNoContent:
+			time.Sleep(workerPollInterval)
+			continue
+		case status != http.StatusOK:
+			return inputError(fmt.Errorf("asking %s for work: %s", coordinatorURL, response.Status))
+		case err != nil:
+			return inputError(fmt.Errorf("reading work from %s: %v", coordinatorURL, err))
+		}
+
+		p, err := server.readRequest(assignment.Request)
+		if err != nil {
+			return fmt.Errorf("work %s: %w", assignment.ID, err)
+		}
+
+		slog.Info("annealing", "work", assignment.ID)
+		cancel := make(chan struct{})
+		stopChecking := watchForCancel(coordinatorURL+"/work/"+assignment.ID, token, cancel)
+		result := server.solve(p, nil, cancel)
+		close(stopChecking)
+
+		body, _ := json.Marshal(result)
+		response, err = coordinatorRequest(http.MethodPost, coordinatorURL+"/work/"+assignment.ID, token, body)
+		if err != nil {
+			// The coordinator will hand the replica out again once it presumes this worker lost
+			slog.Error("reporting a result", "work", assignment.ID, "error", err)
+			continue
+		}
+		response.Body.Close()
+		if response.StatusCode == http.StatusUnauthorized {
+			return inputError(fmt.Errorf("reporting to %s: %s", coordinatorURL, response.Status))
+		}
+	}
+}
+
+// Checks every so often whether the puzzle of a replica being annealed has already been finished by
+// another replica, closing cancel if it has. Closing the returned channel stops the checks.
+func watchForCancel(workURL string, token string, cancel chan struct{}) chan struct{} {
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(workerCancelInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			}
+
+			response, err := coordinatorRequest(http.MethodGet, workURL, token, nil)
+			if err != nil {
+				continue
+			}
+			var state struct {
+				Done bool `json:"done"`
+			}
+			json.NewDecoder(response.Body).Decode(&state)
+			response.Body.Close()
+			if state.Done {
+				close(cancel)
+				return
+			}
+		}
+	}()
+	return stop
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A 9x9 puzzle hard enough that annealing never solves it in the few cooling steps the tests run.
+const testPuzzle = "4.....8.5.3..........7......2.....6.....8.4......1.......6.3.7.5..2.....1.4......"
+
+// The solution of testPuzzle.
+const testSolution = "417369825632158947958724316825437169791586432346912758289643571573291684164875293"
+
+// Returns a coordinator of testPuzzle, as one replica, with the given API tokens.
+func testCoordinator(t *testing.T, tokens []apiToken) *coordinator {
+	t.Helper()
+
+	puzzle, constraints := readTestPuzzle(t)
+	if cost := solver.CostFunction(readTestSolution(t), constraints); cost != 0 {
+		t.Fatalf("the test solution costs %v", cost)
+	}
+
+	return &coordinator{
+		tasks:     []*distributedTask{{line: 1, puzzle: puzzle, constraints: constraints, leases: make([]time.Time, 1), finished: make([]bool, 1)}},
+		server:    &solveServer{tokens: tokens, metrics: newServerMetrics()},
+		symbols:   "123456789",
+		blockXDim: 3,
+		blockYDim: 3,
+		start:     time.Now(),
+		remaining: 1,
+		finished:  make(chan struct{}),
+	}
+}
+
+// Returns the test puzzle with the constraints of a standard 9x9 sudoku.
+func readTestPuzzle(t *testing.T) ([][]int, []solver.Constraint) {
+	t.Helper()
+
+	puzzle, err := readInOneLine(strings.NewReader(testPuzzle), 1, "", ".", "123456789", 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return puzzle, solver.PuzzleConstraints(9, solver.BlockRegionMap(3, 3), nil, nil)
+}
+
+// Returns the solution of the test puzzle.
+func readTestSolution(t *testing.T) [][]int {
+	t.Helper()
+
+	solution, err := readInOneLine(strings.NewReader(testSolution), 1, "", ".", "123456789", 3, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return solution
+}
+
+// Reports a result of the coordinator's one replica, returning the status it answers with.
+func reportResult(c *coordinator, token string, body string) int {
+
+	request := httptest.NewRequest(http.MethodPost, "/work/0.0", strings.NewReader(body))
+	if token != "" {
+		request.Header.Set(tokenHeader, token)
+	}
+	recorder := httptest.NewRecorder()
+	c.handleWork(recorder, request)
+
+	return recorder.Code
+}
+
+func TestCoordinatorTurnsAwayResultsThatDontCheckOut(t *testing.T) {
+
+	// Swapping two squares of the solution that aren't clues breaks it, and swapping the first two changes the clue 4
+	broken := testSolution[:1] + testSolution[2:3] + testSolution[1:2] + testSolution[3:]
+	changed := testSolution[1:2] + testSolution[:1] + testSolution[2:]
+
+	for name, body := range map[string]string{
+		"an unsolved candidate reported solved": `{"solved": true, "cost": 0, "solution": "` + broken + `"}`,
+		"a candidate that changes a clue":       `{"solved": false, "cost": 2, "solution": "` + changed + `"}`,
+		"a candidate that can't be read":        `{"solved": true, "cost": 0, "solution": "41736"}`,
+	} {
+		c := testCoordinator(t, nil)
+		if status := reportResult(c, "", body); status != http.StatusUnprocessableEntity {
+			t.Errorf("%s was answered with %d, not 422", name, status)
+		}
+		if c.tasks[0].result != nil || c.tasks[0].finished[0] {
+			t.Errorf("%s was recorded", name)
+		}
+	}
+
+	c := testCoordinator(t, nil)
+	if status := reportResult(c, "", `{"solved": false, "cost": 5, "solution": "`+testSolution+`"}`); status != http.StatusNoContent {
+		t.Fatalf("a solution was answered with %d, not 204", status)
+	}
+	if result := c.tasks[0].result; result == nil || !result.Solved || result.Cost != 0 {
+		t.Errorf("a solution reported unsolved was recorded as %+v, not solved", result)
+	}
+}
+
+func TestCoordinatorNeedsAWorkerToken(t *testing.T) {
+
+	c := testCoordinator(t, []apiToken{{Name: "worker", Token: "s3cret"}})
+	body := `{"solved": true, "cost": 0, "solution": "` + testSolution + `"}`
+
+	if status := reportResult(c, "", body); status != http.StatusUnauthorized {
+		t.Errorf("a result sent without a token was answered with %d, not 401", status)
+	}
+	if status := reportResult(c, "wrong", body); status != http.StatusUnauthorized {
+		t.Errorf("a result sent with the wrong token was answered with %d, not 401", status)
+	}
+	if status := reportResult(c, "s3cret", body); status != http.StatusNoContent {
+		t.Errorf("a result sent with the token was answered with %d, not 204", status)
+	}
+}
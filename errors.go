@@ -0,0 +1,81 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The exit statuses of the program, one for each kind of failure.
+const (
+	exitSolved        = 0
+	exitNoSolution    = 1
+	exitInvalidPuzzle = 2
+	exitIOError       = 3
+	exitBadFlags      = 4
+)
+
+// ErrNoSolution is returned when annealing finishes without solving the puzzle.
+var ErrNoSolution = errors.New("no viable solution to the puzzle was found")
+
+// ErrInvalidSolution is returned by verify when the grid breaks one of the puzzle's rules.
+var ErrInvalidSolution = errors.New("the grid is not a valid solution")
+
+// Flag parsing errors are reported by the flag package along with the usage, so aren't printed again.
+var errUsageShown = errors.New("invalid flags")
+
+// An InputError reports a failure to read or write a file, standard input or a URL.
+type InputError struct {
+	Err error
+}
+
+func (e *InputError) Error() string { return e.Err.Error() }
+func (e *InputError) Unwrap() error { return e.Err }
+
+// A FlagError reports a flag that couldn't be parsed or doesn't make sense for the puzzle.
+type FlagError struct {
+	Err error
+}
+
+func (e *FlagError) Error() string { return e.Err.Error() }
+func (e *FlagError) Unwrap() error { return e.Err }
+
+// Returns a PuzzleError with the formatted message.
+func puzzleErrorf(format string, a ...interface{}) error {
+	return &solver.PuzzleError{Err: fmt.Errorf(format, a...)}
+}
+
+// Returns a FlagError with the formatted message.
+func flagErrorf(format string, a ...interface{}) error {
+	return &FlagError{fmt.Errorf(format, a...)}
+}
+
+// Wraps err in an InputError, passing nil through so it can wrap the result of scanner.Err and similar.
+func inputError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &InputError{err}
+}
+
+// Returns the exit status for the error a command finished with.
+func exitCode(err error) int {
+
+	var puzzleErr *solver.PuzzleError
+	var inputErr *InputError
+	var flagErr *FlagError
+
+	switch {
+	case err == nil:
+		return exitSolved
+	case errors.As(err, &flagErr):
+		return exitBadFlags
+	case errors.As(err, &inputErr):
+		return exitIOError
+	case errors.As(err, &puzzleErr):
+		return exitInvalidPuzzle
+	}
+
+	return exitNoSolution
+}
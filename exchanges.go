@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The least width of a ladder diagram, the height of each gap between its rungs and of the margins
+// above and below them, and where the shares of time at each rung start, in pixels.
+const (
+	ladderWidth  = 520
+	ladderGap    = 70
+	ladderTop    = 40
+	ladderShares = 260
+)
+
+// Follows the exchanges of an annealing run up and down its temperature ladder, for the -ladder-report.
+// A replica is a candidate solution as it is handed from annealer to annealer, named by the annealer it
+// started at. Exchanges counts the trades of each neighbouring pair of annealers (pair i being annealers
+// i and i+1), and time the cooling steps each replica spent at each annealer's temperature. The
+// temperatures are those of the first cooling step, as set by -t and the ladder.
+type ladderReport struct {
+	steps        int
+	temperatures []float64
+	exchanges    []int
+	time         [][]int
+	replicaAt    []int
+	stepStart    []int
+}
+
+// Returns a report for a ladder of the given number of annealers, every replica starting at its own.
+func newLadderReport(annealers int) *ladderReport {
+
+	l := &ladderReport{exchanges: make([]int, annealers), time: make([][]int, annealers), replicaAt: make([]int, annealers)}
+	for i := range l.replicaAt {
+		l.replicaAt[i] = i
+		l.time[i] = make([]int, annealers)
+	}
+	l.stepStart = append([]int(nil), l.replicaAt...)
+
+	return l
+}
+
+// Records an exchange between annealers i and j (the colder one first). Used as the exchange hook of
+// anneal.
+func (l *ladderReport) exchange(i int, j int) {
+
+	l.exchanges[i]++
+	l.replicaAt[i], l.replicaAt[j] = l.replicaAt[j], l.replicaAt[i]
+}
+
+// Records a cooling step. Used as the progress function of anneal, which calls it after the step's
+// exchanges, so the step is counted with the replicas where they were before them.
+func (l *ladderReport) record(p solver.AnnealProgress) {
+
+	l.steps++
+	if l.temperatures == nil {
+		for _, replica := range p.Replicas {
+			l.temperatures = append(l.temperatures, replica.Temperature)
+		}
+	}
+	for annealer, replica := range l.stepStart {
+		l.time[replica][annealer]++
+	}
+	copy(l.stepStart, l.replicaAt)
+}
+
+// Returns the fraction of the cooling steps in which the annealers of pair i exchanged candidates.
+func (l *ladderReport) rate(i int) float64 {
+
+	if l.steps == 0 {
+		return 0
+	}
+
+	return float64(l.exchanges[i]) / float64(l.steps)
+}
+
+// Writes the report: the exchange rate of every neighbouring pair of annealers, the share of the run each
+// replica spent at each temperature, and the ladder drawn in text from the hottest annealer down, with
+// a bar for the exchange rate between each rung and the next (a block for every 2.5%, rounded up).
+func (l *ladderReport) write(w io.Writer) {
+
+	n := len(l.replicaAt)
+	out := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Temperature ladder of %d annealers over %d cooling steps (temperatures of the first step):\n\n", n, l.steps)
+	fmt.Fprintln(out, "pair\ttemperatures\texchanges\trate")
+	for i := 0; i < n-1; i++ {
+		fmt.Fprintf(out, "%d-%d\t%.4g / %.4g\t%d\t%.1f%%\n", i, i+1, l.temperature(i), l.temperature(i+1), l.exchanges[i], 100*l.rate(i))
+	}
+	out.Flush()
+
+	fmt.Fprintln(w)
+	header := []string{"time at annealer"}
+	for i := 0; i < n; i++ {
+		header = append(header, fmt.Sprint(i))
+	}
+	fmt.Fprintln(out, strings.Join(header, "\t"))
+	for replica, counts := range l.time {
+		fields := []string{fmt.Sprintf("replica %d", replica)}
+		for _, count := range counts {
+			fields = append(fields, fmt.Sprintf("%.0f%%", 100*float64(count)/math.Max(1, float64(l.steps))))
+		}
+		fmt.Fprintln(out, strings.Join(fields, "\t"))
+	}
+	out.Flush()
+
+	fmt.Fprintln(w)
+	for i := n - 1; i >= 0; i-- {
+		end := ""
+		if i == n-1 {
+			end = " hottest"
+		} else if i == 0 {
+			end = " coldest"
+		}
+		fmt.Fprintf(w, "  %2d  T %-10.4g ══╪══%s\n", i, l.temperature(i), end)
+		if i > 0 {
+			fmt.Fprintf(w, "  %16s   │  %5.1f%% %s\n", "", 100*l.rate(i-1), strings.Repeat("█", int(math.Ceil(40*l.rate(i-1)))))
+		}
+	}
+}
+
+// Returns the temperature of annealer i at the first cooling step, or 0 before it.
+func (l *ladderReport) temperature(i int) float64 {
+
+	if i >= len(l.temperatures) {
+		return 0
+	}
+
+	return l.temperatures[i]
+}
+
+// Writes the ladder as an SVG diagram to the named file: a rung for every annealer, the hottest at the
+// top, joined to the next by a rail as thick as the exchange rate between them is high, with the share
+// of the run each replica spent at a rung shaded beside it.
+func (l *ladderReport) writeSVG(filename string) error {
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return inputError(err)
+	}
+	defer file.Close()
+
+	n := len(l.replicaAt)
+	width, height := ladderWidth, ladderTop*2+ladderGap*(n-1)
+	if shares := ladderShares + n*14 + 20; shares > width {
+		width = shares
+	}
+	rungY := func(i int) int {
+		return ladderTop + ladderGap*(n-1-i)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", width, height)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", width, height)
+	fmt.Fprintf(&b, `<text x="150" y="20" text-anchor="middle">exchange rate</text>`+"\n")
+	fmt.Fprintf(&b, `<text x="%d" y="20" text-anchor="middle">time at the rung by replica</text>`+"\n", ladderShares+n*7)
+
+	for i := 0; i < n; i++ {
+		y := rungY(i)
+		if i > 0 {
+			width := math.Max(1, 16*l.rate(i-1))
+			fmt.Fprintf(&b, `<line x1="150" y1="%d" x2="150" y2="%d" stroke="#1f77b4" stroke-width="%.1f"/>`+"\n", y, rungY(i-1), width)
+			fmt.Fprintf(&b, `<text x="170" y="%d">%.1f%%</text>`+"\n", (y+rungY(i-1))/2+4, 100*l.rate(i-1))
+		}
+		fmt.Fprintf(&b, `<line x1="110" y1="%d" x2="190" y2="%d" stroke="black" stroke-width="3"/>`+"\n", y, y)
+		fmt.Fprintf(&b, `<text x="100" y="%d" text-anchor="end">%d: T %.4g</text>`+"\n", y+4, i, l.temperature(i))
+
+		for replica := range l.time {
+			share := float64(l.time[replica][i]) / math.Max(1, float64(l.steps))
+			colour := plotColours[replica%len(plotColours)]
+			fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="rgb(%d,%d,%d)" fill-opacity="%.2f" stroke="#ccc"><title>replica %d: %.0f%%</title></rect>`+"\n",
+				ladderShares+replica*14, y-6, colour.R, colour.G, colour.B, share, replica, 100*share)
+		}
+	}
+
+	b.WriteString("</svg>\n")
+
+	_, err = io.WriteString(file, b.String())
+	return inputError(err)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The explain subcommand. Solves a puzzle as far as it can with human techniques instead of annealing,
+// printing every step of the reasoning, then the puzzle as far as it got and how many times each
+// technique was used.
+func explainCommand(args []string) error {
+
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "", "The file containing the puzzle to be explained (- reads standard input)")
+	linePtr := flags.Int("l", 1, "The line of the puzzle in the file")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	puzzleLine, lineErr := checkPositiveInt("l", *linePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *filePtr == "" {
+		return flagErrorf("a puzzle must be given with -f")
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	puzzle, err := readPuzzleFile(*filePtr, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	constraints := solver.PuzzleConstraints(len(puzzle), regionMap, variant, nil)
+
+	if conflicts := findClueConflicts(puzzle, constraints); len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			fmt.Println(conflict)
+		}
+		return puzzleErrorf("the puzzle's clues conflict, so it has no solution")
+	}
+
+	steps, grid, solved := solver.ExplainPuzzle(puzzle, constraints, symbols)
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	counts := make(map[string]int)
+	for i, step := range steps {
+		fmt.Fprintf(out, "%d. %s: %s\n", i+1, strings.ToUpper(step.Technique[:1])+step.Technique[1:], step.Text)
+		counts[step.Technique]++
+	}
+	fmt.Fprintln(out)
+
+	writer := puzzleWriter{format: "grid", blockXDim: blockXDim, blockYDim: blockYDim, symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
+	if err := writer.write(out, grid); err != nil {
+		return inputError(err)
+	}
+	fmt.Fprintln(out)
+
+	var used []string
+	for _, technique := range solver.LogicalTechniques {
+		if counts[technique] > 0 {
+			used = append(used, fmt.Sprintf("%s %d", technique, counts[technique]))
+		}
+	}
+	if len(used) > 0 {
+		fmt.Fprintf(out, "Techniques used: %s\n", strings.Join(used, ", "))
+	}
+
+	if solved && len(steps) == 0 {
+		fmt.Fprintf(out, "The puzzle is already complete.\n")
+	} else if solved {
+		fmt.Fprintf(out, "Solved by logic alone in %d steps, the hardest being %s.\n", len(steps), solver.HardestTechnique(steps))
+	} else if counts["contradiction"] > 0 {
+		fmt.Fprintf(out, "The puzzle has no solution.\n")
+		return ErrNoSolution
+	} else {
+		empty := 0
+		for r := range grid {
+			for c := range grid[r] {
+				if grid[r][c] == 0 {
+					empty++
+				}
+			}
+		}
+		fmt.Fprintf(out, "Stuck with %d squares left, which need guessing or harder techniques (the annealer can finish it).\n", empty)
+	}
+
+	return nil
+}
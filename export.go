@@ -0,0 +1,511 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A puzzle's constraints as the problems of the export formats: every region that must hold each of its
+// numbers once, named after the kind of region and its number among them (eg. block3), the cages of a
+// killer sudoku, the pair constraints of variants like anti-knight (and of thermometers), the sandwich
+// clues, the arrows, the parity of the squares of an even/odd sudoku (see solver.SquareParities, nil for other
+// puzzles), and for every square the largest number it may hold, which is 0 for squares in no region,
+// like the gaps between the grids of a samurai.
+type coverProblem struct {
+	names      []string
+	regions    [][]solver.Cell
+	cages      []solver.Cage
+	pairs      []solver.PairConstraint
+	sandwiches []solver.SandwichClue
+	arrows     []solver.Arrow
+	parities   [][]int
+	maxNumbers [][]int
+}
+
+// Builds the export problem of a puzzle.
+func newCoverProblem(puzzle [][]int, constraints []solver.Constraint) (p coverProblem) {
+
+	counts := map[string]int{}
+	for _, constraint := range constraints {
+		switch constraint := constraint.(type) {
+		case solver.UniqueConstraint:
+			for _, region := range constraint.Groups {
+				counts[constraint.Kind]++
+				p.names = append(p.names, fmt.Sprintf("%s%d", constraint.Kind, counts[constraint.Kind]))
+				p.regions = append(p.regions, region)
+			}
+		case solver.CageConstraint:
+			p.cages = append(p.cages, constraint.Cages...)
+		case solver.PairConstraint:
+			p.pairs = append(p.pairs, constraint)
+		case solver.SandwichConstraint:
+			p.sandwiches = append(p.sandwiches, constraint.Clues...)
+		case solver.ArrowConstraint:
+			p.arrows = append(p.arrows, constraint.Arrows...)
+		}
+	}
+	p.parities = solver.SquareParities(len(puzzle), constraints)
+
+	p.maxNumbers = make([][]int, len(puzzle))
+	for r := range p.maxNumbers {
+		p.maxNumbers[r] = make([]int, len(puzzle))
+	}
+	for _, region := range p.regions {
+		for _, cell := range region {
+			if m := p.maxNumbers[cell.Row][cell.Col]; m == 0 || len(region) < m {
+				p.maxNumbers[cell.Row][cell.Col] = len(region)
+			}
+		}
+	}
+
+	return p
+}
+
+// Returns the DIMACS variable that is true when the square at row r and column c (counting from 0) of a
+// puzzle of the given dimension holds the number.
+func satVariable(puzzleDim int, r int, c int, number int) int {
+	return (r*puzzleDim+c)*puzzleDim + number
+}
+
+// Writes a puzzle as a SAT problem in DIMACS CNF, with a variable for every number in every square (see
+// satVariable). Every square in a region holds exactly one number, which is its clue if it has one,
+// every region holds each of its numbers at least once and at most once, the squares of every pair of a
+// pair constraint don't hold numbers that break its rule, and no marked square holds a number of the
+// wrong parity.
+func writeCNF(out io.Writer, puzzle [][]int, p coverProblem) error {
+
+	puzzleDim := len(puzzle)
+	var clauses [][]int
+
+	// At least one of a group of variables is true, or with exactly, no more than one
+	oneOf := func(variables []int, exactly bool) {
+		clauses = append(clauses, variables)
+		if !exactly {
+			return
+		}
+		for i := range variables {
+			for j := i + 1; j < len(variables); j++ {
+				clauses = append(clauses, []int{-variables[i], -variables[j]})
+			}
+		}
+	}
+
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			maxNumber := p.maxNumbers[r][c]
+			if maxNumber == 0 {
+				continue
+			}
+			var numbers []int
+			for number := 1; number <= maxNumber; number++ {
+				numbers = append(numbers, satVariable(puzzleDim, r, c, number))
+			}
+			oneOf(numbers, true)
+			if clue := puzzle[r][c]; clue > 0 {
+				clauses = append(clauses, []int{satVariable(puzzleDim, r, c, clue)})
+			}
+			for number := 1; p.parities != nil && number <= maxNumber; number++ {
+				if !solver.SuitsParity(number, p.parities[r][c]) {
+					clauses = append(clauses, []int{-satVariable(puzzleDim, r, c, number)})
+				}
+			}
+		}
+	}
+
+	for _, region := range p.regions {
+		for number := 1; number <= len(region); number++ {
+			var squares []int
+			for _, cell := range region {
+				squares = append(squares, satVariable(puzzleDim, cell.Row, cell.Col, number))
+			}
+			oneOf(squares, true)
+		}
+	}
+
+	for _, constraint := range p.pairs {
+		for _, pair := range constraint.Pairs {
+			for number1 := 1; number1 <= p.maxNumbers[pair[0].Row][pair[0].Col]; number1++ {
+				for number2 := 1; number2 <= p.maxNumbers[pair[1].Row][pair[1].Col]; number2++ {
+					if constraint.Rule.Breaks(number1, number2) {
+						clauses = append(clauses, []int{-satVariable(puzzleDim, pair[0].Row, pair[0].Col, number1), -satVariable(puzzleDim, pair[1].Row, pair[1].Col, number2)})
+					}
+				}
+			}
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "c A %dx%d sudoku. Variable (r*%d+c)*%d+n is true when row r, column c (from 0) holds n\n", puzzleDim, puzzleDim, puzzleDim, puzzleDim)
+	fmt.Fprintf(w, "p cnf %d %d\n", puzzleDim*puzzleDim*puzzleDim, len(clauses))
+	for _, clause := range clauses {
+		for _, literal := range clause {
+			w.WriteString(strconv.Itoa(literal) + " ")
+		}
+		w.WriteString("0\n")
+	}
+
+	return w.Flush()
+}
+
+// Writes a puzzle as an exact cover problem in the format of Knuth's DLX programs: a line naming every
+// item, then a line for every option listing the items it covers. The items are every square (p1_1 for
+// row 1, column 1) and every number of every region (row1_5 for a 5 in the first row), and the options
+// are every number that may go in each square, which is only its clue if it has one, and only the numbers
+// of its parity in an even/odd sudoku.
+func writeExactCover(out io.Writer, puzzle [][]int, p coverProblem) error {
+
+	squareItem := func(r int, c int) string {
+		return fmt.Sprintf("p%d_%d", r+1, c+1)
+	}
+
+	// The regions every square is in
+	squareRegions := make(map[solver.Cell][]int)
+	for i, region := range p.regions {
+		for _, cell := range region {
+			squareRegions[cell] = append(squareRegions[cell], i)
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "| A %dx%d sudoku\n", len(puzzle), len(puzzle))
+
+	var items []string
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if p.maxNumbers[r][c] > 0 {
+				items = append(items, squareItem(r, c))
+			}
+		}
+	}
+	for i, region := range p.regions {
+		for number := 1; number <= len(region); number++ {
+			items = append(items, fmt.Sprintf("%s_%d", p.names[i], number))
+		}
+	}
+	fmt.Fprintln(w, strings.Join(items, " "))
+
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			for number := 1; number <= p.maxNumbers[r][c]; number++ {
+				if puzzle[r][c] > 0 && puzzle[r][c] != number || p.parities != nil && !solver.SuitsParity(number, p.parities[r][c]) {
+					continue
+				}
+				option := []string{squareItem(r, c)}
+				for _, i := range squareRegions[solver.Cell{Row: r, Col: c}] {
+					option = append(option, fmt.Sprintf("%s_%d", p.names[i], number))
+				}
+				fmt.Fprintln(w, strings.Join(option, " "))
+			}
+		}
+	}
+
+	return w.Flush()
+}
+
+// Writes a puzzle as a MiniZinc model for constraint programming solvers: a grid of variables, one per
+// square, with a constraint fixing every clue, an alldifferent constraint for every region (each of
+// them named in a comment), a sum constraint for every killer cage, whose numbers are all different
+// too, a constraint for every pair of a pair constraint, one for the parity of every marked square, and
+// one for every sandwich clue, which finds the 1 and the highest number of its line and sums what lies
+// between them, and one for every arrow. The model prints the solved grid a row per line. Squares in no
+// region are fixed at 0.
+func writeMiniZinc(out io.Writer, puzzle [][]int, p coverProblem) error {
+
+	puzzleDim := len(puzzle)
+	square := func(cell solver.Cell) string {
+		return fmt.Sprintf("grid[%d,%d]", cell.Row+1, cell.Col+1)
+	}
+	squares := func(cells []solver.Cell) string {
+		names := make([]string, len(cells))
+		for i, cell := range cells {
+			names[i] = square(cell)
+		}
+		return "[" + strings.Join(names, ", ") + "]"
+	}
+
+	lowest, highest := 1, 0
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if p.maxNumbers[r][c] == 0 {
+				lowest = 0
+			}
+			if p.maxNumbers[r][c] > highest {
+				highest = p.maxNumbers[r][c]
+			}
+		}
+	}
+
+	w := bufio.NewWriter(out)
+	fmt.Fprintf(w, "%% A %dx%d sudoku\n", puzzleDim, puzzleDim)
+	w.WriteString("include \"alldifferent.mzn\";\n\n")
+	fmt.Fprintf(w, "array[1..%d, 1..%d] of var %d..%d: grid;\n\n", puzzleDim, puzzleDim, lowest, highest)
+
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			cell := solver.Cell{Row: r, Col: c}
+			switch maxNumber := p.maxNumbers[r][c]; {
+			case puzzle[r][c] > 0:
+				fmt.Fprintf(w, "constraint %s = %d;\n", square(cell), puzzle[r][c])
+			case maxNumber == 0:
+				fmt.Fprintf(w, "constraint %s = 0;\n", square(cell))
+			case maxNumber < highest || lowest == 0:
+				fmt.Fprintf(w, "constraint %s in 1..%d;\n", square(cell), maxNumber)
+			}
+		}
+	}
+	w.WriteString("\n")
+
+	for i, region := range p.regions {
+		fmt.Fprintf(w, "constraint alldifferent(%s); %% %s\n", squares(region), p.names[i])
+	}
+	for i, c := range p.cages {
+		fmt.Fprintf(w, "constraint sum(%s) = %d /\\ alldifferent(%s); %% cage%d\n", squares(c.Cells), c.Sum, squares(c.Cells), i+1)
+	}
+	for _, constraint := range p.pairs {
+		fmt.Fprintf(w, "%% %s\n", constraint.Kind)
+		for _, pair := range constraint.Pairs {
+			switch constraint.Rule {
+			case solver.PairsDiffer:
+				fmt.Fprintf(w, "constraint %s != %s;\n", square(pair[0]), square(pair[1]))
+			case solver.PairsNotConsecutive:
+				fmt.Fprintf(w, "constraint abs(%s - %s) != 1;\n", square(pair[0]), square(pair[1]))
+			case solver.PairsConsecutive:
+				fmt.Fprintf(w, "constraint abs(%s - %s) = 1;\n", square(pair[0]), square(pair[1]))
+			case solver.PairsGreater:
+				fmt.Fprintf(w, "constraint %s > %s;\n", square(pair[0]), square(pair[1]))
+			}
+		}
+	}
+	for _, clue := range p.sandwiches {
+		// a and b are where the 1 and the highest number are in the line
+		line := fmt.Sprintf("row(grid, %d)", clue.Index)
+		if clue.Kind == "column" {
+			line = fmt.Sprintf("col(grid, %d)", clue.Index)
+		}
+		fmt.Fprintf(w, "constraint let { var 1..%d: a; var 1..%d: b } in %s[a] = 1 /\\ %s[b] = %d /\\ sum(i in 1..%d)(bool2int(i > min(a, b) /\\ i < max(a, b)) * %s[i]) = %d; %% sandwich %s%d\n",
+			puzzleDim, puzzleDim, line, line, puzzleDim, puzzleDim, line, clue.Sum, clue.Kind, clue.Index)
+	}
+	for i, a := range p.arrows {
+		fmt.Fprintf(w, "constraint %s = sum(%s); %% arrow%d\n", square(a.Circle), squares(a.Shaft), i+1)
+	}
+	if p.parities != nil {
+		w.WriteString("% parity\n")
+		for r := range p.parities {
+			for c, parity := range p.parities[r] {
+				if parity >= 0 {
+					fmt.Fprintf(w, "constraint %s mod 2 = %d;\n", square(solver.Cell{Row: r, Col: c}), parity)
+				}
+			}
+		}
+	}
+
+	w.WriteString("\nsolve satisfy;\n\n")
+	fmt.Fprintf(w, "output [show(grid[r,c]) ++ if c == %d then \"\\n\" else \" \" endif | r, c in 1..%d];\n", puzzleDim, puzzleDim)
+
+	return w.Flush()
+}
+
+// Reads a model written by a SAT solver for a puzzle exported as CNF back into a grid. Both the
+// competition format (an s line saying whether it was satisfiable, and v lines of literals) and
+// MiniSat's (SAT or UNSAT, then a line of literals) are read; c lines are comments. Squares in no region
+// are left as they are in the puzzle. Returns ErrNoSolution when the solver found the puzzle unsatisfiable.
+func readSATModel(r io.Reader, puzzle [][]int, p coverProblem) (grid [][]int, e error) {
+
+	puzzleDim := len(puzzle)
+	grid = solver.CopyPuzzle(puzzle)
+	for row := range grid {
+		for c := range grid[row] {
+			if p.maxNumbers[row][c] > 0 {
+				grid[row][c] = 0
+			}
+		}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || fields[0] == "c" {
+			continue
+		}
+		if fields[0] == "s" || fields[0] == "v" {
+			fields = fields[1:]
+		}
+		for _, field := range fields {
+			switch field {
+			case "SAT", "SATISFIABLE":
+				continue
+			case "UNSAT", "UNSATISFIABLE":
+				return nil, ErrNoSolution
+			}
+			literal, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, puzzleErrorf("invalid literal %q in the SAT model", field)
+			}
+			if literal <= 0 {
+				continue
+			}
+			if literal > puzzleDim*puzzleDim*puzzleDim {
+				return nil, puzzleErrorf("the SAT model's variable %d is out of range for a %dx%d puzzle", literal, puzzleDim, puzzleDim)
+			}
+			square, number := (literal-1)/puzzleDim, (literal-1)%puzzleDim+1
+			row, c := square/puzzleDim, square%puzzleDim
+			// Squares in no region, and numbers too big for their squares, are left free by the CNF
+			if number > p.maxNumbers[row][c] {
+				continue
+			}
+			if grid[row][c] != 0 && grid[row][c] != number {
+				return nil, puzzleErrorf("the SAT model puts both %d and %d in %s", grid[row][c], number, solver.CellName(solver.Cell{Row: row, Col: c}))
+			}
+			grid[row][c] = number
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, inputError(err)
+	}
+
+	for row := range grid {
+		for c := range grid[row] {
+			if grid[row][c] == 0 && p.maxNumbers[row][c] > 0 {
+				return nil, puzzleErrorf("the SAT model leaves %s empty", solver.CellName(solver.Cell{Row: row, Col: c}))
+			}
+		}
+	}
+
+	return grid, nil
+}
+
+// The export subcommand. Writes a puzzle as a SAT problem in DIMACS CNF, an exact cover problem or a
+// MiniZinc model, for external solvers, research tools and teaching, or with -model reads a SAT solver's
+// model for the exported CNF back into the solved grid and checks it against the puzzle.
+func exportCommand(args []string) error {
+
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm or ss). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "", "The file containing the puzzle (- reads standard input)")
+	linePtr := flags.Int("l", 1, "The line of the puzzle in the file")
+	formatPtr := flags.String("to", "cnf", "The problem to write the puzzle as (cnf for DIMACS CNF, exact-cover, or minizinc)")
+	modelPtr := flags.String("model", "", "A SAT solver's model for the puzzle exported as CNF, to read back into the solved grid instead of exporting (- reads standard input)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	puzzleLine, lineErr := checkPositiveInt("l", *linePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *filePtr == "" {
+		return flagErrorf("a puzzle must be given with -f")
+	}
+	if *formatPtr != "cnf" && *formatPtr != "exact-cover" && *formatPtr != "minizinc" {
+		return flagErrorf("unknown export format %q for -to (expected cnf, exact-cover or minizinc)", *formatPtr)
+	}
+	if *modelPtr == "-" && *filePtr == "-" {
+		return flagErrorf("the puzzle and the model can't both be read from standard input")
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	puzzle, regionMap, cages, extra, err := readPuzzle(inFile, *inputModePtr, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	if regionMap == nil && solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	var constraints []solver.Constraint
+	if *inputModePtr == "samurai" {
+		constraints = append(samuraiConstraints(blockXDim), variant...)
+	} else {
+		constraints = solver.PuzzleConstraints(len(puzzle), regionMap, append(variant, extra...), cages)
+	}
+
+	problem := newCoverProblem(puzzle, constraints)
+	if len(problem.cages) > 0 && (*formatPtr != "minizinc" || *modelPtr != "") {
+		return puzzleErrorf("killer cages can only be exported as a MiniZinc model")
+	}
+	if len(problem.sandwiches) > 0 && (*formatPtr != "minizinc" || *modelPtr != "") {
+		return puzzleErrorf("sandwich clues can only be exported as a MiniZinc model")
+	}
+	if len(problem.arrows) > 0 && (*formatPtr != "minizinc" || *modelPtr != "") {
+		return puzzleErrorf("arrows can only be exported as a MiniZinc model")
+	}
+	if len(problem.pairs) > 0 && *formatPtr == "exact-cover" && *modelPtr == "" {
+		return puzzleErrorf("the %s constraint can only be exported as CNF or a MiniZinc model", problem.pairs[0].Kind)
+	}
+
+	if *modelPtr == "" {
+		switch *formatPtr {
+		case "exact-cover":
+			return inputError(writeExactCover(os.Stdout, puzzle, problem))
+		case "minizinc":
+			return inputError(writeMiniZinc(os.Stdout, puzzle, problem))
+		}
+		return inputError(writeCNF(os.Stdout, puzzle, problem))
+	}
+
+	modelFile, err := openInput(*modelPtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer modelFile.Close()
+
+	grid, err := readSATModel(modelFile, puzzle, problem)
+	if errors.Is(err, ErrNoSolution) {
+		fmt.Println("The SAT solver found that the puzzle has no solution.")
+	}
+	if err != nil {
+		return err
+	}
+	for r := range puzzle {
+		for c, clue := range puzzle[r] {
+			if clue > 0 && grid[r][c] != clue {
+				return puzzleErrorf("the SAT model changes the clue in %s, so it isn't a model of this puzzle", solver.CellName(solver.Cell{Row: r, Col: c}))
+			}
+		}
+	}
+	if solver.CostFunction(grid, constraints) != 0 {
+		return puzzleErrorf("the SAT model's grid breaks the puzzle's rules, so it isn't a model of this puzzle")
+	}
+
+	fmt.Println(puzzleWriter{symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}.oneLine(grid))
+	return nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Two or more clues that break a constraint between them, so the puzzle can have no solution however it
+// is annealed. Kind names the region ("row", "block", "cage", ...) and index is its 1-based number.
+// Number is the number given more than once, or 0 when the clues break some other rule between them,
+// like adding up to more than a cage sum.
+type clueConflict struct {
+	kind   string
+	index  int
+	number int
+	cells  []solver.Cell
+}
+
+func (c clueConflict) String() string {
+
+	cells := make([]string, len(c.cells))
+	for i, cell := range c.cells {
+		cells[i] = fmt.Sprintf("r%dc%d", cell.Row+1, cell.Col+1)
+	}
+
+	if c.number == 0 && c.kind == "cage" {
+		return fmt.Sprintf("%s %d: the clues at %s add up to more than the cage sum", c.kind, c.index, strings.Join(cells, ", "))
+	}
+	if c.number == 0 {
+		return fmt.Sprintf("%s %d: the clues at %s break its rule", c.kind, c.index, strings.Join(cells, ", "))
+	}
+	return fmt.Sprintf("%s %d: %d is given at %s", c.kind, c.index, c.number, strings.Join(cells, ", "))
+}
+
+// Checks the clues of a puzzle before it is annealed and returns every conflict between them: a number
+// given twice in a region that must hold every number once, or in a killer cage, cages whose clues
+// already add up to more than their sum, pairs of clues that break the rule of a pair constraint like
+// anti-knight or thermometers, clues of the wrong parity for their squares, and lines and arrows whose
+// clues already rule out their sums. A puzzle with conflicting clues has no solution.
+func findClueConflicts(puzzle [][]int, constraints []solver.Constraint) (conflicts []clueConflict) {
+
+	for _, constraint := range constraints {
+		switch c := constraint.(type) {
+
+		case solver.UniqueConstraint:
+			for index, region := range c.Groups {
+				conflicts = append(conflicts, repeatedClues(puzzle, c.Kind, index+1, region)...)
+			}
+
+		case solver.CageConstraint:
+			for index, k := range c.Cages {
+				conflicts = append(conflicts, repeatedClues(puzzle, "cage", index+1, k.Cells)...)
+
+				sum := 0
+				var clues []solver.Cell
+				for _, cell := range k.Cells {
+					if number := puzzle[cell.Row][cell.Col]; number > 0 {
+						sum += number
+						clues = append(clues, cell)
+					}
+				}
+				if sum > k.Sum {
+					conflicts = append(conflicts, clueConflict{"cage", index + 1, 0, clues})
+				}
+			}
+
+		case solver.PairConstraint:
+			for index, pair := range c.Pairs {
+				number := puzzle[pair[0].Row][pair[0].Col]
+				if !c.Rule.Breaks(number, puzzle[pair[1].Row][pair[1].Col]) {
+					continue
+				}
+				if c.Rule != solver.PairsDiffer {
+					number = 0
+				}
+				conflicts = append(conflicts, clueConflict{c.Kind + " pair", index + 1, number, pair[:]})
+			}
+
+		case solver.ParityConstraint:
+			for index, v := range c.Violations(puzzle) {
+				conflicts = append(conflicts, clueConflict{"parity", index + 1, 0, []solver.Cell{v.Cell}})
+			}
+
+		case solver.SandwichConstraint:
+			clues, cells := c.Conflicts(puzzle)
+			for i, clue := range clues {
+				conflicts = append(conflicts, clueConflict{"sandwich " + clue.Kind, clue.Index, 0, cells[i]})
+			}
+
+		case solver.ArrowConstraint:
+			indexes, cells := c.Conflicts(puzzle)
+			for i, index := range indexes {
+				conflicts = append(conflicts, clueConflict{"arrow", index, 0, cells[i]})
+			}
+		}
+	}
+
+	return conflicts
+}
+
+// Returns a conflict for every number given as a clue more than once in the region, in the order the
+// numbers first appear.
+func repeatedClues(puzzle [][]int, kind string, index int, region []solver.Cell) (conflicts []clueConflict) {
+
+	cellsByNumber := make(map[int][]solver.Cell)
+	var numbers []int
+
+	for _, cell := range region {
+		if number := puzzle[cell.Row][cell.Col]; number > 0 {
+			if cellsByNumber[number] == nil {
+				numbers = append(numbers, number)
+			}
+			cellsByNumber[number] = append(cellsByNumber[number], cell)
+		}
+	}
+
+	for _, number := range numbers {
+		if cells := cellsByNumber[number]; len(cells) > 1 {
+			conflicts = append(conflicts, clueConflict{kind, index, number, cells})
+		}
+	}
+
+	return conflicts
+}
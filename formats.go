@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns the input mode matching a puzzle file's extension, for the interchange formats of popular
+// sudoku programs: .sdk (SadMan Sudoku) and .ss (Simple Sudoku) grid files and .sdm collections of
+// one-line puzzles, along with .ndjson and .jsonl streams of JSON puzzles. Any other file is read in the
+// one-line format.
+func inputModeForFile(filename string) string {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".sdk":
+		return "sdk"
+	case ".ss":
+		return "ss"
+	case ".sdm":
+		return "sdm"
+	case ".ndjson", ".jsonl":
+		return "ndjson"
+	case ".json":
+		return "json"
+	}
+
+	return "one-line"
+}
+
+// Read in a puzzle laid out as a grid over several lines, as used by .sdk and .ss files. Each puzzle row
+// is a line of squares; the '|' and '!' characters and spaces between blocks are ignored, as are blank
+// lines, separator lines made of '-', '+', '!' and '|', '#' comments and '[Section]' headers. Empty
+// squares may be written as '.', '0', 'X' or the given emptyValue. Files holding several grids one after
+// another are supported, and the selected puzzle is counted in grids rather than lines.
+func readInGrid(r io.Reader, puzzleNumber int, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, e error) {
+
+	puzzleDim := blockXDim * blockYDim
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	var rows []string
+
+	for scanner.Scan() {
+
+		text := strings.TrimSpace(scanner.Text())
+
+		if text == "" || strings.HasPrefix(text, "#") || strings.HasPrefix(text, "[") || strings.Trim(text, "-+!|") == "" {
+			continue
+		}
+
+		rows = append(rows, strings.NewReplacer("|", "", "!", "", " ", "").Replace(text))
+	}
+
+	if e = inputError(scanner.Err()); e != nil {
+		return nil, e
+	}
+
+	// Every puzzleDim rows make up one puzzle, and puzzles are counted from 1 (more user friendly)
+	first := (puzzleNumber - 1) * puzzleDim
+	if first < 0 || first >= len(rows) {
+		return nil, puzzleErrorf("there is no puzzle %d in the file, which holds %d grid rows", puzzleNumber, len(rows))
+	}
+	if first+puzzleDim > len(rows) {
+		return nil, puzzleErrorf("the grid for puzzle %d has %d rows but needs %d", puzzleNumber, len(rows)-first, puzzleDim)
+	}
+	rows = rows[first : first+puzzleDim]
+
+	// Normalise the empty squares and reuse the one-line reader on the joined rows
+	for i, row := range rows {
+		squares := strings.Split(row, "")
+		if len(squares) != puzzleDim {
+			return nil, puzzleErrorf("row %d of puzzle %d has %d squares but needs %d", i+1, puzzleNumber, len(squares), puzzleDim)
+		}
+		for j, square := range squares {
+			if _, isSymbol := symbolValue(symbols, square); isSymbol && symbols != "" {
+				continue
+			}
+			if square == "." || square == "0" || square == "X" || square == "x" || square == emptyValue {
+				squares[j] = "."
+			}
+		}
+		rows[i] = strings.Join(squares, "")
+	}
+
+	return readInOneLine(strings.NewReader(strings.Join(rows, "")), 1, "", ".", symbols, blockXDim, blockYDim)
+}
+
+// Read in a puzzle from the selected line that holds the puzzle in the one-line format followed by extras
+// separated by semicolons, like the cages of killer puzzles and the markers of consecutive ones. Returns
+// the puzzle and its extras, leaving out empty ones, or an error if the line is missing.
+func readInOneLineExtras(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, extras []string, e error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanPuzzleLines)
+
+	// Start puzzle at line 1 (more user friendly)
+	for lineCounter := 1; scanner.Scan(); lineCounter++ {
+		if line != lineCounter {
+			continue
+		}
+
+		parts := strings.Split(scanner.Text(), ";")
+		puzzle, e = readInOneLine(strings.NewReader(parts[0]), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if e != nil {
+			return nil, nil, e
+		}
+		for _, extra := range parts[1:] {
+			if strings.TrimSpace(extra) != "" {
+				extras = append(extras, extra)
+			}
+		}
+
+		return puzzle, extras, nil
+	}
+
+	if e = inputError(scanner.Err()); e != nil {
+		return nil, nil, e
+	}
+
+	return nil, nil, puzzleErrorf("there is no line %d in the input", line)
+}
+
+// Reads the selected puzzle in any of the input modes used for solving a single puzzle. Along with the
+// puzzle it returns the region map of jigsaw and samurai puzzles, the cages of killer puzzles and the
+// constraints the puzzle adds to those of its variant, like the markers of consecutive puzzles, which are
+// nil for the other modes.
+func readPuzzle(r io.Reader, mode string, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, regionMap [][]int, cages []solver.Cage, extra []solver.Constraint, e error) {
+
+	switch mode {
+	case "one-line", "sdm":
+		// Read the file into an array
+		puzzle, e = readInOneLine(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	case "sdk", "ss":
+		// Read the grid laid out over several lines
+		puzzle, e = readInGrid(r, line, emptyValue, symbols, blockXDim, blockYDim)
+	case "killer":
+		// Read the puzzle and its cages
+		puzzle, cages, e = readInKiller(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	case "consecutive":
+		// Read the puzzle and the markers between its consecutive neighbours
+		var markers [][2]solver.Cell
+		puzzle, markers, e = readInConsecutive(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		extra = markerConstraints(blockXDim*blockYDim, markers)
+	case "json":
+		// Read the puzzle and its markings from a line of JSON
+		puzzle, extra, e = readInJSON(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	case "jigsaw":
+		// Read the puzzle and the irregular regions on the line after it
+		puzzle, regionMap, e = readInJigsaw(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	case "samurai":
+		// Read the five overlapping grids from consecutive lines
+		puzzle, e = readInSamurai(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if e == nil {
+			regionMap = samuraiRegionMap(blockXDim)
+		}
+	default:
+		e = flagErrorf("no appropriate input mode for the puzzle was entered (%q)", mode)
+	}
+
+	if e != nil {
+		return nil, nil, nil, nil, e
+	}
+
+	// Every mode ends up here, so none can hand back a missing puzzle as though it were read
+	if puzzle == nil {
+		return nil, nil, nil, nil, puzzleErrorf("there is no puzzle at line %d in the input", line)
+	}
+
+	return puzzle, regionMap, cages, extra, nil
+}
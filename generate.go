@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Counts the solutions of a puzzle by backtracking, stopping once limit are found, so a limit of 2 tells
+// whether a puzzle's solution is unique.
+func countSolutions(puzzle [][]int, constraints []solver.Constraint, limit int) (count int) {
+
+	solver.Backtrack(puzzle, constraints, func(solution [][]int) bool {
+		count++
+		return count >= limit
+	}, nil)
+
+	return count
+}
+
+// The symmetries -symmetry can keep the clues of a generated puzzle in, by name, each naming one of
+// clueSymmetries. None leaves the clues in any pattern.
+var generatorSymmetries = map[string]string{
+	"none":          "",
+	"rotational":    "180° rotation",
+	"quarter-turn":  "90° rotation",
+	"horizontal":    "horizontal mirror",
+	"vertical":      "vertical mirror",
+	"diagonal":      "diagonal mirror",
+	"anti-diagonal": "anti-diagonal mirror",
+}
+
+// Returns the move of the clue symmetry -symmetry names (see generatorSymmetries), which is nil for none.
+func generatorSymmetry(name string) (move func(r int, c int, n int) (int, int), e error) {
+
+	symmetry, ok := generatorSymmetries[name]
+	if !ok {
+		names := make([]string, 0, len(generatorSymmetries))
+		for name := range generatorSymmetries {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, flagErrorf("unknown symmetry %q for -symmetry (expected one of %s)", name, strings.Join(names, ", "))
+	}
+	for _, s := range clueSymmetries {
+		if s.name == symmetry {
+			return s.move, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// Parses the value of the -difficulty flag: a grade of rateDifficulty, or a band of them from the easiest
+// to the hardest, eg. medium-expert. Returns the band as indexes in difficulties.
+func parseDifficultyBand(text string) (lowest int, highest int, e error) {
+
+	low, high, isBand := strings.Cut(text, "-")
+	if !isBand {
+		high = low
+	}
+	lowest, highest = difficultyRank(strings.TrimSpace(low)), difficultyRank(strings.TrimSpace(high))
+	if lowest < 0 || highest < 0 {
+		return 0, 0, flagErrorf("invalid value %q for -difficulty: expected a grade (%s) or a band of them, eg. medium-expert", text, strings.Join(difficulties, ", "))
+	}
+	if lowest > highest {
+		return 0, 0, flagErrorf("invalid value %q for -difficulty: the easier grade comes first", text)
+	}
+
+	return lowest, highest, nil
+}
+
+// Returns the name of the band of grades from lowest to highest (indexes in difficulties), as
+// -difficulty takes it.
+func difficultyBandName(lowest int, highest int) string {
+
+	if lowest == highest {
+		return difficulties[lowest]
+	}
+
+	return difficulties[lowest] + "-" + difficulties[highest]
+}
+
+// The annealers that fill in the grids of generated puzzles. The grid annealed from a seed depends on how
+// many annealers there are, so unlike a solve's it isn't one per CPU, which would have a seed generate
+// different puzzles on different machines.
+const generatorAnnealers = 4
+
+// Generates puzzles as generatePuzzle does until one is rated in the band of grades from lowest to
+// highest (indexes in difficulties), since puzzles generated the same way vary a lot in how hard they
+// are, and gives up after the given number of attempts. Returns the puzzle, its solution and its grade.
+func generateInBand(puzzleDim int, constraints []solver.Constraint, minClues int, symmetry func(r int, c int, n int) (int, int), lowest int, highest int, attempts int, rng *rand.Rand) (puzzle [][]int, solution [][]int, grade string, e error) {
+
+	for attempt := 1; ; attempt++ {
+		if puzzle, solution, e = generatePuzzle(puzzleDim, constraints, minClues, symmetry, rng); e != nil {
+			return nil, nil, "", e
+		}
+		grade, _ = rateDifficulty(puzzle, constraints)
+		if rank := difficultyRank(grade); rank >= lowest && rank <= highest {
+			return puzzle, solution, grade, nil
+		}
+		if attempt >= attempts {
+			return nil, nil, "", fmt.Errorf("none of the %d puzzles generated was rated %s (raise -attempts, or try another -clues or -symmetry)", attempt, difficultyBandName(lowest, highest))
+		}
+	}
+}
+
+// Generates a puzzle with a unique solution: a full grid is annealed from an empty puzzle, then its
+// squares are emptied in a random order, keeping every square whose removal would let the puzzle have a
+// second solution, until no more can go or only minClues clues are left. With a symmetry (see
+// removeClues) the clues are left in its pattern. The same rng generates the same puzzle on any
+// machine. Returns the puzzle and its solution.
+func generatePuzzle(puzzleDim int, constraints []solver.Constraint, minClues int, symmetry func(r int, c int, n int) (int, int), rng *rand.Rand) (puzzle [][]int, solution [][]int, e error) {
+
+	empty := make([][]int, puzzleDim)
+	for r := range empty {
+		empty[r] = make([]int, puzzleDim)
+	}
+
+	for solved := false; !solved; {
+		var err error
+		solution, solved, _, err = solver.Solve(empty, constraints, solver.Options{Annealers: generatorAnnealers, Seed: rng.Int63()})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return removeClues(solution, constraints, minClues, symmetry, rng), solution, nil
+}
+
+// Empties the clues of a puzzle with a unique solution in a random order, keeping every clue whose
+// removal would let the puzzle have a second solution, until no more can go or only minClues clues are
+// left. With a symmetry (as one of clueSymmetries moves a square) each clue is emptied together with the
+// clues the symmetry moves it to, or kept with them, so a symmetric pattern of clues stays symmetric.
+// Returns the puzzle left, which has the same unique solution; the puzzle given isn't changed.
+func removeClues(puzzle [][]int, constraints []solver.Constraint, minClues int, symmetry func(r int, c int, n int) (int, int), rng *rand.Rand) [][]int {
+
+	puzzleDim := len(puzzle)
+	puzzle = solver.CopyPuzzle(puzzle)
+	clues := 0
+	for r := range puzzle {
+		for _, number := range puzzle[r] {
+			if number > 0 {
+				clues++
+			}
+		}
+	}
+
+	for _, square := range rng.Perm(puzzleDim * puzzleDim) {
+		if clues <= minClues {
+			break
+		}
+		// The squares the symmetry moves the square to, and on, until it comes back round
+		orbit := []solver.Cell{{Row: square / puzzleDim, Col: square % puzzleDim}}
+		for symmetry != nil {
+			r, c := symmetry(orbit[len(orbit)-1].Row, orbit[len(orbit)-1].Col, puzzleDim)
+			if (solver.Cell{Row: r, Col: c}) == orbit[0] {
+				break
+			}
+			orbit = append(orbit, solver.Cell{Row: r, Col: c})
+		}
+		if clues-len(orbit) < minClues {
+			continue
+		}
+
+		numbers := make([]int, len(orbit))
+		for i, cell := range orbit {
+			numbers[i] = puzzle[cell.Row][cell.Col]
+		}
+		if numbers[0] <= 0 {
+			continue
+		}
+		for _, cell := range orbit {
+			puzzle[cell.Row][cell.Col] = 0
+		}
+		if countSolutions(puzzle, constraints, 2) == 1 {
+			clues -= len(orbit)
+		} else {
+			for i, cell := range orbit {
+				puzzle[cell.Row][cell.Col] = numbers[i]
+			}
+		}
+	}
+
+	return puzzle
+}
+
+// The generate subcommand. Generates puzzles with a unique solution and writes them out, one per line
+// in the one-line format unless -to says otherwise.
+func generateCommand(args []string) error {
+
+	flags := flag.NewFlagSet("generate", flag.ContinueOnError)
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	countPtr := flags.String("n", "1", "The number of puzzles to generate")
+	cluesPtr := flags.Int("clues", 0, "Stop emptying squares once only this many clues are left (0 empties as many as keep the solution unique)")
+	difficultyPtr := flags.String("difficulty", "", "Only write puzzles rated this grade by rate ("+strings.Join(difficulties, ", ")+"), or in a band of grades, eg. medium-expert, generating again until one is")
+	attemptsPtr := flags.Int("attempts", 100, "The most puzzles generated for each one written under -difficulty before giving up")
+	symmetryPtr := flags.String("symmetry", "none", "The symmetry the pattern of clues keeps (none, rotational, quarter-turn, horizontal, vertical, diagonal or anti-diagonal)")
+	seedPtr := flags.Int64("seed", 0, "The seed the puzzles are generated with, for generating the same ones again on any machine (0 picks one at random)")
+	formatPtr := flags.String("to", "one-line", "The output format ("+outputFormatNames+")")
+	delimiterPtr := flags.String("del", "", "The delimeter written between squares")
+	emptyValuePtr := flags.String("e", ".", "The character written for empty squares")
+	symbolsPtr := flags.String("symbols", "", "The symbols written for the numbers 1, 2, 3... Defaults to 1-9 then A-Z for puzzles bigger than 9x9 when squares aren't delimited")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	count, countErr := parsePositiveInt("n", *countPtr)
+
+	for _, err := range []error{dimErr, countErr} {
+		if err != nil {
+			return err
+		}
+	}
+	puzzleDim := blockXDim * blockYDim
+
+	if *cluesPtr < 0 || *cluesPtr > puzzleDim*puzzleDim {
+		return flagErrorf("invalid value %d for -clues: must be between 0 and %d", *cluesPtr, puzzleDim*puzzleDim)
+	}
+	if err := checkOutputFormat("to", *formatPtr); err != nil {
+		return err
+	}
+	symmetry, err := generatorSymmetry(*symmetryPtr)
+	if err != nil {
+		return err
+	}
+	lowest, highest := 0, len(difficulties)-1
+	if *difficultyPtr != "" {
+		if lowest, highest, err = parseDifficultyBand(*difficultyPtr); err != nil {
+			return err
+		}
+	}
+	if *attemptsPtr < 1 {
+		return flagErrorf("invalid value %d for -attempts: must be at least 1", *attemptsPtr)
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, puzzleDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+	constraints := solver.PuzzleConstraints(puzzleDim, regionMap, variant, nil)
+
+	seed := *seedPtr
+	if seed == 0 {
+		seed = solver.FreshSeed()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	writer := puzzleWriter{format: *formatPtr, blockXDim: blockXDim, blockYDim: blockYDim, symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if err := writer.writeHeader(out); err != nil {
+		return inputError(err)
+	}
+
+	for i := 0; i < count; i++ {
+		var puzzle [][]int
+		if *difficultyPtr == "" {
+			puzzle, _, err = generatePuzzle(puzzleDim, constraints, *cluesPtr, symmetry, rng)
+		} else {
+			puzzle, _, _, err = generateInBand(puzzleDim, constraints, *cluesPtr, symmetry, lowest, highest, *attemptsPtr, rng)
+		}
+		if err != nil {
+			return err
+		}
+		if err := writer.write(out, puzzle); err != nil {
+			return inputError(err)
+		}
+		out.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"math/rand"
+	"runtime"
+	"testing"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Runs generate with GOMAXPROCS set to procs, putting it back afterwards.
+func withProcs(procs int, generate func() string) string {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+	return generate()
+}
+
+func TestGeneratePuzzleIsTheSameOnAnyNumberOfCPUs(t *testing.T) {
+
+	constraints := solver.PuzzleConstraints(9, solver.BlockRegionMap(3, 3), nil, nil)
+	generate := func() string {
+		puzzle, _, err := generatePuzzle(9, constraints, 0, nil, rand.New(rand.NewSource(1)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return puzzleWriter{emptyValue: "."}.oneLine(puzzle)
+	}
+
+	if few, many := withProcs(2, generate), withProcs(8, generate); few != many {
+		t.Errorf("the same seed generated %s with 2 CPUs but %s with 8", few, many)
+	}
+}
+
+func TestDailyPuzzleIsTheSameOnAnyNumberOfCPUs(t *testing.T) {
+
+	generate := func() string {
+		daily, err := generateDailyPuzzle("2026-01-31", "easy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return daily.Puzzle
+	}
+
+	if few, many := withProcs(2, generate), withProcs(8, generate); few != many {
+		t.Errorf("the daily puzzle was %s with 2 CPUs but %s with 8", few, many)
+	}
+}
@@ -0,0 +1,260 @@
+package main
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+
+	"github.com/evjrob/sudoku-annealing/proto/solverpb"
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The metadata key gRPC clients send their API token in: the header of the HTTP API, lower-cased as gRPC
+// metadata keys are.
+var grpcTokenKey = strings.ToLower(tokenHeader)
+
+// The gRPC API of the solve server (see proto/solver.proto). It solves, generates and rates puzzles just
+// as the HTTP API does, under the same API tokens, rate limit and caps.
+type grpcSolver struct {
+	solverpb.UnimplementedSolverServer
+	server *solveServer
+}
+
+// The caller of a gRPC method: the API token it was sent with (nil when the server has none) and the
+// client it is rate limited and capped as (see requestClient).
+type grpcCall struct {
+	token  *apiToken
+	client string
+}
+
+// The key a call's grpcCall is kept under in its context.
+type grpcCallKey struct{}
+
+// Returns a gRPC server of the solve server's API, whose calls are turned away like the requests of the
+// HTTP API (see limited) when they have no API token or are over the rate limit.
+func newGRPCServer(s *solveServer) *grpc.Server {
+
+	g := grpc.NewServer(
+		grpc.MaxRecvMsgSize(maxRequestBytes),
+		grpc.UnaryInterceptor(func(ctx context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			ctx, err := s.admitCall(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, request)
+		}),
+		grpc.StreamInterceptor(func(server interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			ctx, err := s.admitCall(stream.Context())
+			if err != nil {
+				return err
+			}
+			return handler(server, admittedStream{stream, ctx})
+		}),
+	)
+	solverpb.RegisterSolverServer(g, &grpcSolver{server: s})
+
+	return g
+}
+
+// A server stream whose context carries the grpcCall of its caller.
+type admittedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (a admittedStream) Context() context.Context {
+	return a.ctx
+}
+
+// Authenticates and rate limits a gRPC call, returning its context with the grpcCall of its caller added,
+// or the status to turn it away with.
+func (s *solveServer) admitCall(ctx context.Context) (context.Context, error) {
+
+	var token *apiToken
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if sent := md.Get(grpcTokenKey); len(sent) > 0 {
+			token = s.findToken(sent[0])
+		}
+	}
+	if len(s.tokens) > 0 {
+		if token == nil {
+			s.metrics.unauthorized()
+			return nil, status.Error(codes.Unauthenticated, "this server needs one of its API tokens in the "+grpcTokenKey+" metadata")
+		}
+		s.metrics.tokenRequest(token.id())
+	}
+
+	call := grpcCall{token: token}
+	if token != nil {
+		call.client = "token " + token.Name
+	} else if p, ok := peer.FromContext(ctx); ok {
+		call.client = p.Addr.String()
+		if host, _, err := net.SplitHostPort(call.client); err == nil {
+			call.client = host
+		}
+	}
+
+	if s.limiter != nil {
+		if ok, wait := s.limiter.allow(call.client, time.Now()); !ok {
+			s.metrics.limited()
+			return nil, status.Errorf(codes.ResourceExhausted, "too many requests, so slow down (try again in %s)", wait.Round(time.Second))
+		}
+	}
+
+	return context.WithValue(ctx, grpcCallKey{}, call), nil
+}
+
+// Returns the caller of a gRPC method.
+func callOf(ctx context.Context) grpcCall {
+	call, _ := ctx.Value(grpcCallKey{}).(grpcCall)
+	return call
+}
+
+// Returns the status a request that couldn't be met is answered with.
+func grpcRequestError(err error) error {
+	return status.Error(codes.InvalidArgument, err.Error())
+}
+
+// Reads the puzzle of a solve request and counts a solve started by the caller against the server's
+// concurrency cap. The caller's solve is to be released from the cap once it finishes.
+func (g *grpcSolver) startSolve(ctx context.Context, request *solverpb.SolveRequest) (serverPuzzle, grpcCall, error) {
+
+	call := callOf(ctx)
+	p, err := g.server.readRequest(solveRequest{
+		Puzzle:         request.Puzzle,
+		Dim:            request.Dim,
+		Variant:        request.Variant,
+		Temperature:    request.Temperature,
+		CoolingRate:    request.CoolingRate,
+		Iterations:     int(request.Iterations),
+		Swaps:          int(request.Swaps),
+		Annealers:      int(request.Annealers),
+		TimeoutSeconds: request.TimeoutSeconds,
+	})
+	if err == nil {
+		p, err = call.token.capPuzzle(p)
+	}
+	if err != nil {
+		g.server.metrics.rejected()
+		return p, call, grpcRequestError(err)
+	}
+
+	if !g.server.concurrency.acquire(call.client) {
+		g.server.metrics.limited()
+		return p, call, status.Errorf(codes.ResourceExhausted, "you already have as many solves running as you may (%d), so wait for one to finish", g.server.concurrency.max)
+	}
+
+	return p, call, nil
+}
+
+// Returns the message of a solve's result.
+func solveResponseMessage(response solveResponse) *solverpb.SolveResponse {
+	return &solverpb.SolveResponse{
+		Solved:   response.Solved,
+		TimedOut: response.TimedOut,
+		Solution: response.Solution,
+		Cost:     response.Cost,
+		Seconds:  response.Seconds,
+		Cached:   response.Cached,
+	}
+}
+
+// Solves a puzzle, as POST /solve does. The solve stops when the call is cancelled.
+func (g *grpcSolver) SolvePuzzle(ctx context.Context, request *solverpb.SolveRequest) (*solverpb.SolveResponse, error) {
+
+	p, call, err := g.startSolve(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	defer g.server.concurrency.release(call.client)
+
+	return solveResponseMessage(g.server.solve(p, nil, ctx.Done())), nil
+}
+
+// Solves a puzzle, streaming its progress and then its result, as /solves/ID/events does. Like that
+// stream, a client falling behind the solve skips to its latest step, and the solve stops when the call
+// is cancelled.
+func (g *grpcSolver) StreamSolve(request *solverpb.SolveRequest, stream solverpb.Solver_StreamSolveServer) error {
+
+	ctx := stream.Context()
+	p, call, err := g.startSolve(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	job := &solveJob{changed: make(chan struct{})}
+	go func() {
+		writer := puzzleWriter{symbols: p.symbols, emptyValue: "."}
+		response := g.server.solve(p, func(progress solver.AnnealProgress) {
+			event := progressEvent{progress.Step, progress.Temperature, progress.BestCost, progress.AcceptanceRate, progress.Elapsed.Seconds(), writer.oneLine(progress.Candidate)}
+			job.update(func() { job.progress = &event })
+		}, ctx.Done())
+		job.update(func() { job.result = &response })
+		g.server.concurrency.release(call.client)
+	}()
+
+	var sent *progressEvent
+	for {
+		progress, result, changed := job.snapshot()
+		if progress != nil && progress != sent {
+			event := &solverpb.Progress{
+				Step:           int32(progress.Step),
+				Temperature:    progress.Temperature,
+				BestCost:       progress.BestCost,
+				AcceptanceRate: progress.AcceptanceRate,
+				ElapsedSeconds: progress.Elapsed,
+				Candidate:      progress.Candidate,
+			}
+			if err := stream.Send(&solverpb.SolveEvent{Event: &solverpb.SolveEvent_Progress{Progress: event}}); err != nil {
+				return err
+			}
+			sent = progress
+		}
+		if result != nil {
+			return stream.Send(&solverpb.SolveEvent{Event: &solverpb.SolveEvent_Result{Result: solveResponseMessage(*result)}})
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// Generates a puzzle, as POST /generate does.
+func (g *grpcSolver) GeneratePuzzle(ctx context.Context, request *solverpb.GenerateRequest) (*solverpb.GenerateResponse, error) {
+
+	response, err := g.server.generate(generateRequest{
+		Dim:        request.Dim,
+		Variant:    request.Variant,
+		Difficulty: request.Difficulty,
+		Clues:      int(request.Clues),
+		Symmetry:   request.Symmetry,
+		Attempts:   int(request.Attempts),
+		Seed:       request.Seed,
+	}, callOf(ctx).token)
+	if err != nil {
+		return nil, grpcRequestError(err)
+	}
+
+	return &solverpb.GenerateResponse{Puzzle: response.Puzzle, Solution: response.Solution, Difficulty: response.Difficulty, Seed: response.Seed}, nil
+}
+
+// Rates a puzzle, as POST /rate does.
+func (g *grpcSolver) RatePuzzle(ctx context.Context, request *solverpb.RateRequest) (*solverpb.RateResponse, error) {
+
+	response, err := g.server.rate(rateRequest{Puzzle: request.Puzzle, Dim: request.Dim, Variant: request.Variant}, callOf(ctx).token)
+	if err != nil {
+		return nil, grpcRequestError(err)
+	}
+
+	return &solverpb.RateResponse{Difficulty: response.Difficulty, Hardest: response.Hardest, Steps: int32(response.Steps)}, nil
+}
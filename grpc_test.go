@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/evjrob/sudoku-annealing/proto/solverpb"
+)
+
+// Serves the gRPC API of a solve server with the given API tokens in memory, returning a client of it.
+func testGRPCClient(t *testing.T, tokens []apiToken) solverpb.SolverClient {
+	t.Helper()
+
+	server := &solveServer{maxTimeout: 10 * time.Second, tokens: tokens, metrics: newServerMetrics()}
+	listener := bufconn.Listen(1 << 20)
+	grpcServer := newGRPCServer(server)
+	go grpcServer.Serve(listener)
+	t.Cleanup(grpcServer.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn", grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) { return listener.DialContext(ctx) }))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return solverpb.NewSolverClient(conn)
+}
+
+func TestGRPCSolvesGeneratesAndRatesPuzzles(t *testing.T) {
+
+	client := testGRPCClient(t, nil)
+	ctx := context.Background()
+
+	// The test solution with a few squares taken out solves in a cooling step or two
+	puzzle := "." + testSolution[1:20] + "..." + testSolution[23:60] + ".." + testSolution[62:]
+
+	solved, err := client.SolvePuzzle(ctx, &solverpb.SolveRequest{Puzzle: puzzle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !solved.Solved || solved.Solution != testSolution {
+		t.Errorf("SolvePuzzle answered %+v, not the solution", solved)
+	}
+
+	stream, err := client.StreamSolve(ctx, &solverpb.SolveRequest{Puzzle: testPuzzle, Iterations: 10, TimeoutSeconds: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var progress int
+	var result *solverpb.SolveResponse
+	for {
+		event, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if event.GetProgress() != nil {
+			progress++
+		}
+		if event.GetResult() != nil {
+			result = event.GetResult()
+		}
+	}
+	if progress == 0 || result == nil || len(result.Solution) != 81 {
+		t.Errorf("StreamSolve sent %d progress events and the result %+v", progress, result)
+	}
+
+	generated, err := client.GeneratePuzzle(ctx, &solverpb.GenerateRequest{Clues: 36, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rated, err := client.RatePuzzle(ctx, &solverpb.RateRequest{Puzzle: generated.Puzzle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rated.Difficulty != generated.Difficulty {
+		t.Errorf("GeneratePuzzle made a puzzle graded %q, which RatePuzzle rates %q", generated.Difficulty, rated.Difficulty)
+	}
+
+	if _, err := client.RatePuzzle(ctx, &solverpb.RateRequest{Puzzle: "41736"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("rating a puzzle that can't be read was answered with %v, not InvalidArgument", err)
+	}
+}
+
+func TestGRPCNeedsAToken(t *testing.T) {
+
+	client := testGRPCClient(t, []apiToken{{Name: "service", Token: "s3cret"}})
+	request := &solverpb.RateRequest{Puzzle: testPuzzle}
+
+	if _, err := client.RatePuzzle(context.Background(), request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("a call without a token was answered with %v, not Unauthenticated", err)
+	}
+	wrong := metadata.AppendToOutgoingContext(context.Background(), grpcTokenKey, "wrong")
+	if _, err := client.RatePuzzle(wrong, request); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("a call with the wrong token was answered with %v, not Unauthenticated", err)
+	}
+	right := metadata.AppendToOutgoingContext(context.Background(), grpcTokenKey, "s3cret")
+	if _, err := client.RatePuzzle(right, request); err != nil {
+		t.Errorf("a call with the token was answered with %v", err)
+	}
+
+	stream, err := client.StreamSolve(context.Background(), &solverpb.SolveRequest{Puzzle: testPuzzle})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("a stream without a token was answered with %v, not Unauthenticated", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"strings"
+)
+
+// The directives a puzzle file's header may hold, by the flag each one sets, eg. "#dim=3x4" for -d.
+var headerDirectives = map[string]string{
+	"dim":       "d",
+	"mode":      "m",
+	"variant":   "variant",
+	"symbols":   "symbols",
+	"empty":     "e",
+	"delimiter": "del",
+}
+
+// Reports whether a line of a puzzle file is a comment, starting with #.
+func isComment(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// A bufio.SplitFunc for puzzle files, splitting them into lines like bufio.ScanLines but skipping
+// comments, so puzzles are numbered (as by -l) without counting them.
+func scanPuzzleLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+
+	// The scanner stops at the end of the input when no line is returned, so every comment in the data
+	// is skipped here rather than one per call
+	for {
+		n, line, err := bufio.ScanLines(data[advance:], atEOF)
+		if err != nil || line == nil {
+			return advance, nil, err
+		}
+		advance += n
+		if !isComment(string(line)) {
+			return advance, line, nil
+		}
+	}
+}
+
+// Reads the directives in the header of a puzzle file: the comments before its first puzzle that are
+// written as #name=value, with a name from headerDirectives. Other comments are annotations, and are
+// skipped. Returns the value of each directive by the flag it sets.
+func readHeader(filename string) (settings map[string]string, e error) {
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+
+	settings = make(map[string]string)
+
+	scanner := bufio.NewScanner(inFile)
+	for scanner.Scan() {
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		if !isComment(text) {
+			break
+		}
+
+		separator := strings.Index(text, "=")
+		if separator < 0 {
+			continue
+		}
+		name := strings.TrimSpace(strings.TrimPrefix(text[:separator], "#"))
+		if flagName, ok := headerDirectives[name]; ok {
+			settings[flagName] = strings.TrimSpace(text[separator+1:])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, inputError(err)
+	}
+
+	return settings, nil
+}
+
+// Sets the flags of a flag set from the header directives of the puzzle file given to its -f flag, so a
+// collection of puzzles can carry its own dimensions, input mode and symbols. Flags set any other way
+// (on the command line, from the environment or from a config file) are left alone, and the header is
+// only read from a file named with -f, not standard input or a URL, since those can't be read twice.
+func applyHeader(flags *flag.FlagSet) error {
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	filename := flags.Lookup("f").Value.String()
+	if !given["f"] || filename == "" || filename == "-" || strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		return nil
+	}
+
+	settings, err := readHeader(filename)
+	if err != nil {
+		return err
+	}
+
+	for flagName, value := range settings {
+		if given[flagName] || flags.Lookup(flagName) == nil {
+			continue
+		}
+		if err := flags.Set(flagName, value); err != nil {
+			return flagErrorf("the header of %s: invalid value %q for -%s: %v", filename, value, flagName, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Reads a square given as r1c1 for one of the hint subcommand's flags, counting rows and columns from 1.
+func parseCell(name string, text string, puzzleDim int) (solver.Cell, error) {
+
+	var row, col int
+	if _, err := fmt.Sscanf(strings.ToLower(strings.TrimSpace(text)), "r%dc%d", &row, &col); err != nil || row < 1 || col < 1 || row > puzzleDim || col > puzzleDim {
+		return solver.Cell{}, flagErrorf("invalid value %q for -%s: must be a square like r3c4, with the row and column between 1 and %d", text, name, puzzleDim)
+	}
+
+	return solver.Cell{Row: row - 1, Col: col - 1}, nil
+}
+
+// The hint subcommand. Solves a puzzle, which may have squares filled in since it was set, and reveals
+// the number in a single empty square: one picked at random, the one asked for with -cell, or with
+// -forced the first square the logical solver can fill in, along with its reasoning.
+func hintCommand(args []string) error {
+
+	flags := flag.NewFlagSet("hint", flag.ContinueOnError)
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "", "The file containing the puzzle, with any squares filled in so far (- reads standard input)")
+	linePtr := flags.Int("l", 1, "The line of the puzzle in the file")
+	cellPtr := flags.String("cell", "", "The empty square to reveal, eg. r3c4. Picked at random when left out")
+	forcedPtr := flags.Bool("forced", false, "Reveal the first square that can be filled in by logic alone, and why, rather than a random one")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	puzzleLine, lineErr := checkPositiveInt("l", *linePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *filePtr == "" {
+		return flagErrorf("a puzzle must be given with -f")
+	}
+	if *forcedPtr && *cellPtr != "" {
+		return flagErrorf("-forced picks the square itself, so it can't be used with -cell")
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	puzzle, err := readPuzzleFile(*filePtr, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	constraints := solver.PuzzleConstraints(len(puzzle), regionMap, variant, nil)
+
+	if conflicts := findClueConflicts(puzzle, constraints); len(conflicts) > 0 {
+		for _, conflict := range conflicts {
+			fmt.Println(conflict)
+		}
+		return puzzleErrorf("the puzzle's clues conflict, so it has no solution")
+	}
+
+	var empty []solver.Cell
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if puzzle[r][c] == 0 {
+				empty = append(empty, solver.Cell{Row: r, Col: c})
+			}
+		}
+	}
+	if len(empty) == 0 {
+		return puzzleErrorf("the puzzle has no empty squares to give a hint for")
+	}
+
+	var cell solver.Cell
+	if *cellPtr != "" {
+		if cell, err = parseCell("cell", *cellPtr, len(puzzle)); err != nil {
+			return err
+		}
+		if puzzle[cell.Row][cell.Col] != 0 {
+			return flagErrorf("%s is already filled in", solver.CellName(cell))
+		}
+	} else {
+		cell = empty[rand.New(rand.NewSource(solver.FreshSeed())).Intn(len(empty))]
+	}
+
+	// The logical solver's first filled in square needs no annealing, and comes with its reasoning
+	if *forcedPtr {
+		steps, _, _ := solver.ExplainPuzzle(puzzle, constraints, symbols)
+		for _, step := range steps {
+			if step.Number > 0 {
+				fmt.Printf("%s is %s (%s: %s)\n", solver.CellName(step.Cell), solver.SymbolText(symbols, step.Number), step.Technique, step.Text)
+				return nil
+			}
+		}
+		fmt.Fprintln(os.Stderr, "No square can be filled in by logic alone, so the hint comes from annealing.")
+	}
+
+	solution, solved, _, err := solver.Solve(puzzle, constraints, solver.Options{})
+	if err != nil {
+		return err
+	}
+	if !solved {
+		fmt.Fprintln(os.Stderr, "No solution was found, so some of the squares filled in may be wrong.")
+		return ErrNoSolution
+	}
+
+	fmt.Printf("%s is %s\n", solver.CellName(cell), solver.SymbolText(symbols, solution[cell.Row][cell.Col]))
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns the named way of making the starting candidate solution, or nil for an empty name, which
+// leaves it to the search algorithm.
+func initialization(name string) (solver.Initializer, error) {
+
+	if name == "" {
+		return nil, nil
+	}
+
+	initialize, found := solver.Initializers[name]
+	if !found {
+		return nil, flagErrorf("unknown initialization %q for -init (expected one of %s)", name, initializationNames())
+	}
+
+	return initialize, nil
+}
+
+// Returns the names of all the initializations, sorted and comma separated for use in messages.
+func initializationNames() string {
+
+	names := make([]string, 0, len(solver.Initializers))
+	for name := range solver.Initializers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
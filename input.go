@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// How long to wait for a puzzle file to download when no other timeout is given.
+const defaultFetchTimeout = 30 * time.Second
+
+// Opens the named puzzle file for reading. The name "-" reads from standard input instead, and http://
+// or https:// URLs are downloaded, giving up after fetchTimeout. Names starting builtin: read the sample
+// puzzles built into the program (see openSample).
+func openInput(filename string, fetchTimeout time.Duration) (io.ReadCloser, error) {
+
+	if filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	if strings.HasPrefix(filename, builtinPrefix) {
+		return openSample(strings.TrimPrefix(filename, builtinPrefix))
+	}
+
+	if strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://") {
+		file, err := fetchInput(filename, fetchTimeout)
+		return file, inputError(err)
+	}
+
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, inputError(err)
+	}
+	return file, nil
+}
+
+// Downloads a puzzle file over HTTP(S). The timeout covers the whole download, including reading the
+// body, so the response is read in full before returning.
+func fetchInput(url string, timeout time.Duration) (io.ReadCloser, error) {
+
+	client := &http.Client{Timeout: timeout}
+
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s: %s", url, response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %v", url, err)
+	}
+
+	return io.NopCloser(strings.NewReader(string(body))), nil
+}
+
+// Reports whether standard input is being piped or redirected into the program rather than typed at a
+// terminal.
+func stdinIsPipe() bool {
+
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice == 0
+}
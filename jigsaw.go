@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Read in a jigsaw (irregular region) sudoku. The selected line holds the puzzle in the one-line format
+// and the line after it maps every cell, in the same order and with the same delimiter, to the ID of the
+// region it belongs to. IDs can be any symbols, eg:
+//
+//	3.......4..2.6.1...1.9.8.2...5...6...2.....1...9...8...8.3.4.6...4.1.9..5.......7
+//	111222333111222333111222333444555666444555666444555666777888999777888999777888999
+//
+// Every region must contain as many cells as there are rows in the puzzle.
+func readInJigsaw(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, regionMap [][]int, e error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanPuzzleLines)
+
+	// Start puzzle at line 1 (more user friendly)
+	lineCounter := 1
+
+	var puzzleText, regionText string
+	regionFound := false
+
+	for scanner.Scan() {
+
+		if line == lineCounter {
+			puzzleText = scanner.Text()
+		} else if line+1 == lineCounter {
+			regionText = scanner.Text()
+			regionFound = true
+		}
+
+		lineCounter++
+	}
+
+	if e = inputError(scanner.Err()); e != nil {
+		return nil, nil, e
+	}
+
+	if !regionFound {
+		return nil, nil, puzzleErrorf("no region map found on line %d after the puzzle", line+1)
+	}
+
+	puzzle, e = readInOneLine(strings.NewReader(puzzleText), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	regionMap, e = parseRegionMap(regionText, delimiter, blockXDim*blockYDim)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	return puzzle, regionMap, nil
+}
+
+// Parse a line of region IDs into a region map. Regions are numbered in the order their IDs first
+// appear, and each must cover exactly puzzleDim cells.
+func parseRegionMap(text string, delimiter string, puzzleDim int) (regionMap [][]int, e error) {
+
+	ids := strings.Split(text, delimiter)
+	if len(ids) < puzzleDim*puzzleDim {
+		return nil, puzzleErrorf("the region map has %d cells but the puzzle needs %d", len(ids), puzzleDim*puzzleDim)
+	}
+
+	regionNumbers := make(map[string]int)
+	regionSizes := make([]int, 0, puzzleDim)
+
+	regionMap = make([][]int, puzzleDim)
+
+	for i := 0; i < puzzleDim; i++ {
+		regionMap[i] = make([]int, puzzleDim)
+		for j := 0; j < puzzleDim; j++ {
+			id := ids[(i*puzzleDim)+j]
+
+			number, seen := regionNumbers[id]
+			if !seen {
+				number = len(regionSizes)
+				regionNumbers[id] = number
+				regionSizes = append(regionSizes, 0)
+			}
+
+			regionMap[i][j] = number
+			regionSizes[number]++
+		}
+	}
+
+	if len(regionSizes) != puzzleDim {
+		return nil, puzzleErrorf("the region map has %d regions but the puzzle needs %d", len(regionSizes), puzzleDim)
+	}
+
+	for id, number := range regionNumbers {
+		if regionSizes[number] != puzzleDim {
+			return nil, puzzleErrorf("region %q has %d cells but needs %d", id, regionSizes[number], puzzleDim)
+		}
+	}
+
+	return regionMap, nil
+}
@@ -0,0 +1,313 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// How long a job of the job queue is kept for after it finishes.
+const jobExpiry = 24 * time.Hour
+
+// The states a job of the job queue goes through: queued until a worker is free, running while it is
+// solved, then done, or cancelled when it is cancelled before it finishes.
+const (
+	jobQueued    = "queued"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobCancelled = "cancelled"
+)
+
+// A job of the job queue, as returned from GET /jobs/ID and kept in the -jobs-file: the request it was
+// posted with (and the name of the API token it was posted with, if any), its status, when it was
+// queued, started and finished, and its latest progress and result. The result of a job cancelled while
+// it ran holds the best candidate it reached.
+type queuedJob struct {
+	ID       string         `json:"id"`
+	Status   string         `json:"status"`
+	Request  solveRequest   `json:"request"`
+	Token    string         `json:"token,omitempty"`
+	Created  time.Time      `json:"created"`
+	Started  *time.Time     `json:"started,omitempty"`
+	Finished *time.Time     `json:"finished,omitempty"`
+	Progress *progressEvent `json:"progress,omitempty"`
+	Result   *solveResponse `json:"result,omitempty"`
+	puzzle   serverPuzzle
+	cancel   chan struct{}
+}
+
+// The job queue behind /jobs: long solves posted to it wait in a queue of bounded length for one of a
+// fixed number of workers, each of which solves one at a time for as long as maxTimeout. Jobs are kept in
+// memory and, when filename isn't empty, in a JSON file rewritten whenever one changes state, so the
+// queue carries on after a restart: any job that was queued or running then is queued again.
+type jobQueue struct {
+	server     *solveServer
+	maxTimeout time.Duration
+	filename   string
+	mutex      sync.Mutex
+	jobs       map[string]*queuedJob
+	pending    chan *queuedJob
+}
+
+// The error returned when a job is posted to a full queue.
+var errQueueFull = errors.New("the job queue is full, so try again later")
+
+// Returns a job queue of the given length with its workers started, reading in the jobs kept in the
+// file (unless filename is empty).
+func newJobQueue(server *solveServer, workers int, length int, maxTimeout time.Duration, filename string) (*jobQueue, error) {
+
+	q := &jobQueue{server: server, maxTimeout: maxTimeout, filename: filename, jobs: map[string]*queuedJob{}}
+
+	var kept []*queuedJob
+	if filename != "" {
+		data, err := os.ReadFile(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, inputError(err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &kept); err != nil {
+				return nil, inputError(fmt.Errorf("%s isn't a file of jobs: %v", filename, err))
+			}
+		}
+	}
+
+	// Jobs that were cut short by the restart start again from the beginning
+	var requeued []*queuedJob
+	for _, job := range kept {
+		if job.Status == jobQueued || job.Status == jobRunning {
+			p, err := server.readRequest(job.Request)
+			if err != nil {
+				slog.Warn("dropping a job that can no longer be read", "job", job.ID, "error", err)
+				continue
+			}
+			job.Status, job.Started, job.Progress, job.puzzle = jobQueued, nil, nil, q.withTimeout(p, job.Request, server.namedToken(job.Token))
+			requeued = append(requeued, job)
+		}
+		job.cancel = make(chan struct{})
+		q.jobs[job.ID] = job
+	}
+	sort.Slice(requeued, func(i, j int) bool { return requeued[i].Created.Before(requeued[j].Created) })
+
+	if len(requeued) > length {
+		length = len(requeued)
+	}
+	q.pending = make(chan *queuedJob, length)
+	for _, job := range requeued {
+		q.pending <- job
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+
+	return q, nil
+}
+
+// Returns the puzzle of a job with its timeout: as long as the request asks for, but no longer than the
+// queue's maxTimeout rather than the server's, nor than the token it was posted with allows.
+func (q *jobQueue) withTimeout(p serverPuzzle, request solveRequest, token *apiToken) serverPuzzle {
+
+	p.timeout = q.maxTimeout
+	if request.TimeoutSeconds > 0 && time.Duration(request.TimeoutSeconds*float64(time.Second)) < p.timeout {
+		p.timeout = time.Duration(request.TimeoutSeconds * float64(time.Second))
+	}
+	p.timeout = token.capTimeout(p.timeout)
+
+	return p
+}
+
+// Writes every job to the queue's file, if it has one, replacing the file whole so that it is never left
+// half written. Finished jobs past their expiry are dropped first. The queue's mutex must be held.
+func (q *jobQueue) save() {
+
+	for id, job := range q.jobs {
+		if job.Finished != nil && time.Since(*job.Finished) > jobExpiry {
+			delete(q.jobs, id)
+		}
+	}
+	if q.filename == "" {
+		return
+	}
+
+	jobs := make([]*queuedJob, 0, len(q.jobs))
+	for _, job := range q.jobs {
+		jobs = append(jobs, job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Created.Before(jobs[j].Created) })
+
+	data, err := json.MarshalIndent(jobs, "", "  ")
+	if err == nil {
+		err = os.WriteFile(q.filename+".tmp", data, 0o644)
+	}
+	if err == nil {
+		err = os.Rename(q.filename+".tmp", q.filename)
+	}
+	if err != nil {
+		slog.Error("saving the job queue", "file", q.filename, "error", err)
+	}
+}
+
+// Queues a solve posted with a token (nil when the server has none), returning a copy of its job, or
+// errQueueFull when the queue has no room for it.
+func (q *jobQueue) submit(request solveRequest, p serverPuzzle, token *apiToken) (queuedJob, error) {
+
+	job := &queuedJob{ID: newJobID(), Status: jobQueued, Request: request, Token: token.name(), Created: time.Now().UTC(), puzzle: q.withTimeout(p, request, token), cancel: make(chan struct{})}
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	select {
+	case q.pending <- job:
+	default:
+		return queuedJob{}, errQueueFull
+	}
+	q.jobs[job.ID] = job
+	q.save()
+
+	return *job, nil
+}
+
+// Returns a copy of a job posted with the named token, and whether there is one with the id.
+func (q *jobQueue) get(id string, token string) (queuedJob, bool) {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, found := q.jobs[id]
+	if !found || job.Token != token || job.Finished != nil && time.Since(*job.Finished) > jobExpiry {
+		return queuedJob{}, false
+	}
+
+	return *job, true
+}
+
+// Cancels a job: one still queued is cancelled straight away, one running is stopped after the cooling
+// step it is on, and one that has finished is deleted. Only the token a job was posted with may cancel
+// it. Returns a copy of the job, and whether there is one with the id.
+func (q *jobQueue) cancel(id string, token string) (queuedJob, bool) {
+
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	job, found := q.jobs[id]
+	if !found || job.Token != token {
+		return queuedJob{}, false
+	}
+
+	switch job.Status {
+	case jobQueued:
+		now := time.Now().UTC()
+		job.Status, job.Finished = jobCancelled, &now
+	case jobRunning:
+		select {
+		case <-job.cancel:
+		default:
+			close(job.cancel)
+		}
+	default:
+		delete(q.jobs, id)
+	}
+	q.save()
+
+	return *job, true
+}
+
+// Solves the jobs of the queue one at a time as they come, skipping any cancelled while they waited.
+func (q *jobQueue) work() {
+
+	for job := range q.pending {
+		q.mutex.Lock()
+		if job.Status != jobQueued {
+			q.mutex.Unlock()
+			continue
+		}
+		now := time.Now().UTC()
+		job.Status, job.Started = jobRunning, &now
+		q.save()
+		q.mutex.Unlock()
+
+		writer := puzzleWriter{symbols: job.puzzle.symbols, emptyValue: "."}
+		response := q.server.solve(job.puzzle, func(progress solver.AnnealProgress) {
+			event := progressEvent{progress.Step, progress.Temperature, progress.BestCost, progress.AcceptanceRate, progress.Elapsed.Seconds(), writer.oneLine(progress.Candidate)}
+			q.mutex.Lock()
+			job.Progress = &event
+			q.mutex.Unlock()
+		}, job.cancel)
+
+		q.mutex.Lock()
+		finished := time.Now().UTC()
+		job.Status, job.Result, job.Finished = jobDone, &response, &finished
+		select {
+		case <-job.cancel:
+			if !response.Solved {
+				job.Status = jobCancelled
+			}
+		default:
+		}
+		q.save()
+		q.mutex.Unlock()
+	}
+}
+
+// Handles the job queue: POST /jobs queues the puzzle in the JSON request body and returns its job, GET
+// /jobs/ID returns a job's status, latest progress and result, and DELETE /jobs/ID cancels it (or deletes
+// it once it has finished). When the server has API tokens, a job is only found by the token it was
+// posted with.
+func (q *jobQueue) handleJobs(w http.ResponseWriter, r *http.Request) {
+
+	id := strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs"), "/")
+
+	if id == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("jobs are queued by POSTing a puzzle"))
+			return
+		}
+		request, p, ok := q.server.readRequestBody(w, r)
+		if !ok {
+			return
+		}
+		job, err := q.submit(request, p, q.server.requestToken(r))
+		if err != nil {
+			w.Header().Set("Retry-After", "60")
+			writeJSONError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		w.Header().Set("Location", "/jobs/"+job.ID)
+		writeJSON(w, http.StatusAccepted, job)
+		return
+	}
+
+	var job queuedJob
+	var found bool
+	switch r.Method {
+	case http.MethodGet:
+		job, found = q.get(id, q.server.requestToken(r).name())
+	case http.MethodDelete:
+		job, found = q.cancel(id, q.server.requestToken(r).name())
+	default:
+		w.Header().Set("Allow", "GET, DELETE")
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("jobs are fetched with GET and cancelled with DELETE"))
+		return
+	}
+	if !found {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("there is no job %q (finished jobs are kept for %s)", id, jobExpiry))
+		return
+	}
+
+	// A running job is only cancelled once its solve stops, so the cancellation is accepted for now
+	status := http.StatusOK
+	if r.Method == http.MethodDelete && job.Status == jobRunning {
+		status = http.StatusAccepted
+	}
+
+	writeJSON(w, status, job)
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Read in a killer sudoku from the selected line. The line holds the puzzle in the one-line format
+// followed by its cages, all separated by semicolons. Each cage is written as its sum and a comma
+// separated list of 1-based cells, eg:
+//
+//	.................................................................................;3:r1c1,r1c2;15:r1c3,r1c4,r1c5
+//
+// Cages made of a single cell are filled in as clues, so the annealer never moves them. The rest are kept
+// by the cost of solver.CageCost, and the annealer's moves don't take a number out of a cage that can hold it
+// (by cageNumbers) into one that can't.
+func readInKiller(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, cages []solver.Cage, e error) {
+
+	puzzle, cageTexts, e := readInOneLineExtras(r, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	if e != nil {
+		return nil, nil, e
+	}
+
+	for _, cageText := range cageTexts {
+		c, err := parseCage(cageText, blockXDim*blockYDim)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if len(c.Cells) == 1 {
+			if c.Sum < 1 || c.Sum > blockXDim*blockYDim {
+				return nil, nil, puzzleErrorf("cage %q has a single cell, so its sum must be a number from 1 to %d", strings.TrimSpace(cageText), blockXDim*blockYDim)
+			}
+			puzzle[c.Cells[0].Row][c.Cells[0].Col] = c.Sum
+		}
+
+		cages = append(cages, c)
+	}
+
+	return puzzle, cages, nil
+}
+
+// Parse a single cage definition of the form "sum:r1c1,r1c2,...".
+func parseCage(text string, puzzleDim int) (c solver.Cage, e error) {
+
+	sumText, cellsText, found := strings.Cut(strings.TrimSpace(text), ":")
+	if !found {
+		return c, puzzleErrorf("cage %q is missing the ':' between its sum and cells", text)
+	}
+
+	c.Sum, e = strconv.Atoi(sumText)
+	if e != nil {
+		return c, puzzleErrorf("cage %q has an invalid sum: %v", text, e)
+	}
+
+	for _, cellText := range strings.Split(cellsText, ",") {
+		var row, col int
+		if _, err := fmt.Sscanf(strings.TrimSpace(cellText), "r%dc%d", &row, &col); err != nil {
+			return c, puzzleErrorf("cage %q has an invalid cell %q", text, cellText)
+		}
+		if row < 1 || row > puzzleDim || col < 1 || col > puzzleDim {
+			return c, puzzleErrorf("cage %q has cell %q outside of the puzzle", text, cellText)
+		}
+		c.Cells = append(c.Cells, solver.Cell{Row: row - 1, Col: col - 1})
+	}
+
+	return c, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Parses the value of the -ladder flag: geometric or geometric:RATIO (with a ratio greater than 1,
+// doubling when left out), linear or linear:STEP (with a step greater than 0, 1 when left out), auto
+// (which leaves the ladder nil, to be tuned for each puzzle), or a comma separated list of increasing
+// temperatures, eg. 0.5,1,3,8. A list sets the temperatures outright, so along with the ladder it returns
+// the list's first temperature and its length to use as the base temperature and the number of annealers;
+// they are 0 for the other ladders.
+func parseLadder(text string) (ladder solver.TemperatureLadder, baseTemperature float64, annealers int, e error) {
+
+	name, value, hasValue := strings.Cut(text, ":")
+
+	switch name {
+	case "auto":
+		// The ladder is built for each puzzle as it is solved (see tuneLadder)
+		return nil, 0, 0, nil
+
+	case "geometric":
+		ratio := 2.0
+		if hasValue {
+			r, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(r > 1) {
+				return nil, 0, 0, flagErrorf("invalid value %q for -ladder: the ratio of a geometric ladder must be a number greater than 1", text)
+			}
+			ratio = r
+		}
+		return solver.GeometricLadder(ratio), 0, 0, nil
+
+	case "linear":
+		step := 1.0
+		if hasValue {
+			s, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(s > 0) {
+				return nil, 0, 0, flagErrorf("invalid value %q for -ladder: the step of a linear ladder must be a number greater than 0", text)
+			}
+			step = s
+		}
+		return solver.LinearLadder(step), 0, 0, nil
+	}
+
+	var temperatures []float64
+	for _, field := range strings.Split(text, ",") {
+		t, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil {
+			return nil, 0, 0, flagErrorf("invalid value %q for -ladder: expected geometric, geometric:RATIO, linear, linear:STEP, auto or a comma separated list of temperatures", text)
+		}
+		if !(t > 0) || (len(temperatures) > 0 && t <= temperatures[len(temperatures)-1]) {
+			return nil, 0, 0, flagErrorf("invalid value %q for -ladder: the temperatures must be greater than 0 and increase from the coldest annealer to the hottest", text)
+		}
+		temperatures = append(temperatures, t)
+	}
+
+	return solver.ListLadder(temperatures), temperatures[0], len(temperatures), nil
+}
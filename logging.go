@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+	"os"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The level of the per-annealer detail logged with -vv, below slog's debug level that -v logs the
+// cooling steps at.
+const levelTrace = slog.LevelDebug - 4
+
+func init() {
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})))
+}
+
+// Adds the flags controlling the logs written to standard error to a flag set: -v and -vv for more
+// detail, and -log-format. parseFlags then sets up logging from them.
+func addLogFlags(flags *flag.FlagSet) {
+	flags.Bool("v", false, "Log a summary of every cooling step on standard error")
+	flags.Bool("vv", false, "Log every annealer's temperature, costs, acceptance rate and exchanges at every cooling step too (implies -v)")
+	flags.String("log-format", "text", "The format of the logs on standard error (text, or json for one JSON object per line)")
+}
+
+// Sets up the default logger from the -v, -vv and -log-format flags of a flag set. Without -v only
+// status messages and warnings are logged.
+func configureLogging(flags *flag.FlagSet) error {
+
+	level := slog.LevelInfo
+	if flags.Lookup("v").Value.String() == "true" {
+		level = slog.LevelDebug
+	}
+	if flags.Lookup("vv").Value.String() == "true" {
+		level = levelTrace
+	}
+	options := &slog.HandlerOptions{Level: level, ReplaceAttr: nameTraceLevel}
+
+	switch format := flags.Lookup("log-format").Value.String(); format {
+	case "text":
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, options)))
+	case "json":
+		slog.SetDefault(slog.New(slog.NewJSONHandler(os.Stderr, options)))
+	default:
+		return flagErrorf("unknown log format %q for -log-format (expected text or json)", format)
+	}
+
+	return nil
+}
+
+// Names levelTrace TRACE in the logs, rather than slog's DEBUG-4.
+func nameTraceLevel(groups []string, attr slog.Attr) slog.Attr {
+	if attr.Key == slog.LevelKey && attr.Value.Any() == levelTrace {
+		attr.Value = slog.StringValue("TRACE")
+	}
+	return attr
+}
+
+// Returns a progress function that logs a summary of every cooling step at the debug level, and the
+// state of every annealer at the trace level, or nil when neither is being logged.
+func logReporter() func(solver.AnnealProgress) {
+
+	logger := slog.Default()
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		return nil
+	}
+	trace := logger.Enabled(context.Background(), levelTrace)
+
+	return func(p solver.AnnealProgress) {
+		logger.Debug("cooling step", "step", p.Step, "temperature", p.Temperature, "best_cost", p.BestCost, "acceptance_rate", p.AcceptanceRate, "elapsed", p.Elapsed)
+		if !trace {
+			return
+		}
+		for i, replica := range p.Replicas {
+			logger.Log(context.Background(), levelTrace, "annealer", "step", p.Step, "annealer", i, "temperature", replica.Temperature,
+				"best_cost", replica.BestCost, "cost", replica.Cost, "acceptance_rate", replica.AcceptanceRate, "exchanges", replica.Exchanges)
+		}
+	}
+}
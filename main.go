@@ -0,0 +1,28 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	// The seed solves start from unless -seed gives one
+	randomSeed = time.Now().Unix()
+
+	// Subcommands are given as the first argument and parse their own flags
+	err := runCommand(os.Args[1:])
+
+	// Every failure ends up here, is reported unless it already has been, and picks the exit status
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil && !errors.Is(err, ErrNoSolution) && !errors.Is(err, errUsageShown) {
+		fmt.Println(err)
+	}
+	os.Exit(exitCode(err))
+}
@@ -0,0 +1,192 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The markings a puzzle given as JSON (on a line of a -m json file or an ndjson stream, or in a request
+// to the solve server) may have besides its clues, for the variants drawn on the grid. Squares are
+// written as 1-based cells, eg. "r1c2": Even and Odd are the squares that must hold even and odd
+// numbers, Greater the inequality signs between squares side by side or one above the other, each
+// written with the sign between its squares, eg. "r1c1>r1c2" or "r1c1<r2c1", SandwichRows and
+// SandwichColumns the sandwich clues of every row and column in order, with null for a line without one,
+// Thermometers the squares of every thermometer from its bulb (see parsePath), and Arrows the squares of
+// every arrow, its circle first and then the squares along its shaft.
+type puzzleMarkup struct {
+	Even            []string   `json:"even,omitempty"`
+	Odd             []string   `json:"odd,omitempty"`
+	Greater         []string   `json:"greater,omitempty"`
+	SandwichRows    []*int     `json:"sandwich_rows,omitempty"`
+	SandwichColumns []*int     `json:"sandwich_columns,omitempty"`
+	Thermometers    [][]string `json:"thermometers,omitempty"`
+	Arrows          [][]string `json:"arrows,omitempty"`
+}
+
+// Parse a list of squares of a puzzle marking, each of the form "r1c2".
+func parseMarkupCells(field string, texts []string, puzzleDim int) (cells []solver.Cell, e error) {
+
+	for _, text := range texts {
+		var row, col int
+		if _, err := fmt.Sscanf(strings.TrimSpace(text), "r%dc%d", &row, &col); err != nil {
+			return nil, puzzleErrorf("%s has an invalid cell %q", field, text)
+		}
+		if row < 1 || row > puzzleDim || col < 1 || col > puzzleDim {
+			return nil, puzzleErrorf("%s has cell %q outside of the puzzle", field, text)
+		}
+		cells = append(cells, solver.Cell{Row: row - 1, Col: col - 1})
+	}
+
+	return cells, nil
+}
+
+// Parse an inequality sign between two squares of a puzzle marking, of the form "r1c1>r1c2" or
+// "r1c1<r2c1". The pair is returned with the square that holds the greater number first.
+func parseInequality(text string, puzzleDim int) (pair [2]solver.Cell, e error) {
+
+	sign := strings.IndexAny(text, "<>")
+	if sign < 0 {
+		return pair, puzzleErrorf("greater has no < or > between the squares of %q", text)
+	}
+
+	cells, err := parseMarkupCells("greater", []string{text[:sign], text[sign+1:]}, puzzleDim)
+	if err != nil {
+		return pair, err
+	}
+	if abs(cells[0].Row-cells[1].Row)+abs(cells[0].Col-cells[1].Col) != 1 {
+		return pair, puzzleErrorf("greater has %q between two squares that aren't side by side or one above the other", text)
+	}
+
+	if text[sign] == '<' {
+		return [2]solver.Cell{cells[1], cells[0]}, nil
+	}
+	return [2]solver.Cell{cells[0], cells[1]}, nil
+}
+
+// Returns the constraints of the markings of a puzzle of the given dimension, which are nil when it has
+// none.
+func (m puzzleMarkup) constraints(puzzleDim int) (constraints []solver.Constraint, e error) {
+
+	if len(m.Even) > 0 || len(m.Odd) > 0 {
+		var parity solver.ParityConstraint
+		if parity.Even, e = parseMarkupCells("even", m.Even, puzzleDim); e != nil {
+			return nil, e
+		}
+		if parity.Odd, e = parseMarkupCells("odd", m.Odd, puzzleDim); e != nil {
+			return nil, e
+		}
+		constraints = append(constraints, parity)
+	}
+
+	if len(m.Greater) > 0 {
+		greater := solver.PairConstraint{Kind: "greater-than", Rule: solver.PairsGreater, Pairs: nil}
+		for _, text := range m.Greater {
+			pair, err := parseInequality(text, puzzleDim)
+			if err != nil {
+				return nil, err
+			}
+			greater.Pairs = append(greater.Pairs, pair)
+		}
+		constraints = append(constraints, greater)
+	}
+
+	if len(m.SandwichRows) > 0 || len(m.SandwichColumns) > 0 {
+		sandwich, err := newSandwichConstraint(puzzleDim, m.SandwichRows, m.SandwichColumns)
+		if err != nil {
+			return nil, err
+		}
+		constraints = append(constraints, sandwich)
+	}
+
+	if len(m.Thermometers) > 0 {
+		var thermometers [][]solver.Cell
+		for _, texts := range m.Thermometers {
+			thermometer, err := parsePath("thermometers", texts, puzzleDim)
+			if err != nil {
+				return nil, err
+			}
+			thermometers = append(thermometers, thermometer)
+		}
+		constraints = append(constraints, solver.ThermometerConstraint(thermometers))
+	}
+
+	if len(m.Arrows) > 0 {
+		var arrows solver.ArrowConstraint
+		for _, texts := range m.Arrows {
+			path, err := parsePath("arrows", texts, puzzleDim)
+			if err != nil {
+				return nil, err
+			}
+			arrows.Arrows = append(arrows.Arrows, solver.Arrow{Circle: path[0], Shaft: path[1:]})
+		}
+		constraints = append(constraints, arrows)
+	}
+
+	return constraints, nil
+}
+
+// A puzzle of a -m json file: the puzzle in the one-line format, and its markings.
+type jsonPuzzle struct {
+	Puzzle string `json:"puzzle"`
+	puzzleMarkup
+}
+
+// Read in a puzzle from the selected line of a -m json file, which holds one JSON object per line with
+// the puzzle in the one-line format and any markings (see puzzleMarkup), eg:
+//
+//	{"puzzle": "...4.6....", "even": ["r1c1", "r1c5"], "odd": ["r2c3"], "greater": ["r1c1>r1c2"]}
+//
+// Along with the puzzle, returns the constraints of its markings.
+func readInJSON(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, extra []solver.Constraint, e error) {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	scanner.Split(scanPuzzleLines)
+
+	// Start puzzle at line 1 (more user friendly)
+	lineCounter := 1
+
+	for scanner.Scan() {
+
+		if line == lineCounter {
+
+			var p jsonPuzzle
+			if err := json.Unmarshal(scanner.Bytes(), &p); err != nil {
+				return nil, nil, puzzleErrorf("line %d isn't a JSON puzzle: %v", line, err)
+			}
+
+			puzzle, e = readInOneLine(strings.NewReader(p.Puzzle), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+			if e != nil {
+				return nil, nil, e
+			}
+
+			if extra, e = p.constraints(blockXDim * blockYDim); e != nil {
+				return nil, nil, e
+			}
+		}
+
+		lineCounter++
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, nil, inputError(err)
+	}
+	if puzzle == nil {
+		return nil, nil, puzzleErrorf("there is no line %d in the input, which has %d lines", line, lineCounter-1)
+	}
+
+	return puzzle, extra, nil
+}
+
+// Returns the absolute value of an integer.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
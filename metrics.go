@@ -0,0 +1,30 @@
+package main
+
+import (
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	_ "net/http/pprof"
+	"runtime"
+)
+
+func init() {
+	expvar.Publish("goroutines", expvar.Func(func() interface{} { return runtime.NumGoroutine() }))
+}
+
+// Serves the net/http/pprof profiles and the expvar counters on the given address (eg. :6060) in the
+// background for as long as the program runs.
+func startDebugServer(addr string) error {
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return flagErrorf("invalid value %q for -pprof: %v", addr, err)
+	}
+
+	go http.Serve(listener, nil)
+
+	slog.Info("serving profiles and counters", "pprof", fmt.Sprintf("http://%s/debug/pprof/", listener.Addr()), "expvar", fmt.Sprintf("http://%s/debug/vars", listener.Addr()))
+	return nil
+}
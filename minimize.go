@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns a minimal puzzle with the same unique solution as the one given: its clues are taken out one
+// at a time, in a random order, as long as the solution stays unique (see removeClues), until none can
+// go or only minClues are left. A puzzle with no solution or more than one can't be minimised, which is
+// reported as a PuzzleError naming it by the given number.
+func minimizePuzzle(number int, puzzle [][]int, constraints []solver.Constraint, minClues int, rng *rand.Rand) ([][]int, error) {
+
+	switch countSolutions(puzzle, constraints, 2) {
+	case 0:
+		return nil, puzzleErrorf("puzzle %d has no solution, so it can't be minimised", number)
+	case 2:
+		return nil, puzzleErrorf("puzzle %d has more than one solution, so it can't be minimised", number)
+	}
+
+	return removeClues(puzzle, constraints, minClues, nil, rng), nil
+}
+
+// The minimize subcommand. Takes the clues out of every puzzle in a file (or the one on -l) for as long
+// as its solution stays unique, and writes out the minimal puzzles left, one per line in the one-line
+// format unless -to says otherwise. Every clue of a minimal puzzle is needed for its solution to be
+// unique, though another order of taking them out may leave fewer.
+func minimizeCommand(args []string) error {
+
+	flags := flag.NewFlagSet("minimize", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm, ss or csv). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file of puzzles to minimise (- reads standard input)")
+	linePtr := flags.Int("l", 0, "The line of the one puzzle to minimise (or the grid number in sdk and ss files). Every puzzle in the file is minimised when left out or 0")
+	cluesPtr := flags.Int("clues", 0, "Stop taking clues out once only this many are left (0 takes out as many as keep the solution unique)")
+	seedPtr := flags.Int64("seed", 0, "The seed of the order the clues are taken out in, for minimising the same way again (0 picks one at random)")
+	formatPtr := flags.String("to", "one-line", "The output format ("+outputFormatNames+")")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("d", *dimPtr)
+	if err != nil {
+		return err
+	}
+	puzzleDim := blockXDim * blockYDim
+	if *cluesPtr < 0 || *cluesPtr > puzzleDim*puzzleDim {
+		return flagErrorf("invalid value %d for -clues: must be between 0 and %d", *cluesPtr, puzzleDim*puzzleDim)
+	}
+	if err := checkOutputFormat("to", *formatPtr); err != nil {
+		return err
+	}
+	line := 0
+	if *linePtr != 0 {
+		if line, err = checkPositiveInt("l", *linePtr); err != nil {
+			return err
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, puzzleDim)
+	if err != nil {
+		return err
+	}
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	var blockMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		blockMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	// Every puzzle is minimised with its own constraints, numbered by its line (or grid) in the file
+	type numberedPuzzle struct {
+		number      int
+		puzzle      [][]int
+		constraints []solver.Constraint
+	}
+	var puzzles []numberedPuzzle
+	if line > 0 {
+		puzzle, regionMap, cages, extra, err := readPuzzle(inFile, *inputModePtr, line, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return err
+		}
+		if regionMap == nil {
+			regionMap = blockMap
+		}
+		var constraints []solver.Constraint
+		if *inputModePtr == "samurai" {
+			constraints = append(samuraiConstraints(blockXDim), variant...)
+		} else {
+			constraints = solver.PuzzleConstraints(len(puzzle), regionMap, append(variant, extra...), cages)
+		}
+		puzzles = append(puzzles, numberedPuzzle{line, puzzle, constraints})
+	} else {
+		all, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return err
+		}
+		for i, puzzle := range all {
+			puzzles = append(puzzles, numberedPuzzle{i + 1, puzzle, solver.PuzzleConstraints(puzzleDim, blockMap, variant, nil)})
+		}
+	}
+
+	seed := *seedPtr
+	if seed == 0 {
+		seed = solver.FreshSeed()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	writer := puzzleWriter{format: *formatPtr, blockXDim: blockXDim, blockYDim: blockYDim, symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if err := writer.writeHeader(out); err != nil {
+		return inputError(err)
+	}
+
+	for _, p := range puzzles {
+		minimal, err := minimizePuzzle(p.number, p.puzzle, p.constraints, *cluesPtr, rng)
+		if err != nil {
+			return err
+		}
+		if err := writer.write(out, minimal); err != nil {
+			return inputError(err)
+		}
+		out.Flush()
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A puzzle read in -m ndjson mode: one JSON object per line, with the fields of a request to the solve
+// server, an ID that is copied to its result and optionally a difficulty (a name or a rating) for the
+// summary, eg. {"id": "a1", "puzzle": "53..7....", "dim": "3x3", "difficulty": "hard"}.
+type ndjsonPuzzle struct {
+	ID         string      `json:"id"`
+	Difficulty interface{} `json:"difficulty"`
+	solveRequest
+}
+
+// The result of solving an ndjson puzzle, written as a line of JSON in the same order as the input.
+// Retries counts the retries made under -retry-policy. Error says why a line couldn't be solved at all,
+// eg. when it isn't valid JSON or its puzzle is malformed.
+type ndjsonResult struct {
+	ID   string `json:"id,omitempty"`
+	Line int    `json:"line"`
+	Dim  string `json:"dim"`
+	solveResponse
+	Retries int    `json:"retries,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Solves a stream of ndjson puzzles from r, starting at the given line, writing each result to out as
+// soon as it is solved so the solver can sit in the middle of a pipeline. Puzzles may each have their
+// own dimensions, variant and annealing parameters; those they leave out are taken from defaults (the
+// dimensions and variant given on the command line, and the parameters in the server's defaults). Each
+// is annealed with the options searchOptions gives for its parameters, for no longer than the server's
+// maxTimeout each attempt, and retried by the retry policy when it isn't solved and the policy's time
+// for it isn't spent; one that runs out of time is reported with the lowest cost it reached. Every
+// puzzle that could be read is added to batch. Returns the number of lines that weren't solved.
+func solveNDJSON(r io.Reader, firstLine int, defaults ndjsonPuzzle, server *solveServer, searchOptions func(annealParams) solver.Options, retry retryPolicy, out io.Writer, batch *batchResults) (unsolved int, e error) {
+
+	encoder := json.NewEncoder(out)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineCounter := 1; scanner.Scan(); lineCounter++ {
+		if lineCounter < firstLine || strings.TrimSpace(scanner.Text()) == "" {
+			continue
+		}
+
+		result, difficulty := solveNDJSONLine(scanner.Text(), defaults, server, searchOptions, retry)
+		result.Line = lineCounter
+		if !result.Solved {
+			unsolved++
+		}
+		if result.Error == "" {
+			batch.add(time.Duration(result.Seconds*float64(time.Second)), result.Solved, result.Retries, result.Cost, difficulty)
+			if result.TimedOut && retry.budget > 0 {
+				batch.timeouts++
+			}
+		}
+
+		if err := encoder.Encode(result); err != nil {
+			return unsolved, inputError(err)
+		}
+	}
+
+	return unsolved, inputError(scanner.Err())
+}
+
+// Solves the puzzle on a single line of ndjson input. Along with the result it returns the puzzle's
+// difficulty, or "" when it has none.
+func solveNDJSONLine(text string, defaults ndjsonPuzzle, server *solveServer, searchOptions func(annealParams) solver.Options, retry retryPolicy) (result ndjsonResult, difficulty string) {
+
+	request := defaults
+	request.Puzzle = ""
+	if err := json.Unmarshal([]byte(text), &request); err != nil {
+		return ndjsonResult{Dim: request.Dim, Error: fmt.Sprintf("invalid JSON: %v", err)}, ""
+	}
+	result.ID, result.Dim = request.ID, request.Dim
+	if request.Difficulty != nil {
+		difficulty = fmt.Sprint(request.Difficulty)
+	}
+
+	p, err := server.readRequest(request.solveRequest)
+	if err != nil {
+		result.Error = err.Error()
+		return result, difficulty
+	}
+
+	start := time.Now()
+	options := searchOptions(p.params)
+	options.Timeout, options.OnCoolingStep = p.timeout, logReporter()
+	options, bestCost := trackBestCost(options)
+	solvedPuzzle, solved, stats := retry.search(p.puzzle, p.constraints, options)
+	result.Retries = stats.Restarts
+
+	result.solveResponse = solveResponse{
+		Solved:   solved,
+		TimedOut: !solved && (time.Since(start) >= p.timeout || retry.outOfTime(start)),
+		Solution: puzzleWriter{symbols: p.symbols, emptyValue: "."}.oneLine(solvedPuzzle),
+		Cost:     solver.CostFunction(solvedPuzzle, p.constraints),
+		Seconds:  time.Since(start).Seconds(),
+	}
+	if result.TimedOut {
+		result.Cost = math.Min(result.Cost, *bestCost)
+	}
+
+	return result, difficulty
+}
@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// An operation of the HTTP API: its method and path (with any path parameters in braces, as OpenAPI
+// writes them), a name and summary for clients, its query parameters and what it takes and answers
+// with. The request and responses are values of the Go types the handler reads and writes, which the
+// OpenAPI document's schemas are built from; more than one response is any one of them. A stream
+// answers with server-sent events instead, bearer says the operation also needs the server's daily key,
+// and solves that it starts a solve counted against the server's -max-concurrent.
+type apiOperation struct {
+	method    string
+	path      string
+	id        string
+	summary   string
+	query     []apiParameter
+	request   interface{}
+	status    int
+	responses []interface{}
+	stream    bool
+	bearer    bool
+	solves    bool
+	errors    []int
+}
+
+// A query parameter of an operation of the HTTP API.
+type apiParameter struct {
+	name        string
+	description string
+}
+
+// A route of the HTTP API: the handler, the patterns it is served at and the operations it serves.
+type apiRoute struct {
+	patterns   []string
+	handler    http.HandlerFunc
+	operations []apiOperation
+}
+
+// Returns the routes of the HTTP API, which serveCommand serves and the OpenAPI document describes, so
+// the document can't fall behind the handlers.
+func (s *solveServer) apiRoutes(queue *jobQueue) []apiRoute {
+
+	return []apiRoute{
+		{[]string{"/solve"}, s.handleSolve, []apiOperation{
+			{method: http.MethodPost, path: "/solve", id: "solve", summary: "Solves a puzzle, answering once the solve finishes",
+				request: solveRequest{}, status: http.StatusOK, responses: []interface{}{solveResponse{}}, solves: true, errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		}},
+		{[]string{"/solves", "/solves/"}, s.handleSolves, []apiOperation{
+			{method: http.MethodPost, path: "/solves", id: "startSolve", summary: "Starts solving a puzzle in the background",
+				request: solveRequest{}, status: http.StatusAccepted, responses: []interface{}{solveStarted{}}, solves: true, errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable}},
+			{method: http.MethodGet, path: "/solves/{id}", id: "getSolve", summary: "Returns the result of a solve, or its latest progress while it runs",
+				status: http.StatusOK, responses: []interface{}{solveResponse{}, solveRunning{}}, errors: []int{http.StatusNotFound}},
+			{method: http.MethodGet, path: "/solves/{id}/events", id: "streamSolve", summary: "Streams a solve as server-sent events: a progress event (a ProgressEvent) after each cooling step, then a result event (a SolveResponse)",
+				status: http.StatusOK, responses: []interface{}{progressEvent{}, solveResponse{}}, stream: true, errors: []int{http.StatusNotFound}},
+		}},
+		{[]string{"/jobs", "/jobs/"}, queue.handleJobs, []apiOperation{
+			{method: http.MethodPost, path: "/jobs", id: "queueJob", summary: "Queues a puzzle too big to wait for as a job",
+				request: solveRequest{}, status: http.StatusAccepted, responses: []interface{}{queuedJob{}}, errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity, http.StatusServiceUnavailable}},
+			{method: http.MethodGet, path: "/jobs/{id}", id: "getJob", summary: "Returns a job's status, latest progress and result",
+				status: http.StatusOK, responses: []interface{}{queuedJob{}}, errors: []int{http.StatusNotFound}},
+			{method: http.MethodDelete, path: "/jobs/{id}", id: "cancelJob", summary: "Cancels a job, or deletes it once it has finished (202 while a running job stops)",
+				status: http.StatusOK, responses: []interface{}{queuedJob{}}, errors: []int{http.StatusNotFound}},
+		}},
+		{[]string{"/generate"}, s.handleGenerate, []apiOperation{
+			{method: http.MethodPost, path: "/generate", id: "generate", summary: "Generates a puzzle with a unique solution",
+				request: generateRequest{}, status: http.StatusOK, responses: []interface{}{generateResponse{}}, errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		}},
+		{[]string{"/rate"}, s.handleRate, []apiOperation{
+			{method: http.MethodPost, path: "/rate", id: "rate", summary: "Rates how hard a puzzle is to solve by hand",
+				request: rateRequest{}, status: http.StatusOK, responses: []interface{}{rateResponse{}}, errors: []int{http.StatusBadRequest, http.StatusUnprocessableEntity}},
+		}},
+		{[]string{"/daily", "/daily/"}, s.handleDaily, []apiOperation{
+			{method: http.MethodGet, path: "/daily", id: "getDaily", summary: "Returns the puzzle of the day of a grade", query: dailyParameters,
+				status: http.StatusOK, responses: []interface{}{dailyPuzzle{}}, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+			{method: http.MethodGet, path: "/daily/solution", id: "getDailySolution", summary: "Returns the puzzle of the day of a grade along with its solution", query: dailyParameters,
+				status: http.StatusOK, responses: []interface{}{dailyPuzzle{}}, bearer: true, errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+		}},
+	}
+}
+
+// The query parameters of /daily and /daily/solution.
+var dailyParameters = []apiParameter{
+	{"difficulty", "The grade of the puzzle (" + strings.Join(difficulties, ", ") + "), medium when left out"},
+	{"date", "The day of the puzzle as YYYY-MM-DD, in UTC, today when left out; only the last week is served"},
+}
+
+// Returns the name of the schema of a Go type in the OpenAPI document: its name with the first letter
+// upper case, eg. SolveRequest for solveRequest.
+func schemaName(t reflect.Type) string {
+
+	name := []rune(t.Name())
+	name[0] = unicode.ToUpper(name[0])
+
+	return string(name)
+}
+
+// Returns the JSON schema of the values of a Go type as encoding/json writes them. Structs are added to
+// schemas by their schemaName and referred to from there.
+func jsonSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return jsonSchema(t.Elem(), schemas)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": jsonSchema(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": jsonSchema(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t == reflect.TypeOf(time.Time{}) {
+			return map[string]interface{}{"type": "string", "format": "date-time"}
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			// The schema is added before its fields, so a struct that refers to itself doesn't recurse forever
+			properties := map[string]interface{}{}
+			schemas[name] = map[string]interface{}{"type": "object", "properties": properties}
+			addProperties(t, properties, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	// Anything else (an interface) can hold any value
+	return map[string]interface{}{}
+}
+
+// Adds the schemas of the fields of a struct that encoding/json writes to properties, by their JSON
+// names. The fields of embedded structs are added as though they were the struct's own.
+func addProperties(t reflect.Type, properties map[string]interface{}, schemas map[string]interface{}) {
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			addProperties(field.Type, properties, schemas)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = jsonSchema(field.Type, schemas)
+	}
+}
+
+// Returns the OpenAPI document describing the routes of the server's HTTP API, with the errors and
+// security its rate limit and API tokens add.
+func (s *solveServer) openAPIDocument(routes []apiRoute) map[string]interface{} {
+
+	schemas := map[string]interface{}{
+		"Error": map[string]interface{}{"type": "object", "properties": map[string]interface{}{"error": map[string]interface{}{"type": "string"}}},
+	}
+	jsonContent := func(schema interface{}) map[string]interface{} {
+		return map[string]interface{}{"application/json": map[string]interface{}{"schema": schema}}
+	}
+	errorResponse := func(status int) map[string]interface{} {
+		return map[string]interface{}{"description": http.StatusText(status), "content": jsonContent(map[string]interface{}{"$ref": "#/components/schemas/Error"})}
+	}
+
+	paths := map[string]interface{}{}
+	for _, route := range routes {
+		for _, op := range route.operations {
+			var parameters []interface{}
+			for _, part := range strings.Split(op.path, "/") {
+				if strings.HasPrefix(part, "{") {
+					parameters = append(parameters, map[string]interface{}{"name": strings.Trim(part, "{}"), "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+				}
+			}
+			for _, parameter := range op.query {
+				parameters = append(parameters, map[string]interface{}{"name": parameter.name, "in": "query", "description": parameter.description, "schema": map[string]interface{}{"type": "string"}})
+			}
+
+			var responseSchemas []interface{}
+			for _, response := range op.responses {
+				responseSchemas = append(responseSchemas, jsonSchema(reflect.TypeOf(response), schemas))
+			}
+			success := map[string]interface{}{"description": http.StatusText(op.status)}
+			switch {
+			case op.stream:
+				success["content"] = map[string]interface{}{"text/event-stream": map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}}
+			case len(responseSchemas) == 1:
+				success["content"] = jsonContent(responseSchemas[0])
+			default:
+				success["content"] = jsonContent(map[string]interface{}{"oneOf": responseSchemas})
+			}
+			responses := map[string]interface{}{strconv.Itoa(op.status): success}
+
+			statuses := append([]int(nil), op.errors...)
+			if op.request != nil {
+				statuses = append(statuses, http.StatusRequestEntityTooLarge)
+			}
+			if len(s.tokens) > 0 || op.bearer {
+				statuses = append(statuses, http.StatusUnauthorized)
+			}
+			if s.limiter != nil || s.concurrency != nil && op.solves {
+				statuses = append(statuses, http.StatusTooManyRequests)
+			}
+			for _, status := range statuses {
+				responses[strconv.Itoa(status)] = errorResponse(status)
+			}
+
+			operation := map[string]interface{}{"operationId": op.id, "summary": op.summary, "responses": responses}
+			if len(parameters) > 0 {
+				operation["parameters"] = parameters
+			}
+			if op.request != nil {
+				operation["requestBody"] = map[string]interface{}{"required": true, "content": jsonContent(jsonSchema(reflect.TypeOf(op.request), schemas))}
+			}
+			if op.bearer {
+				requirement := map[string]interface{}{"daily": []string{}}
+				if len(s.tokens) > 0 {
+					requirement["token"] = []string{}
+				}
+				operation["security"] = []interface{}{requirement}
+			}
+
+			if paths[op.path] == nil {
+				paths[op.path] = map[string]interface{}{}
+			}
+			paths[op.path].(map[string]interface{})[strings.ToLower(op.method)] = operation
+		}
+	}
+
+	securitySchemes := map[string]interface{}{
+		"daily": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "The server's -daily-key"},
+	}
+	document := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":       "sudoku-annealing",
+			"description": "Solves, generates and rates sudoku puzzles by simulated annealing. Puzzles are written in the one-line format, with . for empty squares.",
+			"version":     "1",
+		},
+		"paths":      paths,
+		"components": map[string]interface{}{"schemas": schemas, "securitySchemes": securitySchemes},
+	}
+	if len(s.tokens) > 0 {
+		securitySchemes["token"] = map[string]interface{}{"type": "apiKey", "in": "header", "name": tokenHeader}
+		document["security"] = []interface{}{map[string]interface{}{"token": []string{}}}
+	}
+
+	return document
+}
+
+// Returns a handler of GET /openapi.json, which serves the OpenAPI document of the routes.
+func (s *solveServer) handleOpenAPI(routes []apiRoute) http.HandlerFunc {
+
+	document, _ := json.MarshalIndent(s.openAPIDocument(routes), "", "  ")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(document)
+	}
+}
+
+// The page of the API explorer served at /docs with -explorer: Swagger UI, loaded from a CDN, showing
+// the OpenAPI document.
+const apiExplorerPage = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>sudoku-annealing API</title>
+<link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+<script>SwaggerUIBundle({url: "/openapi.json", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// Handles GET /docs, the API explorer.
+func handleAPIExplorer(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(apiExplorerPage))
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"runtime"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Logs a warning when there are more annealers than the default and over twice as many as the
+// CPUs to run them, since they then take turns and every cooling step slows down. The ladder (nil for the
+// default) gives how hot the hottest of them is.
+func warnAnnealerCount(annealers int, ladder solver.TemperatureLadder) {
+	if cpus := runtime.GOMAXPROCS(0); annealers > 2*cpus && annealers > solver.DefaultAnnealerCount() {
+		slog.Warn(fmt.Sprintf("%d annealers share %d CPUs, so each cooling step takes about %d times as long as with %d; the hottest anneals at %.3g times the base temperature",
+			annealers, cpus, (annealers+cpus-1)/cpus, cpus, solver.Options{Ladder: ladder}.AnnealerTemperature(1, annealers-1)), "annealers", annealers, "cpus", cpus)
+	}
+}
+
+// Returns the options that anneal with these parameters, leaving everything else to the defaults.
+func (p annealParams) options() solver.Options {
+	return solver.Options{Temperature: p.temperature, CoolingRate: p.coolingRate, Iterations: p.iterations, Swaps: p.swaps, AdaptiveSwaps: p.adaptiveSwaps, Annealers: p.annealers}.WithDefaults()
+}
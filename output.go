@@ -0,0 +1,24 @@
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// Opens the file that solutions are written to with -o, appending to it if it already exists so that
+// several runs can build up one file of solutions.
+func openOutput(filename string) (*os.File, error) {
+	return os.OpenFile(filename, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+}
+
+// Writes the result of solving a puzzle to the output file in the writer's format. Puzzles that could not
+// be solved are written as they were given, empty squares and all, so that every puzzle in the output
+// still lines up with the puzzle it came from.
+func writeSolution(out io.Writer, writer puzzleWriter, originalPuzzle [][]int, solvedPuzzle [][]int, successfullySolved bool) error {
+
+	if !successfullySolved {
+		solvedPuzzle = originalPuzzle
+	}
+
+	return writer.write(out, solvedPuzzle)
+}
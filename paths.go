@@ -0,0 +1,34 @@
+package main
+
+import (
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Parse a path drawn over a puzzle, like a thermometer or an arrow, as a list of squares of a puzzle
+// marking. Each square of a path touches the one before it, side by side or diagonally, and no square is
+// on it twice.
+func parsePath(field string, texts []string, puzzleDim int) (path []solver.Cell, e error) {
+
+	path, e = parseMarkupCells(field, texts, puzzleDim)
+	if e != nil {
+		return nil, e
+	}
+	if len(path) < 2 {
+		return nil, puzzleErrorf("%s has a path of fewer than two squares: %q", field, strings.Join(texts, ", "))
+	}
+
+	seen := make(map[solver.Cell]bool)
+	for i, cell := range path {
+		if seen[cell] {
+			return nil, puzzleErrorf("%s has a path through %s twice", field, solver.CellName(cell))
+		}
+		seen[cell] = true
+		if i > 0 && (abs(cell.Row-path[i-1].Row) > 1 || abs(cell.Col-path[i-1].Col) > 1) {
+			return nil, puzzleErrorf("%s has a path from %s to %s, which don't touch", field, solver.CellName(path[i-1]), solver.CellName(cell))
+		}
+	}
+
+	return path, nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// ANSI escape sequences used by the play subcommand to redraw the whole screen and show the cursor.
+const (
+	ansiClearScreen = "\x1b[H\x1b[2J"
+	ansiReverse     = "\x1b[7m"
+	ansiDim         = "\x1b[2m"
+)
+
+// The keys the play subcommand understands, shown beneath the board.
+const playKeys = "arrows/hjkl move, number fills in, 0/space/backspace clears, p pencil marks, ? hint, s solve, q quit"
+
+// A puzzle being played in the terminal: the original puzzle, the board with the numbers filled in so far,
+// the pencil marks of each square as a bit mask with bit n set for the number n, and the cursor.
+type playBoard struct {
+	original     [][]int
+	board        [][]int
+	marks        [][]uint64
+	constraints  []solver.Constraint
+	regionMap    [][]int
+	extraRegions [][]solver.Cell
+	symbols      string
+	cursor       solver.Cell
+	pencil       bool
+	status       string
+}
+
+// Draws the board with the clues in bold, the numbers filled in in cyan, clashing numbers in red and the
+// cursor in reverse video, followed by the pencil marks of the square under the cursor, the status line
+// and the keys. Empty squares with pencil marks show a dot.
+func (p *playBoard) draw() {
+
+	decorate, conflictCount := cellColours(p.original, p.board, p.constraints)
+
+	var b strings.Builder
+	b.WriteString(ansiClearScreen)
+	b.WriteString(formatPuzzle(p.board, p.regionMap, p.extraRegions, p.symbols, func(r int, c int, text string) string {
+		if p.board[r][c] == 0 {
+			if p.marks[r][c] != 0 {
+				text = "·" + text[1:]
+			}
+			text = ansiDim + text + ansiReset
+		} else {
+			text = decorate(r, c, text)
+		}
+		if (solver.Cell{Row: r, Col: c}) == p.cursor {
+			text = ansiReverse + text + ansiReset
+		}
+		return text
+	}))
+
+	mode := "numbers"
+	if p.pencil {
+		mode = "pencil marks"
+	}
+	fmt.Fprintf(&b, "\n%s, entering %s", solver.CellName(p.cursor), mode)
+	if marks := p.marks[p.cursor.Row][p.cursor.Col]; marks != 0 && p.board[p.cursor.Row][p.cursor.Col] == 0 {
+		fmt.Fprintf(&b, ", marked %s", p.markNames(marks))
+	}
+	if conflictCount > 0 {
+		fmt.Fprintf(&b, ", %d clashing squares", conflictCount)
+	}
+	fmt.Fprintf(&b, "\n%s\n%s\n", p.status, playKeys)
+
+	// The terminal is in raw mode, so every line has to return to the start as well
+	os.Stdout.WriteString(strings.Replace(b.String(), "\n", "\r\n", -1))
+}
+
+// Returns the numbers in a bit mask of pencil marks, written with the board's symbols.
+func (p *playBoard) markNames(marks uint64) string {
+
+	var names []string
+	for number := 1; marks>>uint(number) != 0; number++ {
+		if marks&(1<<uint(number)) != 0 {
+			names = append(names, solver.SymbolText(p.symbols, number))
+		}
+	}
+
+	return strings.Join(names, " ")
+}
+
+// Handles a key, returning false when it quits. Keys that are symbols of the puzzle fill in numbers
+// before anything else, so puzzles with letters as symbols can still have them typed.
+func (p *playBoard) press(key string) bool {
+
+	puzzleDim := len(p.board)
+	r, c := p.cursor.Row, p.cursor.Col
+	p.status = ""
+
+	if number, found := p.number(key); found {
+		switch {
+		case p.original[r][c] > 0:
+			p.status = "That square is a clue."
+		case p.pencil:
+			p.marks[r][c] ^= 1 << uint(number)
+		default:
+			p.board[r][c] = number
+			if p.solved() {
+				p.status = "Solved!"
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case "q", "\x03":
+		return false
+	case "up", "k":
+		p.cursor.Row = (r + puzzleDim - 1) % puzzleDim
+	case "down", "j":
+		p.cursor.Row = (r + 1) % puzzleDim
+	case "left", "h":
+		p.cursor.Col = (c + puzzleDim - 1) % puzzleDim
+	case "right", "l":
+		p.cursor.Col = (c + 1) % puzzleDim
+	case "p":
+		p.pencil = !p.pencil
+	case "0", " ", ".", "\x7f", "\b":
+		if p.original[r][c] > 0 {
+			p.status = "That square is a clue."
+		} else if p.pencil {
+			p.marks[r][c] = 0
+		} else {
+			p.board[r][c] = 0
+		}
+	case "?":
+		p.hint()
+	case "s":
+		p.solve()
+	case "":
+	default:
+		p.status = fmt.Sprintf("%q isn't a number or a key.", key)
+	}
+
+	return true
+}
+
+// Returns the number a key types, if any: its symbol's number, or a digit from 1 to 9 when the puzzle
+// has no symbols.
+func (p *playBoard) number(key string) (number int, found bool) {
+
+	if p.symbols != "" {
+		return symbolValue(p.symbols, key)
+	}
+	if len(key) == 1 && key[0] >= '1' && key[0] <= '9' && int(key[0]-'0') <= len(p.board) {
+		return int(key[0] - '0'), true
+	}
+
+	return 0, false
+}
+
+// Reports whether every square is filled in without breaking any constraint.
+func (p *playBoard) solved() bool {
+
+	for r := range p.board {
+		for c := range p.board[r] {
+			if p.board[r][c] == 0 {
+				return false
+			}
+		}
+	}
+
+	return solver.CostFunction(p.board, p.constraints) == 0
+}
+
+// Fills in one square from the board as it stands: the first the logical solver can, or failing that the
+// square under the cursor from an annealed solution, and moves the cursor to it.
+func (p *playBoard) hint() {
+
+	if len(findClueConflicts(p.board, p.constraints)) > 0 {
+		p.status = "Some numbers clash, so there's no hint until they're cleared."
+		return
+	}
+
+	steps, _, _ := solver.ExplainPuzzle(p.board, p.constraints, p.symbols)
+	for _, step := range steps {
+		if step.Number > 0 {
+			p.board[step.Cell.Row][step.Cell.Col] = step.Number
+			p.cursor = step.Cell
+			p.status = fmt.Sprintf("Hint: %s is %s (%s: %s)", solver.CellName(step.Cell), solver.SymbolText(p.symbols, step.Number), step.Technique, step.Text)
+			return
+		}
+	}
+
+	if p.board[p.cursor.Row][p.cursor.Col] != 0 {
+		p.status = "No square can be filled in by logic alone; move to an empty square for a hint from annealing."
+		return
+	}
+	solution, found := p.anneal()
+	if found {
+		p.board[p.cursor.Row][p.cursor.Col] = solution[p.cursor.Row][p.cursor.Col]
+		p.status = fmt.Sprintf("Hint: %s is %s (from annealing)", solver.CellName(p.cursor), solver.SymbolText(p.symbols, solution[p.cursor.Row][p.cursor.Col]))
+	}
+}
+
+// Anneals the board as it stands and fills in every empty square.
+func (p *playBoard) solve() {
+
+	if len(findClueConflicts(p.board, p.constraints)) > 0 {
+		p.status = "Some numbers clash, so the board can't be solved until they're cleared."
+		return
+	}
+
+	if solution, found := p.anneal(); found {
+		p.board = solution
+		p.status = "Solved by annealing."
+	}
+}
+
+// Anneals the board as it stands, treating the numbers filled in as clues. When no solution is found the
+// status says so and found is false.
+func (p *playBoard) anneal() (solution [][]int, found bool) {
+
+	p.status = "Annealing..."
+	p.draw()
+
+	solution, found, _, err := solver.Solve(p.board, p.constraints, solver.Options{})
+	if err != nil {
+		p.status = err.Error()
+		return nil, false
+	}
+	if !found {
+		p.status = "No solution was found from here, so some of the numbers filled in may be wrong."
+	}
+
+	return solution, found
+}
+
+// Reads a key from the terminal, naming the arrow keys, which arrive as escape sequences.
+func readKey(r *bufio.Reader) (string, error) {
+
+	key, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	if key != 0x1b || r.Buffered() < 2 {
+		return string(key), nil
+	}
+
+	sequence := make([]byte, 2)
+	if _, err := r.Read(sequence); err != nil {
+		return "", err
+	}
+	switch string(sequence) {
+	case "[A", "OA":
+		return "up", nil
+	case "[B", "OB":
+		return "down", nil
+	case "[C", "OC":
+		return "right", nil
+	case "[D", "OD":
+		return "left", nil
+	}
+
+	return "", nil
+}
+
+// Runs stty on the terminal standard input is connected to, returning its output.
+func stty(args ...string) (string, error) {
+
+	cmd := exec.Command("stty", args...)
+	cmd.Stdin = os.Stdin
+	output, err := cmd.Output()
+
+	return strings.TrimSpace(string(output)), err
+}
+
+// The play subcommand. Lets a puzzle be played in the terminal: the cursor is moved around the board,
+// numbers and pencil marks are filled in with clashing numbers shown in red, and a hint or the annealer can
+// be asked for at any point, working from the board as it stands. When it quits, the board is printed
+// as a line that can be played on from later.
+func playCommand(args []string) error {
+
+	flags := flag.NewFlagSet("play", flag.ContinueOnError)
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "", "The file containing the puzzle to play")
+	linePtr := flags.Int("l", 1, "The line of the puzzle in the file")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	puzzleLine, lineErr := checkPositiveInt("l", *linePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *filePtr == "" || *filePtr == "-" {
+		return flagErrorf("a puzzle file must be given with -f, since keys are read from standard input")
+	}
+
+	// Numbers are typed a key at a time, so bigger puzzles always need symbols
+	puzzleDim := blockXDim * blockYDim
+	readSymbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, puzzleDim)
+	if err != nil {
+		return err
+	}
+	symbols := readSymbols
+	if symbols == "" && puzzleDim > 9 {
+		symbols = alphanumericSymbols[:puzzleDim]
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	puzzle, err := readPuzzleFile(*filePtr, puzzleLine, *delimiterPtr, *emptyValuePtr, readSymbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if info, err := os.Stdin.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return inputError(fmt.Errorf("play reads keys from a terminal, but standard input isn't one"))
+	}
+
+	p := &playBoard{
+		original:     puzzle,
+		board:        solver.CopyPuzzle(puzzle),
+		marks:        make([][]uint64, puzzleDim),
+		constraints:  solver.PuzzleConstraints(puzzleDim, regionMap, variant, nil),
+		regionMap:    regionMap,
+		extraRegions: solver.ConstraintRegions(variant),
+		symbols:      symbols,
+	}
+	for r := range p.marks {
+		p.marks[r] = make([]uint64, puzzleDim)
+	}
+
+	// Keys are read one at a time without being echoed, until the terminal is put back on the way out
+	saved, err := stty("-g")
+	if err != nil {
+		return inputError(fmt.Errorf("couldn't read the terminal settings with stty: %v", err))
+	}
+	if _, err := stty("raw", "-echo"); err != nil {
+		return inputError(fmt.Errorf("couldn't put the terminal into raw mode with stty: %v", err))
+	}
+
+	keys := bufio.NewReader(os.Stdin)
+	for {
+		p.draw()
+		key, err := readKey(keys)
+		if err != nil || !p.press(key) {
+			break
+		}
+	}
+
+	stty(saved)
+	fmt.Print(ansiClearScreen)
+	printPuzzle(p.board, regionMap, p.extraRegions, symbols, nil)
+	writer := puzzleWriter{format: "one-line", blockXDim: blockXDim, blockYDim: blockYDim, symbols: readSymbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
+	fmt.Printf("\n%s\n", writer.oneLine(p.board))
+	if p.solved() {
+		fmt.Println("Solved!")
+	}
+
+	return nil
+}
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The size of a convergence chart and the margins around its plotting area, in pixels.
+const (
+	plotWidth  = 800
+	plotHeight = 500
+	plotLeft   = 70
+	plotRight  = 110
+	plotTop    = 20
+	plotBottom = 50
+)
+
+// The colours of the lines for each annealer, from coldest to hottest, repeating for any beyond these.
+var plotColours = []color.RGBA{
+	{31, 119, 180, 255},
+	{255, 127, 14, 255},
+	{44, 160, 44, 255},
+	{214, 39, 40, 255},
+	{148, 103, 189, 255},
+	{140, 86, 75, 255},
+	{227, 119, 194, 255},
+	{127, 127, 127, 255},
+}
+
+// Collects the cost of every annealer at every cooling step for a convergence chart.
+type convergencePlot struct {
+	times []float64
+	costs [][]float64
+}
+
+// Records a cooling step. Used as the progress function of anneal.
+func (p *convergencePlot) record(progress solver.AnnealProgress) {
+
+	p.times = append(p.times, progress.Elapsed.Seconds())
+	for i, replica := range progress.Replicas {
+		if i == len(p.costs) {
+			p.costs = append(p.costs, make([]float64, len(p.times)-1))
+		}
+		p.costs[i] = append(p.costs[i], replica.Cost)
+	}
+}
+
+// Writes the chart of cost against time for every annealer to the named file, as an SVG or PNG image
+// depending on its extension.
+func (p *convergencePlot) writeFile(filename string) error {
+
+	if err := checkImageFile("plot", filename); err != nil {
+		return err
+	}
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return inputError(err)
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".svg" {
+		err = p.writeSVG(file)
+	} else {
+		err = png.Encode(file, p.image())
+	}
+
+	return inputError(err)
+}
+
+// Returns the largest time and cost on the chart, which set the ends of its axes.
+func (p *convergencePlot) extent() (maxTime float64, maxCost float64) {
+
+	maxTime, maxCost = 1e-9, 1
+	for i, t := range p.times {
+		maxTime = math.Max(maxTime, t)
+		for _, costs := range p.costs {
+			maxCost = math.Max(maxCost, costs[i])
+		}
+	}
+
+	return maxTime, maxCost
+}
+
+// Returns evenly spaced tick values from 0 to at least max, at a step of 1, 2 or 5 times a power of ten.
+func plotTicks(max float64) (ticks []float64) {
+
+	step := math.Pow(10, math.Floor(math.Log10(max/5)))
+	for _, multiple := range []float64{1, 2, 5, 10} {
+		if max/(step*multiple) <= 6 {
+			step *= multiple
+			break
+		}
+	}
+
+	for i := 0; float64(i)*step <= max+step/2; i++ {
+		ticks = append(ticks, float64(i)*step)
+	}
+
+	return ticks
+}
+
+// Returns the pixel position of a point on the chart.
+func plotPoint(t float64, cost float64, maxTime float64, maxCost float64) (x float64, y float64) {
+
+	x = plotLeft + t/maxTime*(plotWidth-plotLeft-plotRight)
+	y = plotHeight - plotBottom - cost/maxCost*(plotHeight-plotTop-plotBottom)
+
+	return x, y
+}
+
+// Writes the chart as an SVG image.
+func (p *convergencePlot) writeSVG(w io.Writer) error {
+
+	maxTime, maxCost := p.extent()
+	timeTicks, costTicks := plotTicks(maxTime), plotTicks(maxCost)
+	maxTime, maxCost = timeTicks[len(timeTicks)-1], costTicks[len(costTicks)-1]
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="12">`+"\n", plotWidth, plotHeight)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", plotWidth, plotHeight)
+
+	for _, tick := range timeTicks {
+		x, y := plotPoint(tick, 0, maxTime, maxCost)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#ddd"/>`+"\n", x, plotTop, x, y)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="middle">%.4g</text>`+"\n", x, y+18, tick)
+	}
+	for _, tick := range costTicks {
+		x, y := plotPoint(0, tick, maxTime, maxCost)
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%.1f" x2="%d" y2="%.1f" stroke="#ddd"/>`+"\n", x, y, plotWidth-plotRight, y)
+		fmt.Fprintf(&b, `<text x="%.1f" y="%.1f" text-anchor="end">%.4g</text>`+"\n", x-6, y+4, tick)
+	}
+
+	fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle">time (s)</text>`+"\n", (plotWidth+plotLeft-plotRight)/2, plotHeight-10)
+	fmt.Fprintf(&b, `<text x="15" y="%d" text-anchor="middle" transform="rotate(-90 15 %d)">cost</text>`+"\n", plotHeight/2, plotHeight/2)
+
+	for i, costs := range p.costs {
+		colour := plotColours[i%len(plotColours)]
+		points := make([]string, len(costs))
+		for j, cost := range costs {
+			x, y := plotPoint(p.times[j], cost, maxTime, maxCost)
+			points[j] = fmt.Sprintf("%.1f,%.1f", x, y)
+		}
+		fmt.Fprintf(&b, `<polyline fill="none" stroke="rgb(%d,%d,%d)" stroke-width="1.5" points="%s"/>`+"\n", colour.R, colour.G, colour.B, strings.Join(points, " "))
+		fmt.Fprintf(&b, `<rect x="%d" y="%d" width="12" height="12" fill="rgb(%d,%d,%d)"/>`+"\n", plotWidth-plotRight+15, plotTop+i*20, colour.R, colour.G, colour.B)
+		fmt.Fprintf(&b, `<text x="%d" y="%d">replica %d</text>`+"\n", plotWidth-plotRight+32, plotTop+i*20+11, i)
+	}
+
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="black"/>`+"\n", plotLeft, plotTop, plotWidth-plotLeft-plotRight, plotHeight-plotTop-plotBottom)
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Returns the chart as an image, for writing as a PNG.
+func (p *convergencePlot) image() image.Image {
+
+	img := image.NewRGBA(image.Rect(0, 0, plotWidth, plotHeight))
+	white, grey, black := color.RGBA{255, 255, 255, 255}, color.RGBA{221, 221, 221, 255}, color.RGBA{0, 0, 0, 255}
+
+	for x := 0; x < plotWidth; x++ {
+		for y := 0; y < plotHeight; y++ {
+			img.Set(x, y, white)
+		}
+	}
+
+	line := func(x1, y1, x2, y2 float64, c color.Color) {
+		steps := int(math.Max(math.Abs(x2-x1), math.Abs(y2-y1))) + 1
+		for i := 0; i <= steps; i++ {
+			f := float64(i) / float64(steps)
+			img.Set(int(math.Round(x1+f*(x2-x1))), int(math.Round(y1+f*(y2-y1))), c)
+		}
+	}
+
+	// Text is drawn at twice the size of the pixel font, anchored at its right edge or centre
+	text := func(s string, x, y float64, centre bool) {
+		width, _ := pixelTextSize(s, 2)
+		if centre {
+			x -= float64(width) / 2
+		} else {
+			x -= float64(width)
+		}
+		drawPixelText(img, s, int(x), int(y), 2, black)
+	}
+
+	maxTime, maxCost := p.extent()
+	timeTicks, costTicks := plotTicks(maxTime), plotTicks(maxCost)
+	maxTime, maxCost = timeTicks[len(timeTicks)-1], costTicks[len(costTicks)-1]
+
+	for _, tick := range timeTicks {
+		x, y := plotPoint(tick, 0, maxTime, maxCost)
+		line(x, plotTop, x, y, grey)
+		text(fmt.Sprintf("%.4g", tick), x, y+8, true)
+	}
+	for _, tick := range costTicks {
+		x, y := plotPoint(0, tick, maxTime, maxCost)
+		line(x, y, plotWidth-plotRight, y, grey)
+		text(fmt.Sprintf("%.4g", tick), x-6, y-5, false)
+	}
+
+	for i, costs := range p.costs {
+		colour := plotColours[i%len(plotColours)]
+		for j := 1; j < len(costs); j++ {
+			x1, y1 := plotPoint(p.times[j-1], costs[j-1], maxTime, maxCost)
+			x2, y2 := plotPoint(p.times[j], costs[j], maxTime, maxCost)
+			line(x1, y1, x2, y2, colour)
+		}
+		for dx := 0; dx < 12; dx++ {
+			for dy := 0; dy < 12; dy++ {
+				img.Set(plotWidth-plotRight+15+dx, plotTop+i*20+dy, colour)
+			}
+		}
+		text(fmt.Sprint(i), float64(plotWidth-plotRight+32), float64(plotTop+i*20+1), true)
+	}
+
+	line(plotLeft, plotTop, plotWidth-plotRight, plotTop, black)
+	line(plotLeft, plotHeight-plotBottom, plotWidth-plotRight, plotHeight-plotBottom, black)
+	line(plotLeft, plotTop, plotLeft, plotHeight-plotBottom, black)
+	line(plotWidth-plotRight, plotTop, plotWidth-plotRight, plotHeight-plotBottom, black)
+
+	return img
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns a progress function for anneal that writes a status line to w, either as text or as one JSON
+// object per line. With an interval of zero every cooling step is reported, otherwise at most one step
+// per interval is, along with the final step once the puzzle is solved.
+func progressReporter(w io.Writer, format string, interval time.Duration) (func(solver.AnnealProgress), error) {
+
+	if format != "text" && format != "json" {
+		return nil, flagErrorf("unknown progress format %q (expected text or json)", format)
+	}
+
+	var lastReport time.Duration
+
+	return func(p solver.AnnealProgress) {
+
+		if interval > 0 && p.Step > 1 && p.Elapsed-lastReport < interval && p.BestCost > 0 {
+			return
+		}
+		lastReport = p.Elapsed
+
+		if format == "json" {
+			json.NewEncoder(w).Encode(struct {
+				Step           int     `json:"step"`
+				Temperature    float64 `json:"temperature"`
+				BestCost       float64 `json:"best_cost"`
+				AcceptanceRate float64 `json:"acceptance_rate"`
+				Elapsed        float64 `json:"elapsed_seconds"`
+			}{p.Step, p.Temperature, p.BestCost, p.AcceptanceRate, p.Elapsed.Seconds()})
+			return
+		}
+
+		fmt.Fprintf(w, "step %d: temperature %.6g, best cost %v, acceptance rate %.1f%%, elapsed %s\n", p.Step, p.Temperature, p.BestCost, 100*p.AcceptanceRate, p.Elapsed.Round(time.Millisecond))
+	}, nil
+}
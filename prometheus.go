@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A histogram of observed values, counted into buckets by their upper bounds.
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// Returns a histogram with buckets at the given upper bounds, in increasing order.
+func newHistogram(bounds ...float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+// Counts a value into every bucket whose bound it doesn't exceed.
+func (h *histogram) observe(value float64) {
+
+	for i, bound := range h.bounds {
+		if value <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+// Writes the histogram in the Prometheus text format, with its buckets counted cumulatively.
+func (h *histogram) write(w io.Writer, name string, help string) {
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	for i, bound := range h.bounds {
+		fmt.Fprintf(w, "%s_bucket{le=\"%v\"} %d\n", name, bound, h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %v\n%s_count %d\n", name, h.sum, name, h.count)
+}
+
+// Writes a counter (or gauge) with one value for each of its labels, in the Prometheus text format.
+// Labels are written in order, and a value with an empty label is written without one.
+func writeMetric(w io.Writer, name string, kind string, help string, label string, values map[string]float64) {
+
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if key == "" {
+			fmt.Fprintf(w, "%s %v\n", name, values[key])
+		} else {
+			fmt.Fprintf(w, "%s{%s=%q} %v\n", name, label, key, values[key])
+		}
+	}
+}
+
+// The metrics of the solve server, served at /metrics for Prometheus to scrape.
+type serverMetrics struct {
+	mutex             sync.Mutex
+	requests          map[string]float64
+	timeouts          float64
+	inFlight          float64
+	durations         *histogram
+	finalCosts        *histogram
+	replicaIterations map[string]float64
+	tokenRequests     map[string]float64
+}
+
+// Returns the server's metrics, all at zero.
+func newServerMetrics() *serverMetrics {
+	return &serverMetrics{
+		requests:          map[string]float64{},
+		durations:         newHistogram(0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120),
+		finalCosts:        newHistogram(0, 1, 2, 4, 8, 16, 32, 64),
+		replicaIterations: map[string]float64{},
+		tokenRequests:     map[string]float64{},
+	}
+}
+
+// Records the start of a solve.
+func (m *serverMetrics) started() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.inFlight++
+}
+
+// Records the end of a solve, with how it ended (solved, unsolved, timeout or cached), how long it took and the
+// cost it was left with.
+func (m *serverMetrics) finished(result string, seconds float64, finalCost float64) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.inFlight--
+	m.requests[result]++
+	if result == "timeout" {
+		m.timeouts++
+	}
+	m.durations.observe(seconds)
+	m.finalCosts.observe(finalCost)
+}
+
+// Records a request that was turned away before solving, eg. because the puzzle couldn't be read.
+func (m *serverMetrics) rejected() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests["invalid"]++
+}
+
+// Records a request that was turned away because its client was over the server's rate limit.
+func (m *serverMetrics) limited() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests["rate_limited"]++
+}
+
+// Records a request that was turned away because it didn't send one of the server's API tokens.
+func (m *serverMetrics) unauthorized() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.requests["unauthorized"]++
+}
+
+// Records a request sent with an API token, by the token's id.
+func (m *serverMetrics) tokenRequest(id string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.tokenRequests[id]++
+}
+
+// Returns a progress function that counts the iterations each replica runs, for working out their
+// throughput from how quickly the counters rise.
+func (m *serverMetrics) countIterations(internalIterations int) func(solver.AnnealProgress) {
+	return func(progress solver.AnnealProgress) {
+		m.mutex.Lock()
+		defer m.mutex.Unlock()
+		for i := range progress.Replicas {
+			m.replicaIterations[fmt.Sprint(i)] += float64(internalIterations)
+		}
+	}
+}
+
+// Writes every metric in the Prometheus text format.
+func (m *serverMetrics) write(w io.Writer) {
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var b strings.Builder
+	writeMetric(&b, "sudoku_solve_requests_total", "counter", "Solve requests by how they ended (solved, unsolved, timeout, cached, invalid, rate_limited or unauthorized).", "result", m.requests)
+	writeMetric(&b, "sudoku_solve_timeouts_total", "counter", "Solves that gave up when their time ran out.", "", map[string]float64{"": m.timeouts})
+	writeMetric(&b, "sudoku_solves_in_flight", "gauge", "Solves running now.", "", map[string]float64{"": m.inFlight})
+	m.durations.write(&b, "sudoku_solve_duration_seconds", "How long solves took.")
+	m.finalCosts.write(&b, "sudoku_solve_final_cost", "The cost solves ended with (0 when solved).")
+	writeMetric(&b, "sudoku_replica_iterations_total", "counter", "Iterations run by each replica, from the coldest (0) up.", "replica", m.replicaIterations)
+	writeMetric(&b, "sudoku_token_requests_total", "counter", "Requests sent with each API token, by its id (the start of the SHA-256 hash of its name).", "token", m.tokenRequests)
+
+	io.WriteString(w, b.String())
+}
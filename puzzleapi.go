@@ -0,0 +1,216 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The largest puzzle side /generate generates, since proving a solution unique takes far too long for a
+// request beyond it, and the most puzzles it generates for one in a band of grades.
+const (
+	maxGenerateDim      = 9
+	maxGenerateAttempts = 100
+)
+
+// A request to generate a puzzle, as posted to /generate, with the same defaults as the generate
+// command's flags: a standard 9x9 puzzle of any grade, with as few clues as keep its solution unique.
+type generateRequest struct {
+	Dim        string `json:"dim"`
+	Variant    string `json:"variant"`
+	Difficulty string `json:"difficulty"`
+	Clues      int    `json:"clues"`
+	Symmetry   string `json:"symmetry"`
+	Attempts   int    `json:"attempts"`
+	Seed       int64  `json:"seed"`
+}
+
+// A puzzle generated by /generate, with its solution and grade, both in the one-line format, and the seed
+// that generates it again.
+type generateResponse struct {
+	Puzzle     string `json:"puzzle"`
+	Solution   string `json:"solution"`
+	Difficulty string `json:"difficulty"`
+	Seed       int64  `json:"seed"`
+}
+
+// A request to rate a puzzle, as posted to /rate.
+type rateRequest struct {
+	Puzzle  string `json:"puzzle"`
+	Dim     string `json:"dim"`
+	Variant string `json:"variant"`
+}
+
+// The rating of a puzzle from /rate: its grade (see rateDifficulty), the hardest technique it needs,
+// unless it is evil or invalid, and the number of steps the techniques took.
+type rateResponse struct {
+	Difficulty string `json:"difficulty"`
+	Hardest    string `json:"hardest,omitempty"`
+	Steps      int    `json:"steps"`
+}
+
+// Returns the block dimensions and constraints of a puzzle with the given dim and variant, defaulting
+// to a standard 3x3 one, for the requests of /generate and /rate sent with a token (nil for none).
+func (s *solveServer) requestConstraints(dim string, variant string, token *apiToken) (blockXDim int, blockYDim int, constraints []solver.Constraint, e error) {
+
+	if dim == "" {
+		dim = "3x3"
+	}
+	if variant == "" {
+		variant = "standard"
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("dim", dim)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if s.maxDim > 0 && blockXDim*blockYDim > s.maxDim {
+		return 0, 0, nil, fmt.Errorf("invalid dim %q: this server solves puzzles no larger than %dx%d", dim, s.maxDim, s.maxDim)
+	}
+	if token != nil && token.MaxDim > 0 && blockXDim*blockYDim > token.MaxDim {
+		return 0, 0, nil, fmt.Errorf("invalid dim %q: token %q solves puzzles no larger than %dx%d", dim, token.Name, token.MaxDim, token.MaxDim)
+	}
+
+	extra, err := variantConstraints(variant, blockXDim, blockYDim)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	var regionMap [][]int
+	if solver.HasBlocks(variant) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	return blockXDim, blockYDim, solver.PuzzleConstraints(blockXDim*blockYDim, regionMap, extra, nil), nil
+}
+
+// Handles POST /generate, which generates a puzzle with a unique solution as the generate command does
+// and returns it along with its solution and grade.
+func (s *solveServer) handleGenerate(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("puzzles are generated by POSTing a request"))
+		return
+	}
+
+	var request generateRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			writeJSONError(w, http.StatusBadRequest, err)
+			return
+		}
+	}
+
+	response, err := s.generate(request, s.requestToken(r))
+	if err != nil {
+		writeJSONError(w, requestErrorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Generates the puzzle a request to /generate (or the gRPC API) asks for, sent with a token (nil for
+// none). A request that can't be met is an error, and a PuzzleError when no puzzle of its grade could be
+// generated in its attempts.
+func (s *solveServer) generate(request generateRequest, token *apiToken) (response generateResponse, e error) {
+
+	blockXDim, blockYDim, constraints, err := s.requestConstraints(request.Dim, request.Variant, token)
+	if err != nil {
+		return response, err
+	}
+	puzzleDim := blockXDim * blockYDim
+	if puzzleDim > maxGenerateDim {
+		return response, fmt.Errorf("invalid dim %q: puzzles larger than %dx%d take too long to generate here (use the generate command)", request.Dim, maxGenerateDim, maxGenerateDim)
+	}
+	if request.Clues < 0 || request.Clues > puzzleDim*puzzleDim {
+		return response, fmt.Errorf("invalid clues %d: must be between 0 and %d", request.Clues, puzzleDim*puzzleDim)
+	}
+	if request.Symmetry == "" {
+		request.Symmetry = "none"
+	}
+	symmetry, err := generatorSymmetry(request.Symmetry)
+	if err != nil {
+		return response, err
+	}
+	lowest, highest := 0, len(difficulties)-1
+	if request.Difficulty != "" {
+		if lowest, highest, err = parseDifficultyBand(request.Difficulty); err != nil {
+			return response, err
+		}
+	}
+	if request.Attempts == 0 {
+		request.Attempts = maxGenerateAttempts
+	}
+	if request.Attempts < 0 || request.Attempts > maxGenerateAttempts {
+		return response, fmt.Errorf("invalid attempts %d: must be between 1 and %d", request.Attempts, maxGenerateAttempts)
+	}
+	if request.Seed == 0 {
+		request.Seed = solver.FreshSeed()
+	}
+
+	rng := rand.New(rand.NewSource(request.Seed))
+	puzzle, solution, grade, err := generateInBand(puzzleDim, constraints, request.Clues, symmetry, lowest, highest, request.Attempts, rng)
+	if err != nil {
+		return response, puzzleErrorf("%w", err)
+	}
+
+	writer := puzzleWriter{emptyValue: "."}
+	return generateResponse{writer.oneLine(puzzle), writer.oneLine(solution), grade, request.Seed}, nil
+}
+
+// Handles POST /rate, which rates how hard the puzzle in the JSON request body is to solve by hand, as
+// the rate command does.
+func (s *solveServer) handleRate(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("puzzles are rated by POSTing them"))
+		return
+	}
+
+	var request rateRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	response, err := s.rate(request, s.requestToken(r))
+	if err != nil {
+		writeJSONError(w, requestErrorStatus(err), err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// Rates the puzzle of a request to /rate (or the gRPC API), sent with a token (nil for none). A puzzle
+// that can't be read is a PuzzleError.
+func (s *solveServer) rate(request rateRequest, token *apiToken) (response rateResponse, e error) {
+
+	blockXDim, blockYDim, constraints, err := s.requestConstraints(request.Dim, request.Variant, token)
+	if err != nil {
+		return response, err
+	}
+	symbols, err := puzzleSymbols("", "", blockXDim*blockYDim)
+	if err != nil {
+		return response, err
+	}
+	puzzle, err := readInOneLine(strings.NewReader(request.Puzzle), 1, "", ".", symbols, blockXDim, blockYDim)
+	if err != nil {
+		return response, err
+	}
+
+	grade, steps := rateDifficulty(puzzle, constraints)
+	response = rateResponse{Difficulty: grade, Steps: len(steps)}
+	if grade != "evil" && grade != "invalid" {
+		response.Hardest = solver.HardestTechnique(steps)
+	}
+
+	return response, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The difficulty grades rateDifficulty gives, from the easiest to the hardest.
+var difficulties = []string{"easy", "medium", "hard", "expert", "evil"}
+
+// The grade of the puzzles whose hardest step is each of the techniques of solver.ExplainPuzzle.
+var techniqueDifficulty = map[string]string{
+	"naked single":       "easy",
+	"hidden single":      "medium",
+	"pointing":           "hard",
+	"box-line reduction": "hard",
+	"intersection":       "hard",
+	"naked pair":         "expert",
+	"naked triple":       "expert",
+}
+
+// Returns where a grade comes in difficulties, from 0 for easy, or -1 for one that isn't there (like
+// "invalid").
+func difficultyRank(grade string) int {
+
+	for i, difficulty := range difficulties {
+		if grade == difficulty {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Rates how hard a puzzle is to solve by hand by the hardest technique solver.ExplainPuzzle needs for it: easy
+// when naked singles are enough, medium with hidden singles, hard with pointing pairs and box-line
+// reduction, expert with naked pairs and triples, and evil when those techniques get stuck and the
+// solver has to guess. A puzzle the techniques show has no solution is rated "invalid". Also returns the
+// steps taken.
+func rateDifficulty(puzzle [][]int, constraints []solver.Constraint) (grade string, steps []solver.LogicalStep) {
+
+	steps, _, solved := solver.ExplainPuzzle(puzzle, constraints, "")
+	if len(steps) > 0 && steps[len(steps)-1].Technique == "contradiction" {
+		return "invalid", steps
+	}
+	if !solved {
+		return "evil", steps
+	}
+	if hardest := solver.HardestTechnique(steps); hardest != "" {
+		return techniqueDifficulty[hardest], steps
+	}
+
+	return "easy", steps
+}
+
+// The rate subcommand. Rates every puzzle in a file (or the one on -l) by how hard it is to solve by
+// hand, then counts the puzzles of each grade.
+func rateCommand(args []string) error {
+
+	flags := flag.NewFlagSet("rate", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, sdm, sdk, ss or csv). Detected from the file extension (.csv, .sdk, .sdm or .ss) when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file of puzzles to rate (- reads standard input)")
+	linePtr := flags.Int("l", 0, "The number of the one puzzle in the file to rate. Every puzzle is rated when left out or 0")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("d", *dimPtr)
+	if err != nil {
+		return err
+	}
+	puzzleLine := 0
+	if *linePtr != 0 {
+		if puzzleLine, err = checkPositiveInt("l", *linePtr); err != nil {
+			return err
+		}
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+	constraints := solver.PuzzleConstraints(blockXDim*blockYDim, regionMap, variant, nil)
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	puzzles, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	if puzzleLine > len(puzzles) {
+		return puzzleErrorf("%s has %d puzzles, so there is no puzzle %d", *filePtr, len(puzzles), puzzleLine)
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	counts := make(map[string]int)
+	for i, puzzle := range puzzles {
+		if puzzleLine > 0 && i+1 != puzzleLine {
+			continue
+		}
+
+		grade, steps := rateDifficulty(puzzle, constraints)
+		counts[grade]++
+
+		if hardest := solver.HardestTechnique(steps); hardest != "" && grade != "evil" && grade != "invalid" {
+			fmt.Fprintf(out, "%d: %s (%s, %d steps)\n", i+1, grade, hardest, len(steps))
+		} else {
+			fmt.Fprintf(out, "%d: %s\n", i+1, grade)
+		}
+	}
+
+	if puzzleLine == 0 && len(puzzles) > 1 {
+		fmt.Fprintln(out)
+		for _, grade := range append(difficulties, "invalid") {
+			if counts[grade] > 0 {
+				fmt.Fprintf(out, "%-8s %d\n", grade, counts[grade])
+			}
+		}
+	}
+
+	return nil
+}
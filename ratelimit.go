@@ -0,0 +1,184 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The largest request body the server reads, which is plenty for the largest puzzle and its markup.
+const maxRequestBytes = 1 << 20
+
+// How long a client's rate limit is remembered for once it last made a request. By then its bucket is
+// full again, so forgetting it changes nothing.
+const rateLimitExpiry = 10 * time.Minute
+
+// A bucket of tokens for one client: it holds at most the limiter's burst, refills at its rate, and each
+// request takes one.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limits how often each client (by IP address) may make requests of the server: rate requests a minute
+// on average, with bursts of up to burst at once.
+type rateLimiter struct {
+	rate    float64
+	burst   float64
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+	pruned  time.Time
+}
+
+// Returns a limiter of rate requests a minute for each client, with bursts of up to burst.
+func newRateLimiter(rate float64, burst int) *rateLimiter {
+	return &rateLimiter{rate: rate / 60, burst: float64(burst), buckets: map[string]*tokenBucket{}, pruned: time.Now()}
+}
+
+// Takes a token from the client's bucket, returning whether it had one and, when it didn't, how long until
+// it will.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Clients that haven't made a request for a while are forgotten, so the buckets don't grow forever
+	if now.Sub(l.pruned) > rateLimitExpiry {
+		for key, bucket := range l.buckets {
+			if now.Sub(bucket.last) > rateLimitExpiry {
+				delete(l.buckets, key)
+			}
+		}
+		l.pruned = now
+	}
+
+	bucket, ok := l.buckets[client]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[client] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.last).Seconds()*l.rate)
+	bucket.last = now
+
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+	}
+	bucket.tokens--
+
+	return true, 0
+}
+
+// Caps how many solves each client may have running at once, counting those of /solve and /solves alike,
+// so that solving in the background is no way round it. A nil cap allows any number.
+type concurrencyCap struct {
+	max     int
+	mutex   sync.Mutex
+	running map[string]int
+}
+
+// Returns a cap of max solves running at once for each client.
+func newConcurrencyCap(max int) *concurrencyCap {
+	return &concurrencyCap{max: max, running: map[string]int{}}
+}
+
+// Counts a solve the client starts, returning false (and counting nothing) when it already has as many
+// running as it may.
+func (c *concurrencyCap) acquire(client string) bool {
+
+	if c == nil {
+		return true
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.running[client] >= c.max {
+		return false
+	}
+	c.running[client]++
+
+	return true
+}
+
+// Counts a solve of the client's as finished.
+func (c *concurrencyCap) release(client string) {
+
+	if c == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Clients with nothing running are forgotten, so the counts don't grow forever
+	if c.running[client]--; c.running[client] <= 0 {
+		delete(c.running, client)
+	}
+}
+
+// Returns the IP address a request came from, which is what clients are told apart by.
+func clientAddress(r *http.Request) string {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// Returns the client a request is limited as: its API token, when it has one, or else its IP address.
+func (s *solveServer) requestClient(r *http.Request) string {
+
+	if token := s.requestToken(r); token != nil {
+		return "token " + token.Name
+	}
+
+	return clientAddress(r)
+}
+
+// Counts a solve started by the client of a request against the server's concurrency cap, returning the
+// client to release it for once it finishes. When the client already has as many running as it may, a
+// 429 Too Many Requests response is written and ok is false.
+func (s *solveServer) startSolving(w http.ResponseWriter, r *http.Request) (client string, ok bool) {
+
+	client = s.requestClient(r)
+	if !s.concurrency.acquire(client) {
+		s.metrics.limited()
+		w.Header().Set("Retry-After", "10")
+		writeJSONError(w, http.StatusTooManyRequests, fmt.Errorf("you already have as many solves running as you may (%d), so wait for one to finish", s.concurrency.max))
+		return client, false
+	}
+
+	return client, true
+}
+
+// Wraps a handler of the API so that its request bodies are no larger than maxRequestBytes, clients
+// without an API token are turned away when the server has tokens (see authenticate) and, when the server
+// has a rate limit, clients over it are turned away with 429 Too Many Requests. Clients with a token are
+// limited by their token rather than their IP address (see requestClient).
+func (s *solveServer) limited(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		if _, ok := s.authenticate(w, r); !ok {
+			return
+		}
+
+		if s.limiter != nil {
+			if ok, wait := s.limiter.allow(s.requestClient(r), time.Now()); !ok {
+				s.metrics.limited()
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeJSONError(w, http.StatusTooManyRequests, errors.New("too many requests, so slow down"))
+				return
+			}
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+		handler(w, r)
+	}
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The size of a square in a rendered puzzle, and the widths of the lines between squares and between
+// regions, in pixels.
+const (
+	renderCellSize  = 40
+	renderThinLine  = 1
+	renderThickLine = 3
+)
+
+// Checks that an image can be written to the file given to the named flag, which must end in .svg or .png.
+func checkImageFile(name string, filename string) error {
+
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".svg", ".png":
+		return nil
+	}
+
+	return flagErrorf("invalid value %q for -%s: images are written as .svg or .png files", filename, name)
+}
+
+// Returns the inequality signs of the greater-than constraints among constraints, each as the pair of
+// squares it sits between with the square holding the greater number first. Thermometers follow the
+// same rule, but are drawn as their tubes rather than signs, so aren't returned.
+func inequalitySigns(constraints []solver.Constraint) (signs [][2]solver.Cell) {
+
+	for _, constraint := range constraints {
+		if p, ok := constraint.(solver.PairConstraint); ok && p.Rule == solver.PairsGreater && p.Kind == "greater-than" {
+			signs = append(signs, p.Pairs...)
+		}
+	}
+
+	return signs
+}
+
+// Returns the three points of the chevron drawn for an inequality sign, in pixels from the top left of
+// the image: its two ends on the side of the greater square, and the tip between them pointing at the
+// smaller one.
+func signPoints(sign [2]solver.Cell, margin int) (ends [2]image.Point, tip image.Point) {
+
+	down, across := sign[1].Row-sign[0].Row, sign[1].Col-sign[0].Col
+	size := renderCellSize / 8
+
+	// The middle of the edge between the two squares
+	x := margin + sign[0].Col*renderCellSize + renderCellSize/2 + across*renderCellSize/2
+	y := margin + sign[0].Row*renderCellSize + renderCellSize/2 + down*renderCellSize/2
+
+	tip = image.Pt(x+across*size, y+down*size)
+	ends[0] = image.Pt(x-across*size-down*size, y-down*size-across*size)
+	ends[1] = image.Pt(x-across*size+down*size, y-down*size+across*size)
+
+	return ends, tip
+}
+
+// Writes the puzzle to the named file as an SVG or PNG image, depending on its extension. Numbers that
+// were clues in the original puzzle are drawn in bold black, and the rest in blue, and thick lines are
+// drawn around the edge of the puzzle and between its regions. With a nil region map only the edge is.
+// The inequality signs of a greater-than sudoku (see inequalitySigns) are drawn over the edges they sit
+// on.
+func renderPuzzleFile(filename string, puzzle [][]int, originalPuzzle [][]int, regionMap [][]int, signs [][2]solver.Cell, symbols string) error {
+
+	file, err := os.Create(filename)
+	if err != nil {
+		return inputError(err)
+	}
+	defer file.Close()
+
+	if strings.ToLower(filepath.Ext(filename)) == ".svg" {
+		err = renderSVG(file, puzzle, originalPuzzle, regionMap, signs, symbols)
+	} else {
+		err = png.Encode(file, renderImage(puzzle, originalPuzzle, regionMap, signs, symbols))
+	}
+
+	return inputError(err)
+}
+
+// Returns whether each edge of a square runs along the border of a region (or the edge of the puzzle),
+// and so is drawn as a thick line. Blocked squares lie outside of every region.
+func squareEdges(puzzle [][]int, regionMap [][]int, r int, c int) (top bool, left bool, bottom bool, right bool) {
+
+	regionAt := func(r int, c int) int {
+		if r < 0 || c < 0 || r >= len(puzzle) || c >= len(puzzle) || puzzle[r][c] == solver.BlockedSquare {
+			return -1
+		}
+		if regionMap == nil {
+			return 0
+		}
+		return regionMap[r][c]
+	}
+
+	region := regionAt(r, c)
+
+	return region != regionAt(r-1, c), region != regionAt(r, c-1), region != regionAt(r+1, c), region != regionAt(r, c+1)
+}
+
+// Writes the puzzle as an SVG image.
+func renderSVG(w io.Writer, puzzle [][]int, originalPuzzle [][]int, regionMap [][]int, signs [][2]solver.Cell, symbols string) error {
+
+	margin := renderThickLine
+	size := len(puzzle)*renderCellSize + 2*margin
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" font-family="sans-serif" font-size="%d" text-anchor="middle">`+"\n", size, size, renderCellSize*2/3)
+	fmt.Fprintf(&b, `<rect width="%d" height="%d" fill="white"/>`+"\n", size, size)
+
+	var thin, thick strings.Builder
+
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if puzzle[r][c] == solver.BlockedSquare {
+				continue
+			}
+
+			x, y := margin+c*renderCellSize, margin+r*renderCellSize
+
+			// Each edge is drawn once, by the square above or to the left of it, unless it is thick
+			top, left, bottom, right := squareEdges(puzzle, regionMap, r, c)
+			for _, edge := range []struct {
+				thick          bool
+				x1, y1, x2, y2 int
+			}{
+				{top, x, y, x + renderCellSize, y},
+				{left, x, y, x, y + renderCellSize},
+				{bottom, x, y + renderCellSize, x + renderCellSize, y + renderCellSize},
+				{right, x + renderCellSize, y, x + renderCellSize, y + renderCellSize},
+			} {
+				line := fmt.Sprintf(`<line x1="%d" y1="%d" x2="%d" y2="%d"/>`+"\n", edge.x1, edge.y1, edge.x2, edge.y2)
+				if edge.thick {
+					thick.WriteString(line)
+				} else if edge.x1 == x && edge.y1 == y {
+					thin.WriteString(line)
+				}
+			}
+
+			if puzzle[r][c] > 0 {
+				style := `fill="#1f5fa8"`
+				if originalPuzzle[r][c] > 0 {
+					style = `font-weight="bold"`
+				}
+				fmt.Fprintf(&b, `<text x="%d" y="%d" %s>%s</text>`+"\n", x+renderCellSize/2, y+renderCellSize*3/4, style, solver.SymbolText(symbols, puzzle[r][c]))
+			}
+		}
+	}
+
+	fmt.Fprintf(&b, `<g stroke="#888" stroke-width="%d">`+"\n%s</g>\n", renderThinLine, thin.String())
+	fmt.Fprintf(&b, `<g stroke="black" stroke-width="%d" stroke-linecap="square">`+"\n%s</g>\n", renderThickLine, thick.String())
+	if len(signs) > 0 {
+		fmt.Fprintf(&b, `<g stroke="black" stroke-width="%d" fill="none">`+"\n", renderThickLine-1)
+		for _, sign := range signs {
+			ends, tip := signPoints(sign, margin)
+			fmt.Fprintf(&b, `<polyline points="%d,%d %d,%d %d,%d"/>`+"\n", ends[0].X, ends[0].Y, tip.X, tip.Y, ends[1].X, ends[1].Y)
+		}
+		b.WriteString("</g>\n")
+	}
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Returns the puzzle as an image, for writing as a PNG. Numbers are drawn with the pixel font, which has
+// no bold face, so clues are told apart by colour alone.
+func renderImage(puzzle [][]int, originalPuzzle [][]int, regionMap [][]int, signs [][2]solver.Cell, symbols string) image.Image {
+
+	margin := renderThickLine
+	size := len(puzzle)*renderCellSize + 2*margin
+
+	img := image.NewRGBA(image.Rect(0, 0, size, size))
+	white, grey, black, blue := color.RGBA{255, 255, 255, 255}, color.RGBA{136, 136, 136, 255}, color.RGBA{0, 0, 0, 255}, color.RGBA{31, 95, 168, 255}
+
+	fill := func(x1, y1, x2, y2 int, c color.Color) {
+		for x := x1; x < x2; x++ {
+			for y := y1; y < y2; y++ {
+				img.Set(x, y, c)
+			}
+		}
+	}
+
+	fill(0, 0, size, size, white)
+
+	// Thin lines are drawn for every square first, then thick lines over them along the region borders
+	for pass := 0; pass < 2; pass++ {
+		for r := range puzzle {
+			for c := range puzzle[r] {
+				if puzzle[r][c] == solver.BlockedSquare {
+					continue
+				}
+
+				x, y := margin+c*renderCellSize, margin+r*renderCellSize
+				top, left, bottom, right := squareEdges(puzzle, regionMap, r, c)
+
+				if pass == 0 {
+					fill(x, y, x+renderCellSize+renderThinLine, y+renderThinLine, grey)
+					fill(x, y, x+renderThinLine, y+renderCellSize+renderThinLine, grey)
+					fill(x, y+renderCellSize, x+renderCellSize+renderThinLine, y+renderCellSize+renderThinLine, grey)
+					fill(x+renderCellSize, y, x+renderCellSize+renderThinLine, y+renderCellSize+renderThinLine, grey)
+					continue
+				}
+
+				half := renderThickLine / 2
+				if top {
+					fill(x-half, y-half, x+renderCellSize+half+1, y+half+1, black)
+				}
+				if left {
+					fill(x-half, y-half, x+half+1, y+renderCellSize+half+1, black)
+				}
+				if bottom {
+					fill(x-half, y+renderCellSize-half, x+renderCellSize+half+1, y+renderCellSize+half+1, black)
+				}
+				if right {
+					fill(x+renderCellSize-half, y-half, x+renderCellSize+half+1, y+renderCellSize+half+1, black)
+				}
+			}
+		}
+	}
+
+	// Each stroke of a sign is drawn as a run of small squares from one end to the other
+	stroke := func(from image.Point, to image.Point) {
+		steps := abs(to.X-from.X) + abs(to.Y-from.Y)
+		for step := 0; step <= steps; step++ {
+			x, y := from.X+(to.X-from.X)*step/steps, from.Y+(to.Y-from.Y)*step/steps
+			fill(x-1, y-1, x+1, y+1, black)
+		}
+	}
+	for _, sign := range signs {
+		ends, tip := signPoints(sign, margin)
+		stroke(ends[0], tip)
+		stroke(ends[1], tip)
+	}
+
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if puzzle[r][c] <= 0 {
+				continue
+			}
+
+			colour := blue
+			if originalPuzzle[r][c] > 0 {
+				colour = black
+			}
+
+			text := solver.SymbolText(symbols, puzzle[r][c])
+			scale := 5
+			if len(text) > 1 {
+				scale = 3
+			}
+			width, height := pixelTextSize(text, scale)
+			drawPixelText(img, text, margin+c*renderCellSize+(renderCellSize-width)/2, margin+r*renderCellSize+(renderCellSize-height)/2, scale, colour)
+		}
+	}
+
+	return img
+}
+
+// The render subcommand. Reads a puzzle (and optionally the puzzle it was solved from, whose clues are
+// drawn in bold) and draws it as an SVG or PNG image.
+func renderCommand(args []string) error {
+
+	flags := flag.NewFlagSet("render", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, json, killer, consecutive, jigsaw, samurai, sdk, sdm or ss). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which decides whether blocks are drawn ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file containing the puzzle or solution to draw (- reads standard input)")
+	linePtr := flags.Int("l", 1, "The line of the puzzle in the file (or the grid number in sdk and ss files)")
+	originalFilePtr := flags.String("orig", "", "An optional file containing the original puzzle, whose clues are drawn in bold")
+	originalLinePtr := flags.String("orig-l", "1", "The line of the original puzzle in the -orig file")
+	outPtr := flags.String("o", "", "The .svg or .png file to draw the puzzle in")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	line, lineErr := checkPositiveInt("l", *linePtr)
+	originalLine, originalLineErr := parsePositiveInt("orig-l", *originalLinePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, originalLineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *outPtr == "" {
+		return flagErrorf("the image to draw must be given with -o")
+	}
+	if err := checkImageFile("o", *outPtr); err != nil {
+		return err
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if _, err := variantConstraints(*variantPtr, blockXDim, blockYDim); err != nil {
+		return err
+	}
+
+	// Without -m, the puzzle and the original are each read in the mode of their file's extension
+	readFile := func(filename string, line int) ([][]int, [][]int, [][2]solver.Cell, error) {
+		mode := *inputModePtr
+		if mode == "" {
+			mode = inputModeForFile(filename)
+		}
+
+		inFile, err := openInput(filename, defaultFetchTimeout)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		defer inFile.Close()
+
+		puzzle, regionMap, _, extra, err := readPuzzle(inFile, mode, line, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		return puzzle, regionMap, inequalitySigns(extra), err
+	}
+
+	puzzle, regionMap, signs, err := readFile(*filePtr, line)
+	if err != nil {
+		return err
+	}
+
+	// Without the original puzzle every number is drawn as a clue. A solution drawn from a plain grid
+	// takes its inequality signs from the original
+	originalPuzzle := puzzle
+	if *originalFilePtr != "" {
+		var originalSigns [][2]solver.Cell
+		originalPuzzle, _, originalSigns, err = readFile(*originalFilePtr, originalLine)
+		if err != nil {
+			return err
+		}
+		if signs == nil {
+			signs = originalSigns
+		}
+		if len(originalPuzzle) != len(puzzle) {
+			return puzzleErrorf("the original puzzle is %dx%d but the puzzle to draw is %dx%d", len(originalPuzzle), len(originalPuzzle), len(puzzle), len(puzzle))
+		}
+	}
+
+	if regionMap == nil && solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	return renderPuzzleFile(*outPtr, puzzle, originalPuzzle, regionMap, signs, symbols)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// A solve recorded with -record for reproducing with -replay: the seed the random numbers were drawn
+// with (which every annealer's own random numbers are drawn from in turn), the flags it was run with, the
+// hash of its puzzle and what came of it.
+type runRecord struct {
+	Seed       int64             `json:"seed"`
+	PuzzleHash string            `json:"puzzle_hash"`
+	Flags      map[string]string `json:"flags"`
+	Solved     bool              `json:"solved"`
+	Cost       float64           `json:"cost"`
+	Solution   string            `json:"solution"`
+}
+
+// The flags that aren't recorded, since they only say where the run's output goes or how it was set up
+// (the settings of a -config file or -preset are recorded as the flags they set).
+var unrecordedFlags = map[string]bool{
+	"record": true, "replay": true, "config": true, "preset": true, "o": true, "output": true, "checkpoint": true,
+	"resume": true, "results": true, "training-out": true, "pprof": true,
+}
+
+// The flags of the search itself, which are recorded even when they weren't given, since a default can
+// differ where the run is replayed: -a is one annealer per CPU by default.
+var searchFlags = []string{"a", "t", "c", "i", "s", "ladder", "accept", "init", "algo"}
+
+// Starts the record of a solve with the seed, the search flags it ran with and every other flag it was
+// given, however it was given.
+func newRunRecord(flags *flag.FlagSet, seed int64) runRecord {
+
+	record := runRecord{Seed: seed, Flags: map[string]string{}}
+	flags.Visit(func(f *flag.Flag) {
+		if !unrecordedFlags[f.Name] {
+			record.Flags[f.Name] = f.Value.String()
+		}
+	})
+	for _, name := range searchFlags {
+		if f := flags.Lookup(name); f != nil {
+			record.Flags[name] = f.Value.String()
+		}
+	}
+	record.Flags["seed"] = strconv.FormatInt(seed, 10)
+
+	return record
+}
+
+// Writes a run record to a file as indented JSON.
+func writeRunRecord(filename string, record runRecord) error {
+
+	data, err := json.MarshalIndent(record, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return inputError(os.WriteFile(filename, append(data, '\n'), 0644))
+}
+
+// Reads a run record written by -record.
+func readRunRecord(filename string) (record runRecord, e error) {
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return record, err
+	}
+	defer inFile.Close()
+
+	if err := json.NewDecoder(inFile).Decode(&record); err != nil {
+		return record, inputError(fmt.Errorf("%s isn't a run recorded with -record: %w", filename, err))
+	}
+
+	return record, nil
+}
+
+// Sets the flags of the run recorded in the -replay file that weren't given on the command line, so a
+// replay can add reports like -vv or -trace, or change a parameter to see what difference it makes.
+func applyReplay(flags *flag.FlagSet) error {
+
+	filename := flags.Lookup("replay").Value.String()
+	if filename == "" {
+		return nil
+	}
+
+	record, err := readRunRecord(filename)
+	if err != nil {
+		return err
+	}
+
+	given := make(map[string]bool)
+	flags.Visit(func(f *flag.Flag) {
+		given[f.Name] = true
+	})
+
+	names := make([]string, 0, len(record.Flags))
+	for name := range record.Flags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if flags.Lookup(name) == nil || unrecordedFlags[name] {
+			return flagErrorf("%s records the flag -%s, which isn't a flag of this command", filename, name)
+		}
+		if given[name] {
+			continue
+		}
+		if err := flags.Set(name, record.Flags[name]); err != nil {
+			return flagErrorf("%s records an invalid value %q for -%s: %v", filename, record.Flags[name], name, err)
+		}
+	}
+
+	return nil
+}
+
+// Logs whether a replayed run reproduced the recorded one, and if not how its result differs.
+func (r runRecord) reportReplay(replayed runRecord) {
+
+	var differences []string
+
+	if replayed.Solved != r.Solved {
+		differences = append(differences, fmt.Sprintf("solved is %v, not %v", replayed.Solved, r.Solved))
+	}
+	if replayed.Cost != r.Cost {
+		differences = append(differences, fmt.Sprintf("the final cost is %v, not %v", replayed.Cost, r.Cost))
+	}
+	if replayed.Solution != r.Solution {
+		differences = append(differences, "the final candidate is different")
+	}
+
+	if len(differences) > 0 {
+		slog.Warn("the replay differs from the recorded run", "differences", differences)
+	} else {
+		slog.Info("the replay reproduced the recorded run", "solved", replayed.Solved, "cost", replayed.Cost)
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestReplayRunsWithTheRecordedAnnealersOnAnyNumberOfCPUs(t *testing.T) {
+
+	dir := t.TempDir()
+	puzzleFile, recorded, replayed := filepath.Join(dir, "puzzle.txt"), filepath.Join(dir, "run.json"), filepath.Join(dir, "replay.json")
+	if err := os.WriteFile(puzzleFile, []byte(testPuzzle+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	solve := func(procs int, args ...string) runRecord {
+		defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(procs))
+		if err := solveCommand(append(args, "-q")); err != nil && exitCode(err) != exitNoSolution {
+			t.Fatal(err)
+		}
+		record, err := readRunRecord(args[len(args)-1])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return record
+	}
+
+	// Recorded on 2 CPUs, where -a defaults to 4 annealers, and replayed on 16, where it defaults to 8
+	run := solve(2, "-f", puzzleFile, "-seed", "3", "-i", "50", "-record", recorded)
+	replay := solve(16, "-replay", recorded, "-record", replayed)
+
+	if run.Flags["a"] != "4" || replay.Flags["a"] != "4" {
+		t.Errorf("the run was recorded with -a %q and replayed with -a %q, not the 4 it ran with", run.Flags["a"], replay.Flags["a"])
+	}
+	if replay.Solution != run.Solution || replay.Cost != run.Cost {
+		t.Errorf("the replay ended with %s (cost %v), not the recorded %s (cost %v)", replay.Solution, replay.Cost, run.Solution, run.Cost)
+	}
+}
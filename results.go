@@ -0,0 +1,75 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// The seed the run's solves are drawn from, recorded with every result.
+var randomSeed int64
+
+// The columns of the results store that the attempts are grouped by for each -by of the stats subcommand.
+var statsGroupings = map[string]string{
+	"params": "temperature, cooling_rate, iterations, swaps, annealers",
+	"puzzle": "puzzle_hash",
+}
+
+// The results of one group of solve attempts, as the stats subcommand reports them. Key is the group's
+// annealing parameters, or the hash of its puzzle.
+type statsGroup struct {
+	key        string
+	runs       int
+	solved     int
+	medianTime time.Duration
+	meanCost   float64
+}
+
+// The stats subcommand. Queries a results store for how often and how quickly the attempts of each
+// combination of the annealing parameters (or each puzzle) solved, and their mean final cost.
+func statsCommand(args []string) error {
+
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	resultsPtr := flags.String("results", "results.db", "The results database written by solving with -results")
+	byPtr := flags.String("by", "params", "What to group the attempts by (params or puzzle)")
+	puzzlePtr := flags.String("puzzle", "", "Only count the attempts at the puzzle with this hash")
+	topPtr := flags.Int("top", 0, "The number of the best groups to report (0 reports them all)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	if statsGroupings[*byPtr] == "" {
+		return flagErrorf("unknown grouping %q for -by (expected params or puzzle)", *byPtr)
+	}
+
+	// Opening a database that isn't there would make an empty one
+	if _, err := os.Stat(*resultsPtr); err != nil {
+		return inputError(err)
+	}
+	store, err := openResultsStore(*resultsPtr)
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	groups, err := store.stats(*byPtr, *puzzlePtr, *topPtr)
+	if err != nil {
+		return err
+	}
+	if len(groups) == 0 {
+		return puzzleErrorf("there are no results to report in %s", *resultsPtr)
+	}
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	defer out.Flush()
+
+	fmt.Fprintf(out, "solved\trate\tmedian time\tmean cost\t\t%s\n", *byPtr)
+	for _, group := range groups {
+		fmt.Fprintf(out, "%d/%d\t%.0f%%\t%s\t%.2f\t\t%s\n", group.solved, group.runs, 100*float64(group.solved)/float64(group.runs), group.medianTime.Round(time.Millisecond), group.meanCost, group.key)
+	}
+
+	return nil
+}
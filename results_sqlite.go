@@ -0,0 +1,134 @@
+//go:build !js
+
+package main
+
+import (
+	"database/sql"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+// The table every solve attempt is recorded in, with the fields of training mode's results and when the
+// attempt was recorded. Matches is null for attempts at puzzles without a known solution.
+const resultsSchema = `
+CREATE TABLE IF NOT EXISTS attempts (
+	id INTEGER PRIMARY KEY,
+	recorded_at TEXT NOT NULL,
+	line INTEGER NOT NULL,
+	puzzle_hash TEXT NOT NULL,
+	temperature REAL NOT NULL,
+	cooling_rate REAL NOT NULL,
+	iterations INTEGER NOT NULL,
+	swaps INTEGER NOT NULL,
+	annealers INTEGER NOT NULL,
+	seed INTEGER NOT NULL,
+	solved INTEGER NOT NULL,
+	seconds REAL NOT NULL,
+	final_cost REAL NOT NULL,
+	iterations_executed INTEGER NOT NULL,
+	restarts INTEGER NOT NULL,
+	matches INTEGER
+);
+CREATE INDEX IF NOT EXISTS attempts_puzzle_hash ON attempts (puzzle_hash);
+`
+
+// An SQLite database of solve attempts, which any number of runs can record theirs in over a long tuning
+// campaign and the stats subcommand queries.
+type resultsStore struct {
+	db *sql.DB
+}
+
+// Opens the results database in the named file, creating it (and its table) if need be. Runs recording in
+// the same database at once wait up to five seconds for each other's writes.
+func openResultsStore(filename string) (*resultsStore, error) {
+
+	db, err := sql.Open("sqlite", filename)
+	if err != nil {
+		return nil, inputError(err)
+	}
+	// The busy timeout is kept by the connection, so there is only the one
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec("PRAGMA busy_timeout = 5000"); err != nil {
+		db.Close()
+		return nil, inputError(err)
+	}
+	if _, err := db.Exec(resultsSchema); err != nil {
+		db.Close()
+		return nil, inputError(err)
+	}
+
+	return &resultsStore{db}, nil
+}
+
+// Records a solve attempt.
+func (s *resultsStore) write(record trainingRecord) error {
+
+	_, err := s.db.Exec(`INSERT INTO attempts (recorded_at, line, puzzle_hash, temperature, cooling_rate, iterations, swaps, annealers, seed, solved, seconds, final_cost, iterations_executed, restarts, matches)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		time.Now().UTC().Format(time.RFC3339), record.Line, record.PuzzleHash, record.Temperature, record.CoolingRate, record.Iterations, record.Swaps, record.Annealers, record.Seed,
+		record.Solved, record.Seconds, record.FinalCost, record.IterationsExecuted, record.Restarts, record.Matches)
+
+	return err
+}
+
+// Returns the attempts grouped by their annealing parameters or their puzzle (see statsGroupings), only
+// counting those at the puzzle with the given hash unless it is empty. Groups are ordered best first: by
+// solve rate, then by median time, then by their first attempt. Only the first top are returned, unless
+// top is 0.
+func (s *resultsStore) stats(by string, puzzleHash string, top int) (groups []statsGroup, e error) {
+
+	columns := statsGroupings[by]
+	if top <= 0 {
+		top = -1
+	}
+
+	// The median is the middle time of a group's attempts, or the mean of the middle two
+	rows, err := s.db.Query(`
+		WITH ranked AS (
+			SELECT id, `+columns+`, solved, seconds, final_cost,
+				ROW_NUMBER() OVER (PARTITION BY `+columns+` ORDER BY seconds) AS n,
+				COUNT(*) OVER (PARTITION BY `+columns+`) AS runs
+			FROM attempts
+			WHERE ? = '' OR puzzle_hash = ?
+		)
+		SELECT `+columns+`, COUNT(*), SUM(solved),
+			AVG(CASE WHEN n IN ((runs + 1) / 2, (runs + 2) / 2) THEN seconds END) AS median,
+			AVG(final_cost)
+		FROM ranked
+		GROUP BY `+columns+`
+		ORDER BY CAST(SUM(solved) AS REAL) / COUNT(*) DESC, median, MIN(id)
+		LIMIT ?`, puzzleHash, puzzleHash, top)
+	if err != nil {
+		return nil, inputError(err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var group statsGroup
+		var params annealParams
+		var median float64
+
+		aggregates := []interface{}{&group.runs, &group.solved, &median, &group.meanCost}
+		if by == "puzzle" {
+			err = rows.Scan(append([]interface{}{&group.key}, aggregates...)...)
+		} else {
+			err = rows.Scan(append([]interface{}{&params.temperature, &params.coolingRate, &params.iterations, &params.swaps, &params.annealers}, aggregates...)...)
+			group.key = params.String()
+		}
+		if err != nil {
+			return nil, inputError(err)
+		}
+
+		group.medianTime = time.Duration(median * float64(time.Second))
+		groups = append(groups, group)
+	}
+
+	return groups, inputError(rows.Err())
+}
+
+// Closes the database.
+func (s *resultsStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResultsStoreGroupsAttemptsBestFirst(t *testing.T) {
+
+	store, err := openResultsStore(filepath.Join(t.TempDir(), "results.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	slow := annealParams{temperature: 1, coolingRate: 0.9, iterations: 100, swaps: 1, annealers: 4}
+	quick := annealParams{temperature: 2, coolingRate: 0.9, iterations: 100, swaps: 1, annealers: 4}
+	for _, attempt := range []struct {
+		params  annealParams
+		puzzle  string
+		solved  bool
+		seconds float64
+		cost    float64
+	}{
+		{slow, "a", true, 3, 0},
+		{slow, "b", true, 1, 0},
+		{slow, "a", false, 2, 6},
+		{slow, "b", true, 5, 0},
+		{quick, "a", true, 1, 0},
+		{quick, "a", false, 0.5, 4},
+		{quick, "b", false, 4, 2},
+	} {
+		record := trainingRecord{Temperature: attempt.params.temperature, CoolingRate: attempt.params.coolingRate, Iterations: attempt.params.iterations, Swaps: attempt.params.swaps,
+			Annealers: attempt.params.annealers, PuzzleHash: attempt.puzzle, Solved: attempt.solved, Seconds: attempt.seconds, FinalCost: attempt.cost}
+		if err := store.write(record); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, test := range []struct {
+		by, puzzle string
+		top        int
+		want       []statsGroup
+	}{
+		{"params", "", 0, []statsGroup{
+			{slow.String(), 4, 3, 2500 * time.Millisecond, 1.5},
+			{quick.String(), 3, 1, time.Second, 2},
+		}},
+		{"puzzle", "", 1, []statsGroup{{"b", 3, 2, 4 * time.Second, 2.0 / 3}}},
+		{"params", "a", 0, []statsGroup{
+			{quick.String(), 2, 1, 750 * time.Millisecond, 2},
+			{slow.String(), 2, 1, 2500 * time.Millisecond, 3},
+		}},
+	} {
+		groups, err := store.stats(test.by, test.puzzle, test.top)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(groups, test.want) {
+			t.Errorf("the attempts by %s (at puzzle %q, top %d) were %+v, not %+v", test.by, test.puzzle, test.top, groups, test.want)
+		}
+	}
+}
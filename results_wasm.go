@@ -0,0 +1,24 @@
+//go:build js
+
+package main
+
+// The SQLite driver isn't built for WebAssembly, which has no files to keep a database in anyway, so
+// there the results store is only a stand-in that can't be opened.
+type resultsStore struct{}
+
+// Reports that WebAssembly builds can't keep results.
+func openResultsStore(filename string) (*resultsStore, error) {
+	return nil, flagErrorf("-results needs SQLite, which isn't built into WebAssembly")
+}
+
+func (s *resultsStore) write(record trainingRecord) error {
+	return nil
+}
+
+func (s *resultsStore) stats(by string, puzzleHash string, top int) (groups []statsGroup, e error) {
+	return nil, nil
+}
+
+func (s *resultsStore) Close() error {
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// How the puzzles of a batch that aren't solved are retried: up to retries more times, each with the
+// iterations and temperature of the attempt before multiplied by the given factors, and more annealers.
+// Budget, unless zero, is the most time all the attempts at one puzzle may take between them (the
+// -per-puzzle-timeout).
+type retryPolicy struct {
+	retries     int
+	iterations  float64
+	temperature float64
+	annealers   int
+	budget      time.Duration
+}
+
+// Parses the value of the -retry-policy flag: none, or the number of retries optionally followed by how
+// each escalates the parameters of the attempt before it, eg. 3:iterations=2,temperature=1.5,annealers=2.
+// Those left out double the iterations, raise the temperature by half and add 2 annealers.
+func parseRetryPolicy(text string) (policy retryPolicy, e error) {
+
+	if text == "none" {
+		return policy, nil
+	}
+
+	count, escalation, _ := strings.Cut(text, ":")
+	retries, err := strconv.Atoi(count)
+	if err != nil || retries < 0 {
+		return policy, flagErrorf("invalid value %q for -retry-policy: expected none, or a number of retries optionally followed by :iterations=X,temperature=Y,annealers=Z", text)
+	}
+	policy = retryPolicy{retries: retries, iterations: 2, temperature: 1.5, annealers: 2}
+
+	if escalation == "" {
+		return policy, nil
+	}
+	for _, setting := range strings.Split(escalation, ",") {
+		name, value, _ := strings.Cut(setting, "=")
+		switch name {
+		case "iterations", "temperature":
+			factor, err := strconv.ParseFloat(value, 64)
+			if err != nil || !(factor >= 1) {
+				return policy, flagErrorf("invalid value %q for -retry-policy: the %s factor must be a number of at least 1", text, name)
+			}
+			if name == "iterations" {
+				policy.iterations = factor
+			} else {
+				policy.temperature = factor
+			}
+		case "annealers":
+			added, err := strconv.Atoi(value)
+			if err != nil || added < 0 {
+				return policy, flagErrorf("invalid value %q for -retry-policy: the annealers added must be a whole number of at least 0", text)
+			}
+			policy.annealers = added
+		default:
+			return policy, flagErrorf("invalid value %q for -retry-policy: unknown setting %q (expected iterations, temperature or annealers)", text, name)
+		}
+	}
+
+	return policy, nil
+}
+
+// Returns the options of the given retry (counting from 1), escalated from those of the first attempt.
+func (p retryPolicy) escalate(options solver.Options, retry int) solver.Options {
+
+	for i := 0; i < retry; i++ {
+		options.Iterations = int(float64(options.Iterations) * p.iterations)
+		options.Temperature *= p.temperature
+		options.Annealers += p.annealers
+	}
+	// A listed -ladder carries on past its last temperature for the extra annealers (see solver.ListLadder)
+	return options
+}
+
+// Searches for a solution, retrying with escalated options as long as the puzzle isn't solved and the
+// policy has retries and time left. Each attempt gives up once the policy's budget for the puzzle is
+// spent, if that comes before its own timeout. The stats add up every attempt, and their Restarts are the
+// number of retries made, so 0 means the first attempt settled it.
+func (p retryPolicy) search(originalPuzzle [][]int, constraints []solver.Constraint, options solver.Options) (solvedPuzzle [][]int, solutionFound bool, stats solver.Stats) {
+
+	start := time.Now()
+	for retry := 0; ; retry++ {
+		attemptOptions := p.escalate(options, retry)
+		if p.budget > 0 {
+			// A timeout of zero is none at all, so an attempt with its budget all but spent gets a nanosecond
+			left := time.Duration(math.Max(float64(p.budget-time.Since(start)), 1))
+			if attemptOptions.Timeout == 0 || left < attemptOptions.Timeout {
+				attemptOptions.Timeout = left
+			}
+		}
+		solved, found, attempt := solver.Search(originalPuzzle, constraints, attemptOptions)
+
+		attempt.Steps += stats.Steps
+		attempt.Iterations += stats.Iterations
+		attempt.CostEvaluations += stats.CostEvaluations
+		attempt.Exchanges += stats.Exchanges
+		attempt.WallTime += stats.WallTime
+		attempt.Restarts = retry
+		stats = attempt
+
+		if found || retry >= p.retries || p.outOfTime(start) {
+			return solved, found, stats
+		}
+	}
+}
+
+// Reports whether a puzzle whose attempts started at start has used up the policy's budget.
+func (p retryPolicy) outOfTime(start time.Time) bool {
+	return p.budget > 0 && time.Since(start) >= p.budget
+}
+
+// Returns the options with their OnNewBest hook wrapped to keep the lowest cost any attempt at a puzzle
+// reaches, which is recorded for a puzzle that runs out of time in place of the cost it ended on.
+func trackBestCost(options solver.Options) (solver.Options, *float64) {
+
+	best := math.Inf(1)
+	onNewBest := options.OnNewBest
+	options.OnNewBest = func(candidate [][]int, cost float64) {
+		best = math.Min(best, cost)
+		if onNewBest != nil {
+			onNewBest(candidate, cost)
+		}
+	}
+
+	return options, &best
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns the top left corners of the five overlapping grids of a samurai sudoku, in the order top left,
+// top right, middle, bottom left and bottom right, along with the dimension of the composite puzzle.
+// Each corner grid shares one block with the middle grid, so for a standard samurai sudoku of 9x9 grids
+// the composite puzzle is 21x21.
+func samuraiLayout(blockDim int) (corners []solver.Cell, compositeDim int) {
+
+	gridDim := blockDim * blockDim
+	middle := gridDim - blockDim
+	outer := 2 * middle
+
+	corners = []solver.Cell{{Row: 0, Col: 0}, {Row: 0, Col: outer}, {Row: middle, Col: middle}, {Row: outer, Col: 0}, {Row: outer, Col: outer}}
+
+	return corners, outer + gridDim
+}
+
+// Read in a samurai sudoku. The five grids are given on five consecutive lines starting at the selected
+// one, each in the one-line format and in the order top left, top right, middle, bottom left and bottom
+// right. Clues in the shared corner blocks may be given in either grid, but must agree where they are
+// given in both. Squares outside of all five grids are set to solver.BlockedSquare.
+func readInSamurai(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, e error) {
+
+	if blockXDim != blockYDim {
+		return nil, flagErrorf("samurai sudoku needs square blocks, not %dx%d", blockXDim, blockYDim)
+	}
+
+	corners, compositeDim := samuraiLayout(blockXDim)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Split(scanPuzzleLines)
+
+	// Start puzzle at line 1 (more user friendly)
+	lineCounter := 1
+
+	gridTexts := make([]string, 0, len(corners))
+
+	for scanner.Scan() {
+
+		if lineCounter >= line && lineCounter < line+len(corners) {
+			gridTexts = append(gridTexts, scanner.Text())
+		}
+
+		lineCounter++
+	}
+
+	if e = inputError(scanner.Err()); e != nil {
+		return nil, e
+	}
+
+	if len(gridTexts) < len(corners) {
+		return nil, puzzleErrorf("a samurai sudoku needs %d grids on lines %d to %d, but only %d were found", len(corners), line, line+len(corners)-1, len(gridTexts))
+	}
+
+	puzzle = make([][]int, compositeDim)
+	for i := range puzzle {
+		puzzle[i] = make([]int, compositeDim)
+		for j := range puzzle[i] {
+			puzzle[i][j] = solver.BlockedSquare
+		}
+	}
+
+	for g, corner := range corners {
+
+		grid, err := readInOneLine(strings.NewReader(gridTexts[g]), 1, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range grid {
+			for j := range grid[i] {
+
+				square := &puzzle[corner.Row+i][corner.Col+j]
+
+				if *square > 0 && grid[i][j] > 0 && *square != grid[i][j] {
+					return nil, puzzleErrorf("grid %d gives %d at row %d, column %d, but an overlapping grid gives %d", g+1, grid[i][j], i+1, j+1, *square)
+				}
+
+				if *square <= 0 {
+					*square = grid[i][j]
+				}
+			}
+		}
+	}
+
+	return puzzle, nil
+}
+
+// Builds the constraints of a samurai sudoku: the rows, columns and blocks of each of the five grids.
+// The blocks shared between grids only appear once.
+func samuraiConstraints(blockDim int) []solver.Constraint {
+
+	corners, _ := samuraiLayout(blockDim)
+	gridDim := blockDim * blockDim
+
+	var rows, columns, blocks [][]solver.Cell
+	seenBlocks := make(map[solver.Cell]bool)
+
+	for _, corner := range corners {
+
+		for i := 0; i < gridDim; i++ {
+			row := make([]solver.Cell, gridDim)
+			column := make([]solver.Cell, gridDim)
+			for j := 0; j < gridDim; j++ {
+				row[j] = solver.Cell{Row: corner.Row + i, Col: corner.Col + j}
+				column[j] = solver.Cell{Row: corner.Row + j, Col: corner.Col + i}
+			}
+			rows = append(rows, row)
+			columns = append(columns, column)
+		}
+
+		for b := 0; b < gridDim; b++ {
+			block := solver.BlockCells(b, blockDim, blockDim)
+			for k := range block {
+				block[k].Row += corner.Row
+				block[k].Col += corner.Col
+			}
+
+			if !seenBlocks[block[0]] {
+				seenBlocks[block[0]] = true
+				blocks = append(blocks, block)
+			}
+		}
+	}
+
+	return []solver.Constraint{solver.UniqueConstraint{Kind: "row", Groups: rows}, solver.UniqueConstraint{Kind: "column", Groups: columns}, solver.UniqueConstraint{Kind: "block", Groups: blocks}}
+}
+
+// Returns the region map of a samurai sudoku used to draw it: every block of every grid gets its own
+// region, and squares outside of the grids are in region -1.
+func samuraiRegionMap(blockDim int) (regionMap [][]int) {
+
+	_, compositeDim := samuraiLayout(blockDim)
+
+	regionMap = make([][]int, compositeDim)
+	for i := range regionMap {
+		regionMap[i] = make([]int, compositeDim)
+		for j := range regionMap[i] {
+			regionMap[i][j] = -1
+		}
+	}
+
+	for b, block := range samuraiConstraints(blockDim)[2].Regions() {
+		for _, cell := range block {
+			regionMap[cell.Row][cell.Col] = b
+		}
+	}
+
+	return regionMap
+}
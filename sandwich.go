@@ -0,0 +1,46 @@
+package main
+
+import (
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns the sandwich constraint of a puzzle of the given dimension with the clues of its rows and its
+// columns, where a nil clue leaves the line without one. Either list may be empty, but otherwise has a
+// clue (or nil) for every line.
+func newSandwichConstraint(puzzleDim int, rows []*int, columns []*int) (s solver.SandwichConstraint, e error) {
+
+	// The most the numbers between the 1 and the highest can add up to is all of them
+	most := puzzleDim*(puzzleDim+1)/2 - 1 - puzzleDim
+
+	for _, line := range []struct {
+		kind  string
+		field string
+		sums  []*int
+	}{{"row", "sandwich_rows", rows}, {"column", "sandwich_columns", columns}} {
+		if len(line.sums) == 0 {
+			continue
+		}
+		if len(line.sums) != puzzleDim {
+			return s, puzzleErrorf("%s has %d clues, but the puzzle has %d %ss", line.field, len(line.sums), puzzleDim, line.kind)
+		}
+		for i, sum := range line.sums {
+			if sum == nil {
+				continue
+			}
+			if *sum < 0 || *sum > most {
+				return s, puzzleErrorf("%s has the clue %d for %s %d, which isn't between 0 and %d", line.field, *sum, line.kind, i+1, most)
+			}
+			cells := make([]solver.Cell, puzzleDim)
+			for j := range cells {
+				if line.kind == "row" {
+					cells[j] = solver.Cell{Row: i, Col: j}
+				} else {
+					cells[j] = solver.Cell{Row: j, Col: i}
+				}
+			}
+			s.Clues = append(s.Clues, solver.SandwichClue{Kind: line.kind, Index: i + 1, Cells: cells, Sum: *sum})
+		}
+	}
+
+	return s, nil
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// How long the server waits for the headers of a request and for all of it, and how long it keeps an idle
+// connection open. An answer may take as long to write as the longest solve plus writeTimeoutMargin,
+// except for the event streams of /solves, which run for as long as their solve does.
+const (
+	readHeaderTimeout  = 10 * time.Second
+	readTimeout        = 30 * time.Second
+	idleTimeout        = 2 * time.Minute
+	writeTimeoutMargin = 30 * time.Second
+)
+
+// A request to solve a puzzle, as posted to /solve. Only the puzzle is needed; the rest default to the
+// same values as the solve command's flags.
+type solveRequest struct {
+	Puzzle         string  `json:"puzzle"`
+	Dim            string  `json:"dim"`
+	Variant        string  `json:"variant"`
+	Temperature    float64 `json:"temperature"`
+	CoolingRate    float64 `json:"cooling_rate"`
+	Iterations     int     `json:"iterations"`
+	Swaps          int     `json:"swaps"`
+	Annealers      int     `json:"annealers"`
+	TimeoutSeconds float64 `json:"timeout_seconds"`
+	puzzleMarkup
+}
+
+// The result of a solve, as returned from /solve. The solution is the best candidate when the puzzle
+// wasn't solved, and Cached says the solution came from the server's cache rather than annealing.
+type solveResponse struct {
+	Solved   bool    `json:"solved"`
+	TimedOut bool    `json:"timed_out"`
+	Cached   bool    `json:"cached,omitempty"`
+	Solution string  `json:"solution"`
+	Cost     float64 `json:"cost"`
+	Seconds  float64 `json:"seconds"`
+}
+
+// A puzzle read from a solve request, ready to anneal.
+type serverPuzzle struct {
+	puzzle      [][]int
+	constraints []solver.Constraint
+	symbols     string
+	params      annealParams
+	timeout     time.Duration
+}
+
+// The solve server: an HTTP API for solving puzzles, with metrics for monitoring it. Solutions are
+// cached when cache isn't nil, clients are rate limited when limiter isn't, and their solves running at
+// once are capped when concurrency isn't. The largest puzzle side, iterations and annealers a request
+// may ask for are capped at maxDim, maxIterations and maxAnnealers, unless they are 0, and further by
+// the caps of the API token a request is sent with, when the server has tokens. At most as many solves
+// run in the background for /solves as solveSlots holds. Daily holds the daily puzzles generated so
+// far, by date and grade, and dailyKey is the key their solutions are served with (none when empty).
+type solveServer struct {
+	maxTimeout    time.Duration
+	maxDim        int
+	maxIterations int
+	maxAnnealers  int
+	limiter       *rateLimiter
+	concurrency   *concurrencyCap
+	tokens        []apiToken
+	defaults      annealParams
+	metrics       *serverMetrics
+	cache         *solutionCache
+	jobsMutex     sync.Mutex
+	jobs          map[string]*solveJob
+	solveSlots    chan struct{}
+	dailyMutex    sync.Mutex
+	daily         map[string]dailyPuzzle
+	dailyKey      string
+}
+
+// Fills in the defaults of a solve request and reads its puzzle, returning an error describing anything
+// that is wrong with it.
+func (s *solveServer) readRequest(request solveRequest) (p serverPuzzle, e error) {
+
+	if request.Dim == "" {
+		request.Dim = "3x3"
+	}
+	if request.Variant == "" {
+		request.Variant = "standard"
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("dim", request.Dim)
+	if err != nil {
+		return p, err
+	}
+	if s.maxDim > 0 && blockXDim*blockYDim > s.maxDim {
+		return p, fmt.Errorf("invalid dim %q: this server solves puzzles no larger than %dx%d", request.Dim, s.maxDim, s.maxDim)
+	}
+
+	// Parameters the request leaves out take the server's defaults, if it has any, then the solver's
+	if request.Temperature == 0 {
+		request.Temperature = s.defaults.temperature
+	}
+	if request.CoolingRate == 0 {
+		request.CoolingRate = s.defaults.coolingRate
+	}
+	if request.Iterations == 0 {
+		request.Iterations = s.defaults.iterations
+	}
+	adaptiveSwaps := request.Swaps == 0 && s.defaults.adaptiveSwaps
+	if request.Swaps == 0 {
+		request.Swaps = s.defaults.swaps
+	}
+	if request.Annealers == 0 {
+		request.Annealers = s.defaults.annealers
+	}
+
+	options := solver.Options{Temperature: request.Temperature, CoolingRate: request.CoolingRate, Iterations: request.Iterations, Swaps: request.Swaps, Annealers: request.Annealers}.WithDefaults()
+	if err := options.Validate(); err != nil {
+		return p, err
+	}
+	if s.maxIterations > 0 && options.Iterations > s.maxIterations {
+		return p, fmt.Errorf("invalid iterations %d: this server runs no more than %d at each step", options.Iterations, s.maxIterations)
+	}
+	if s.maxAnnealers > 0 && options.Annealers > s.maxAnnealers {
+		return p, fmt.Errorf("invalid annealers %d: this server runs no more than %d at once", options.Annealers, s.maxAnnealers)
+	}
+	p.params = annealParams{options.Temperature, options.CoolingRate, options.Iterations, options.Swaps, options.Annealers, adaptiveSwaps}
+
+	// Every solve is cut short at the server's longest timeout, whatever it asks for
+	p.timeout = s.maxTimeout
+	if request.TimeoutSeconds > 0 && time.Duration(request.TimeoutSeconds*float64(time.Second)) < p.timeout {
+		p.timeout = time.Duration(request.TimeoutSeconds * float64(time.Second))
+	}
+
+	variant, err := variantConstraints(request.Variant, blockXDim, blockYDim)
+	if err != nil {
+		return p, err
+	}
+
+	p.symbols, err = puzzleSymbols("", "", blockXDim*blockYDim)
+	if err != nil {
+		return p, err
+	}
+
+	p.puzzle, err = readInOneLine(strings.NewReader(request.Puzzle), 1, "", ".", p.symbols, blockXDim, blockYDim)
+	if err != nil {
+		return p, err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(request.Variant) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+	markup, err := request.constraints(len(p.puzzle))
+	if err != nil {
+		return p, err
+	}
+	p.constraints = solver.PuzzleConstraints(len(p.puzzle), regionMap, append(variant, markup...), nil)
+
+	if conflicts := findClueConflicts(p.puzzle, p.constraints); len(conflicts) > 0 {
+		return p, puzzleErrorf("the clues conflict, so the puzzle has no solution (%v)", conflicts[0])
+	}
+
+	return p, nil
+}
+
+// Anneals the puzzle until it is solved, annealing gives up, its time runs out or cancel is closed (a nil
+// cancel never is), calling progress (if it isn't nil) after every cooling step.
+func (s *solveServer) solve(p serverPuzzle, progress func(solver.AnnealProgress), cancel <-chan struct{}) solveResponse {
+
+	s.metrics.started()
+	start := time.Now()
+
+	if solution, found := s.cache.lookup(p.puzzle, p.constraints); found {
+		response := solveResponse{
+			Solved:   true,
+			Cached:   true,
+			Solution: puzzleWriter{symbols: p.symbols, emptyValue: "."}.oneLine(solution),
+			Seconds:  time.Since(start).Seconds(),
+		}
+		s.metrics.finished("cached", response.Seconds, 0)
+		return response
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	closeStop := func() { stopOnce.Do(func() { close(stop) }) }
+	timer := time.AfterFunc(p.timeout, closeStop)
+	finished := make(chan struct{})
+	defer close(finished)
+	go func() {
+		select {
+		case <-cancel:
+			closeStop()
+		case <-finished:
+		}
+	}()
+	options := p.params.options()
+	options.OnCoolingStep = solver.CombineProgress(progress, s.metrics.countIterations(p.params.iterations), logReporter())
+	options.Stop = stop
+	solvedPuzzle, solved, _ := solver.Search(p.puzzle, p.constraints, options)
+	timedOut := !timer.Stop() && !solved
+
+	if solved && s.cache != nil {
+		if err := s.cache.store(p.puzzle, solvedPuzzle); err != nil {
+			slog.Error("caching a solution", "error", err)
+		}
+	}
+
+	response := solveResponse{
+		Solved:   solved,
+		TimedOut: timedOut,
+		Solution: puzzleWriter{symbols: p.symbols, emptyValue: "."}.oneLine(solvedPuzzle),
+		Cost:     solver.CostFunction(solvedPuzzle, p.constraints),
+		Seconds:  time.Since(start).Seconds(),
+	}
+
+	result := "unsolved"
+	if solved {
+		result = "solved"
+	} else if timedOut {
+		result = "timeout"
+	}
+	s.metrics.finished(result, response.Seconds, response.Cost)
+
+	return response
+}
+
+// Writes a JSON response.
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+// Writes an error as a JSON response.
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// Handles POST /solve, which solves the puzzle in the JSON request body and returns the result.
+func (s *solveServer) handleSolve(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeJSONError(w, http.StatusMethodNotAllowed, errors.New("puzzles are solved by POSTing them"))
+		return
+	}
+
+	_, p, ok := s.readRequestBody(w, r)
+	if !ok {
+		return
+	}
+	client, ok := s.startSolving(w, r)
+	if !ok {
+		return
+	}
+	defer s.concurrency.release(client)
+
+	writeJSON(w, http.StatusOK, s.solve(p, nil, nil))
+}
+
+// Reads the solve request in the JSON body of a request, returning it along with its puzzle. When it
+// can't be read, an error response is written and ok is false.
+func (s *solveServer) readRequestBody(w http.ResponseWriter, r *http.Request) (request solveRequest, p serverPuzzle, ok bool) {
+
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		s.metrics.rejected()
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		writeJSONError(w, status, err)
+		return request, p, false
+	}
+
+	p, err := s.readRequest(request)
+	if err == nil {
+		p, err = s.requestToken(r).capPuzzle(p)
+	}
+	if err != nil {
+		s.metrics.rejected()
+		writeJSONError(w, requestErrorStatus(err), err)
+		return request, p, false
+	}
+
+	return request, p, true
+}
+
+// Returns the status of the response to a request that couldn't be met: 422 Unprocessable Entity for
+// puzzles that are malformed or can't be solved, to tell them apart from other mistakes in the request,
+// and 400 Bad Request for those.
+func requestErrorStatus(err error) int {
+
+	var puzzleErr *solver.PuzzleError
+	if errors.As(err, &puzzleErr) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusBadRequest
+}
+
+// Handles GET /metrics, which Prometheus scrapes.
+func (s *solveServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	s.metrics.write(w)
+}
+
+// The serve subcommand. Runs the solver as an HTTP service: puzzles are POSTed to /solve as JSON, or to
+// /solves to solve them in the background while streaming their progress, or queued on /jobs for a pool
+// of workers when they are too big to wait for, /generate and /rate generate and rate them, /daily serves
+// a puzzle of each grade every day, /openapi.json describes all of those (see apiRoutes), and /metrics
+// reports how the solves are going for monitoring. Everything else serves the web frontend for solving
+// puzzles in the browser. With -grpc-addr, the gRPC API (see grpcSolver) is served too.
+func serveCommand(args []string) error {
+
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addrPtr := flags.String("addr", ":8080", "The address to listen on")
+	timeoutPtr := flags.Duration("timeout", time.Minute, "The longest a solve may run for before it gives up, whatever the request asks for")
+	cachePtr := flags.Bool("cache", false, "Remember the solution of every puzzle solved, and answer any puzzle asked for again with it instead of annealing")
+	cacheFilePtr := flags.String("cache-file", "", "A file to keep the -cache in, so the solutions are remembered from one run of the server to the next (implies -cache)")
+	addAnnealingFlags(flags)
+	maxDimPtr := flags.Int("max-dim", 0, "The largest puzzle side a request may ask for, eg. 16 turns away 25x25 puzzles (0 allows any)")
+	maxIterationsPtr := flags.Int("max-iterations", 0, "The most iterations at each step a request may ask for (0 allows any)")
+	maxAnnealersPtr := flags.Int("max-annealers", 0, "The most annealers a request may ask for (0 allows any)")
+	ratePtr := flags.Float64("rate", 0, "The requests a minute each client (by IP address) may make of the API on average, beyond which they are turned away (0 doesn't limit them)")
+	burstPtr := flags.Int("burst", 10, "The requests a client may make at once before -rate limits them")
+	maxConcurrentPtr := flags.Int("max-concurrent", 0, "The most solves each client may have running at once, on /solve and /solves alike, beyond which more are turned away (0 allows any)")
+	maxSolvesPtr := flags.Int("max-solves", 8, "The most solves started on /solves that may run in the background at once, beyond which more are turned away")
+	workersPtr := flags.Int("workers", 2, "The workers solving the jobs queued on /jobs, each one job at a time")
+	queuePtr := flags.Int("queue", 100, "The most jobs that may wait on /jobs for a worker, beyond which more are turned away")
+	jobTimeoutPtr := flags.Duration("job-timeout", time.Hour, "The longest a job queued on /jobs may run for before it gives up, whatever the request asks for")
+	jobsFilePtr := flags.String("jobs-file", "", "A file to keep the jobs of /jobs in, so they carry on from one run of the server to the next")
+	tokensPtr := flags.String("tokens", "", "A JSON file of API tokens, one of which every request of the API must then send in the "+tokenHeader+" header, each with a name and optional caps")
+	publicPtr := flags.Bool("public-metrics", false, "Serve /metrics and /openapi.json to anyone, without the API tokens and rate limit of the API, eg. for a Prometheus that can't send a token")
+	explorerPtr := flags.Bool("explorer", false, "Serve an API explorer at /docs, which loads Swagger UI from a CDN to show /openapi.json")
+	grpcAddrPtr := flags.String("grpc-addr", "", "An address to serve the gRPC API on as well, eg. :9090 (none when empty)")
+	dailyKeyPtr := flags.String("daily-key", "", "The key clients send as a bearer token for the solutions of daily puzzles from /daily/solution, which isn't served without one")
+	flags.String("config", "", configUsage)
+	addLogFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	defaults, err := annealingOptions(flags)
+	if err != nil {
+		return err
+	}
+
+	if *timeoutPtr <= 0 {
+		return flagErrorf("invalid value %q for -timeout: must be greater than 0", timeoutPtr.String())
+	}
+	for _, limit := range []struct {
+		name  string
+		value int
+	}{{"max-dim", *maxDimPtr}, {"max-iterations", *maxIterationsPtr}, {"max-annealers", *maxAnnealersPtr}} {
+		if limit.value < 0 {
+			return flagErrorf("invalid value %d for -%s: must be 0 or more", limit.value, limit.name)
+		}
+	}
+	if *ratePtr < 0 {
+		return flagErrorf("invalid value %v for -rate: must be 0 or more", *ratePtr)
+	}
+	if *maxConcurrentPtr < 0 {
+		return flagErrorf("invalid value %d for -max-concurrent: must be 0 or more", *maxConcurrentPtr)
+	}
+	if *burstPtr < 1 {
+		return flagErrorf("invalid value %d for -burst: must be at least 1", *burstPtr)
+	}
+	if *jobTimeoutPtr <= 0 {
+		return flagErrorf("invalid value %q for -job-timeout: must be greater than 0", jobTimeoutPtr.String())
+	}
+	if *maxSolvesPtr < 1 {
+		return flagErrorf("invalid value %d for -max-solves: must be at least 1", *maxSolvesPtr)
+	}
+	if *workersPtr < 1 {
+		return flagErrorf("invalid value %d for -workers: must be at least 1", *workersPtr)
+	}
+	if *queuePtr < 1 {
+		return flagErrorf("invalid value %d for -queue: must be at least 1", *queuePtr)
+	}
+
+	server := &solveServer{maxTimeout: *timeoutPtr, maxDim: *maxDimPtr, maxIterations: *maxIterationsPtr, maxAnnealers: *maxAnnealersPtr, defaults: defaults, metrics: newServerMetrics(), jobs: map[string]*solveJob{}, solveSlots: make(chan struct{}, *maxSolvesPtr), daily: map[string]dailyPuzzle{}, dailyKey: *dailyKeyPtr}
+	if *cachePtr || *cacheFilePtr != "" {
+		cache, err := openSolutionCache(*cacheFilePtr)
+		if err != nil {
+			return err
+		}
+		defer cache.Close()
+		server.cache = cache
+	}
+	if *tokensPtr != "" {
+		tokens, err := readAPITokens(*tokensPtr)
+		if err != nil {
+			return err
+		}
+		server.tokens = tokens
+		for _, token := range tokens {
+			slog.Info("accepting API token", "name", token.Name, "id", token.id())
+		}
+	}
+	if *ratePtr > 0 {
+		server.limiter = newRateLimiter(*ratePtr, *burstPtr)
+	}
+	if *maxConcurrentPtr > 0 {
+		server.concurrency = newConcurrencyCap(*maxConcurrentPtr)
+	}
+	queue, err := newJobQueue(server, *workersPtr, *queuePtr, *jobTimeoutPtr, *jobsFilePtr)
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	routes := server.apiRoutes(queue)
+	for _, route := range routes {
+		for _, pattern := range route.patterns {
+			mux.HandleFunc(pattern, server.limited(route.handler))
+		}
+	}
+	// What the server reports about itself is only for its clients too, unless it is opened up
+	described, measured := server.limited(server.handleOpenAPI(routes)), server.limited(server.handleMetrics)
+	if *publicPtr {
+		described, measured = server.handleOpenAPI(routes), server.handleMetrics
+	}
+	mux.HandleFunc("/openapi.json", described)
+	if *explorerPtr {
+		mux.HandleFunc("/docs", handleAPIExplorer)
+	}
+	mux.HandleFunc("/metrics", measured)
+	mux.Handle("/", webHandler())
+
+	httpServer := &http.Server{
+		Addr:              *addrPtr,
+		Handler:           mux,
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      *timeoutPtr + writeTimeoutMargin,
+		IdleTimeout:       idleTimeout,
+	}
+
+	// The gRPC API is served alongside, and stops when the HTTP server does
+	if *grpcAddrPtr != "" {
+		listener, err := net.Listen("tcp", *grpcAddrPtr)
+		if err != nil {
+			return inputError(err)
+		}
+		grpcServer := newGRPCServer(server)
+		defer grpcServer.Stop()
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				slog.Error("serving gRPC", "error", err)
+			}
+		}()
+		slog.Info("serving gRPC", "addr", *grpcAddrPtr)
+	}
+
+	slog.Info("serving", "addr", *addrPtr)
+	return inputError(httpServer.ListenAndServe())
+}
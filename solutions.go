@@ -0,0 +1,99 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The fewest squares two different solutions of a puzzle can differ in. Every solution is at least a
+// Latin square, and the smallest change that leaves a Latin square one is a swap of two numbers between
+// two rows and two columns, which changes four squares.
+const solutionDistance = 4
+
+// Keeps a search away from the solutions of a puzzle found already, for a pool of solutions. A candidate
+// close to one of them costs more the closer it is, and one that differs from all of them in at least
+// solutionDistance squares costs nothing, which every other solution does. The cost is the number of
+// squares each found solution is short of solutionDistance away from the candidate, added up.
+type distinctConstraint struct {
+	found [][][]int
+}
+
+func (d distinctConstraint) Cost(puzzle solver.Puzzle) (cost float64) {
+
+	for _, solution := range d.found {
+		differ := 0
+		for r := 0; r < len(solution) && differ < solutionDistance; r++ {
+			for c := range solution[r] {
+				if puzzle[r][c] != solution[r][c] {
+					differ++
+				}
+			}
+		}
+		if differ < solutionDistance {
+			cost += float64(solutionDistance - differ)
+		}
+	}
+
+	return cost
+}
+
+// The solutions to keep away from aren't regions of the puzzle, so there are none to mark.
+func (d distinctConstraint) Regions() [][]solver.Cell {
+	return nil
+}
+
+func (d distinctConstraint) String() string {
+	return "distinct"
+}
+
+// Searches for up to n distinct solutions of a puzzle, as -solutions. Each search after the first keeps
+// away from the solutions found before it (see distinctConstraint), so any solution it finds is a new
+// one, and the pool is given up on after misses searches in a row that find none. The first search is
+// seeded with the options' seed and each after it with one drawn from that, so the pool is repeated
+// given the seed. Returns the solutions in the order they were found and, when there are none, the
+// cheapest candidate any search ended on. The stats add up every search, and their Restarts are the
+// number of searches after the first.
+func solutionPool(originalPuzzle [][]int, constraints []solver.Constraint, options solver.Options, n int, misses int) (solutions [][][]int, best [][]int, stats solver.Stats) {
+
+	if options.Seed == 0 {
+		options.Seed = solver.FreshSeed()
+	}
+	seeds := rand.New(rand.NewSource(options.Seed))
+	bestCost := math.Inf(1)
+
+	for attempt, missed := 0, 0; len(solutions) < n && missed < misses; attempt++ {
+		attemptOptions := options
+		if attempt > 0 {
+			attemptOptions.Seed = seeds.Int63()
+		}
+		searchConstraints := constraints
+		if len(solutions) > 0 {
+			searchConstraints = append(append([]solver.Constraint(nil), constraints...), distinctConstraint{solutions})
+		}
+		candidate, found, searched := solver.Search(originalPuzzle, searchConstraints, attemptOptions)
+
+		searched.Steps += stats.Steps
+		searched.Iterations += stats.Iterations
+		searched.CostEvaluations += stats.CostEvaluations
+		searched.Exchanges += stats.Exchanges
+		searched.WallTime += stats.WallTime
+		searched.Restarts, searched.Seed = attempt, options.Seed
+		stats = searched
+
+		if found {
+			solutions, missed = append(solutions, candidate), 0
+		} else if missed++; len(solutions) == 0 {
+			if cost := solver.CostFunction(candidate, constraints); cost < bestCost {
+				best, bestCost = candidate, cost
+			}
+		}
+	}
+
+	if len(solutions) > 0 {
+		best = solutions[0]
+	}
+
+	return solutions, best, stats
+}
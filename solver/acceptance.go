@@ -0,0 +1,60 @@
+package solver
+
+import (
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// Decides whether an annealer at the given temperature moves from its current candidate solution, of
+// oldCost, to a neighbouring one of newCost that costs no less. Cheaper candidates are always accepted
+// without asking. Random numbers, if any are needed, are drawn from rng.
+type AcceptanceRule interface {
+	Accept(oldCost float64, newCost float64, temperature float64, rng *rand.Rand) bool
+}
+
+// The Metropolis criterion of classic simulated annealing: a costlier candidate is accepted with the
+// probability exp((oldCost - newCost) / temperature).
+type Metropolis struct{}
+
+func (Metropolis) Accept(oldCost float64, newCost float64, temperature float64, rng *rand.Rand) bool {
+	return acceptanceProbability(oldCost, newCost, temperature) > rng.Float64()
+}
+
+// Threshold accepting: a costlier candidate is accepted whenever it costs less than the temperature more
+// than the current one, with no chance involved.
+type ThresholdAccepting struct{}
+
+func (ThresholdAccepting) Accept(oldCost float64, newCost float64, temperature float64, rng *rand.Rand) bool {
+	return newCost-oldCost < temperature
+}
+
+// The great deluge: any candidate below the water level is accepted, however it compares with the
+// current one, as is any that costs the same as it. The level is Level times the temperature, so it sinks
+// as the annealers cool and is higher for the hotter annealers.
+type GreatDeluge struct {
+	Level float64
+}
+
+func (d GreatDeluge) Accept(oldCost float64, newCost float64, temperature float64, rng *rand.Rand) bool {
+	return newCost == oldCost || newCost < d.Level*temperature
+}
+
+// The acceptance rules selectable with -accept, by name.
+var AcceptanceRules = map[string]AcceptanceRule{
+	"metropolis": Metropolis{},
+	"threshold":  ThresholdAccepting{},
+	"deluge":     GreatDeluge{1000},
+}
+
+// Returns the names of all the acceptance rules, sorted and comma separated for use in messages.
+func AcceptanceRuleNames() string {
+
+	names := make([]string, 0, len(AcceptanceRules))
+	for name := range AcceptanceRules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
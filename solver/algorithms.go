@@ -0,0 +1,96 @@
+package solver
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Searches for a solution to a puzzle under the given constraints, the way anneal does: the options must
+// already have their defaults filled in, and along with the solution (or the best candidate found when
+// solutionFound is false) the stats of the search are returned.
+type solver func(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats)
+
+// The search algorithms selectable with -algo, by name. Every one of them shares the puzzles,
+// constraints and cost function of the annealer, and backtrack is an exact search to compare them with.
+var Algorithms = map[string]solver{
+	"anneal":     anneal,
+	"population": populationAnneal,
+	"genetic":    geneticSearch,
+	"tabu":       tabuSearch,
+	"backtrack":  backtrackSearch,
+}
+
+// Returns the names of all the search algorithms, sorted and comma separated for use in messages.
+func AlgorithmNames() string {
+
+	names := make([]string, 0, len(Algorithms))
+	for name := range Algorithms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Makes the random number generator of a search from the seed the options give (see FreshSeed for
+// searches without one), and fills in the built-in move, cost function and initialization for any the
+// options leave unset. Returns the options and the generator, which belongs to the search alone, along
+// with the puzzle's constraints in the flat representation when the built-in move and cost function are
+// used and the puzzle has one (see newFlatConstraints), since they run much quicker on it, and nil
+// otherwise.
+func prepareSearch(originalPuzzle [][]int, constraints []Constraint, options Options) (Options, *flatConstraints, *rand.Rand) {
+
+	if options.Seed == 0 {
+		options.Seed = FreshSeed()
+	}
+	rng := rand.New(rand.NewSource(options.Seed))
+
+	var flat *flatConstraints
+	if options.Neighbour == nil && options.Cost == nil {
+		flat, _ = newFlatConstraints(originalPuzzle, constraints)
+	}
+	if options.Neighbour == nil {
+		options.Neighbour = getNeighbour
+	}
+	if options.Cost == nil {
+		options.Cost = CostFunction
+	}
+	if options.Initialization == nil {
+		options.Initialization = Initializers["random"]
+	}
+
+	return options, flat, rng
+}
+
+// Returns a seed for a search the options give none, read from the operating system's random source so
+// that searches started at the same moment, on any goroutine, don't repeat each other. Never zero.
+func FreshSeed() int64 {
+
+	var buf [8]byte
+	if _, err := crand.Read(buf[:]); err != nil {
+		return time.Now().UnixNano() | 1
+	}
+	if seed := int64(binary.LittleEndian.Uint64(buf[:]) >> 1); seed != 0 {
+		return seed
+	}
+
+	return 1
+}
+
+// Searches for a solution with the algorithm the options name, which must be one of algorithms. The
+// stats record the seed and the puzzle's hash along with what the search did, so a search given no seed
+// can still be repeated from the one it drew.
+func Search(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	if options.Seed == 0 {
+		options.Seed = FreshSeed()
+	}
+	solvedPuzzle, solutionFound, stats = Algorithms[options.Algorithm](originalPuzzle, constraints, options)
+	stats.Seed, stats.PuzzleHash = options.Seed, PuzzleHash(originalPuzzle)
+
+	return solvedPuzzle, solutionFound, stats
+}
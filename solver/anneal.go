@@ -0,0 +1,443 @@
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Starts n annealing goroutines at the increasing temperatures of the Ladder option (doubling from each
+// to the next by default) where n is defined by the Annealers option. Once each annealing goroutine is
+// returned any hotter goroutines with lower costs than their cooler neighbours will trade their
+// candidate solutions with that neighbour. The options must already have their defaults filled in (see
+// Options.WithDefaults). Along with the result, returns the stats of what annealing did.
+func anneal(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	options, flat, rng := prepareSearch(originalPuzzle, constraints, options)
+	counts := &annealCounts{}
+
+	start := time.Now()
+	firstStep := 1
+	baseTemperature := options.Temperature
+	finalTemperature := options.FinalTemperature
+	coolingRate := options.CoolingRate
+	concurrentAnnealerCount := options.Annealers
+	progress, stop, resume := options.OnCoolingStep, options.Stop, options.Resume
+
+	var initialSolution [][]int
+	if resume == nil {
+		initialSolution = options.Initialization(originalPuzzle, constraints, rng)
+	} else {
+		start = start.Add(-resume.Elapsed)
+		firstStep = resume.Step + 1
+		baseTemperature = resume.Temperature * coolingRate
+	}
+
+	stats.Accepted = make([]int64, concurrentAnnealerCount)
+	defer func() {
+		stats.Iterations = atomic.LoadInt64(&counts.iterations)
+		stats.CostEvaluations = atomic.LoadInt64(&counts.costEvaluations)
+		stats.WallTime = time.Since(start)
+	}()
+
+	// Create channels for each of the concurrent annealers of differing temperatures
+	annealerSolution := make([]chan [][]int, concurrentAnnealerCount)
+	annealerCost := make([]chan float64, concurrentAnnealerCount)
+	annealerAcceptance := make([]chan float64, concurrentAnnealerCount)
+	annealerBestCost := make([]chan float64, concurrentAnnealerCount)
+	annealerCounts := make([]annealCounts, concurrentAnnealerCount)
+	for i := 0; i < concurrentAnnealerCount; i++ {
+		annealerSolution[i] = make(chan [][]int, 1)
+		annealerCost[i] = make(chan float64, 1)
+		annealerAcceptance[i] = make(chan float64, 1)
+		annealerBestCost[i] = make(chan float64, 1)
+	}
+
+	// A resumed run keeps the temperatures it was checkpointed with, rather than tuning a new ladder
+	if resume != nil && len(resume.Temperatures) == concurrentAnnealerCount {
+		options.Ladder, options.AutoLadder = ListLadder(resume.Temperatures), false
+	}
+	if options.AutoLadder {
+		candidate := initialSolution
+		if resume != nil {
+			candidate = resume.Solutions[0]
+		}
+		options.Ladder = tuneLadder(originalPuzzle, candidate, constraints, options, flat, rng, counts)
+	}
+
+	annealerSolutions := make([][][]int, concurrentAnnealerCount)
+	annealerCosts := make([]float64, concurrentAnnealerCount)
+	annealerSwaps := make([]int, concurrentAnnealerCount)
+	replicas := make([]replicaProgress, concurrentAnnealerCount)
+
+	for i := 0; i < concurrentAnnealerCount; i++ {
+		if resume != nil {
+			annealerSolutions[i] = CopyPuzzle(resume.Solutions[i])
+		} else if options.DiverseStarts && i > 0 {
+			annealerSolutions[i] = options.Initialization(originalPuzzle, constraints, rng)
+		} else {
+			annealerSolutions[i] = CopyPuzzle(initialSolution)
+		}
+		annealerCosts[i] = options.Cost(annealerSolutions[i], constraints)
+		annealerSwaps[i] = options.Swaps
+		atomic.AddInt64(&counts.costEvaluations, 1)
+	}
+
+	bestCost := math.Inf(1)
+
+	// The state the run can be carried on from, kept when it is asked for. A resumed run keeps the best
+	// candidate of the run it carries on
+	var state SolverState
+	if options.OnState != nil {
+		state = SolverState{PuzzleHash: PuzzleHash(originalPuzzle), CoolingRate: coolingRate, Iterations: options.Iterations, Swaps: options.Swaps, Annealers: concurrentAnnealerCount, BestCost: math.Inf(1)}
+		if resume != nil && resume.Best != nil {
+			state.Best, state.BestCost = resume.Best, resume.BestCost
+		}
+	}
+
+	// While the cost is not zero and we haven't hit our final temperature
+	for step := firstStep; baseTemperature > finalTemperature; step++ {
+
+		acceptanceRate := 0.0
+		stats.Steps++
+		stats.FinalTemperature = baseTemperature
+
+		// Every annealer runs at once, each drawing from its own random number generator. They are seeded
+		// in turn from the run's, so a run can still be repeated from its seed. All of them stop as soon
+		// as one finds a solution
+		solved := &atomic.Bool{}
+		for i := 0; i < concurrentAnnealerCount; i++ {
+			temperature := options.AnnealerTemperature(baseTemperature, i)
+			annealerCounts[i] = annealCounts{}
+			annealerRng := rand.New(rand.NewSource(rng.Int63()))
+			annealerOptions := options
+			annealerOptions.Swaps = annealerSwaps[i]
+			go annealerInternalIterator(originalPuzzle, annealerSolutions[i], constraints, temperature, annealerOptions, flat, annealerRng, &annealerCounts[i], solved, annealerSolution[i], annealerCost[i], annealerAcceptance[i], annealerBestCost[i])
+		}
+
+		for i := 0; i < concurrentAnnealerCount; i++ {
+			temperature := options.AnnealerTemperature(baseTemperature, i)
+			annealerSolutions[i] = <-annealerSolution[i]
+			annealerCosts[i] = <-annealerCost[i]
+			acceptance := <-annealerAcceptance[i]
+			replicas[i] = replicaProgress{temperature, <-annealerBestCost[i], annealerCosts[i], acceptance, 0}
+			acceptanceRate += replicas[i].AcceptanceRate / float64(concurrentAnnealerCount)
+
+			iterations := atomic.LoadInt64(&annealerCounts[i].iterations)
+			stats.Accepted[i] += int64(math.Round(acceptance * float64(iterations)))
+			atomic.AddInt64(&counts.iterations, iterations)
+			atomic.AddInt64(&counts.costEvaluations, atomic.LoadInt64(&annealerCounts[i].costEvaluations))
+
+			if options.AdaptiveSwaps {
+				annealerSwaps[i] = adaptSwaps(annealerSwaps[i], acceptance, options.Swaps)
+			}
+		}
+
+		// If a hotter goroutine has a better solution than a colder one then we swap the solutions
+		for i := concurrentAnnealerCount - 1; i > 0; i-- {
+			if annealerCosts[i] < annealerCosts[i-1] {
+				annealerSolutions[i], annealerSolutions[i-1] = annealerSolutions[i-1], annealerSolutions[i]
+				annealerCosts[i], annealerCosts[i-1] = annealerCosts[i-1], annealerCosts[i]
+				replicas[i].Exchanges++
+				replicas[i-1].Exchanges++
+				exchangeCount.Add(1)
+				stats.Exchanges++
+				if options.OnExchange != nil {
+					options.OnExchange(i-1, i)
+				}
+			}
+		}
+
+		best := 0
+		for i, cost := range annealerCosts {
+			if cost < annealerCosts[best] {
+				best = i
+			}
+		}
+		if options.OnNewBest != nil && annealerCosts[best] < bestCost {
+			options.OnNewBest(annealerSolutions[best], annealerCosts[best])
+		}
+		bestCost = math.Min(bestCost, annealerCosts[best])
+
+		// The run carries on from a new seed after every step, so a checkpoint taken now can pick it up
+		seed := rng.Int63()
+		rng.Seed(seed)
+
+		if progress != nil {
+			progress(AnnealProgress{step, baseTemperature, annealerCosts[best], acceptanceRate, time.Since(start), annealerSolutions[best], append([]replicaProgress(nil), replicas...), append([][][]int(nil), annealerSolutions...), seed})
+		}
+		if options.OnState != nil {
+			if annealerCosts[best] < state.BestCost {
+				state.Best, state.BestCost = CopyPuzzle(annealerSolutions[best]), annealerCosts[best]
+			}
+			state.Temperatures = make([]float64, concurrentAnnealerCount)
+			for i := range state.Temperatures {
+				state.Temperatures[i] = replicas[i].Temperature
+			}
+			state.Step, state.Temperature, state.Elapsed, state.Seed = step, baseTemperature, time.Since(start), seed
+			state.Solutions = append([][][]int(nil), annealerSolutions...)
+			options.OnState(state)
+		}
+
+		// If the coldest goroutine has cost zero then we have solved the puzzle
+		if annealerCosts[0] == 0 {
+			return annealerSolutions[0], true, stats
+		}
+
+		select {
+		case <-stop:
+			return annealerSolutions[0], false, stats
+		default:
+		}
+		if options.Timeout > 0 && time.Since(start) >= options.Timeout {
+			return annealerSolutions[0], false, stats
+		}
+
+		// Cool all of the goroutines
+		baseTemperature = baseTemperature * coolingRate
+	}
+
+	return annealerSolutions[0], false, stats
+}
+
+// How many iterations an annealer makes between checks of whether another one has solved the puzzle.
+const solvedCheckInterval = 64
+
+// The most swaps each annealer starts with when the swaps are adapted, and the acceptance rates above
+// which an annealer makes one more swap per candidate and below which it makes one fewer.
+const (
+	MaxAdaptiveSwaps       = 4
+	swapIncreaseAcceptance = 0.5
+	swapDecreaseAcceptance = 0.2
+)
+
+// Returns the swaps an annealer makes per candidate in the next cooling step, from the swaps it made
+// and the fraction of its candidates that were accepted in the last. Large moves are kept while most
+// candidates are accepted, early on and in the hotter annealers, and they shrink one swap at a time down
+// to single swaps as the annealer cools and accepts fewer.
+func adaptSwaps(swaps int, acceptance float64, maxSwaps int) int {
+
+	if acceptance > swapIncreaseAcceptance && swaps < maxSwaps {
+		return swaps + 1
+	}
+	if acceptance < swapDecreaseAcceptance && swaps > 1 {
+		return swaps - 1
+	}
+	return swaps
+}
+
+// Gets a neighbouring candidate solution with the Neighbour option and runs the probibalistic steps of the annealing
+// process as many times as specified by the Iterations option. Along with the solution and its cost, the fraction of
+// the candidate solutions that were accepted is sent back on aa and the lowest cost seen on ab. When flat is set the
+// steps run on the flat representation instead (see flatConstraints.anneal). Random numbers are drawn from rng,
+// which belongs to this annealer alone, and the iterations and cost evaluations are added to counts. The
+// annealers of a cooling step share solved (unless it is nil): the first to find a solution sets it, and
+// the rest then stop early with the candidates they have, rather than finishing their iterations.
+func annealerInternalIterator(originalPuzzle [][]int, candidateSolution [][]int, constraints []Constraint, temperature float64, options Options, flat *flatConstraints, rng *rand.Rand, counts *annealCounts, solved *atomic.Bool, as chan [][]int, ac chan float64, aa chan float64, ab chan float64) {
+
+	internalIterations, swapCount := options.Iterations, options.Swaps
+
+	if flat != nil {
+		solution, cost, acceptance, bestCost := flat.anneal(candidateSolution, temperature, internalIterations, swapCount, options.Acceptance, rng, counts, solved)
+		as <- solution
+		ac <- cost
+		aa <- acceptance
+		ab <- bestCost
+		return
+	}
+
+	// Set updatedSolution and updatedCost to the current values associated with candidateSolution
+	updatedSolution := CopyPuzzle(candidateSolution)
+	updatedCost := options.Cost(updatedSolution, constraints)
+	accepted := 0
+	bestCost := updatedCost
+
+	iterations := internalIterations
+	for i := 0; i < internalIterations; i++ {
+		if i > 0 && i%solvedCheckInterval == 0 && solved != nil && solved.Load() {
+			iterations = i
+			break
+		}
+
+		newCandidateSolution := options.Neighbour(updatedSolution, swapCount, originalPuzzle, rng)
+		newCandidateCost := options.Cost(newCandidateSolution, constraints)
+
+		// If the cost is zero, then we found a viable solution. exit!
+		if newCandidateCost == 0 {
+			if solved != nil {
+				solved.Store(true)
+			}
+			iterationCount.Add(int64(i + 1))
+			atomic.AddInt64(&counts.iterations, int64(i+1))
+			atomic.AddInt64(&counts.costEvaluations, int64(i+2))
+			as <- newCandidateSolution
+			ac <- 0
+			aa <- float64(accepted+1) / float64(i+1)
+			ab <- 0
+			return
+		}
+
+		// Otherwise, if the cost is less then switch to that solution
+		if newCandidateCost < updatedCost {
+			updatedSolution = newCandidateSolution
+			updatedCost = newCandidateCost
+			accepted++
+			bestCost = math.Min(bestCost, updatedCost)
+
+			// And finally switch to a more costly solution if the acceptance rule says so
+		} else {
+			if options.Acceptance.Accept(updatedCost, newCandidateCost, temperature, rng) {
+				updatedSolution = newCandidateSolution
+				updatedCost = newCandidateCost
+				accepted++
+			}
+		}
+	}
+
+	iterationCount.Add(int64(iterations))
+	atomic.AddInt64(&counts.iterations, int64(iterations))
+	atomic.AddInt64(&counts.costEvaluations, int64(iterations+1))
+	as <- updatedSolution
+	ac <- updatedCost
+	aa <- float64(accepted) / float64(iterations)
+	ab <- bestCost
+	return
+}
+
+// Gets a neighbouring candidate solution to the current one by randomly swapping two numbers in the puzzle.
+// It also ensures that the neighbouring solution created does not modify or swap one of the clues (or
+// blocked squares) in the original puzzle. The squares are picked with rng.
+func getNeighbour(currentPuzzle [][]int, swapCount int, originalPuzzle [][]int, rng *rand.Rand) (neighbourPuzzle [][]int) {
+
+	puzzleDim := len(originalPuzzle)
+
+	// Copy the current puzzle into neighbourPuzzle
+	neighbourPuzzle = CopyPuzzle(currentPuzzle)
+
+	for i := 0; i < swapCount; i++ {
+		randomXIndex1 := rng.Intn(puzzleDim)
+		randomYIndex1 := rng.Intn(puzzleDim)
+
+		randomXIndex2 := rng.Intn(puzzleDim)
+		randomYIndex2 := rng.Intn(puzzleDim)
+
+		// Keep randomly reassigning the index until we get one that wasn't defined in the
+		// original puzzle.
+		for originalPuzzle[randomXIndex1][randomYIndex1] != 0 {
+			randomXIndex1 = rng.Intn(puzzleDim)
+			randomYIndex1 = rng.Intn(puzzleDim)
+		}
+
+		for originalPuzzle[randomXIndex2][randomYIndex2] != 0 {
+			randomXIndex2 = rng.Intn(puzzleDim)
+			randomYIndex2 = rng.Intn(puzzleDim)
+		}
+
+		// Swap the two randomly selected elements
+		neighbourPuzzle[randomXIndex1][randomYIndex1], neighbourPuzzle[randomXIndex2][randomYIndex2] = neighbourPuzzle[randomXIndex2][randomYIndex2], neighbourPuzzle[randomXIndex1][randomYIndex1]
+	}
+
+	return neighbourPuzzle
+}
+
+// A cost function for the provided sudoku puzzle. The cost is the sum of the costs of every constraint
+// on the puzzle: its rows, columns and blocks (or irregular jigsaw regions), the extra regions of the
+// puzzle variant and any killer sudoku cages. A cost of zero for the whole puzzle indicates that it has
+// been solved.
+func CostFunction(puzzle [][]int, constraints []Constraint) (cost float64) {
+
+	// Initialize the cost to zero
+	cost = 0.0
+	costEvaluationCount.Add(1)
+
+	for _, constraint := range constraints {
+		cost += constraint.Cost(puzzle)
+	}
+
+	return cost
+}
+
+// The probability that the Metropolis criterion accepts a candidate of newCost in place of one of oldCost.
+func acceptanceProbability(oldCost float64, newCost float64, temperature float64) (probability float64) {
+	return math.Exp((oldCost - newCost) / temperature)
+}
+
+// Randomly sets all blank values in the original puzzle to a number from 1 to
+// NumberCount so the anneaing function has a complete (but incorrect) base to
+// start from. It ensures that the occurances of each number is correct for the
+// puzzle. Eg. for a standard sudoku, there will be 9 of each number. Blocked
+// squares (see BlockedSquare) are left as they are, and the spots are picked with rng.
+func randomInitialization(originalPuzzle [][]int, numberCount int, rng *rand.Rand) (initializedPuzzle [][]int) {
+
+	puzzleDim := len(originalPuzzle)
+
+	// Every square that isn't blocked holds a number, and each number occurs equally often
+	usableSquares := 0
+	for i := range originalPuzzle {
+		for j := range originalPuzzle[i] {
+			if originalPuzzle[i][j] != BlockedSquare {
+				usableSquares++
+			}
+		}
+	}
+
+	remainingNumbers := make(map[int]int)
+
+	var emptySpots [][]int
+	emptySpots = make([][]int, 0)
+
+	initializedPuzzle = make([][]int, puzzleDim)
+
+	// Set all of the remainingNumbers to the maximum possible
+	for i := 1; i <= numberCount; i++ {
+		remainingNumbers[i] = usableSquares / numberCount
+	}
+
+	// For each occurance of a number in the originalPuzzle, subtract one from
+	// remainingNumbers and duplicate it in the initializedPuzzle
+	for i := 0; i < puzzleDim; i++ {
+		initializedPuzzle[i] = make([]int, puzzleDim)
+		for j := 0; j < puzzleDim; j++ {
+			if originalPuzzle[i][j] > 0 {
+				remainingNumbers[originalPuzzle[i][j]]--
+				initializedPuzzle[i][j] = originalPuzzle[i][j]
+
+			} else if originalPuzzle[i][j] == BlockedSquare {
+				initializedPuzzle[i][j] = BlockedSquare
+
+			} else {
+				emptySpots = append(emptySpots, []int{i, j})
+			}
+		}
+	}
+
+	// For every remaining number, randomly assign it to one of the remaining empty spots
+	// then delete that empty spot from the slice. The numbers go in order, not the map's, so that a run
+	// can be repeated from its seed
+	for remainingNumber := 1; remainingNumber <= numberCount; remainingNumber++ {
+		for i := 0; i < remainingNumbers[remainingNumber]; i++ {
+			spotIndex := rng.Intn(len(emptySpots))
+			spot := emptySpots[spotIndex]
+			initializedPuzzle[spot[0]][spot[1]] = remainingNumber
+			emptySpots[spotIndex] = emptySpots[len(emptySpots)-1]
+			emptySpots = emptySpots[0 : len(emptySpots)-1]
+		}
+	}
+
+	return initializedPuzzle
+}
+
+func CopyPuzzle(originalPuzzle [][]int) (copiedPuzzle [][]int) {
+
+	puzzleDim := len(originalPuzzle)
+
+	copiedPuzzle = make([][]int, puzzleDim, puzzleDim)
+
+	for i := 0; i < puzzleDim; i++ {
+		copiedPuzzle[i] = make([]int, puzzleDim, puzzleDim)
+		copy(copiedPuzzle[i], originalPuzzle[i])
+	}
+
+	return copiedPuzzle
+}
@@ -0,0 +1,94 @@
+package solver
+
+import (
+	"math/bits"
+	"time"
+)
+
+// Solves a puzzle by backtracking (see Backtrack) rather than annealing, as -algo backtrack: an exact
+// search that always finds a solution when there is one, given the time, and is a yardstick for the
+// annealer. Only the Timeout and Stop options are used. Returns the solution, or a copy of the puzzle when
+// there is none or the search was stopped, and the stats of the search, where every grid tried is an
+// iteration.
+func backtrackSearch(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	start := time.Now()
+	stopped := func() bool {
+		select {
+		case <-options.Stop:
+			return true
+		default:
+		}
+		return options.Timeout > 0 && time.Since(start) >= options.Timeout
+	}
+
+	solvedPuzzle = CopyPuzzle(originalPuzzle)
+	stats.Iterations = Backtrack(originalPuzzle, constraints, func(solution [][]int) bool {
+		solvedPuzzle, solutionFound = CopyPuzzle(solution), true
+		return true
+	}, stopped)
+	stats.WallTime = time.Since(start)
+
+	return solvedPuzzle, solutionFound, stats
+}
+
+// Searches for the solutions of a puzzle by backtracking, calling found with each one (a grid that is
+// reused, so must be copied to be kept) until it returns true. Squares are filled in the order of the
+// fewest numbers their regions leave them, and every constraint is checked once the grid is full, so the
+// rules beyond the regions that must hold every number once (like killer cages) are kept as well. The
+// search gives up once stopped (when it isn't nil) returns true, which it is asked every so often. Returns
+// the number of grids it tried numbers in.
+func Backtrack(puzzle [][]int, constraints []Constraint, found func(solution [][]int) bool, stopped func() bool) (nodes int64) {
+
+	s := newLogicalSolver(puzzle, constraints, "")
+	grid := s.grid
+	all := uint64(1)<<uint(NumberCount(constraints)+1) - 2
+
+	var search func() bool
+	search = func() bool {
+
+		if nodes++; stopped != nil && nodes%1024 == 0 && stopped() {
+			return true
+		}
+
+		// The empty square with the fewest numbers left
+		var chosen Cell
+		chosenMask, chosenCount := uint64(0), -1
+		for r := range grid {
+			for c := range grid[r] {
+				if grid[r][c] != 0 {
+					continue
+				}
+				mask := all
+				for _, peer := range s.peers[r][c] {
+					mask &^= 1 << uint(grid[peer.Row][peer.Col])
+				}
+				n := bits.OnesCount64(mask)
+				if n == 0 {
+					return false
+				}
+				if chosenCount < 0 || n < chosenCount {
+					chosen, chosenMask, chosenCount = Cell{r, c}, mask, n
+				}
+			}
+		}
+
+		if chosenCount < 0 {
+			return CostFunction(grid, constraints) == 0 && found(grid)
+		}
+
+		for mask := chosenMask; mask != 0; mask &= mask - 1 {
+			grid[chosen.Row][chosen.Col] = bits.TrailingZeros64(mask)
+			if search() {
+				grid[chosen.Row][chosen.Col] = 0
+				return true
+			}
+		}
+		grid[chosen.Row][chosen.Col] = 0
+
+		return false
+	}
+
+	search()
+	return nodes
+}
@@ -0,0 +1,113 @@
+package solver
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The state of an annealing solve after one of its cooling steps, which it can be carried on from
+// (with Options.Resume) after a crash or shutdown, or in another process. It holds every annealer's
+// candidate solution and temperature from coldest to hottest, the base temperature of the step, the best
+// candidate so far and the time spent. A solve reseeds its random number generator after every cooling
+// step, and every annealer's generator is seeded from it in turn at the start of the next, so Seed, the
+// seed it took after this one, stands for the state of all of them and a resumed solve draws the same
+// random numbers the original would have. The puzzle's hash and the annealing parameters are kept to
+// check that a solve is resumed on the same puzzle.
+type SolverState struct {
+	PuzzleHash   string        `json:"puzzle_hash"`
+	CoolingRate  float64       `json:"cooling_rate"`
+	Iterations   int           `json:"iterations"`
+	Swaps        int           `json:"swaps"`
+	Annealers    int           `json:"annealers"`
+	Step         int           `json:"step"`
+	Temperature  float64       `json:"temperature"`
+	Temperatures []float64     `json:"temperatures"`
+	Elapsed      time.Duration `json:"elapsed_ns"`
+	Seed         int64         `json:"seed"`
+	BestCost     float64       `json:"best_cost"`
+	Best         [][]int       `json:"best"`
+	Solutions    [][][]int     `json:"solutions"`
+}
+
+// Encodes the state for persisting or sending to another process, in the given format: gob, which is
+// compact, or json, which other programs can read.
+func (s SolverState) MarshalState(format string) ([]byte, error) {
+
+	switch format {
+	case "gob":
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case "json":
+		return json.Marshal(s)
+	}
+
+	return nil, fmt.Errorf("unknown state format %q (expected gob or json)", format)
+}
+
+// Decodes a state encoded by MarshalState in either format, telling JSON from gob by its opening brace.
+func UnmarshalState(data []byte) (s SolverState, e error) {
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		e = json.Unmarshal(trimmed, &s)
+	} else {
+		e = gob.NewDecoder(bytes.NewReader(data)).Decode(&s)
+	}
+
+	return s, e
+}
+
+// Returns the format a checkpoint file is written in: json for a .json file, and gob otherwise.
+func CheckpointFormat(filename string) string {
+
+	if strings.ToLower(filepath.Ext(filename)) == ".json" {
+		return "json"
+	}
+
+	return "gob"
+}
+
+// Checks that a checkpoint was taken while annealing the given puzzle, and that it holds the state of
+// every annealer, before it is resumed.
+func (s SolverState) Check(puzzle [][]int) error {
+
+	if s.PuzzleHash != PuzzleHash(puzzle) {
+		return puzzleErrorf("the checkpoint was taken while solving a different puzzle")
+	}
+	if s.Annealers < 1 || len(s.Solutions) != s.Annealers {
+		return puzzleErrorf("the checkpoint holds %d annealers' solutions but was taken with %d annealers", len(s.Solutions), s.Annealers)
+	}
+	if len(s.Temperatures) > 0 && len(s.Temperatures) != s.Annealers {
+		return puzzleErrorf("the checkpoint holds %d annealers' temperatures but was taken with %d annealers", len(s.Temperatures), s.Annealers)
+	}
+	for _, solution := range s.Solutions {
+		if len(solution) != len(puzzle) {
+			return puzzleErrorf("the checkpoint's solutions are not the size of the puzzle")
+		}
+	}
+	return nil
+}
+
+// Returns a short hash of the clues of a puzzle, which identifies it however it was written in the input.
+func PuzzleHash(puzzle [][]int) string {
+
+	squares := make([]string, 0, len(puzzle)*len(puzzle))
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			squares = append(squares, strconv.Itoa(puzzle[r][c]))
+		}
+	}
+
+	sum := sha256.Sum256([]byte(strings.Join(squares, ",")))
+	return hex.EncodeToString(sum[:8])
+}
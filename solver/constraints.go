@@ -0,0 +1,144 @@
+package solver
+
+import (
+	"math"
+)
+
+// A sudoku grid, indexed by row then column. Empty squares hold 0.
+type Puzzle [][]int
+
+// The position of a single square in a puzzle, counting rows and columns from 0.
+type Cell struct {
+	Row int
+	Col int
+}
+
+// A rule that a solved puzzle has to satisfy. Cost returns how badly the puzzle breaks the rule, with
+// zero meaning it is satisfied, and Regions returns the groups of cells the rule applies to. Rows,
+// columns, blocks and the regions of the puzzle variants are all constraints, and the annealer only
+// ever sees the sum of their costs, so new rules can be added without touching it.
+type Constraint interface {
+	Cost(p Puzzle) float64
+	Regions() [][]Cell
+}
+
+// A constraint requiring each of its regions (Groups, the cells of each) to contain every number exactly
+// once. Kind names the regions ("row", "column", "block", ...) when reporting violations.
+type UniqueConstraint struct {
+	Kind   string
+	Groups [][]Cell
+}
+
+// The cost is the sum over all regions of the absolute difference between the occurances of a number
+// in that region and it's expected occurance of 1. A region of n cells should hold the numbers 1 to n.
+func (u UniqueConstraint) Cost(p Puzzle) (cost float64) {
+
+	// Numbers are shifted down by one, so 1 is stored in index 0, 2 in index 1, and so forth.
+	regionCounts := make([]int, len(p))
+
+	for _, region := range u.Groups {
+
+		counts := regionCounts[:len(region)]
+		for i := range counts {
+			counts[i] = 0
+		}
+
+		for _, cell := range region {
+			if number := p[cell.Row][cell.Col]; number > 0 && number <= len(counts) {
+				counts[number-1]++
+			}
+		}
+
+		for _, count := range counts {
+			cost += math.Abs(float64(count - 1))
+		}
+	}
+
+	return cost
+}
+
+func (u UniqueConstraint) Regions() [][]Cell {
+	return u.Groups
+}
+
+// Every row of a puzzle of the given dimension must contain each number once.
+func rowConstraint(puzzleDim int) Constraint {
+
+	rows := make([][]Cell, puzzleDim)
+	for r := range rows {
+		for c := 0; c < puzzleDim; c++ {
+			rows[r] = append(rows[r], Cell{r, c})
+		}
+	}
+
+	return UniqueConstraint{"row", rows}
+}
+
+// Every column of a puzzle of the given dimension must contain each number once.
+func columnConstraint(puzzleDim int) Constraint {
+
+	columns := make([][]Cell, puzzleDim)
+	for c := range columns {
+		for r := 0; r < puzzleDim; r++ {
+			columns[c] = append(columns[c], Cell{r, c})
+		}
+	}
+
+	return UniqueConstraint{"column", columns}
+}
+
+// Every block (or irregular jigsaw region) given by the region map must contain each number once.
+func blockConstraint(regionMap [][]int) Constraint {
+	return UniqueConstraint{"block", RegionsFromMap(regionMap)}
+}
+
+// Builds the full set of constraints for a puzzle: its rows, columns and blocks, the extra constraints
+// of the puzzle variant, and the cages of a killer sudoku if there are any. Puzzles without a region
+// map, like Latin squares, have no blocks.
+func PuzzleConstraints(puzzleDim int, regionMap [][]int, variant []Constraint, cages []Cage) (constraints []Constraint) {
+
+	constraints = []Constraint{rowConstraint(puzzleDim), columnConstraint(puzzleDim)}
+
+	if regionMap != nil {
+		constraints = append(constraints, blockConstraint(regionMap))
+	}
+
+	constraints = append(constraints, variant...)
+
+	if len(cages) > 0 {
+		constraints = append(constraints, CageConstraint{cages})
+	}
+
+	return constraints
+}
+
+// Returns how many different numbers the puzzle holds, which is the size of its largest region that
+// must contain every number once (eg. 9 for a standard sudoku, even when it is part of a samurai).
+func NumberCount(constraints []Constraint) (count int) {
+
+	for _, constraint := range constraints {
+		if u, ok := constraint.(UniqueConstraint); ok {
+			for _, region := range u.Groups {
+				if len(region) > count {
+					count = len(region)
+				}
+			}
+		}
+	}
+
+	return count
+}
+
+// Returns every region of the given constraints in a single list.
+func ConstraintRegions(constraints []Constraint) (regions [][]Cell) {
+
+	for _, constraint := range constraints {
+		regions = append(regions, constraint.Regions()...)
+	}
+
+	return regions
+}
+
+// Squares of a samurai sudoku that fall outside of all five grids hold this value. Like clues they are
+// never moved by the annealer, and they are left blank when the puzzle is printed.
+const BlockedSquare = -1
@@ -0,0 +1,10 @@
+// Package solver solves sudoku puzzles and their variants by simulated annealing, with parallel tempering
+// between annealers at a ladder of temperatures, and by the other searches of Algorithms. Puzzles are rows
+// of numbers from 1, with 0 for the empty squares, and their rules are a list of Constraints: the rows,
+// columns and blocks of PuzzleConstraints, plus those of a variant (see VariantConstraints and
+// RegisterVariant) or a killer sudoku's cages. The sudokuAnnealing command is a thin layer over this
+// package.
+//
+//	constraints := solver.PuzzleConstraints(9, solver.BlockRegionMap(3, 3), nil, nil)
+//	solution, solved, stats, err := solver.Solve(puzzle, constraints, solver.Options{Timeout: time.Minute})
+package solver
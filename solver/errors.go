@@ -0,0 +1,26 @@
+package solver
+
+import "fmt"
+
+// A PuzzleError reports a puzzle that can't be read or can't be solved as given: a malformed line, a
+// number out of range or clues that conflict with each other.
+type PuzzleError struct {
+	Err error
+}
+
+func (e *PuzzleError) Error() string { return e.Err.Error() }
+
+func (e *PuzzleError) Unwrap() error { return e.Err }
+
+// Returns a PuzzleError with the formatted message.
+func puzzleErrorf(format string, a ...interface{}) error {
+	return &PuzzleError{fmt.Errorf(format, a...)}
+}
+
+// Returns the absolute value of an integer.
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,42 @@
+package solver_test
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+func ExampleSolveProgress() {
+
+	puzzle := [][]int{
+		{1, 0, 0, 4},
+		{0, 4, 1, 0},
+		{2, 0, 0, 3},
+		{0, 3, 2, 0},
+	}
+	constraints := solver.PuzzleConstraints(4, solver.BlockRegionMap(2, 2), nil, nil)
+
+	events, result, err := solver.SolveProgress(puzzle, constraints, solver.Options{Seed: 1}, solver.ProgressOptions{Interval: 100 * time.Millisecond, Snapshots: true})
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+
+	// A GUI would redraw event.Grid and show event.BestCost here
+	for event := range events {
+		_ = event.Grid
+	}
+
+	solve := <-result
+	fmt.Println(solve.Solved)
+	for _, row := range solve.Solution {
+		fmt.Println(row)
+	}
+	// Output:
+	// true
+	// [1 2 3 4]
+	// [3 4 1 2]
+	// [2 1 4 3]
+	// [4 3 2 1]
+}
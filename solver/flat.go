@@ -0,0 +1,605 @@
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// Inside the annealers' inner loop a candidate solution is held as one slice of squares, row by row, so
+// the square in row r and column c is at r*dim+c. Each square holds its number, 0 when empty, or
+// flatBlocked for the blocked squares of samurai puzzles.
+const flatBlocked = math.MaxUint8
+
+// Returns the squares of a puzzle in the flat representation.
+func flatten(puzzle [][]int) []uint8 {
+
+	cells := make([]uint8, 0, len(puzzle)*len(puzzle))
+	for _, row := range puzzle {
+		for _, number := range row {
+			if number == BlockedSquare {
+				cells = append(cells, flatBlocked)
+			} else {
+				cells = append(cells, uint8(number))
+			}
+		}
+	}
+
+	return cells
+}
+
+// Returns a puzzle of the given dimension from its squares in the flat representation.
+func unflatten(cells []uint8, puzzleDim int) [][]int {
+
+	puzzle := make([][]int, puzzleDim)
+	for r := range puzzle {
+		puzzle[r] = make([]int, puzzleDim)
+		for c, number := range cells[r*puzzleDim : (r+1)*puzzleDim] {
+			if number == flatBlocked {
+				puzzle[r][c] = BlockedSquare
+			} else {
+				puzzle[r][c] = int(number)
+			}
+		}
+	}
+
+	return puzzle
+}
+
+// A pair of squares of a pair constraint, given by their flat indexes, and the rule their numbers follow.
+type flatPair struct {
+	square1 int
+	square2 int
+	rule    pairRule
+}
+
+// A killer sudoku cage with its squares given by their flat indexes.
+type flatCage struct {
+	sum   int
+	cells []int
+}
+
+// A sandwich clue with the squares of its line given by their flat indexes, in order.
+type flatSandwich struct {
+	sum   int
+	cells []int
+}
+
+// An arrow with its circle and the squares along its shaft given by their flat indexes.
+type flatArrow struct {
+	circle int
+	shaft  []int
+}
+
+// The constraints of a puzzle precomputed for the flat representation: the flat indexes of the squares of
+// every region whose numbers must be unique (rows, columns, blocks and the regions of the variants), the
+// cages of a killer sudoku, the pairs of pair constraints like anti-knight, the lines with sandwich
+// clues, the arrows, the regions, cages, pairs, sandwich lines and arrows each square belongs to, the
+// parity each square must have in an even/odd sudoku (see SquareParities; nil without one), the numbers
+// the cages of each square can hold in a killer sudoku (see cageNumbers; nil for squares in no cage, and
+// altogether without cages), and the squares that moves may swap, which are neither clues nor blocked.
+type flatConstraints struct {
+	puzzleDim        int
+	maxNumber        int
+	regions          [][]int
+	cages            []flatCage
+	pairs            []flatPair
+	sandwiches       []flatSandwich
+	arrows           []flatArrow
+	squareRegions    [][]int
+	squareCages      [][]int
+	squarePairs      [][]int
+	squareSandwiches [][]int
+	squareArrows     [][]int
+	parities         []int
+	cageNumbers      [][]bool
+	freeSquares      []int
+}
+
+// Precomputes the constraints of a puzzle for the flat representation. ok is false when one of the
+// constraints has no flat form, or a number doesn't fit in a square, and the puzzle has to be annealed
+// as it is.
+func newFlatConstraints(originalPuzzle [][]int, constraints []Constraint) (f *flatConstraints, ok bool) {
+
+	puzzleDim := len(originalPuzzle)
+	f = &flatConstraints{puzzleDim: puzzleDim, maxNumber: NumberCount(constraints)}
+	if f.maxNumber >= flatBlocked {
+		return nil, false
+	}
+
+	index := func(cell Cell) int {
+		return cell.Row*puzzleDim + cell.Col
+	}
+
+	f.squareRegions = make([][]int, puzzleDim*puzzleDim)
+	f.squareCages = make([][]int, puzzleDim*puzzleDim)
+	f.squarePairs = make([][]int, puzzleDim*puzzleDim)
+	f.squareSandwiches = make([][]int, puzzleDim*puzzleDim)
+	f.squareArrows = make([][]int, puzzleDim*puzzleDim)
+
+	for _, constraint := range constraints {
+		switch constraint := constraint.(type) {
+		case UniqueConstraint:
+			for _, region := range constraint.Groups {
+				indexes := make([]int, len(region))
+				for i, cell := range region {
+					indexes[i] = index(cell)
+					f.squareRegions[indexes[i]] = append(f.squareRegions[indexes[i]], len(f.regions))
+				}
+				f.regions = append(f.regions, indexes)
+			}
+		case CageConstraint:
+			for _, c := range constraint.Cages {
+				indexes := make([]int, len(c.Cells))
+				for i, cell := range c.Cells {
+					indexes[i] = index(cell)
+					f.squareCages[indexes[i]] = append(f.squareCages[indexes[i]], len(f.cages))
+				}
+				f.cages = append(f.cages, flatCage{c.Sum, indexes})
+				f.restrictCageNumbers(c, indexes)
+			}
+		case PairConstraint:
+			for _, pair := range constraint.Pairs {
+				square1, square2 := index(pair[0]), index(pair[1])
+				f.squarePairs[square1] = append(f.squarePairs[square1], len(f.pairs))
+				f.squarePairs[square2] = append(f.squarePairs[square2], len(f.pairs))
+				f.pairs = append(f.pairs, flatPair{square1, square2, constraint.Rule})
+			}
+		case SandwichConstraint:
+			for _, clue := range constraint.Clues {
+				indexes := make([]int, len(clue.Cells))
+				for i, cell := range clue.Cells {
+					indexes[i] = index(cell)
+					f.squareSandwiches[indexes[i]] = append(f.squareSandwiches[indexes[i]], len(f.sandwiches))
+				}
+				f.sandwiches = append(f.sandwiches, flatSandwich{clue.Sum, indexes})
+			}
+		case ArrowConstraint:
+			for _, a := range constraint.Arrows {
+				circle := index(a.Circle)
+				f.squareArrows[circle] = append(f.squareArrows[circle], len(f.arrows))
+				shaft := make([]int, len(a.Shaft))
+				for i, cell := range a.Shaft {
+					shaft[i] = index(cell)
+					f.squareArrows[shaft[i]] = append(f.squareArrows[shaft[i]], len(f.arrows))
+				}
+				f.arrows = append(f.arrows, flatArrow{circle, shaft})
+			}
+		case ParityConstraint:
+			// Every parity constraint is read at once below
+		default:
+			return nil, false
+		}
+	}
+
+	if parities := SquareParities(puzzleDim, constraints); parities != nil {
+		for _, row := range parities {
+			f.parities = append(f.parities, row...)
+		}
+	}
+
+	for r := range originalPuzzle {
+		for c, number := range originalPuzzle[r] {
+			if number > f.maxNumber {
+				return nil, false
+			}
+			if number == 0 {
+				f.freeSquares = append(f.freeSquares, r*puzzleDim+c)
+			}
+		}
+	}
+
+	return f, true
+}
+
+// Narrows the numbers the squares of a cage can hold to those its sum allows. A cage whose sum no numbers
+// add up to is left for its cost to report, rather than leaving its squares nothing to hold.
+func (f *flatConstraints) restrictCageNumbers(c Cage, squares []int) {
+
+	numbers, ok := cageNumbers(c.Sum, len(c.Cells), f.maxNumber)
+	if !ok {
+		return
+	}
+	if f.cageNumbers == nil {
+		f.cageNumbers = make([][]bool, f.puzzleDim*f.puzzleDim)
+	}
+
+	for _, square := range squares {
+		if f.cageNumbers[square] == nil {
+			f.cageNumbers[square] = numbers
+			continue
+		}
+		narrowed := make([]bool, len(numbers))
+		for number := range narrowed {
+			narrowed[number] = numbers[number] && f.cageNumbers[square][number]
+		}
+		f.cageNumbers[square] = narrowed
+	}
+}
+
+// Reports whether a list of region (or cage, sandwich line or arrow) numbers holds the given one.
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
+
+// A candidate solution in the flat representation along with how often each number occurs in each of
+// its regions and cages, and the sum of each cage. The counts are fixed-size arrays laid end to end, one
+// of maxNumber+1 entries per region or cage, and are kept up to date as squares are swapped, so the cost
+// of a candidate is worked out from the few counts a swap changes rather than from scratch. Line is
+// reused to hold the numbers of a sandwich line while its sum is worked out.
+type flatState struct {
+	f            *flatConstraints
+	cells        []uint8
+	regionCounts []int
+	cageCounts   []int
+	cageSums     []int
+	line         []int
+	cost         int
+}
+
+// Counts the numbers of a candidate solution in every region and cage, and works out its cost: the same
+// cost as CostFunction.
+func (f *flatConstraints) newState(cells []uint8) *flatState {
+
+	width := f.maxNumber + 1
+	state := &flatState{
+		f:            f,
+		cells:        cells,
+		regionCounts: make([]int, len(f.regions)*width),
+		cageCounts:   make([]int, len(f.cages)*width),
+		cageSums:     make([]int, len(f.cages)),
+		line:         make([]int, f.puzzleDim),
+	}
+
+	for r, region := range f.regions {
+		for _, square := range region {
+			if number := int(cells[square]); number > 0 && number <= len(region) {
+				state.regionCounts[r*width+number]++
+			}
+		}
+		// A region of n squares should hold the numbers 1 to n once each
+		for number := 1; number <= len(region); number++ {
+			state.cost += abs(state.regionCounts[r*width+number] - 1)
+		}
+	}
+
+	for c, cage := range f.cages {
+		for _, square := range cage.cells {
+			number := int(cells[square])
+			state.cageSums[c] += number
+			if number > 0 {
+				state.cageCounts[c*width+number]++
+			}
+		}
+		state.cost += abs(state.cageSums[c] - cage.sum)
+		for number := 1; number <= f.maxNumber; number++ {
+			state.cost += duplicates(state.cageCounts[c*width+number])
+		}
+	}
+
+	for _, pair := range f.pairs {
+		if pair.rule.Breaks(int(cells[pair.square1]), int(cells[pair.square2])) {
+			state.cost++
+		}
+	}
+
+	for sandwich := range f.sandwiches {
+		state.cost += state.sandwichCost(sandwich)
+	}
+
+	for a := range f.arrows {
+		state.cost += state.arrowCost(a)
+	}
+
+	for square, parity := range f.parities {
+		if number := int(cells[square]); number > 0 && number != flatBlocked && !SuitsParity(number, parity) {
+			state.cost++
+		}
+	}
+
+	return state
+}
+
+// Returns how many times a number occurs beyond the first, given how many times it occurs.
+func duplicates(count int) int {
+	if count > 1 {
+		return count - 1
+	}
+	return 0
+}
+
+// Moves a number into (change 1) or out of (change -1) a region, updating the cost.
+func (s *flatState) countRegion(region int, number int, change int) {
+
+	if number == 0 || number > len(s.f.regions[region]) {
+		return
+	}
+
+	count := &s.regionCounts[region*(s.f.maxNumber+1)+number]
+	s.cost -= abs(*count - 1)
+	*count += change
+	s.cost += abs(*count - 1)
+}
+
+// Moves a number into (change 1) or out of (change -1) a cage, updating the cost.
+func (s *flatState) countCage(c int, number int, change int) {
+
+	s.cost -= abs(s.cageSums[c] - s.f.cages[c].sum)
+	s.cageSums[c] += change * number
+	s.cost += abs(s.cageSums[c] - s.f.cages[c].sum)
+
+	if number == 0 {
+		return
+	}
+
+	count := &s.cageCounts[c*(s.f.maxNumber+1)+number]
+	s.cost -= duplicates(*count)
+	*count += change
+	s.cost += duplicates(*count)
+}
+
+// Takes the pairs that hold one of two squares out of the cost (change -1), or puts them back in (change
+// 1). A pair holding both breaks a symmetric rule just the same when they are swapped, so of those only
+// the pairs with rules like greater-than are counted, once.
+func (s *flatState) countPairs(square1 int, square2 int, change int) {
+
+	for _, square := range [2]int{square1, square2} {
+		for _, p := range s.f.squarePairs[square] {
+			pair := s.f.pairs[p]
+			if (pair.square1 == square1 && pair.square2 == square2) || (pair.square1 == square2 && pair.square2 == square1) {
+				if pair.rule.symmetric() || square != square1 {
+					continue
+				}
+			}
+			if pair.rule.Breaks(int(s.cells[pair.square1]), int(s.cells[pair.square2])) {
+				s.cost += change
+			}
+		}
+	}
+}
+
+// Returns how far the sum of a sandwich line is from its clue.
+func (s *flatState) sandwichCost(sandwich int) int {
+
+	line := s.f.sandwiches[sandwich]
+	numbers := s.line[:len(line.cells)]
+	for i, square := range line.cells {
+		numbers[i] = int(s.cells[square])
+	}
+
+	return abs(sandwichSum(numbers) - line.sum)
+}
+
+// Takes the sandwich lines that hold either of two squares out of the cost (change -1), or puts them back
+// in (change 1). Their sums depend on where the numbers are in the line, so unlike the counts of a
+// region they change even when both squares are in the same line.
+func (s *flatState) countSandwiches(square1 int, square2 int, change int) {
+
+	for _, sandwich := range s.f.squareSandwiches[square1] {
+		s.cost += change * s.sandwichCost(sandwich)
+	}
+	for _, sandwich := range s.f.squareSandwiches[square2] {
+		if !containsIndex(s.f.squareSandwiches[square1], sandwich) {
+			s.cost += change * s.sandwichCost(sandwich)
+		}
+	}
+}
+
+// Returns 1 when the shaft of an arrow doesn't add up to its circle, and 0 when it does.
+func (s *flatState) arrowCost(a int) int {
+
+	arrow := s.f.arrows[a]
+	sum := 0
+	for _, square := range arrow.shaft {
+		sum += int(s.cells[square])
+	}
+	if sum != int(s.cells[arrow.circle]) {
+		return 1
+	}
+
+	return 0
+}
+
+// Takes the arrows that hold either of two squares out of the cost (change -1), or puts them back in
+// (change 1). An arrow holding both changes too when one is its circle.
+func (s *flatState) countArrows(square1 int, square2 int, change int) {
+
+	for _, a := range s.f.squareArrows[square1] {
+		s.cost += change * s.arrowCost(a)
+	}
+	for _, a := range s.f.squareArrows[square2] {
+		if !containsIndex(s.f.squareArrows[square1], a) {
+			s.cost += change * s.arrowCost(a)
+		}
+	}
+}
+
+// Swaps the numbers in two squares, updating the counts and cost of only the regions, cages and pairs
+// that hold one square but not the other, and the sandwich lines and arrows that hold either. Swapping
+// the same squares again undoes it.
+func (s *flatState) swapSquares(square1 int, square2 int) {
+
+	number1, number2 := int(s.cells[square1]), int(s.cells[square2])
+	if number1 == number2 {
+		return
+	}
+
+	for _, region := range s.f.squareRegions[square1] {
+		if !containsIndex(s.f.squareRegions[square2], region) {
+			s.countRegion(region, number1, -1)
+			s.countRegion(region, number2, 1)
+		}
+	}
+	for _, region := range s.f.squareRegions[square2] {
+		if !containsIndex(s.f.squareRegions[square1], region) {
+			s.countRegion(region, number2, -1)
+			s.countRegion(region, number1, 1)
+		}
+	}
+	for _, c := range s.f.squareCages[square1] {
+		if !containsIndex(s.f.squareCages[square2], c) {
+			s.countCage(c, number1, -1)
+			s.countCage(c, number2, 1)
+		}
+	}
+	for _, c := range s.f.squareCages[square2] {
+		if !containsIndex(s.f.squareCages[square1], c) {
+			s.countCage(c, number2, -1)
+			s.countCage(c, number1, 1)
+		}
+	}
+
+	s.countPairs(square1, square2, -1)
+	s.countSandwiches(square1, square2, -1)
+	s.countArrows(square1, square2, -1)
+	s.cells[square1], s.cells[square2] = s.cells[square2], s.cells[square1]
+	s.countPairs(square1, square2, 1)
+	s.countSandwiches(square1, square2, 1)
+	s.countArrows(square1, square2, 1)
+
+	if s.f.parities != nil {
+		s.cost += parityCost(number2, s.f.parities[square1]) - parityCost(number1, s.f.parities[square1])
+		s.cost += parityCost(number1, s.f.parities[square2]) - parityCost(number2, s.f.parities[square2])
+	}
+}
+
+// Returns the cost of a number in a square of the given parity: 1 when it doesn't suit it, and 0 when it
+// does or the square is empty.
+func parityCost(number int, parity int) int {
+	if number > 0 && !SuitsParity(number, parity) {
+		return 1
+	}
+	return 0
+}
+
+// The most times a move draws another pair of squares to swap when the numbers of a pair don't suit each
+// other's squares (see suitsSwap), before swapping them anyway.
+const swapRedraws = 16
+
+// A swap of the numbers in two squares, remembered so that it can be undone.
+type flatSwap struct {
+	square1 int
+	square2 int
+}
+
+// Turns the candidate solution into a neighbouring one in place by swapping the numbers in randomly
+// chosen pairs of squares, leaving the clues and blocked squares alone. The swaps are appended to swaps,
+// so the same slice can be reused for every candidate, and returned for undo.
+func (s *flatState) swap(swaps []flatSwap, swapCount int, rng *rand.Rand) []flatSwap {
+
+	freeSquares := s.f.freeSquares
+	if len(freeSquares) == 0 {
+		return swaps
+	}
+
+	for i := 0; i < swapCount; i++ {
+		swap := flatSwap{freeSquares[rng.Intn(len(freeSquares))], freeSquares[rng.Intn(len(freeSquares))]}
+		// In an even/odd or killer sudoku only numbers that suit each other's squares are swapped, so the
+		// parity the initialization arranged is kept, and numbers only leave the cages that can hold them
+		// for others that can
+		for redraw := 0; (s.f.parities != nil || s.f.cageNumbers != nil) && redraw < swapRedraws && !s.suitsSwap(swap); redraw++ {
+			swap = flatSwap{freeSquares[rng.Intn(len(freeSquares))], freeSquares[rng.Intn(len(freeSquares))]}
+		}
+		s.swapSquares(swap.square1, swap.square2)
+		swaps = append(swaps, swap)
+	}
+
+	return swaps
+}
+
+// Reports whether the numbers of the two squares of a swap suit each other's square: the parity of the
+// square in an even/odd sudoku, and in a killer sudoku its cages, which the swap mustn't move a number
+// into when they can't hold it unless the cages it leaves couldn't either.
+func (s *flatState) suitsSwap(swap flatSwap) bool {
+
+	number1, number2 := int(s.cells[swap.square1]), int(s.cells[swap.square2])
+
+	if s.f.parities != nil && (!SuitsParity(number2, s.f.parities[swap.square1]) || !SuitsParity(number1, s.f.parities[swap.square2])) {
+		return false
+	}
+	if s.f.cageNumbers != nil {
+		if s.cagesHold(swap.square1, number1) && !s.cagesHold(swap.square2, number1) {
+			return false
+		}
+		if s.cagesHold(swap.square2, number2) && !s.cagesHold(swap.square1, number2) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Reports whether the cages of a square can hold a number, as they can any number when it is in none of
+// them and the empty square.
+func (s *flatState) cagesHold(square int, number int) bool {
+	numbers := s.f.cageNumbers[square]
+	return numbers == nil || number == 0 || number >= len(numbers) || numbers[number]
+}
+
+// Undoes swaps made by swap, last first, turning a rejected candidate back into the one it came from.
+func (s *flatState) undo(swaps []flatSwap) {
+	for i := len(swaps) - 1; i >= 0; i-- {
+		s.swapSquares(swaps[i].square1, swaps[i].square2)
+	}
+}
+
+// The same annealing steps as annealerInternalIterator, run on the flat representation. Each candidate
+// is made by swapping squares of the current solution in place, its cost worked out from the counts the
+// swaps change, and rejected candidates are undone, so nothing is copied or allocated per candidate.
+// Returns the annealer's candidate solution and its cost, the fraction of the candidates that were
+// accepted and the lowest cost seen. Costlier candidates are accepted by the given rule, and random
+// numbers are drawn from rng.
+func (f *flatConstraints) anneal(candidateSolution [][]int, temperature float64, internalIterations int, swapCount int, accept AcceptanceRule, rng *rand.Rand, counts *annealCounts, solved *atomic.Bool) (solution [][]int, cost float64, acceptance float64, bestCost float64) {
+
+	state := f.newState(flatten(candidateSolution))
+	swaps := make([]flatSwap, 0, swapCount)
+
+	cost = float64(state.cost)
+	bestCost = cost
+	accepted := 0
+
+	iterations := 0
+	for iterations < internalIterations {
+		// Once another annealer has solved the puzzle there is no need to go on either
+		if iterations > 0 && iterations%solvedCheckInterval == 0 && solved != nil && solved.Load() {
+			break
+		}
+
+		iterations++
+		swaps = state.swap(swaps[:0], swapCount, rng)
+		nextCost := float64(state.cost)
+
+		// A candidate of cost zero is a solution, so there is no need to go on
+		if nextCost == 0 {
+			cost, bestCost = 0, 0
+			accepted++
+			if solved != nil {
+				solved.Store(true)
+			}
+			break
+		}
+
+		if nextCost < cost {
+			cost = nextCost
+			accepted++
+			bestCost = math.Min(bestCost, cost)
+		} else if accept.Accept(cost, nextCost, temperature, rng) {
+			cost = nextCost
+			accepted++
+		} else {
+			state.undo(swaps)
+		}
+	}
+
+	iterationCount.Add(int64(iterations))
+	costEvaluationCount.Add(int64(iterations + 1))
+	atomic.AddInt64(&counts.iterations, int64(iterations))
+	atomic.AddInt64(&counts.costEvaluations, int64(iterations+1))
+
+	return unflatten(state.cells, f.puzzleDim), cost, float64(accepted) / float64(iterations), bestCost
+}
@@ -0,0 +1,169 @@
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// The genetic algorithm picks each parent as the cheapest of tournamentSize candidates drawn at random,
+// mutates a child with the chance mutationChance, and carries the eliteCount cheapest candidates over to
+// the next generation unchanged.
+const (
+	tournamentSize = 3
+	mutationChance = 0.3
+	eliteCount     = 2
+)
+
+// A genetic algorithm: a population of candidate solutions (the Population option) evolves for up to
+// the Generations option, each generation made from the last by crossing over parents picked by
+// tournament selection. A child takes every block whole from one parent or the other (see
+// permutationRegions), and is then sometimes mutated by swapping the numbers in the Swaps option's pairs
+// of empty squares of a block. Starting from blockInitialization, every block of every candidate stays a
+// permutation of its numbers, and only the rows, columns and other regions have to be put right. The
+// candidates are costed with the Cost option; the Neighbour option and everything about temperatures and
+// annealers don't apply. The options must already have their defaults filled in (see
+// Options.WithDefaults). Returns the solution, or the best candidate found, and the stats of the search,
+// where each generation is a step and each child an iteration.
+func geneticSearch(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	if options.Initialization == nil {
+		options.Initialization = blockInitialization
+	}
+	options, _, rng := prepareSearch(originalPuzzle, constraints, options)
+	start := time.Now()
+	defer func() {
+		stats.WallTime = time.Since(start)
+	}()
+
+	// The empty squares of each block, which mutation swaps between
+	var blocks [][]Cell
+	for _, region := range permutationRegions(originalPuzzle, constraints) {
+		var emptySquares []Cell
+		for _, cell := range region {
+			if originalPuzzle[cell.Row][cell.Col] == 0 {
+				emptySquares = append(emptySquares, cell)
+			}
+		}
+		if len(emptySquares) > 1 {
+			blocks = append(blocks, emptySquares)
+		}
+	}
+	regions := permutationRegions(originalPuzzle, constraints)
+
+	cost := func(candidate [][]int) float64 {
+		stats.CostEvaluations++
+		return options.Cost(candidate, constraints)
+	}
+
+	population := make([][][]int, options.Population)
+	costs := make([]float64, options.Population)
+	for i := range population {
+		population[i] = options.Initialization(originalPuzzle, constraints, rng)
+		costs[i] = cost(population[i])
+	}
+
+	// Draws tournamentSize candidates and returns the cheapest
+	tournament := func() [][]int {
+		winner := rng.Intn(len(population))
+		for i := 1; i < tournamentSize; i++ {
+			if challenger := rng.Intn(len(population)); costs[challenger] < costs[winner] {
+				winner = challenger
+			}
+		}
+		return population[winner]
+	}
+
+	bestCost := math.Inf(1)
+	var best [][]int
+
+	for generation := 1; generation <= options.Generations; generation++ {
+
+		stats.Steps++
+
+		ranked := rankByCost(costs)
+		if costs[ranked[0]] < bestCost {
+			best, bestCost = population[ranked[0]], costs[ranked[0]]
+			if options.OnNewBest != nil {
+				options.OnNewBest(best, bestCost)
+			}
+		}
+
+		if options.OnCoolingStep != nil {
+			generationBest := replicaProgress{0, costs[ranked[0]], costs[ranked[0]], 0, 0}
+			options.OnCoolingStep(AnnealProgress{generation, 0, costs[ranked[0]], 0, time.Since(start), population[ranked[0]], []replicaProgress{generationBest}, append([][][]int(nil), population...), 0})
+		}
+
+		if bestCost == 0 {
+			return best, true, stats
+		}
+
+		select {
+		case <-options.Stop:
+			return best, false, stats
+		default:
+		}
+		if options.Timeout > 0 && time.Since(start) >= options.Timeout {
+			return best, false, stats
+		}
+
+		next := make([][][]int, 0, len(population))
+		nextCosts := make([]float64, 0, len(population))
+		for _, i := range ranked {
+			if len(next) == eliteCount {
+				break
+			}
+			next = append(next, population[i])
+			nextCosts = append(nextCosts, costs[i])
+		}
+
+		for len(next) < len(population) {
+			child := crossover(tournament(), tournament(), regions, rng)
+			if len(blocks) > 0 && rng.Float64() < mutationChance {
+				for s := 0; s < options.Swaps; s++ {
+					block := blocks[rng.Intn(len(blocks))]
+					i, j := rng.Intn(len(block)), rng.Intn(len(block))
+					child[block[i].Row][block[i].Col], child[block[j].Row][block[j].Col] = child[block[j].Row][block[j].Col], child[block[i].Row][block[i].Col]
+				}
+			}
+			next = append(next, child)
+			nextCosts = append(nextCosts, cost(child))
+			stats.Iterations++
+			iterationCount.Add(1)
+		}
+
+		population, costs = next, nextCosts
+	}
+
+	return best, false, stats
+}
+
+// Returns a child of two candidate solutions that takes each of the given regions whole from one parent
+// or the other, picked with rng. Squares outside of the regions come from the first parent.
+func crossover(parent1 [][]int, parent2 [][]int, regions [][]Cell, rng *rand.Rand) [][]int {
+
+	child := CopyPuzzle(parent1)
+	for _, region := range regions {
+		if rng.Intn(2) == 0 {
+			continue
+		}
+		for _, cell := range region {
+			child[cell.Row][cell.Col] = parent2[cell.Row][cell.Col]
+		}
+	}
+
+	return child
+}
+
+// Returns the indexes of the costs from cheapest to costliest.
+func rankByCost(costs []float64) []int {
+
+	ranked := make([]int, len(costs))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	sort.SliceStable(ranked, func(a, b int) bool { return costs[ranked[a]] < costs[ranked[b]] })
+
+	return ranked
+}
@@ -0,0 +1,85 @@
+package solver
+
+import (
+	"math/rand"
+)
+
+// Fills in the empty squares of a puzzle to make the candidate solution annealing starts from, drawing
+// any random numbers it needs from rng.
+type Initializer func(originalPuzzle [][]int, constraints []Constraint, rng *rand.Rand) [][]int
+
+// The ways of making the starting candidate solution selectable with -init, by name. Both put numbers of
+// the right parity in the squares of an even/odd sudoku where they can.
+var Initializers = map[string]Initializer{
+	"random": func(originalPuzzle [][]int, constraints []Constraint, rng *rand.Rand) [][]int {
+		initializedPuzzle := randomInitialization(originalPuzzle, NumberCount(constraints), rng)
+		var squares []Cell
+		for r := range originalPuzzle {
+			for c := range originalPuzzle[r] {
+				squares = append(squares, Cell{r, c})
+			}
+		}
+		arrangeParity(initializedPuzzle, originalPuzzle, constraints, [][]Cell{squares})
+		return initializedPuzzle
+	},
+	"blocks": blockInitialization,
+}
+
+// Returns the blocks of a puzzle, or its rows when it has no blocks, which between them cover every square
+// that isn't blocked once.
+func permutationRegions(originalPuzzle [][]int, constraints []Constraint) [][]Cell {
+
+	for _, constraint := range constraints {
+		if u, ok := constraint.(UniqueConstraint); ok && u.Kind == "block" {
+			return u.Groups
+		}
+	}
+
+	return rowConstraint(len(originalPuzzle)).Regions()
+}
+
+// Fills in every block of the puzzle as a permutation of its numbers: the numbers its clues leave out
+// are shuffled into its empty squares, so no block starts with a number missing or repeated and only the
+// rows, columns and other regions add to the starting cost. Puzzles without blocks, like Latin squares,
+// have their rows filled in the same way instead. Should the clues of a region repeat a number, the
+// squares left over are given random numbers.
+func blockInitialization(originalPuzzle [][]int, constraints []Constraint, rng *rand.Rand) (initializedPuzzle [][]int) {
+
+	numbers := NumberCount(constraints)
+	initializedPuzzle = CopyPuzzle(originalPuzzle)
+	regions := permutationRegions(originalPuzzle, constraints)
+
+	for _, region := range regions {
+
+		given := make([]bool, len(region)+1)
+		var emptySquares []Cell
+		for _, cell := range region {
+			if number := originalPuzzle[cell.Row][cell.Col]; number == 0 {
+				emptySquares = append(emptySquares, cell)
+			} else if number > 0 && number <= len(region) {
+				given[number] = true
+			}
+		}
+
+		var missing []int
+		for number := 1; number <= len(region); number++ {
+			if !given[number] {
+				missing = append(missing, number)
+			}
+		}
+		rng.Shuffle(len(missing), func(i, j int) {
+			missing[i], missing[j] = missing[j], missing[i]
+		})
+
+		for i, cell := range emptySquares {
+			if i < len(missing) {
+				initializedPuzzle[cell.Row][cell.Col] = missing[i]
+			} else {
+				initializedPuzzle[cell.Row][cell.Col] = rng.Intn(numbers) + 1
+			}
+		}
+	}
+	arrangeParity(initializedPuzzle, originalPuzzle, constraints, regions)
+
+	return initializedPuzzle
+}
@@ -0,0 +1,91 @@
+package solver
+
+import (
+	"math"
+)
+
+// A killer sudoku cage: a group of cells whose values must add up to sum without repeating a number.
+type Cage struct {
+	Sum   int
+	Cells []Cell
+}
+
+// The constraint formed by all of the cages in a killer sudoku.
+type CageConstraint struct {
+	Cages []Cage
+}
+
+func (k CageConstraint) Cost(p Puzzle) float64 {
+	return CageCost(p, k.Cages)
+}
+
+func (k CageConstraint) Regions() (regions [][]Cell) {
+	for _, c := range k.Cages {
+		regions = append(regions, c.Cells)
+	}
+	return regions
+}
+
+// Returns the numbers from 1 to maxNumber that a cage of the given size and sum can hold, indexed by
+// number: those in some set of size different numbers that adds up to sum. ok is false when no set does.
+func cageNumbers(sum int, size int, maxNumber int) (numbers []bool, ok bool) {
+
+	numbers = make([]bool, maxNumber+1)
+	if size < 1 || size > maxNumber || sum < 1 || sum > maxNumber*size {
+		return numbers, false
+	}
+
+	for number := 1; number <= maxNumber && number <= sum; number++ {
+		// Whether some k of the other numbers add up to s, built up one number at a time
+		rest := sum - number
+		reachable := make([][]bool, size)
+		for k := range reachable {
+			reachable[k] = make([]bool, rest+1)
+		}
+		reachable[0][0] = true
+		for other := 1; other <= maxNumber; other++ {
+			if other == number {
+				continue
+			}
+			for k := size - 1; k >= 1; k-- {
+				for s := rest; s >= other; s-- {
+					reachable[k][s] = reachable[k][s] || reachable[k-1][s-other]
+				}
+			}
+		}
+		if reachable[size-1][rest] {
+			numbers[number], ok = true, true
+		}
+	}
+
+	return numbers, ok
+}
+
+// The cage component of the cost for a killer sudoku. Each cage adds the absolute difference between
+// the sum of its cells and its target sum, plus one for every repeated occurance of a number inside it.
+func CageCost(puzzle [][]int, cages []Cage) (cost float64) {
+
+	for _, c := range cages {
+
+		sum := 0
+		counts := make(map[int]int)
+
+		for _, cell := range c.Cells {
+			number := puzzle[cell.Row][cell.Col]
+			sum += number
+			if number > 0 {
+				counts[number]++
+			}
+		}
+
+		cost += math.Abs(float64(sum - c.Sum))
+
+		for _, count := range counts {
+			if count > 1 {
+				cost += float64(count - 1)
+			}
+		}
+	}
+
+	return cost
+}
@@ -0,0 +1,74 @@
+package solver
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCageNumbers(t *testing.T) {
+
+	for _, test := range []struct {
+		sum, size int
+		want      []int
+	}{
+		{3, 2, []int{1, 2}},
+		{17, 2, []int{8, 9}},
+		{10, 2, []int{1, 2, 3, 4, 6, 7, 8, 9}},
+		{6, 3, []int{1, 2, 3}},
+		{23, 3, []int{6, 8, 9}},
+		{45, 9, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+	} {
+		numbers, ok := cageNumbers(test.sum, test.size, 9)
+		var got []int
+		for number, held := range numbers {
+			if held {
+				got = append(got, number)
+			}
+		}
+		if !ok || !reflect.DeepEqual(got, test.want) {
+			t.Errorf("a cage of %d squares summing to %d can hold %v (ok %v), not %v", test.size, test.sum, got, ok, test.want)
+		}
+	}
+
+	for _, test := range []struct{ sum, size int }{{2, 2}, {18, 2}, {46, 9}, {10, 10}} {
+		if _, ok := cageNumbers(test.sum, test.size, 9); ok {
+			t.Errorf("a cage of %d squares summing to %d can hold some numbers", test.size, test.sum)
+		}
+	}
+}
+
+func TestSwapsKeepNumbersInCagesThatCanHoldThem(t *testing.T) {
+
+	// Cage 3:r1c1,r1c2 can only hold 1 and 2, and 17:r1c3,r1c4 only 8 and 9
+	puzzle := parseTestPuzzle(strings.Repeat(".", 81))
+	cages := []Cage{{Sum: 3, Cells: []Cell{{0, 0}, {0, 1}}}, {Sum: 17, Cells: []Cell{{0, 2}, {0, 3}}}}
+	flat, ok := newFlatConstraints(puzzle, PuzzleConstraints(9, BlockRegionMap(3, 3), nil, cages))
+	if !ok {
+		t.Fatal("the killer sudoku has no flat form")
+	}
+
+	candidate := flatten(puzzle)
+	copy(candidate, []uint8{1, 2, 8, 9, 3, 4, 5, 6, 7})
+	state := flat.newState(candidate)
+
+	for _, test := range []struct {
+		square1, square2 int
+		suits            bool
+	}{
+		{0, 1, true},  // 1 and 2 both suit the first cage
+		{0, 2, false}, // 1 can't be in the second cage, nor 8 in the first
+		{1, 4, false}, // 2 may leave the first cage for a square in none, but 3 can't go into it
+		{4, 5, true},  // Neither square is in a cage
+	} {
+		if suits := state.suitsSwap(flatSwap{test.square1, test.square2}); suits != test.suits {
+			t.Errorf("swapping squares %d and %d suits the cages is %v, not %v", test.square1, test.square2, suits, test.suits)
+		}
+	}
+
+	// A number that its cage can't hold may be swapped for one it can
+	state.cells[0], state.cells[4] = 5, 1
+	if !state.suitsSwap(flatSwap{0, 4}) {
+		t.Error("swapping a 5 out of a cage that can't hold it for a 1 doesn't suit the cages")
+	}
+}
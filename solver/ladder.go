@@ -0,0 +1,128 @@
+package solver
+
+import (
+	"log/slog"
+	"math"
+	"math/rand"
+)
+
+// A temperature ladder: the multiple of the base temperature that annealer i (counting from 0, the
+// coldest) runs at. Every annealer cools at the same rate, so the ladder keeps its shape as it cools.
+type TemperatureLadder func(i int) float64
+
+// A ladder whose temperatures grow by the same ratio from one annealer to the next: 1, ratio, ratio^2...
+// The default ladder doubles them.
+func GeometricLadder(ratio float64) TemperatureLadder {
+	return func(i int) float64 {
+		return math.Pow(ratio, float64(i))
+	}
+}
+
+// A ladder whose temperatures grow by the same step (a multiple of the base temperature) from one
+// annealer to the next: 1, 1+step, 1+2*step...
+func LinearLadder(step float64) TemperatureLadder {
+	return func(i int) float64 {
+		return 1 + step*float64(i)
+	}
+}
+
+// A ladder of the given temperatures, relative to the first. Annealers beyond the end of the list carry on
+// at the ratio between its last two temperatures (or double the last, for a list of one).
+func ListLadder(temperatures []float64) TemperatureLadder {
+
+	ratio := 2.0
+	if n := len(temperatures); n > 1 {
+		ratio = temperatures[n-1] / temperatures[n-2]
+	}
+
+	return func(i int) float64 {
+		if i < len(temperatures) {
+			return temperatures[i] / temperatures[0]
+		}
+		last := len(temperatures) - 1
+		return temperatures[last] / temperatures[0] * math.Pow(ratio, float64(i-last))
+	}
+}
+
+// The pilot runs of tuneLadder: the temperatures tried, spread geometrically over the default ladder's
+// span, and the stretches of annealing at each, the first of which is left out of the average cost.
+const (
+	pilotTemperatures = 12
+	pilotStretches    = 5
+)
+
+// Builds a ladder for the annealers from short pilot runs, a standard first step of parallel tempering.
+// The puzzle is annealed from candidate for a few steps' worth of iterations at fixed temperatures across
+// the span of the default ladder (from the base temperature to 2^(annealers-1) times it), measuring the
+// average cost at each. Two annealers at temperatures T1 < T2 whose average costs are E1 and E2 would
+// accept each other's candidates with a probability of about exp(-(1/T1 - 1/T2)(E2 - E1)), so the
+// temperatures are placed where the sum of those exponents, accumulated up the pilot temperatures, is
+// evenly divided between the annealers, giving every neighbouring pair about the same exchange rate.
+// Falls back on the default ladder when the pilot runs can't tell the temperatures apart. The pilot runs
+// are seeded from rng, and the work is added to counts.
+func tuneLadder(originalPuzzle [][]int, candidate [][]int, constraints []Constraint, options Options, flat *flatConstraints, rng *rand.Rand, counts *annealCounts) TemperatureLadder {
+
+	n := options.Annealers
+	if n < 2 {
+		return nil
+	}
+
+	base := options.Temperature
+	span := math.Pow(2, float64(n-1))
+
+	solution, cost := make(chan [][]int, 1), make(chan float64, 1)
+	acceptance, bestCost := make(chan float64, 1), make(chan float64, 1)
+	pilotOptions := options
+	pilotOptions.Iterations = options.Iterations/pilotStretches + 1
+
+	temperatures := make([]float64, pilotTemperatures)
+	costs := make([]float64, pilotTemperatures)
+	for k := range temperatures {
+		temperatures[k] = base * math.Pow(span, float64(k)/float64(pilotTemperatures-1))
+		pilotRng := rand.New(rand.NewSource(rng.Int63()))
+
+		current := candidate
+		for stretch := 0; stretch < pilotStretches; stretch++ {
+			annealerInternalIterator(originalPuzzle, current, constraints, temperatures[k], pilotOptions, flat, pilotRng, counts, nil, solution, cost, acceptance, bestCost)
+			current = <-solution
+			if c := <-cost; stretch > 0 {
+				costs[k] += c / (pilotStretches - 1)
+			}
+			<-acceptance
+			<-bestCost
+		}
+	}
+
+	// The distance between neighbouring pilot temperatures is how unlikely an exchange between them is
+	distances := make([]float64, pilotTemperatures)
+	for k := 1; k < pilotTemperatures; k++ {
+		gap := (1/temperatures[k-1] - 1/temperatures[k]) * math.Max(0, costs[k]-costs[k-1])
+		distances[k] = distances[k-1] + gap
+	}
+	total := distances[pilotTemperatures-1]
+	if !(total > 0) {
+		return nil
+	}
+
+	ladder := make([]float64, n)
+	ladder[0] = base
+	k := 1
+	for i := 1; i < n; i++ {
+		target := total * float64(i) / float64(n-1)
+		for k < pilotTemperatures-1 && distances[k] < target {
+			k++
+		}
+		// Interpolate between the pilot temperatures on either side of the target, on a log scale
+		fraction := 1.0
+		if gap := distances[k] - distances[k-1]; gap > 0 {
+			fraction = (target - distances[k-1]) / gap
+		}
+		ladder[i] = temperatures[k-1] * math.Pow(temperatures[k]/temperatures[k-1], fraction)
+		if ladder[i] <= ladder[i-1] {
+			ladder[i] = ladder[i-1] * (1 + 1e-9)
+		}
+	}
+
+	slog.Debug("tuned the temperature ladder", "temperatures", ladder, "pilot_costs", costs)
+	return ListLadder(ladder)
+}
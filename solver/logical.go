@@ -0,0 +1,416 @@
+package solver
+
+import (
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// One deduction made while solving a puzzle by logic: the technique it used and what it concluded, in
+// words. Steps that fill in a square give the square and its number; Number is 0 for the rest.
+type LogicalStep struct {
+	Technique string
+	Text      string
+	Cell      Cell
+	Number    int
+}
+
+// The human solving techniques ExplainPuzzle knows, from the simplest to the hardest. Every step is made
+// with the simplest technique that gets anywhere, so the hardest technique a puzzle needs says how
+// difficult it is to solve by hand.
+var LogicalTechniques = []string{"naked single", "hidden single", "pointing", "box-line reduction", "intersection", "naked pair", "naked triple"}
+
+// A region that must hold every number once, named for the steps that mention it (eg. "row 3").
+type namedRegion struct {
+	name  string
+	kind  string
+	cells []Cell
+}
+
+// The state of a puzzle being solved by logic: the squares filled in so far, and the numbers each empty
+// square could still hold as a bit mask, with bit n set when n is possible.
+type logicalSolver struct {
+	grid       [][]int
+	candidates [][]uint64
+	regions    []namedRegion
+	peers      [][][]Cell
+	symbols    string
+	steps      []LogicalStep
+}
+
+// Solves a puzzle as far as human techniques allow: naked and hidden singles, pointing pairs and
+// triples, box-line reduction (and the same between any two other regions, like diagonals), and naked
+// pairs and triples. Only the constraints whose regions must hold every number once, the pairs of
+// squares that must hold different numbers and the parity of the squares of an even/odd sudoku are
+// used, so the cages of a killer sudoku are ignored. Numbers in the steps are written with the given
+// symbols. Returns every step made, the puzzle filled in as far as they got, and whether it was solved.
+// A puzzle with no solution ends with a step saying which square has no numbers left.
+func ExplainPuzzle(puzzle [][]int, constraints []Constraint, symbols string) (steps []LogicalStep, grid [][]int, solved bool) {
+
+	s := newLogicalSolver(puzzle, constraints, symbols)
+
+	for {
+		if cell, stuck := s.emptySquare(); stuck {
+			s.step("contradiction", "%s has no numbers left, so the puzzle has no solution", CellName(cell))
+			return s.steps, s.grid, false
+		}
+		if s.complete() {
+			return s.steps, s.grid, true
+		}
+		if !(s.nakedSingle() || s.hiddenSingle() || s.intersection() || s.nakedSubset(2) || s.nakedSubset(3)) {
+			return s.steps, s.grid, false
+		}
+	}
+}
+
+// Returns a solver for the puzzle with the numbers each empty square could hold worked out from the
+// clues.
+func newLogicalSolver(puzzle [][]int, constraints []Constraint, symbols string) *logicalSolver {
+
+	numbers := NumberCount(constraints)
+	s := &logicalSolver{grid: CopyPuzzle(puzzle), symbols: symbols}
+
+	for _, constraint := range constraints {
+		if u, ok := constraint.(UniqueConstraint); ok {
+			for index, region := range u.Groups {
+				if len(region) == numbers {
+					s.regions = append(s.regions, namedRegion{fmt.Sprintf("%s %d", u.Kind, index+1), u.Kind, region})
+				}
+			}
+		}
+	}
+
+	s.peers = make([][][]Cell, len(puzzle))
+	s.candidates = make([][]uint64, len(puzzle))
+	for r := range puzzle {
+		s.peers[r] = make([][]Cell, len(puzzle[r]))
+		s.candidates[r] = make([]uint64, len(puzzle[r]))
+	}
+	for _, region := range s.regions {
+		for _, cell := range region.cells {
+			for _, peer := range region.cells {
+				if peer != cell {
+					s.peers[cell.Row][cell.Col] = append(s.peers[cell.Row][cell.Col], peer)
+				}
+			}
+		}
+	}
+	// The squares of a pair that must differ can't hold each other's numbers either
+	for _, constraint := range constraints {
+		if p, ok := constraint.(PairConstraint); ok && p.Rule == PairsDiffer {
+			for _, pair := range p.Pairs {
+				s.peers[pair[0].Row][pair[0].Col] = append(s.peers[pair[0].Row][pair[0].Col], pair[1])
+				s.peers[pair[1].Row][pair[1].Col] = append(s.peers[pair[1].Row][pair[1].Col], pair[0])
+			}
+		}
+	}
+
+	// Squares of an even/odd sudoku can only hold the numbers of their parity
+	all := uint64(1)<<uint(numbers+1) - 2
+	parities := SquareParities(len(puzzle), constraints)
+	for r := range puzzle {
+		for c := range puzzle[r] {
+			if puzzle[r][c] != 0 {
+				continue
+			}
+			s.candidates[r][c] = all
+			for number := 1; parities != nil && number <= numbers; number++ {
+				if !SuitsParity(number, parities[r][c]) {
+					s.candidates[r][c] &^= 1 << uint(number)
+				}
+			}
+		}
+	}
+	for r := range puzzle {
+		for c, number := range puzzle[r] {
+			if number > 0 {
+				for _, peer := range s.peers[r][c] {
+					s.candidates[peer.Row][peer.Col] &^= 1 << uint(number)
+				}
+			}
+		}
+	}
+
+	return s
+}
+
+// Records a step.
+func (s *logicalSolver) step(technique string, format string, args ...interface{}) {
+	s.steps = append(s.steps, LogicalStep{Technique: technique, Text: fmt.Sprintf(format, args...)})
+}
+
+// Fills in a square and rules its number out of every square sharing a region with it, recording the
+// step that found it.
+func (s *logicalSolver) place(cell Cell, number int, technique string, format string, args ...interface{}) {
+
+	s.step(technique, format, args...)
+	s.steps[len(s.steps)-1].Cell = cell
+	s.steps[len(s.steps)-1].Number = number
+
+	s.grid[cell.Row][cell.Col] = number
+	s.candidates[cell.Row][cell.Col] = 0
+	for _, peer := range s.peers[cell.Row][cell.Col] {
+		s.candidates[peer.Row][peer.Col] &^= 1 << uint(number)
+	}
+}
+
+// Rules the numbers in a bit mask out of the given squares, except those in keep, and returns the
+// squares that lost any.
+func (s *logicalSolver) eliminate(mask uint64, cells []Cell, keep []Cell) (changed []Cell) {
+
+	for _, cell := range cells {
+		if s.grid[cell.Row][cell.Col] != 0 || containsCell(keep, cell) {
+			continue
+		}
+		if s.candidates[cell.Row][cell.Col]&mask != 0 {
+			s.candidates[cell.Row][cell.Col] &^= mask
+			changed = append(changed, cell)
+		}
+	}
+
+	return changed
+}
+
+// Returns an empty square with no numbers left, if there is one.
+func (s *logicalSolver) emptySquare() (Cell, bool) {
+
+	for r := range s.grid {
+		for c := range s.grid[r] {
+			if s.grid[r][c] == 0 && s.candidates[r][c] == 0 {
+				return Cell{r, c}, true
+			}
+		}
+	}
+
+	return Cell{}, false
+}
+
+// Reports whether every square is filled in.
+func (s *logicalSolver) complete() bool {
+
+	for r := range s.grid {
+		for c := range s.grid[r] {
+			if s.grid[r][c] == 0 {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// A naked single: an empty square that can only hold one number.
+func (s *logicalSolver) nakedSingle() bool {
+
+	for r := range s.grid {
+		for c := range s.grid[r] {
+			if mask := s.candidates[r][c]; s.grid[r][c] == 0 && bits.OnesCount64(mask) == 1 {
+				number := bits.TrailingZeros64(mask)
+				s.place(Cell{r, c}, number, "naked single", "%s can only be %s", CellName(Cell{r, c}), SymbolText(s.symbols, number))
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// A hidden single: a number that can only go in one square of a region.
+func (s *logicalSolver) hiddenSingle() bool {
+
+	for _, region := range s.regions {
+		for number := 1; number <= len(region.cells); number++ {
+			places := s.places(region.cells, number)
+			if len(places) == 1 {
+				s.place(places[0], number, "hidden single", "%s can only go in %s of %s", SymbolText(s.symbols, number), CellName(places[0]), region.name)
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Pointing pairs and triples, box-line reduction, and the same between any other two regions: when a
+// number can only go in the squares one region shares with another, it can't go anywhere else in the
+// other region either.
+func (s *logicalSolver) intersection() bool {
+
+	for _, region := range s.regions {
+		for number := 1; number <= len(region.cells); number++ {
+			places := s.places(region.cells, number)
+			if len(places) < 2 {
+				continue
+			}
+
+			for _, other := range s.regions {
+				if other.name == region.name || !containsCells(other.cells, places) {
+					continue
+				}
+				changed := s.eliminate(1<<uint(number), other.cells, places)
+				if len(changed) == 0 {
+					continue
+				}
+
+				technique := "intersection"
+				if region.kind == "block" {
+					technique = "pointing"
+				} else if other.kind == "block" {
+					technique = "box-line reduction"
+				}
+				s.step(technique, "%s can only go in %s of %s, which are also in %s, so it's ruled out of %s", SymbolText(s.symbols, number), cellNames(places), region.name, other.name, cellNames(changed))
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Naked pairs (size 2) and triples (size 3): that many empty squares of a region that can only hold that
+// many numbers between them, which must then go in those squares, so none of the region's other squares
+// can hold them.
+func (s *logicalSolver) nakedSubset(size int) bool {
+
+	technique := map[int]string{2: "naked pair", 3: "naked triple"}[size]
+
+	for _, region := range s.regions {
+
+		var empty []Cell
+		for _, cell := range region.cells {
+			if s.grid[cell.Row][cell.Col] == 0 {
+				empty = append(empty, cell)
+			}
+		}
+		if len(empty) <= size {
+			continue
+		}
+
+		// Every choice of size squares, as increasing indexes into empty
+		chosen := make([]int, size)
+		var try func(depth int, from int, mask uint64) bool
+		try = func(depth int, from int, mask uint64) bool {
+			if bits.OnesCount64(mask) > size {
+				return false
+			}
+			if depth == size {
+				subset := make([]Cell, size)
+				for i, index := range chosen {
+					subset[i] = empty[index]
+				}
+				changed := s.eliminate(mask, region.cells, subset)
+				if len(changed) == 0 {
+					return false
+				}
+				s.step(technique, "%s of %s can only hold %s, so they're ruled out of %s", cellNames(subset), region.name, s.numberNames(mask), cellNames(changed))
+				return true
+			}
+			for i := from; i < len(empty); i++ {
+				chosen[depth] = i
+				if try(depth+1, i+1, mask|s.candidates[empty[i].Row][empty[i].Col]) {
+					return true
+				}
+			}
+			return false
+		}
+		if try(0, 0, 0) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the empty squares among the given ones that could still hold a number.
+func (s *logicalSolver) places(cells []Cell, number int) (places []Cell) {
+
+	for _, cell := range cells {
+		if s.grid[cell.Row][cell.Col] == number {
+			return nil
+		}
+		if s.candidates[cell.Row][cell.Col]&(1<<uint(number)) != 0 {
+			places = append(places, cell)
+		}
+	}
+
+	return places
+}
+
+// Returns the numbers in a bit mask, written with the solver's symbols and separated by "and".
+func (s *logicalSolver) numberNames(mask uint64) string {
+
+	var names []string
+	for number := 1; mask>>uint(number) != 0; number++ {
+		if mask&(1<<uint(number)) != 0 {
+			names = append(names, SymbolText(s.symbols, number))
+		}
+	}
+
+	return strings.Join(names, " and ")
+}
+
+// Returns the r1c1 style name of a square.
+func CellName(cell Cell) string {
+	return fmt.Sprintf("r%dc%d", cell.Row+1, cell.Col+1)
+}
+
+// Returns the names of squares, comma separated.
+func cellNames(cells []Cell) string {
+
+	names := make([]string, len(cells))
+	for i, cell := range cells {
+		names[i] = CellName(cell)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// Reports whether a square is among the given ones.
+func containsCell(cells []Cell, cell Cell) bool {
+
+	for _, c := range cells {
+		if c == cell {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Reports whether every one of some squares is among the given ones.
+func containsCells(cells []Cell, some []Cell) bool {
+
+	for _, cell := range some {
+		if !containsCell(cells, cell) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the hardest of the techniques used by some steps, in the order of LogicalTechniques, or "" if
+// there were none.
+func HardestTechnique(steps []LogicalStep) (hardest string) {
+
+	rank := -1
+	for _, step := range steps {
+		for i, technique := range LogicalTechniques {
+			if technique == step.Technique && i > rank {
+				rank, hardest = i, technique
+			}
+		}
+	}
+
+	return hardest
+}
+
+// Returns the symbol used to show a number, or the number itself when there is no symbol set.
+func SymbolText(symbols string, number int) string {
+
+	if symbols == "" {
+		return fmt.Sprint(number)
+	}
+
+	return string([]rune(symbols)[number-1])
+}
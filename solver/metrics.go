@@ -0,0 +1,13 @@
+package solver
+
+import (
+	"expvar"
+)
+
+// Counters of the work done by the annealers over the life of the program, published by expvar at
+// /debug/vars when -pprof is given.
+var (
+	iterationCount      = expvar.NewInt("iterations")
+	costEvaluationCount = expvar.NewInt("cost_evaluations")
+	exchangeCount       = expvar.NewInt("exchanges")
+)
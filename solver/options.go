@@ -0,0 +1,304 @@
+package solver
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// The fewest and most annealers run by default. The annealers form a temperature ladder, each twice as hot
+// as the one below it, so a couple of rungs are needed for exchanges to help, while much beyond eight the
+// hottest annealers are too hot to ever hold onto a good candidate.
+const (
+	minDefaultAnnealers = 4
+	maxDefaultAnnealers = 8
+)
+
+// Returns the default number of annealers: one for every CPU the program may use, since every annealer
+// runs at once, kept between minDefaultAnnealers and maxDefaultAnnealers.
+func DefaultAnnealerCount() int {
+
+	count := runtime.GOMAXPROCS(0)
+	if count < minDefaultAnnealers {
+		return minDefaultAnnealers
+	}
+	if count > maxDefaultAnnealers {
+		return maxDefaultAnnealers
+	}
+	return count
+}
+
+// The options of a solve. Anything left as its zero value takes its value from DefaultOptions, the same
+// defaults as the command line's flags.
+type Options struct {
+	// The base temperature of the coldest annealer; annealer i runs at Temperature * Ladder(i), which
+	// doubles from one annealer to the next when Ladder is nil (GeometricLadder(2))
+	Temperature float64
+	Ladder      TemperatureLadder
+	// Builds the ladder for each puzzle from pilot runs instead (see tuneLadder)
+	AutoLadder bool
+	// The cooling schedule: every cooling step multiplies the temperatures by CoolingRate (between 0 and
+	// 1) until the base temperature falls below FinalTemperature
+	CoolingRate      float64
+	FinalTemperature float64
+	// The candidate solutions each annealer tries at every cooling step, and the swaps that make each one
+	Iterations int
+	Swaps      int
+	// With AdaptiveSwaps every annealer starts with Swaps swaps per candidate and adapts them to its
+	// acceptance rate after every cooling step (see adaptSwaps)
+	AdaptiveSwaps bool
+	// The number of annealers (replicas), from coldest to hottest, which all run at once
+	Annealers int
+	// The move strategy, which returns a neighbouring candidate solution without changing the clues or
+	// the current candidate, and the cost function, which is zero for a solution. When neither is set the
+	// built-in swap move and CostFunction run on a quicker flat representation of the puzzle; setting
+	// either runs both on the puzzle as it is (with getNeighbour or CostFunction for the one left unset).
+	// The move draws its random numbers from rng, which belongs to the annealer calling it
+	Neighbour func(current [][]int, swapCount int, originalPuzzle [][]int, rng *rand.Rand) [][]int
+	Cost      func(puzzle [][]int, constraints []Constraint) float64
+	// Decides whether annealers accept candidates that cost more than their current ones
+	Acceptance AcceptanceRule
+	// Makes the candidate solution every annealer starts from (see initializers). When nil the search
+	// algorithm picks: randomInitialization's shuffle of the numbers left over by the clues, or
+	// blockInitialization for the genetic algorithm
+	Initialization Initializer
+	// Gives every annealer a starting candidate of its own, made the same way, rather than a copy of one
+	DiverseStarts bool
+	// The search to run (see Algorithms), the number of candidates population annealing and the genetic
+	// algorithm keep, and the most generations the genetic algorithm evolves them for
+	Algorithm   string
+	Population  int
+	Generations int
+	// The moves tabu search keeps a number from going back into a square it was swapped out of for
+	TabuTenure int
+	// Seeds the solve's own random number generator, so a solve given the same seed is repeated. A solve
+	// given none draws a fresh seed, which its stats record
+	Seed int64
+	// Annealing gives up after the cooling step it is on once Timeout has passed (unless zero) or Stop is
+	// closed (unless nil)
+	Timeout time.Duration
+	Stop    <-chan struct{}
+	// Hooks for watching the run, each called unless nil: OnCoolingStep after every cooling step,
+	// OnNewBest whenever a cooling step ends with a candidate cheaper than any before it (which must not be
+	// modified), and OnExchange whenever the annealers i and j (the colder one first) trade candidates
+	OnCoolingStep func(AnnealProgress)
+	OnNewBest     func(candidate [][]int, cost float64)
+	OnExchange    func(i int, j int)
+	// Called unless nil after every cooling step of anneal with the state the solve can be carried on
+	// from, which must not be modified, and the state to carry on from (unless nil). The other search
+	// algorithms don't report their state or resume
+	OnState func(state SolverState)
+	Resume  *SolverState
+}
+
+// Returns the default options: the same as the command line's defaults.
+func DefaultOptions() Options {
+	return Options{
+		Temperature:      1,
+		CoolingRate:      0.9,
+		FinalTemperature: 0.00001,
+		Iterations:       1000,
+		Swaps:            1,
+		Annealers:        DefaultAnnealerCount(),
+		Acceptance:       Metropolis{},
+		Algorithm:        "anneal",
+		Population:       50,
+		Generations:      10000,
+		TabuTenure:       5,
+	}
+}
+
+// Returns the options with everything left unset taken from the defaults.
+func (o Options) WithDefaults() Options {
+
+	defaults := DefaultOptions()
+	if o.Temperature == 0 {
+		o.Temperature = defaults.Temperature
+	}
+	if o.CoolingRate == 0 {
+		o.CoolingRate = defaults.CoolingRate
+	}
+	if o.FinalTemperature == 0 {
+		o.FinalTemperature = defaults.FinalTemperature
+	}
+	if o.Iterations == 0 {
+		o.Iterations = defaults.Iterations
+	}
+	if o.Swaps == 0 {
+		o.Swaps = defaults.Swaps
+	}
+	if o.Annealers == 0 {
+		o.Annealers = defaults.Annealers
+	}
+	if o.Acceptance == nil {
+		o.Acceptance = defaults.Acceptance
+	}
+	if o.Algorithm == "" {
+		o.Algorithm = defaults.Algorithm
+	}
+	if o.Population == 0 {
+		o.Population = defaults.Population
+	}
+	if o.Generations == 0 {
+		o.Generations = defaults.Generations
+	}
+	if o.TabuTenure == 0 {
+		o.TabuTenure = defaults.TabuTenure
+	}
+
+	return o
+}
+
+// Returns an error describing the first option that is out of range.
+func (o Options) Validate() error {
+
+	switch {
+	case !(o.Temperature > 0):
+		return fmt.Errorf("the temperature must be greater than 0, not %v", o.Temperature)
+	case !(o.CoolingRate > 0 && o.CoolingRate < 1):
+		return fmt.Errorf("the cooling rate must be greater than 0 and less than 1, not %v", o.CoolingRate)
+	case !(o.FinalTemperature > 0):
+		return fmt.Errorf("the final temperature must be greater than 0, not %v", o.FinalTemperature)
+	case o.Iterations < 1:
+		return fmt.Errorf("the iterations must be at least 1, not %d", o.Iterations)
+	case o.Swaps < 1:
+		return fmt.Errorf("the swaps must be at least 1, not %d", o.Swaps)
+	case o.Annealers < 1:
+		return fmt.Errorf("the annealers must be at least 1, not %d", o.Annealers)
+	case Algorithms[o.Algorithm] == nil:
+		return fmt.Errorf("unknown algorithm %q (expected one of %s)", o.Algorithm, AlgorithmNames())
+	case o.Population < 1:
+		return fmt.Errorf("the population must be at least 1, not %d", o.Population)
+	case o.Generations < 1:
+		return fmt.Errorf("the generations must be at least 1, not %d", o.Generations)
+	case o.TabuTenure < 1:
+		return fmt.Errorf("the tabu tenure must be at least 1, not %d", o.TabuTenure)
+	case o.Timeout < 0:
+		return fmt.Errorf("the timeout must not be negative, not %s", o.Timeout)
+	}
+	return nil
+}
+
+// Returns the temperature annealer i runs at when the base temperature is base.
+func (o Options) AnnealerTemperature(base float64, i int) float64 {
+	if o.Ladder == nil {
+		return base * math.Pow(2, float64(i))
+	}
+	return base * o.Ladder(i)
+}
+
+// Solves a puzzle under the given constraints with simulated annealing, for programs that embed the
+// solver. Options left unset take their defaults. Returns the solution, or the best candidate found
+// when solved is false, the stats of the solve, and an error if the options are out of range. Solves
+// share no state, so any number may run at once on different goroutines.
+func Solve(puzzle [][]int, constraints []Constraint, options Options) (solution [][]int, solved bool, stats Stats, e error) {
+
+	options, err := solveOptions(puzzle, options)
+	if err != nil {
+		return nil, false, stats, err
+	}
+
+	solution, solved, stats = Search(puzzle, constraints, options)
+	return solution, solved, stats, nil
+}
+
+// Fills in the defaults of the options of Solve or SolveProgress and checks them. A resumed solve is
+// checked to be of the same puzzle, and carries on with the parameters it was started with.
+func solveOptions(puzzle [][]int, options Options) (Options, error) {
+
+	options = options.WithDefaults()
+	if err := options.Validate(); err != nil {
+		return options, err
+	}
+	if resume := options.Resume; resume != nil {
+		if err := resume.Check(puzzle); err != nil {
+			return options, err
+		}
+		options.CoolingRate, options.Iterations, options.Swaps, options.Annealers = resume.CoolingRate, resume.Iterations, resume.Swaps, resume.Annealers
+		options.Seed = resume.Seed
+	}
+
+	return options, nil
+}
+
+// A report of a solve started by SolveProgress after one of its cooling steps: the step, the base
+// temperature, the lowest cost of any annealer and the time since the solve started. Grid is a copy of
+// the candidate with the lowest cost when the solve was asked for snapshots, and nil otherwise.
+type ProgressEvent struct {
+	Step        int
+	Temperature float64
+	BestCost    float64
+	Elapsed     time.Duration
+	Grid        [][]int
+}
+
+// How SolveProgress reports progress. Interval is the least time between two events, so a GUI redrawing
+// on every event isn't flooded (zero sends every cooling step), and Snapshots adds a copy of the best
+// candidate to every event.
+type ProgressOptions struct {
+	Interval  time.Duration
+	Snapshots bool
+}
+
+// The outcome of a solve started by SolveProgress, as Solve returns it.
+type SolveResult struct {
+	Solution [][]int
+	Solved   bool
+	Stats    Stats
+}
+
+// Solves a puzzle like Solve, but in the background, for programs that watch the solve from a GUI or a
+// web page. Progress is sent on events after cooling steps, at most one per progress.Interval along with
+// the step that solves the puzzle, and the solve never waits for it to be read: a reader that falls
+// behind skips to the latest event. Once the solve finishes events is closed and its result is sent on
+// result. Any OnCoolingStep in the options is still called. Returns an error, without starting the solve,
+// if the options are out of range.
+func SolveProgress(puzzle [][]int, constraints []Constraint, options Options, progress ProgressOptions) (events <-chan ProgressEvent, result <-chan SolveResult, e error) {
+
+	options, err := solveOptions(puzzle, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	if progress.Interval < 0 {
+		return nil, nil, fmt.Errorf("the progress interval must not be negative, not %s", progress.Interval)
+	}
+
+	eventChan := make(chan ProgressEvent, 1)
+	resultChan := make(chan SolveResult, 1)
+
+	var lastEvent time.Duration
+	send := func(p AnnealProgress) {
+
+		if progress.Interval > 0 && p.Step > 1 && p.Elapsed-lastEvent < progress.Interval && p.BestCost > 0 {
+			return
+		}
+		lastEvent = p.Elapsed
+
+		event := ProgressEvent{p.Step, p.Temperature, p.BestCost, p.Elapsed, nil}
+		if progress.Snapshots {
+			event.Grid = CopyPuzzle(p.Candidate)
+		}
+
+		// Only the solve sends, so once an unread event is dropped there is room for the latest
+		select {
+		case eventChan <- event:
+		default:
+			select {
+			case <-eventChan:
+			default:
+			}
+			eventChan <- event
+		}
+	}
+	options.OnCoolingStep = CombineProgress(options.OnCoolingStep, send)
+
+	go func() {
+		solution, solved, stats := Search(puzzle, constraints, options)
+		close(eventChan)
+		resultChan <- SolveResult{solution, solved, stats}
+	}()
+
+	return eventChan, resultChan, nil
+}
@@ -0,0 +1,92 @@
+package solver
+
+import (
+	"testing"
+	"time"
+)
+
+// A 9x9 puzzle hard enough that annealing never solves it in the few cooling steps the tests run.
+const testPuzzle = "4.....8.5.3..........7......2.....6.....8.4......1.......6.3.7.5..2.....1.4......"
+
+// Returns the puzzle written on one line, with '.' for its empty squares.
+func parseTestPuzzle(line string) [][]int {
+
+	puzzleDim := 1
+	for puzzleDim*puzzleDim < len(line) {
+		puzzleDim++
+	}
+
+	puzzle := make([][]int, puzzleDim)
+	for i := range puzzle {
+		puzzle[i] = make([]int, puzzleDim)
+		for j := range puzzle[i] {
+			if square := line[i*puzzleDim+j]; square != '.' {
+				puzzle[i][j] = int(square - '0')
+			}
+		}
+	}
+
+	return puzzle
+}
+
+// Returns the test puzzle with the constraints of a standard 9x9 sudoku.
+func readTestPuzzle(t *testing.T) ([][]int, []Constraint) {
+	t.Helper()
+
+	return parseTestPuzzle(testPuzzle), PuzzleConstraints(9, BlockRegionMap(3, 3), nil, nil)
+}
+
+// Returns the state of a solve of the puzzle by the given number of annealers after its first cooling
+// step, which is where the solve stops.
+func firstSolverState(t *testing.T, puzzle [][]int, constraints []Constraint, annealers int) *SolverState {
+	t.Helper()
+
+	var state *SolverState
+	stop := make(chan struct{})
+	options := Options{Annealers: annealers, Iterations: 10, Seed: 1, Stop: stop}
+	options.OnState = func(s SolverState) {
+		if state == nil {
+			state = &s
+			close(stop)
+		}
+	}
+	if _, _, _, err := Solve(puzzle, constraints, options); err != nil {
+		t.Fatal(err)
+	}
+	if state == nil {
+		t.Fatal("the solve never reported its state")
+	}
+
+	return state
+}
+
+func TestSolveProgressResumesWithTheStatesAnnealers(t *testing.T) {
+
+	puzzle, constraints := readTestPuzzle(t)
+	state := firstSolverState(t, puzzle, constraints, 2)
+
+	// The options ask for more annealers than the state has solutions for, which the state overrides
+	options := Options{Annealers: 3, Iterations: 10, Timeout: time.Second, Resume: state}
+	events, result, err := SolveProgress(puzzle, constraints, options, ProgressOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range events {
+	}
+
+	if accepted := (<-result).Stats.Accepted; len(accepted) != 2 {
+		t.Errorf("the resumed solve ran %d annealers, not the state's 2", len(accepted))
+	}
+}
+
+func TestSolveProgressChecksTheResumedPuzzle(t *testing.T) {
+
+	puzzle, constraints := readTestPuzzle(t)
+	state := firstSolverState(t, puzzle, constraints, 2)
+
+	other := CopyPuzzle(puzzle)
+	other[0][1] = 1
+	if _, _, err := SolveProgress(other, constraints, Options{Resume: state}, ProgressOptions{}); err == nil {
+		t.Error("resuming the state of another puzzle was not an error")
+	}
+}
@@ -0,0 +1,149 @@
+package solver
+
+import (
+	"fmt"
+)
+
+// How the numbers in the two squares of a pair have to relate.
+type pairRule int
+
+const (
+	// The squares hold different numbers, as in anti-knight and anti-king sudoku.
+	PairsDiffer pairRule = iota
+	// The squares don't hold consecutive numbers, as in non-consecutive sudoku.
+	PairsNotConsecutive
+	// The squares hold consecutive numbers, as on either side of the markers of consecutive sudoku.
+	PairsConsecutive
+	// The first square holds the greater number, as at the inequality signs of greater-than sudoku.
+	PairsGreater
+)
+
+// Reports whether the numbers in the two squares of a pair break the rule. Empty squares break nothing.
+func (rule pairRule) Breaks(number1 int, number2 int) bool {
+
+	if number1 <= 0 || number2 <= 0 {
+		return false
+	}
+
+	switch rule {
+	case PairsDiffer:
+		return number1 == number2
+	case PairsNotConsecutive:
+		return abs(number1-number2) == 1
+	case PairsConsecutive:
+		return abs(number1-number2) != 1
+	case PairsGreater:
+		return number1 <= number2
+	}
+
+	return false
+}
+
+// Reports whether a rule is broken the same way with the squares of its pairs the other way around.
+func (rule pairRule) symmetric() bool {
+	return rule != PairsGreater
+}
+
+// A constraint on pairs of squares, each pair adding one to the cost when its numbers break the rule.
+// Kind names the constraint ("anti-knight", "non-consecutive", ...) when reporting violations.
+type PairConstraint struct {
+	Kind  string
+	Rule  pairRule
+	Pairs [][2]Cell
+}
+
+func (p PairConstraint) Cost(puzzle Puzzle) (cost float64) {
+
+	for _, pair := range p.Pairs {
+		if p.Rule.Breaks(puzzle[pair[0].Row][pair[0].Col], puzzle[pair[1].Row][pair[1].Col]) {
+			cost++
+		}
+	}
+
+	return cost
+}
+
+// The pairs of a constraint like anti-knight cover every square, so there are no regions to mark when
+// the puzzle is printed.
+func (p PairConstraint) Regions() [][]Cell {
+	return nil
+}
+
+func (p PairConstraint) String() string {
+	return p.Kind
+}
+
+// A pair of squares whose numbers break the rule of a pair constraint.
+type pairViolation struct {
+	kind    string
+	rule    pairRule
+	pair    [2]Cell
+	number1 int
+	number2 int
+}
+
+func (v pairViolation) String() string {
+
+	squares := CellName(v.pair[0]) + " and " + CellName(v.pair[1])
+	switch v.rule {
+	case PairsNotConsecutive:
+		return fmt.Sprintf("%s: %s hold %d and %d, which are consecutive", v.kind, squares, v.number1, v.number2)
+	case PairsConsecutive:
+		return fmt.Sprintf("%s: %s hold %d and %d, which aren't consecutive", v.kind, squares, v.number1, v.number2)
+	case PairsGreater:
+		return fmt.Sprintf("%s: %s > %s, but they hold %d and %d", v.kind, CellName(v.pair[0]), CellName(v.pair[1]), v.number1, v.number2)
+	}
+
+	return fmt.Sprintf("%s: %s both hold %d", v.kind, squares, v.number1)
+}
+
+// Returns the pairs that break the rule of a pair constraint in a puzzle, which may be partly filled in.
+func (p PairConstraint) Violations(puzzle [][]int) (violations []pairViolation) {
+
+	for _, pair := range p.Pairs {
+		number1, number2 := puzzle[pair[0].Row][pair[0].Col], puzzle[pair[1].Row][pair[1].Col]
+		if p.Rule.Breaks(number1, number2) {
+			violations = append(violations, pairViolation{p.Kind, p.Rule, pair, number1, number2})
+		}
+	}
+
+	return violations
+}
+
+// Returns every pair of squares of a puzzle of the given dimension that one of the moves (as rows down
+// and columns across) leads between. Each pair is found once as long as no move is the reverse of
+// another.
+func MovePairs(puzzleDim int, moves [][2]int) (pairs [][2]Cell) {
+
+	for r := 0; r < puzzleDim; r++ {
+		for c := 0; c < puzzleDim; c++ {
+			for _, move := range moves {
+				r2, c2 := r+move[0], c+move[1]
+				if r2 >= 0 && r2 < puzzleDim && c2 >= 0 && c2 < puzzleDim {
+					pairs = append(pairs, [2]Cell{{r, c}, {r2, c2}})
+				}
+			}
+		}
+	}
+
+	return pairs
+}
+
+// The moves between squares side by side, or one above the other.
+var OrthogonalMoves = [][2]int{{0, 1}, {1, 0}}
+
+// Squares a knight's move apart must hold different numbers.
+func antiKnightConstraint(puzzleDim int) Constraint {
+	return PairConstraint{"anti-knight", PairsDiffer, MovePairs(puzzleDim, [][2]int{{1, -2}, {1, 2}, {2, -1}, {2, 1}})}
+}
+
+// Squares a king's move apart must hold different numbers. The squares beside each other already do, as
+// they share a row or column, so only the diagonal neighbours are paired.
+func antiKingConstraint(puzzleDim int) Constraint {
+	return PairConstraint{"anti-king", PairsDiffer, MovePairs(puzzleDim, [][2]int{{1, -1}, {1, 1}})}
+}
+
+// Squares side by side, or one above the other, must not hold consecutive numbers.
+func nonConsecutiveConstraint(puzzleDim int) Constraint {
+	return PairConstraint{"non-consecutive", PairsNotConsecutive, MovePairs(puzzleDim, OrthogonalMoves)}
+}
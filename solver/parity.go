@@ -0,0 +1,124 @@
+package solver
+
+import (
+	"fmt"
+)
+
+// The constraint of an even/odd sudoku: the squares that must hold even numbers, and those that must hold
+// odd ones.
+type ParityConstraint struct {
+	Even []Cell
+	Odd  []Cell
+}
+
+// The cost is the number of marked squares holding a number of the wrong parity.
+func (p ParityConstraint) Cost(puzzle Puzzle) (cost float64) {
+	return float64(len(p.Violations(puzzle)))
+}
+
+// The marked squares are shaded (or circled) in print rather than regions, so there are none to mark.
+func (p ParityConstraint) Regions() [][]Cell {
+	return nil
+}
+
+func (p ParityConstraint) String() string {
+	return "parity"
+}
+
+// A marked square holding a number of the wrong parity.
+type parityViolation struct {
+	Cell   Cell
+	number int
+	even   bool
+}
+
+func (v parityViolation) String() string {
+	if v.even {
+		return fmt.Sprintf("parity: %s holds %d, which isn't even", CellName(v.Cell), v.number)
+	}
+	return fmt.Sprintf("parity: %s holds %d, which isn't odd", CellName(v.Cell), v.number)
+}
+
+// Returns the marked squares of a puzzle, which may be partly filled in, that hold a number of the wrong
+// parity. Empty squares break nothing.
+func (p ParityConstraint) Violations(puzzle [][]int) (violations []parityViolation) {
+
+	for _, cell := range p.Even {
+		if number := puzzle[cell.Row][cell.Col]; number > 0 && number%2 != 0 {
+			violations = append(violations, parityViolation{cell, number, true})
+		}
+	}
+	for _, cell := range p.Odd {
+		if number := puzzle[cell.Row][cell.Col]; number > 0 && number%2 != 1 {
+			violations = append(violations, parityViolation{cell, number, false})
+		}
+	}
+
+	return violations
+}
+
+// Returns the parity every square of a puzzle of the given dimension must have under the parity
+// constraints among constraints: 0 for even, 1 for odd, and -1 for squares that may hold either. Returns
+// nil when there are no parity constraints.
+func SquareParities(puzzleDim int, constraints []Constraint) (parities [][]int) {
+
+	for _, constraint := range constraints {
+		p, ok := constraint.(ParityConstraint)
+		if !ok {
+			continue
+		}
+		if parities == nil {
+			parities = make([][]int, puzzleDim)
+			for r := range parities {
+				parities[r] = make([]int, puzzleDim)
+				for c := range parities[r] {
+					parities[r][c] = -1
+				}
+			}
+		}
+		for _, cell := range p.Even {
+			parities[cell.Row][cell.Col] = 0
+		}
+		for _, cell := range p.Odd {
+			parities[cell.Row][cell.Col] = 1
+		}
+	}
+
+	return parities
+}
+
+// Reports whether a number suits a square that must have the given parity (see SquareParities).
+func SuitsParity(number int, parity int) bool {
+	return parity < 0 || number%2 == parity
+}
+
+// Rearranges the numbers an initialization filled into the empty squares of each region so that as many
+// as possible suit the parity of their square: each square whose number doesn't is swapped with another
+// empty square of the same region whose number suits it, and which its own number suits in turn. As the
+// numbers only move within their regions, the counts the initialization filled each region with are
+// kept. Does nothing when there are no parity constraints.
+func arrangeParity(puzzle [][]int, originalPuzzle [][]int, constraints []Constraint, regions [][]Cell) {
+
+	parities := SquareParities(len(puzzle), constraints)
+	if parities == nil {
+		return
+	}
+
+	for _, region := range regions {
+		for _, cell := range region {
+			if originalPuzzle[cell.Row][cell.Col] != 0 || SuitsParity(puzzle[cell.Row][cell.Col], parities[cell.Row][cell.Col]) {
+				continue
+			}
+			for _, other := range region {
+				if originalPuzzle[other.Row][other.Col] != 0 {
+					continue
+				}
+				number, otherNumber := puzzle[cell.Row][cell.Col], puzzle[other.Row][other.Col]
+				if SuitsParity(otherNumber, parities[cell.Row][cell.Col]) && SuitsParity(number, parities[other.Row][other.Col]) {
+					puzzle[cell.Row][cell.Col], puzzle[other.Row][other.Col] = otherNumber, number
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package solver
+
+import (
+	"fmt"
+)
+
+// The numbers along each thermometer, from its bulb, must rise. Each square along it has to hold a
+// greater number than the one before it, so the thermometer is a pair constraint whose pairs are its
+// squares and the ones before them.
+func ThermometerConstraint(thermometers [][]Cell) Constraint {
+
+	constraint := PairConstraint{"thermometer", PairsGreater, nil}
+	for _, thermometer := range thermometers {
+		for i := 1; i < len(thermometer); i++ {
+			constraint.Pairs = append(constraint.Pairs, [2]Cell{thermometer[i], thermometer[i-1]})
+		}
+	}
+
+	return constraint
+}
+
+// An arrow of an arrow sudoku: the numbers along its shaft add up to the number in its circle.
+type Arrow struct {
+	Circle Cell
+	Shaft  []Cell
+}
+
+// Returns the sum of the numbers along the shaft of an arrow.
+func (a Arrow) sum(puzzle [][]int) (sum int) {
+
+	for _, cell := range a.Shaft {
+		sum += puzzle[cell.Row][cell.Col]
+	}
+
+	return sum
+}
+
+// The constraint of the arrows of a puzzle.
+type ArrowConstraint struct {
+	Arrows []Arrow
+}
+
+// The cost is the number of arrows whose shafts don't add up to their circles.
+func (a ArrowConstraint) Cost(puzzle Puzzle) (cost float64) {
+	return float64(len(a.Violations(puzzle)))
+}
+
+// Arrows are drawn over the squares rather than around regions, so there are none to mark.
+func (a ArrowConstraint) Regions() [][]Cell {
+	return nil
+}
+
+func (a ArrowConstraint) String() string {
+	return "arrow"
+}
+
+// An arrow whose shaft doesn't add up to its circle. Index is its 1-based number.
+type arrowViolation struct {
+	index int
+	arrow Arrow
+	sum   int
+	want  int
+}
+
+func (v arrowViolation) String() string {
+	return fmt.Sprintf("arrow %d: the shaft from %s adds up to %d, not the %d in its circle", v.index, CellName(v.arrow.Shaft[0]), v.sum, v.want)
+}
+
+// Returns the arrows of a filled grid whose shafts don't add up to their circles.
+func (a ArrowConstraint) Violations(puzzle [][]int) (violations []arrowViolation) {
+
+	for i, arrow := range a.Arrows {
+		want := puzzle[arrow.Circle.Row][arrow.Circle.Col]
+		if sum := arrow.sum(puzzle); sum != want {
+			violations = append(violations, arrowViolation{i + 1, arrow, sum, want})
+		}
+	}
+
+	return violations
+}
+
+// Returns the arrows of a puzzle whose clues already rule them out: those whose circle is given with
+// clues along the shaft adding up to more than it, or to anything else when the whole shaft is given.
+// Along with each arrow's 1-based number, returns the clues that break it.
+func (a ArrowConstraint) Conflicts(puzzle [][]int) (indexes []int, cells [][]Cell) {
+
+	for i, arrow := range a.Arrows {
+		want := puzzle[arrow.Circle.Row][arrow.Circle.Col]
+		if want == 0 {
+			continue
+		}
+
+		given := []Cell{arrow.Circle}
+		sum, full := 0, true
+		for _, cell := range arrow.Shaft {
+			if number := puzzle[cell.Row][cell.Col]; number > 0 {
+				sum += number
+				given = append(given, cell)
+			} else {
+				full = false
+			}
+		}
+
+		if sum > want || full && sum != want {
+			indexes = append(indexes, i+1)
+			cells = append(cells, given)
+		}
+	}
+
+	return indexes, cells
+}
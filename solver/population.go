@@ -0,0 +1,171 @@
+package solver
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+	"time"
+)
+
+// Population annealing: rather than a ladder of annealers at different temperatures, a population of
+// candidate solutions (the Population option) all anneal at the same temperature. At every cooling step
+// the population is first resampled for the new, lower temperature, each candidate being cloned or culled
+// in proportion to how much likelier it is at the new temperature than the old. Cheap candidates are
+// cloned and costly ones culled, so the search piles onto the most promising candidates as it cools,
+// while the clones go on to anneal apart from each other. Every candidate then anneals for the Iterations
+// option at the new temperature, all of them at once. The options must already have their defaults filled
+// in (see Options.WithDefaults), and the Annealers, DiverseStarts, OnExchange and Resume options don't
+// apply. Returns the solution, or the best candidate of the last step, and the stats of the search.
+func populationAnneal(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	options, flat, rng := prepareSearch(originalPuzzle, constraints, options)
+	counts := &annealCounts{}
+	start := time.Now()
+
+	stats.Accepted = make([]int64, 1)
+	defer func() {
+		stats.Iterations = atomic.LoadInt64(&counts.iterations)
+		stats.CostEvaluations = atomic.LoadInt64(&counts.costEvaluations)
+		stats.WallTime = time.Since(start)
+	}()
+
+	members := make([][][]int, options.Population)
+	costs := make([]float64, options.Population)
+	for i := range members {
+		members[i] = options.Initialization(originalPuzzle, constraints, rng)
+		costs[i] = options.Cost(members[i], constraints)
+		atomic.AddInt64(&counts.costEvaluations, 1)
+	}
+
+	memberSolution := make([]chan [][]int, options.Population)
+	memberCost := make([]chan float64, options.Population)
+	memberAcceptance := make([]chan float64, options.Population)
+	memberBestCost := make([]chan float64, options.Population)
+	memberCounts := make([]annealCounts, options.Population)
+	for i := range members {
+		memberSolution[i] = make(chan [][]int, 1)
+		memberCost[i] = make(chan float64, 1)
+		memberAcceptance[i] = make(chan float64, 1)
+		memberBestCost[i] = make(chan float64, 1)
+	}
+
+	bestCost := math.Inf(1)
+	best := 0
+
+	for step, temperature := 1, options.Temperature; temperature > options.FinalTemperature; step, temperature = step+1, temperature*options.CoolingRate {
+
+		stats.Steps++
+		stats.FinalTemperature = temperature
+
+		if step > 1 {
+			members, costs = resamplePopulation(members, costs, 1/(temperature/options.CoolingRate), 1/temperature, rng)
+		}
+
+		// Clones share their candidate with the original until they anneal, which never changes a
+		// candidate in place. Every candidate draws from its own random number generator, seeded from the
+		// search's
+		solved := &atomic.Bool{}
+		for i := range members {
+			memberCounts[i] = annealCounts{}
+			memberRng := rand.New(rand.NewSource(rng.Int63()))
+			go annealerInternalIterator(originalPuzzle, members[i], constraints, temperature, options, flat, memberRng, &memberCounts[i], solved, memberSolution[i], memberCost[i], memberAcceptance[i], memberBestCost[i])
+		}
+
+		acceptanceRate := 0.0
+		stepBestCost := math.Inf(1)
+		for i := range members {
+			members[i] = <-memberSolution[i]
+			costs[i] = <-memberCost[i]
+			acceptance := <-memberAcceptance[i]
+			stepBestCost = math.Min(stepBestCost, <-memberBestCost[i])
+			acceptanceRate += acceptance / float64(len(members))
+
+			iterations := atomic.LoadInt64(&memberCounts[i].iterations)
+			stats.Accepted[0] += int64(math.Round(acceptance * float64(iterations)))
+			atomic.AddInt64(&counts.iterations, iterations)
+			atomic.AddInt64(&counts.costEvaluations, atomic.LoadInt64(&memberCounts[i].costEvaluations))
+		}
+
+		best = 0
+		for i := range members {
+			if costs[i] < costs[best] {
+				best = i
+			}
+		}
+
+		if options.OnNewBest != nil && costs[best] < bestCost {
+			options.OnNewBest(members[best], costs[best])
+		}
+		bestCost = math.Min(bestCost, costs[best])
+
+		if options.OnCoolingStep != nil {
+			population := replicaProgress{temperature, stepBestCost, costs[best], acceptanceRate, 0}
+			options.OnCoolingStep(AnnealProgress{step, temperature, costs[best], acceptanceRate, time.Since(start), members[best], []replicaProgress{population}, append([][][]int(nil), members...), 0})
+		}
+
+		if costs[best] == 0 {
+			return members[best], true, stats
+		}
+
+		select {
+		case <-options.Stop:
+			return members[best], false, stats
+		default:
+		}
+		if options.Timeout > 0 && time.Since(start) >= options.Timeout {
+			return members[best], false, stats
+		}
+	}
+
+	return members[best], false, stats
+}
+
+// Resamples a population of candidate solutions for a change of inverse temperature (1/T) from
+// oldBeta to newBeta, keeping its size. Each candidate is expected to be copied in proportion to its
+// weight exp(-(newBeta-oldBeta)*cost), so candidates cheaper than the rest are cloned and the costliest
+// are culled. Systematic resampling is used, which copies each candidate the whole number of times it is
+// expected to be copied and once more with the chance of the fraction left over, drawn with rng. The
+// copies share their candidate solution.
+func resamplePopulation(members [][][]int, costs []float64, oldBeta float64, newBeta float64, rng *rand.Rand) ([][][]int, []float64) {
+
+	// Weights are taken relative to the cheapest candidate so that they can't all round to zero
+	lowest := math.Inf(1)
+	for _, cost := range costs {
+		lowest = math.Min(lowest, cost)
+	}
+
+	weights := make([]float64, len(costs))
+	total := 0.0
+	for i, cost := range costs {
+		weights[i] = math.Exp(-(newBeta - oldBeta) * (cost - lowest))
+		total += weights[i]
+	}
+
+	size := len(members)
+	resampled := make([][][]int, 0, size)
+	resampledCosts := make([]float64, 0, size)
+
+	offset := rng.Float64()
+	expected := 0.0
+	for i := range members {
+		expected += weights[i] / total * float64(size)
+		for len(resampled) < size && float64(len(resampled))+offset < expected {
+			resampled = append(resampled, members[i])
+			resampledCosts = append(resampledCosts, costs[i])
+		}
+	}
+
+	// Rounding can leave the population a candidate short, which is made up with the cheapest
+	for len(resampled) < size {
+		cheapest := 0
+		for i, cost := range costs {
+			if cost < costs[cheapest] {
+				cheapest = i
+			}
+		}
+		resampled = append(resampled, members[cheapest])
+		resampledCosts = append(resampledCosts, costs[cheapest])
+	}
+
+	return resampled, resampledCosts
+}
@@ -0,0 +1,57 @@
+package solver
+
+import (
+	"time"
+)
+
+// The state of the annealers after a cooling step. Temperature is the base temperature of the coldest
+// annealer, BestCost the lowest cost of any annealer, and AcceptanceRate the fraction of candidate solutions accepted during
+// the step, averaged over all of the annealers. Candidate is the candidate solution with the lowest cost,
+// which must not be modified, and Replicas holds the state of each annealer from coldest to hottest.
+// Solutions holds every annealer's candidate solution in the same order, which must not be modified either.
+// Seed is what the run draws its random numbers from after the step, when it can be carried on from it
+// (see SolverState), and zero otherwise.
+type AnnealProgress struct {
+	Step           int
+	Temperature    float64
+	BestCost       float64
+	AcceptanceRate float64
+	Elapsed        time.Duration
+	Candidate      [][]int
+	Replicas       []replicaProgress
+	Solutions      [][][]int
+	Seed           int64
+}
+
+// The state of one annealer after a cooling step: its temperature, the lowest cost it reached during the
+// step and its cost at the end of it, the fraction of candidate solutions it accepted, and how many
+// times it then exchanged its solution with a neighbouring annealer (0, 1 or 2).
+type replicaProgress struct {
+	Temperature    float64
+	BestCost       float64
+	Cost           float64
+	AcceptanceRate float64
+	Exchanges      int
+}
+
+// Combines progress functions into one that calls each in turn, skipping any that are nil. Returns nil
+// when there are none, so anneal doesn't collect progress at all.
+func CombineProgress(reporters ...func(AnnealProgress)) func(AnnealProgress) {
+
+	var active []func(AnnealProgress)
+	for _, report := range reporters {
+		if report != nil {
+			active = append(active, report)
+		}
+	}
+
+	if len(active) == 0 {
+		return nil
+	}
+
+	return func(p AnnealProgress) {
+		for _, report := range active {
+			report(p)
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package solver
+
+import (
+	"fmt"
+)
+
+// A sandwich clue: the sum of the numbers between the 1 and the highest number (9 in a 9x9 puzzle) of
+// a row or column, wherever they are in it. Kind names the line ("row" or "column"), index is its 1-based
+// number and Cells are its squares in order.
+type SandwichClue struct {
+	Kind  string
+	Index int
+	Cells []Cell
+	Sum   int
+}
+
+// The constraint of a sandwich sudoku: the clues given beside its rows and above its columns.
+type SandwichConstraint struct {
+	Clues []SandwichClue
+}
+
+// Returns where the bread of a line of numbers is: the first 1 and the first of the highest number, in the
+// order they come in. ok is false until the line holds both.
+func sandwichEnds(numbers []int) (first int, last int, ok bool) {
+
+	lowest, highest := -1, -1
+	for i, number := range numbers {
+		if number == 1 && lowest < 0 {
+			lowest = i
+		}
+		if number == len(numbers) && highest < 0 {
+			highest = i
+		}
+	}
+	if lowest < 0 || highest < 0 {
+		return 0, 0, false
+	}
+
+	if lowest > highest {
+		return highest, lowest, true
+	}
+	return lowest, highest, true
+}
+
+// Returns the sum of the numbers between the 1 and the highest number of a line of numbers (see
+// sandwichEnds), which is 0 until the line holds both.
+func sandwichSum(numbers []int) (sum int) {
+
+	first, last, ok := sandwichEnds(numbers)
+	if !ok {
+		return 0
+	}
+	for _, number := range numbers[first+1 : last] {
+		sum += number
+	}
+
+	return sum
+}
+
+// Returns the numbers in the squares of a line, in order.
+func lineNumbers(puzzle [][]int, cells []Cell) []int {
+
+	numbers := make([]int, len(cells))
+	for i, cell := range cells {
+		numbers[i] = puzzle[cell.Row][cell.Col]
+	}
+
+	return numbers
+}
+
+// The cost is how far the sum of each line is from its clue, added up over the lines. A line that doesn't
+// hold a 1 or the highest number yet counts its whole clue, but its row or column is then broken too.
+func (s SandwichConstraint) Cost(puzzle Puzzle) (cost float64) {
+
+	for _, clue := range s.Clues {
+		cost += float64(abs(sandwichSum(lineNumbers(puzzle, clue.Cells)) - clue.Sum))
+	}
+
+	return cost
+}
+
+// The clues sit outside the grid, so there are no regions to mark when the puzzle is printed.
+func (s SandwichConstraint) Regions() [][]Cell {
+	return nil
+}
+
+func (s SandwichConstraint) String() string {
+	return "sandwich"
+}
+
+// A line whose sandwich sum isn't its clue.
+type sandwichViolation struct {
+	clue SandwichClue
+	sum  int
+}
+
+func (v sandwichViolation) String() string {
+	return fmt.Sprintf("sandwich: %s %d adds up to %d between its 1 and %d, not %d", v.clue.Kind, v.clue.Index, v.sum, len(v.clue.Cells), v.clue.Sum)
+}
+
+// Returns the lines of a filled grid whose sandwich sums aren't their clues.
+func (s SandwichConstraint) Violations(puzzle [][]int) (violations []sandwichViolation) {
+
+	for _, clue := range s.Clues {
+		if sum := sandwichSum(lineNumbers(puzzle, clue.Cells)); sum != clue.Sum {
+			violations = append(violations, sandwichViolation{clue, sum})
+		}
+	}
+
+	return violations
+}
+
+// Returns the lines of a puzzle whose clues already rule out their sandwich sums: those given both a 1
+// and the highest number with clues between them adding up to more than the sum, or to anything else
+// when every square between them is given. Along with each line, returns the clues that break it.
+func (s SandwichConstraint) Conflicts(puzzle [][]int) (clues []SandwichClue, cells [][]Cell) {
+
+	for _, clue := range s.Clues {
+		numbers := lineNumbers(puzzle, clue.Cells)
+		first, last, ok := sandwichEnds(numbers)
+		if !ok {
+			continue
+		}
+
+		given := []Cell{clue.Cells[first]}
+		sum, full := 0, true
+		for i := first + 1; i < last; i++ {
+			if numbers[i] > 0 {
+				sum += numbers[i]
+				given = append(given, clue.Cells[i])
+			} else {
+				full = false
+			}
+		}
+		given = append(given, clue.Cells[last])
+
+		if sum > clue.Sum || full && sum != clue.Sum {
+			clues = append(clues, clue)
+			cells = append(cells, given)
+		}
+	}
+
+	return clues, cells
+}
@@ -0,0 +1,56 @@
+package solver
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// What a solve did: the cooling steps it ran, the candidate solutions tried across every annealer, the
+// evaluations of the cost function, the candidates each annealer accepted (from coldest to hottest), the
+// exchanges between annealers, the restarts, the time taken and the base temperature of the last
+// cooling step, along with the seed the random numbers were drawn with (0 when the options gave none) and
+// the hash of the puzzle, which identify the solve.
+type Stats struct {
+	Steps            int
+	Iterations       int64
+	CostEvaluations  int64
+	Accepted         []int64
+	Exchanges        int
+	Restarts         int
+	WallTime         time.Duration
+	FinalTemperature float64
+	Seed             int64
+	PuzzleHash       string
+}
+
+// The iterations and cost evaluations of a solve, counted as the annealers go. Both are updated atomically.
+type annealCounts struct {
+	iterations      int64
+	costEvaluations int64
+}
+
+// Writes the stats as a table.
+func (s Stats) Print(w io.Writer) {
+
+	out := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	defer out.Flush()
+
+	accepted := make([]string, len(s.Accepted))
+	for i, count := range s.Accepted {
+		accepted[i] = fmt.Sprint(count)
+	}
+
+	fmt.Fprintf(out, "cooling steps\t%d\n", s.Steps)
+	fmt.Fprintf(out, "iterations\t%d\n", s.Iterations)
+	fmt.Fprintf(out, "cost evaluations\t%d\n", s.CostEvaluations)
+	fmt.Fprintf(out, "accepted by annealer\t%s\n", strings.Join(accepted, " "))
+	fmt.Fprintf(out, "exchanges\t%d\n", s.Exchanges)
+	fmt.Fprintf(out, "restarts\t%d\n", s.Restarts)
+	fmt.Fprintf(out, "wall time\t%s\n", s.WallTime)
+	fmt.Fprintf(out, "final temperature\t%.6g\n", s.FinalTemperature)
+	fmt.Fprintf(out, "seed\t%d\n", s.Seed)
+	fmt.Fprintf(out, "puzzle hash\t%s\n", s.PuzzleHash)
+}
@@ -0,0 +1,156 @@
+package solver
+
+import (
+	"math"
+	"time"
+)
+
+// The number of random swaps tabu search weighs up before making each move.
+const tabuCandidates = 500
+
+// Tabu search: rather than accepting moves by chance at a temperature, every move weighs up tabuCandidates
+// random swaps of two empty squares (the built-in neighbour move) and makes the cheapest, even when it
+// costs more than the current candidate. Neither number a move swaps may then go back into the square it
+// left for the next TabuTenure moves, which keeps the search from undoing its moves and cycling back into
+// the same local minimum. A tabu swap is still made if it would be cheaper than any candidate found
+// so far (the aspiration criterion). The search runs the Iterations option's moves at each step, for as
+// many steps as the cooling schedule would have, so it tries as many candidates as annealing would. Only
+// the Cost option and the initialization are used from the options, which must already have their defaults
+// filled in (see Options.WithDefaults). Returns the solution, or the best candidate found, and the stats of
+// the search, where each move is an iteration.
+func tabuSearch(originalPuzzle [][]int, constraints []Constraint, options Options) (solvedPuzzle [][]int, solutionFound bool, stats Stats) {
+
+	options, flat, rng := prepareSearch(originalPuzzle, constraints, options)
+	start := time.Now()
+	defer func() {
+		stats.WallTime = time.Since(start)
+	}()
+
+	puzzleDim := len(originalPuzzle)
+	var freeSquares []int
+	for r := range originalPuzzle {
+		for c, number := range originalPuzzle[r] {
+			if number == 0 {
+				freeSquares = append(freeSquares, r*puzzleDim+c)
+			}
+		}
+	}
+
+	// Squares are numbered r*puzzleDim+c, and swapped and costed on the flat representation when there is
+	// one, so each swap weighed up only costs the few counts it changes
+	candidate := options.Initialization(originalPuzzle, constraints, rng)
+	swap := func(square1 int, square2 int) {
+		r1, c1, r2, c2 := square1/puzzleDim, square1%puzzleDim, square2/puzzleDim, square2%puzzleDim
+		candidate[r1][c1], candidate[r2][c2] = candidate[r2][c2], candidate[r1][c1]
+	}
+	number := func(square int) int {
+		return candidate[square/puzzleDim][square%puzzleDim]
+	}
+	currentCost := func() float64 {
+		return options.Cost(candidate, constraints)
+	}
+	snapshot := func() [][]int {
+		return CopyPuzzle(candidate)
+	}
+	if flat != nil {
+		state := flat.newState(flatten(candidate))
+		swap = state.swapSquares
+		number = func(square int) int {
+			return int(state.cells[square])
+		}
+		currentCost = func() float64 {
+			return float64(state.cost)
+		}
+		snapshot = func() [][]int {
+			return unflatten(state.cells, puzzleDim)
+		}
+	}
+
+	cost := currentCost()
+	stats.CostEvaluations++
+	best, bestCost := snapshot(), cost
+	if cost == 0 || len(freeSquares) < 2 {
+		return best, cost == 0, stats
+	}
+
+	// The move until which each number may not go back into each square, at square*(numbers+1)+number
+	numbers := NumberCount(constraints)
+	tabuUntil := make([]int, puzzleDim*puzzleDim*(numbers+1))
+	tabu := func(square int, number int) *int {
+		return &tabuUntil[square*(numbers+1)+number]
+	}
+	move := 0
+
+	for step, temperature := 1, options.Temperature; temperature > options.FinalTemperature; step, temperature = step+1, temperature*options.CoolingRate {
+
+		stats.Steps++
+		stepBestCost := cost
+
+		for i := 0; i < options.Iterations; i++ {
+			move++
+
+			chosen1, chosen2, chosenCost := -1, -1, math.Inf(1)
+			for k := 0; k < tabuCandidates; k++ {
+				square1, square2 := freeSquares[rng.Intn(len(freeSquares))], freeSquares[rng.Intn(len(freeSquares))]
+				if number(square1) == number(square2) {
+					continue
+				}
+
+				swap(square1, square2)
+				nextCost := currentCost()
+				swap(square1, square2)
+				stats.CostEvaluations++
+
+				isTabu := *tabu(square1, number(square2)) >= move || *tabu(square2, number(square1)) >= move
+				if isTabu && nextCost >= bestCost {
+					continue
+				}
+				if nextCost < chosenCost {
+					chosen1, chosen2, chosenCost = square1, square2, nextCost
+				}
+			}
+
+			if chosen1 < 0 {
+				continue
+			}
+
+			*tabu(chosen1, number(chosen1)) = move + options.TabuTenure
+			*tabu(chosen2, number(chosen2)) = move + options.TabuTenure
+			swap(chosen1, chosen2)
+			cost = chosenCost
+			stats.Iterations++
+			iterationCount.Add(1)
+			stepBestCost = math.Min(stepBestCost, cost)
+
+			if cost < bestCost {
+				best, bestCost = snapshot(), cost
+				if options.OnNewBest != nil {
+					options.OnNewBest(best, bestCost)
+				}
+			}
+			if cost == 0 {
+				break
+			}
+		}
+
+		if options.OnCoolingStep != nil {
+			searcher := replicaProgress{0, stepBestCost, cost, 0, 0}
+			options.OnCoolingStep(AnnealProgress{step, 0, bestCost, 0, time.Since(start), best, []replicaProgress{searcher}, [][][]int{snapshot()}, 0})
+		}
+
+		if bestCost == 0 {
+			return best, true, stats
+		}
+
+		select {
+		case <-options.Stop:
+			return best, false, stats
+		default:
+		}
+		if options.Timeout > 0 && time.Since(start) >= options.Timeout {
+			return best, false, stats
+		}
+	}
+
+	return best, false, stats
+}
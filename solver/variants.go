@@ -0,0 +1,187 @@
+package solver
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A VariantBuilder builds the extra constraints for a puzzle variant from the puzzle's block dimensions.
+type VariantBuilder func(blockXDim int, blockYDim int) ([]Constraint, error)
+
+// The registered puzzle variants, by name.
+var variants = map[string]VariantBuilder{
+	"standard": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return nil, nil
+	},
+	"hyper": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		windows, err := hyperWindows(blockXDim, blockYDim)
+		if err != nil {
+			return nil, err
+		}
+		return []Constraint{UniqueConstraint{"window", windows}}, nil
+	},
+	"diagonal": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return []Constraint{UniqueConstraint{"diagonal", diagonals(blockXDim * blockYDim)}}, nil
+	},
+	"anti-knight": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return []Constraint{antiKnightConstraint(blockXDim * blockYDim)}, nil
+	},
+	"anti-king": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return []Constraint{antiKingConstraint(blockXDim * blockYDim)}, nil
+	},
+	"non-consecutive": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return []Constraint{nonConsecutiveConstraint(blockXDim * blockYDim)}, nil
+	},
+	// Latin squares only have rows and columns, so this adds nothing and HasBlocks drops the blocks
+	"latin": func(blockXDim int, blockYDim int) ([]Constraint, error) {
+		return nil, nil
+	},
+}
+
+// Registers a puzzle variant so it can be selected by name. This is how custom constraints (anti-knight,
+// non-consecutive and so on) are plugged in without changing the annealer.
+func RegisterVariant(name string, build VariantBuilder) {
+	variants[name] = build
+}
+
+// Returns the extra constraints of the named puzzle variant.
+func VariantConstraints(variant string, blockXDim int, blockYDim int) (constraints []Constraint, e error) {
+
+	build, found := variants[variant]
+	if !found {
+		return nil, fmt.Errorf("unknown puzzle variant %q (expected one of %s)", variant, VariantNames())
+	}
+
+	return build(blockXDim, blockYDim)
+}
+
+// Reports whether puzzles of the named variant are divided into blocks. Only Latin squares aren't.
+func HasBlocks(variant string) bool {
+	return variant != "latin"
+}
+
+// Returns the names of all registered variants, sorted and comma separated for use in messages.
+func VariantNames() string {
+
+	names := make([]string, 0, len(variants))
+	for name := range variants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return strings.Join(names, ", ")
+}
+
+// Returns the cells of block number b (counting left to right, top to bottom). Blocks are blockXDim
+// columns wide and blockYDim rows tall, matching the layout drawn by printPuzzle.
+func BlockCells(b int, blockXDim int, blockYDim int) (cells []Cell) {
+
+	puzzleDim := blockXDim * blockYDim
+	blocksPerRow := puzzleDim / blockXDim
+
+	rowOffset := (b / blocksPerRow) * blockYDim
+	colOffset := (b % blocksPerRow) * blockXDim
+
+	cells = make([]Cell, 0, puzzleDim)
+	for k := 0; k < puzzleDim; k++ {
+		cells = append(cells, Cell{rowOffset + k/blockXDim, colOffset + k%blockXDim})
+	}
+
+	return cells
+}
+
+// Returns a region map for a puzzle made of rectangular blocks, giving the block number of every cell.
+func BlockRegionMap(blockXDim int, blockYDim int) (regionMap [][]int) {
+
+	puzzleDim := blockXDim * blockYDim
+
+	regionMap = make([][]int, puzzleDim)
+	for i := range regionMap {
+		regionMap[i] = make([]int, puzzleDim)
+	}
+
+	for b := 0; b < puzzleDim; b++ {
+		for _, cell := range BlockCells(b, blockXDim, blockYDim) {
+			regionMap[cell.Row][cell.Col] = b
+		}
+	}
+
+	return regionMap
+}
+
+// Converts a region map into the list of cells making up each region, in region number order. Cells in
+// negative regions don't belong to any region.
+func RegionsFromMap(regionMap [][]int) (regions [][]Cell) {
+
+	for r := range regionMap {
+		for c, region := range regionMap[r] {
+			if region < 0 {
+				continue
+			}
+			for len(regions) <= region {
+				regions = append(regions, nil)
+			}
+			regions[region] = append(regions[region], Cell{r, c})
+		}
+	}
+
+	return regions
+}
+
+// Returns the shaded windows of a hyper-sudoku (also known as windoku). For a standard 9x9 puzzle
+// these are the four 3x3 windows whose top left corners sit at rows and columns 2 and 6, each one
+// set in from the blocks by a single cell. Only square blocks can have windows.
+func hyperWindows(blockXDim int, blockYDim int) (windows [][]Cell, e error) {
+
+	if blockXDim != blockYDim {
+		return nil, fmt.Errorf("hyper-sudoku needs square blocks, not %dx%d", blockXDim, blockYDim)
+	}
+
+	blockDim := blockXDim
+
+	for i := 0; i < blockDim-1; i++ {
+		for j := 0; j < blockDim-1; j++ {
+
+			rowOffset := 1 + i*(blockDim+1)
+			colOffset := 1 + j*(blockDim+1)
+
+			window := make([]Cell, 0, blockDim*blockDim)
+			for k := 0; k < blockDim*blockDim; k++ {
+				window = append(window, Cell{rowOffset + k/blockDim, colOffset + k%blockDim})
+			}
+
+			windows = append(windows, window)
+		}
+	}
+
+	return windows, nil
+}
+
+// Returns the two main diagonals of a puzzle of the given dimension, as used by X-sudoku.
+func diagonals(puzzleDim int) [][]Cell {
+
+	leading := make([]Cell, puzzleDim)
+	trailing := make([]Cell, puzzleDim)
+
+	for i := 0; i < puzzleDim; i++ {
+		leading[i] = Cell{i, i}
+		trailing[i] = Cell{i, puzzleDim - 1 - i}
+	}
+
+	return [][]Cell{leading, trailing}
+}
+
+// Reports whether the cell at row r and column c belongs to one of the regions.
+func InRegions(regions [][]Cell, r int, c int) bool {
+
+	for _, region := range regions {
+		for _, cell := range region {
+			if cell.Row == r && cell.Col == c {
+				return true
+			}
+		}
+	}
+
+	return false
+}
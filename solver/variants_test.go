@@ -0,0 +1,61 @@
+package solver_test
+
+import (
+	"testing"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A custom rule, as a program embedding the solver would write it: the four corners hold different numbers.
+type cornersConstraint struct {
+	puzzleDim int
+}
+
+func (c cornersConstraint) corners() []solver.Cell {
+	last := c.puzzleDim - 1
+	return []solver.Cell{{Row: 0, Col: 0}, {Row: 0, Col: last}, {Row: last, Col: 0}, {Row: last, Col: last}}
+}
+
+// The cost is the number of pairs of corners holding the same number.
+func (c cornersConstraint) Cost(p solver.Puzzle) (cost float64) {
+
+	corners := c.corners()
+	for i := range corners {
+		for _, other := range corners[i+1:] {
+			if p[corners[i].Row][corners[i].Col] == p[other.Row][other.Col] {
+				cost++
+			}
+		}
+	}
+
+	return cost
+}
+
+func (c cornersConstraint) Regions() [][]solver.Cell {
+	return [][]solver.Cell{c.corners()}
+}
+
+func TestRegisterVariantWithACustomConstraint(t *testing.T) {
+
+	solver.RegisterVariant("corners", func(blockXDim int, blockYDim int) ([]solver.Constraint, error) {
+		return []solver.Constraint{cornersConstraint{blockXDim * blockYDim}}, nil
+	})
+
+	variant, err := solver.VariantConstraints("corners", 2, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	puzzle := [][]int{{1, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}, {0, 0, 0, 0}}
+	constraints := solver.PuzzleConstraints(4, solver.BlockRegionMap(2, 2), variant, nil)
+
+	solution, solved, _, err := solver.Solve(puzzle, constraints, solver.Options{Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !solved {
+		t.Fatal("the puzzle of the custom variant wasn't solved")
+	}
+	if cost := (cornersConstraint{4}).Cost(solution); cost != 0 {
+		t.Errorf("the solution %v repeats a number in its corners", solution)
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// How long the result of a solve started with POST /solves is kept for after it finishes.
+const solveJobExpiry = 10 * time.Minute
+
+// A progress event streamed from an in-flight solve: the state after a cooling step, with the candidate
+// solution of lowest cost in the one-line format.
+type progressEvent struct {
+	Step           int     `json:"step"`
+	Temperature    float64 `json:"temperature"`
+	BestCost       float64 `json:"best_cost"`
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	Elapsed        float64 `json:"elapsed_seconds"`
+	Candidate      string  `json:"candidate"`
+}
+
+// The answer to POST /solves: the id of the solve started, and where its progress is streamed.
+type solveStarted struct {
+	ID     string `json:"id"`
+	Events string `json:"events"`
+}
+
+// The answer to GET /solves/ID while the solve is still running, with its latest progress (none before
+// its first cooling step).
+type solveRunning struct {
+	Running  bool           `json:"running"`
+	Progress *progressEvent `json:"progress"`
+}
+
+// A solve running in the background, started with the named API token (empty when the server has none).
+// Listeners wait on changed, which is closed (and replaced) whenever the latest progress or the result
+// changes, and so only ever see the latest step rather than falling behind a fast solve.
+type solveJob struct {
+	token    string
+	mutex    sync.Mutex
+	progress *progressEvent
+	result   *solveResponse
+	changed  chan struct{}
+}
+
+// Changes the job and wakes everything waiting on it.
+func (j *solveJob) update(change func()) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	change()
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+// Returns the latest progress and the result (nil until the solve finishes), and a channel that is closed
+// when either changes.
+func (j *solveJob) snapshot() (*progressEvent, *solveResponse, chan struct{}) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.progress, j.result, j.changed
+}
+
+// Returns a new random job id.
+func newJobID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// The error returned when a solve is posted to /solves while the most it runs at once are running.
+var errSolvesFull = errors.New("too many solves are running in the background, so try again later")
+
+// Starts solving the puzzle posted with the named token in the background and returns the id of its job,
+// or errSolvesFull when there is no room for another. The client's solve is released from the server's
+// concurrency cap once it finishes (or straight away when it can't start).
+func (s *solveServer) startJob(p serverPuzzle, token string, client string) (string, error) {
+
+	select {
+	case s.solveSlots <- struct{}{}:
+	default:
+		s.concurrency.release(client)
+		return "", errSolvesFull
+	}
+
+	job := &solveJob{token: token, changed: make(chan struct{})}
+	id := newJobID()
+
+	s.jobsMutex.Lock()
+	s.jobs[id] = job
+	s.jobsMutex.Unlock()
+
+	go func() {
+		writer := puzzleWriter{symbols: p.symbols, emptyValue: "."}
+		response := s.solve(p, func(progress solver.AnnealProgress) {
+			event := progressEvent{progress.Step, progress.Temperature, progress.BestCost, progress.AcceptanceRate, progress.Elapsed.Seconds(), writer.oneLine(progress.Candidate)}
+			job.update(func() { job.progress = &event })
+		}, nil)
+		job.update(func() { job.result = &response })
+		<-s.solveSlots
+		s.concurrency.release(client)
+
+		time.AfterFunc(solveJobExpiry, func() {
+			s.jobsMutex.Lock()
+			delete(s.jobs, id)
+			s.jobsMutex.Unlock()
+		})
+	}()
+
+	return id, nil
+}
+
+// Handles POST /solves, which starts solving the puzzle in the JSON request body in the background and
+// returns its id, and GET /solves/ID and /solves/ID/events, which return the result of a solve (once it
+// has one) and stream its progress. When the server has API tokens, a solve is only found by the token it
+// was started with.
+func (s *solveServer) handleSolves(w http.ResponseWriter, r *http.Request) {
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/solves"), "/")
+
+	if path == "" {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeJSONError(w, http.StatusMethodNotAllowed, errors.New("solves are started by POSTing a puzzle"))
+			return
+		}
+		_, p, ok := s.readRequestBody(w, r)
+		if !ok {
+			return
+		}
+		client, ok := s.startSolving(w, r)
+		if !ok {
+			return
+		}
+		id, err := s.startJob(p, s.requestToken(r).name(), client)
+		if err != nil {
+			w.Header().Set("Retry-After", "60")
+			writeJSONError(w, http.StatusServiceUnavailable, err)
+			return
+		}
+		w.Header().Set("Location", "/solves/"+id)
+		writeJSON(w, http.StatusAccepted, solveStarted{id, "/solves/" + id + "/events"})
+		return
+	}
+
+	id, events := path, false
+	if strings.HasSuffix(path, "/events") {
+		id, events = strings.TrimSuffix(path, "/events"), true
+	}
+
+	s.jobsMutex.Lock()
+	job, found := s.jobs[id]
+	s.jobsMutex.Unlock()
+	if !found || job.token != s.requestToken(r).name() {
+		writeJSONError(w, http.StatusNotFound, fmt.Errorf("there is no solve %q (results are kept for %s)", id, solveJobExpiry))
+		return
+	}
+
+	if events {
+		streamJob(w, r, job)
+		return
+	}
+
+	progress, result, _ := job.snapshot()
+	if result != nil {
+		writeJSON(w, http.StatusOK, result)
+		return
+	}
+	writeJSON(w, http.StatusOK, solveRunning{true, progress})
+}
+
+// Streams a job's progress as server-sent events: a progress event for each cooling step the stream
+// keeps up with, then a result event when the solve finishes, after which the stream ends.
+func streamJob(w http.ResponseWriter, r *http.Request, job *solveJob) {
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSONError(w, http.StatusInternalServerError, errors.New("the server can't stream events"))
+		return
+	}
+
+	// The stream lasts as long as the solve, which may be longer than the server's write timeout allows
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	send := func(event string, value interface{}) {
+		data, _ := json.Marshal(value)
+		fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+		flusher.Flush()
+	}
+
+	lastStep := 0
+	for {
+		progress, result, changed := job.snapshot()
+
+		if progress != nil && progress.Step != lastStep {
+			send("progress", progress)
+			lastStep = progress.Step
+		}
+		if result != nil {
+			send("result", result)
+			return
+		}
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
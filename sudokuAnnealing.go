@@ -25,23 +25,27 @@ package main
 
 import (
 	"bufio"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"math"
-	"math/rand"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
 )
 
 // Modified from https://stackoverflow.com/questions/9862443/golang-is-there-a-better-way-read-a-file-of-integers-into-an-array
 // Read in the start state of the sudoku puzzle (of arbitrary dimension) in a single line presentation.
-func readInOneLine(r io.Reader, line int, delimiter string, emptyValue string, blockXDim int, blockYDim int) (puzzle [][]int, e error) {
+// Squares are read as numbers, or as symbols when a symbol set is given (see puzzleSymbols). Returns an
+// error if the line is missing, has the wrong number of squares, or holds anything other than the
+// numbers 1 to N and the empty value (or 0).
+func readInOneLine(r io.Reader, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, e error) {
 
 	scanner := bufio.NewScanner(r)
-	scanner.Split(bufio.ScanLines)
+	scanner.Split(scanPuzzleLines)
 
 	// Start puzzle at line 1 (more user friendly)
 	lineCounter := 1
@@ -53,20 +57,31 @@ func readInOneLine(r io.Reader, line int, delimiter string, emptyValue string, b
 		if line == lineCounter {
 
 			// Read the puzzle text in and split it into it's components
-			puzzleText := scanner.Text()
+			puzzleText := strings.TrimSpace(scanner.Text())
 			puzzleElements := strings.Split(puzzleText, delimiter)
 			puzzleDim := blockXDim * blockYDim
+
+			if len(puzzleElements) != puzzleDim*puzzleDim {
+				return nil, puzzleErrorf("line %d has %d squares but a puzzle with %dx%d blocks needs %d", line, len(puzzleElements), blockXDim, blockYDim, puzzleDim*puzzleDim)
+			}
+
 			puzzle = make([][]int, puzzleDim)
 
 			for i := 0; i < puzzleDim; i++ {
 				puzzle[i] = make([]int, puzzleDim)
 				for j := 0; j < puzzleDim; j++ {
 					element := puzzleElements[(i*puzzleDim)+j]
-					value, err := strconv.Atoi(element)
-					if err == nil {
+					if value, found := symbolValue(symbols, element); symbols != "" && found {
 						puzzle[i][j] = value
-					} else if element == emptyValue {
+					} else if value, err := strconv.Atoi(element); symbols == "" && err == nil {
+						if value < 0 || value > puzzleDim {
+							return nil, puzzleErrorf("line %d, row %d, column %d: %d is out of range, numbers must be from 1 to %d", line, i+1, j+1, value, puzzleDim)
+						}
+						puzzle[i][j] = value
+					} else if element == emptyValue || element == "0" {
 						puzzle[i][j] = 0
+					} else {
+						return nil, puzzleErrorf("line %d, row %d, column %d: %q is not a number from 1 to %d or the empty value %q", line, i+1, j+1, element, puzzleDim, emptyValue)
 					}
 				}
 			}
@@ -75,7 +90,15 @@ func readInOneLine(r io.Reader, line int, delimiter string, emptyValue string, b
 		lineCounter++
 	}
 
-	return puzzle, scanner.Err()
+	if e = inputError(scanner.Err()); e != nil {
+		return nil, e
+	}
+
+	if puzzle == nil {
+		return nil, puzzleErrorf("there is no line %d in the input, which has %d lines", line, lineCounter-1)
+	}
+
+	return puzzle, nil
 }
 
 // return the number of digits in an int up to 4. Sudoku puzzles of greater than
@@ -98,394 +121,772 @@ func numDigits(n int) int {
 }
 
 
-func printPuzzle(puzzle [][]int, blockXDim int, blockYDim int) {
+// Prints the puzzle in a frame with lines drawn along the borders between its regions, which for a
+// standard puzzle are its blocks. Cells that belong to one of the extra regions of a puzzle variant are
+// marked with a trailing *. Numbers are shown as symbols when a symbol set is given. If decorate is not
+// nil it colours the text of each filled cell (see cellColours).
+func printPuzzle(puzzle [][]int, regionMap [][]int, extraRegions [][]solver.Cell, symbols string, decorate func(r int, c int, text string) string) {
+	fmt.Print(formatPuzzle(puzzle, regionMap, extraRegions, symbols, decorate))
+}
+
+// Lays the puzzle out as printPuzzle does, using Unicode box-drawing characters. Squares outside of the
+// puzzle, such as the blocked squares of a samurai sudoku, are left outside of the frame. If decorate is
+// not nil, the text of every cell (blank for empty cells) is passed through it after the layout is worked
+// out, so it can be wrapped in terminal colours without upsetting the alignment.
+func formatPuzzle(puzzle [][]int, regionMap [][]int, extraRegions [][]solver.Cell, symbols string, decorate func(r int, c int, text string) string) string {
 
-	width := numDigits(blockXDim * blockYDim)
+	var b strings.Builder
 
-	for r := range puzzle {
-		if r > 0 && r % blockYDim == 0 {
-			for i := 0; i < (blockXDim - 1) + (width + 2) * blockXDim * blockYDim; i++ {
-				fmt.Printf("%s", "-")
+	puzzleDim := len(puzzle)
+	width := numDigits(puzzleDim)
+	if symbols != "" {
+		width = 1
+	}
+
+	// The region of a square, where squares beyond the edges and blocked squares are all in region -1
+	regionAt := func(r int, c int) int {
+		if r < 0 || c < 0 || r >= puzzleDim || c >= puzzleDim || puzzle[r][c] == solver.BlockedSquare {
+			return -1
+		}
+		if regionMap == nil {
+			return 0
+		}
+		return regionMap[r][c]
+	}
+
+	// Only leave a gap for a border between two columns or rows if a border runs between them somewhere
+	columnBorders := make([]bool, puzzleDim+1)
+	rowBorders := make([]bool, puzzleDim+1)
+	for r := 0; r <= puzzleDim; r++ {
+		for c := 0; c <= puzzleDim; c++ {
+			if regionAt(r, c) != regionAt(r, c-1) {
+				columnBorders[c] = true
 			}
-			fmt.Println()
+			if regionAt(r, c) != regionAt(r-1, c) {
+				rowBorders[r] = true
+			}
+		}
+	}
+
+	for r := 0; r <= puzzleDim; r++ {
+		if rowBorders[r] {
+			for c := 0; c <= puzzleDim; c++ {
+				if columnBorders[c] {
+					// Join up the lines meeting at this corner
+					up := regionAt(r-1, c-1) != regionAt(r-1, c)
+					down := regionAt(r, c-1) != regionAt(r, c)
+					left := regionAt(r-1, c-1) != regionAt(r, c-1)
+					right := regionAt(r-1, c) != regionAt(r, c)
+					b.WriteString(boxCorner(up, down, left, right))
+				}
+				if c == puzzleDim {
+					break
+				}
+				if regionAt(r, c) != regionAt(r-1, c) {
+					b.WriteString(strings.Repeat("─", width + 2))
+				} else {
+					b.WriteString(strings.Repeat(" ", width + 2))
+				}
+			}
+			b.WriteString("\n")
 		}
-		for c := range puzzle[r] {
-			if c > 0 && c % blockXDim == 0 {
-				fmt.Printf("|")
+		if r == puzzleDim {
+			break
+		}
+		for c := 0; c <= puzzleDim; c++ {
+			if columnBorders[c] {
+				if regionAt(r, c) != regionAt(r, c-1) {
+					b.WriteString("│")
+				} else {
+					b.WriteString(" ")
+				}
+			}
+			if c == puzzleDim {
+				break
+			}
+			marker := " "
+			if solver.InRegions(extraRegions, r, c) {
+				marker = "*"
 			}
 			if puzzle[r][c] > 0 {
-				fmt.Printf("%-*s%d ", width - numDigits(puzzle[r][c]) + 1, " ", puzzle[r][c])
+				text := solver.SymbolText(symbols, puzzle[r][c])
+				padding := width - len(text) + 1
+				if decorate != nil {
+					text = decorate(r, c, text)
+				}
+				fmt.Fprintf(&b, "%-*s%s%s", padding, " ", text, marker)
+			} else if decorate != nil && puzzle[r][c] == 0 {
+				fmt.Fprintf(&b, " %s%s", decorate(r, c, strings.Repeat(" ", width)), marker)
 			} else {
-				fmt.Printf("%-*s ", width + 1, " ")
+				fmt.Fprintf(&b, "%-*s%s", width + 1, " ", marker)
 			}
 		}
-		fmt.Printf("\n")
+		b.WriteString("\n")
 	}
+
+	return b.String()
 }
 
-// Starts n annealing goroutines at exponentially increasing temperatures 2^n where n is defined by the
-// concurrentAnnealerCount value passed to the function. Once each annealing goroutine is returned any
-// hotter goroutines with lower costs than their cooler neighbours will trade their candidate solutions
-// with that neighbour.
-func anneal(originalPuzzle [][]int, blockXDim int, blockYDim int, baseTemperature float64, coolingRate float64, internalIterations int, swapCount int, concurrentAnnealerCount int) (solvedPuzzle [][]int, solutionFound bool) {
+// Returns the box-drawing character joining lines that leave a corner in the given directions.
+func boxCorner(up bool, down bool, left bool, right bool) string {
 
-	initialSolution := randomInitialization(originalPuzzle)
+	corners := []rune(" ╶╴─╷┌┐┬╵└┘┴│├┤┼")
 
-	baseTemperature = baseTemperature
-	finalTemperature := 0.00001
-	coolingRate = coolingRate
+	index := 0
+	for i, line := range []bool{right, left, down, up} {
+		if line {
+			index += 1 << uint(i)
+		}
+	}
 
-	// Create a channel for the concurrent annealers of differing temperatures
-	annealerSolution := make(chan [][]int)
-	annealerCost := make(chan float64)
+	return string(corners[index])
+}
 
-	annealerSolutions := make([][][]int, concurrentAnnealerCount)
-	annealerCosts := make([]float64, concurrentAnnealerCount)
 
-	for i := 0; i < concurrentAnnealerCount; i++ {
-		annealerSolutions[i] = copyPuzzle(initialSolution)
-		annealerCosts[i] = costFunction(initialSolution, blockXDim, blockYDim)
-	}
+// Solves a single puzzle, or every puzzle of a dataset in csv mode, and returns ErrNoSolution when
+// annealing couldn't solve it (unless -strict=false).
+func solveCommand(args []string) (e error) {
 
-	// While the cost is not zero and we haven't hit our final temperature
-	for baseTemperature > finalTemperature {
+	start := time.Now()
 
-		for i := 0; i < concurrentAnnealerCount; i++ {
-			go annealerInternalIterator(originalPuzzle, annealerSolutions[i], blockXDim, blockYDim, baseTemperature*math.Pow(2, float64(i)), internalIterations, swapCount, annealerSolution, annealerCost)
-			annealerSolutions[i] = <- annealerSolution
-			annealerCosts[i] = <- annealerCost
-		}
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+
+	inputModePtr := flags.String("m", "one-line", "An input mode used to interpret the input file (one-line, json, killer, consecutive, jigsaw, samurai, csv, ndjson, sdk, sdm or ss). Detected from the file extension for .sdk, .sdm, .ss, .json, .ndjson and .jsonl files")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "puzzles.txt", "The filename to be checked (- reads standard input, as does leaving this out when input is piped in, and http(s) URLs are downloaded)")
+	fetchTimeoutPtr := flags.Duration("fetch-timeout", defaultFetchTimeout, "How long to wait for a puzzle file given as a URL to download")
+	linePtr := flags.Int("l", 1, "The line of the puzzle to be solved (or the grid number in sdk and ss files)")
+	ladderPtr := flags.String("ladder", "geometric", "How the annealers' temperatures are spaced: geometric:RATIO multiplies them by RATIO from one annealer to the next (doubling by default), linear:STEP adds STEP times the base temperature (1 by default), or a comma separated list of temperatures (eg. 0.5,1,3,8) sets them outright, in place of -t and -a, and auto spaces them for an even exchange rate between neighbours, from short pilot runs on each puzzle")
+	addAnnealingFlags(flags)
+	acceptPtr := flags.String("accept", "metropolis", "How annealers decide to accept costlier candidates: metropolis by chance, exp(-increase/temperature), threshold when the cost rises by less than the temperature, deluge when the cost is under a water level of 1000 times the temperature or no higher than before ("+solver.AcceptanceRuleNames()+")")
+	initPtr := flags.String("init", "", "How the empty squares are filled in before annealing: random shuffles the numbers the clues leave out across the whole puzzle, blocks fills each block with the numbers its clues leave out ("+initializationNames()+"). Left out, -algo genetic uses blocks and the others random")
+	algorithmPtr := flags.String("algo", "anneal", "The search to run: anneal runs -a annealers up a temperature ladder that trade candidates, population anneals a -population of candidates at one temperature, cloning the cheap and culling the costly ones as it cools, genetic evolves a -population by crossing over blocks, tabu makes the cheapest swap that isn't -tenure tabu, backtrack searches exhaustively for an exact solution ("+solver.AlgorithmNames()+")")
+	populationPtr := flags.String("population", "50", "The number of candidates -algo population and -algo genetic keep")
+	generationsPtr := flags.String("generations", "10000", "The most generations -algo genetic evolves for")
+	tenurePtr := flags.String("tenure", "5", "The number of moves -algo tabu keeps a number from going back into a square it was swapped out of")
+	diversePtr := flags.Bool("diverse", false, "Start every annealer from a candidate of its own, each filled in by -init independently, rather than all from the same one")
+	progressPtr := flags.Bool("progress", false, "Report the temperature, best cost, acceptance rate and elapsed time on standard error as annealing proceeds")
+	progressFormatPtr := flags.String("progress-format", "text", "The format of the -progress reports (text, or json for one JSON object per line)")
+	progressIntervalPtr := flags.Duration("progress-interval", 0, "The least time between -progress reports (0 reports every cooling step)")
+	noColourPtr := flags.Bool("no-color", false, "Print the puzzles without colours (they are only coloured when printing to a terminal anyway)")
+	renderOutPtr := flags.String("render-out", "", "An .svg or .png file to draw the solution (or final candidate) in, with the clues in bold")
+	plotPtr := flags.String("plot", "", "An .svg or .png file to draw a chart of every annealer's cost against time in")
+	ladderReportPtr := flags.Bool("ladder-report", false, "Print how the temperature ladder worked once annealing finishes: how often each pair of neighbouring annealers exchanged candidates, how long each candidate spent at each temperature, and the ladder drawn in text, for telling whether -a and -t make a useful ladder")
+	ladderSVGPtr := flags.String("ladder-svg", "", "An .svg file to draw the temperature ladder in, with the exchange rate between every pair of neighbouring annealers")
+	tracePtr := flags.String("trace", "", "A CSV file to record the temperature, costs, acceptance rate and exchanges of every annealer at every cooling step in")
+	pprofPtr := flags.String("pprof", "", "An address (eg. :6060) to serve net/http/pprof profiles and expvar counters of the annealers' work on while running")
+	watchPtr := flags.Bool("watch", false, "Redraw the best candidate solution in place in the terminal as annealing proceeds, with clues in bold and clashing numbers in red")
+	outputPtr := flags.String("o", "", "A file that each solution is appended to, on one line in the one-line format of the input unless -output-format says otherwise")
+	flags.StringVar(outputPtr, "output", "", "The same as -o")
+	outputFormatPtr := flags.String("output-format", "one-line", "The format solutions are written to the -o file in ("+outputFormatNames+")")
+	trainingModePtr := flags.Bool("training-mode", false, "Enables a minimal output indicating only if a solution was found and how long that result took in seconds."+
+		" Intended for collecting data to determine the optimal combination of the other flags.")
+	trainingFormatPtr := flags.String("training-format", "csv", "The format of the -training-mode results (csv, or ndjson for one JSON object per line)")
+	trainingHeaderPtr := flags.Bool("training-header", false, "Write a header line naming the columns before the -training-mode CSV results (unless appending to a file that already has them)")
+	resultsPtr := flags.String("results", "", "An SQLite database that every solve attempt is recorded in, for querying with the stats subcommand")
+	trainingOutPtr := flags.String("training-out", "", "A file that the -training-mode results are appended to instead of being printed")
+	modePtr := flags.String("mode", "solve", "How to run: solve puzzles here, or share a batch of them across machines as the coordinator (which hands out the puzzles of the file) or a worker (which anneals them)")
+	addrPtr := flags.String("addr", ":9090", "The address the -mode coordinator listens for workers on")
+	coordinatorPtr := flags.String("coordinator", "", "The address (eg. host:9090) of the coordinator a -mode worker gets its work from")
+	tokensPtr := flags.String("tokens", "", "A JSON file of API tokens (as for serve), one of which a -mode coordinator then needs from every worker in the "+tokenHeader+" header")
+	tokenPtr := flags.String("token", "", "The API token a -mode worker sends its coordinator, when the coordinator was given -tokens")
+	replicasPtr := flags.String("replicas", "1", "The number of workers each puzzle is annealed on at once by a -mode coordinator, each with its own part of the temperature ladder")
+	timeoutPtr := flags.Duration("timeout", time.Hour, "The longest each worker anneals a puzzle for under -mode coordinator, or each puzzle is annealed for in -m ndjson mode")
+	checkpointPtr := flags.String("checkpoint", "", "A file to save the state of the run in every -checkpoint-interval (and when interrupted), for carrying on later with -resume")
+	checkpointIntervalPtr := flags.Duration("checkpoint-interval", time.Minute, "How often to save a -checkpoint")
+	analyzePtr := flags.Bool("analyze", false, "Print how the clues of the puzzle are spread over its rows, columns, blocks and numbers and which symmetries they have before solving it (see the analyze command)")
+	statsPtr := flags.Bool("stats", false, "Print what annealing did once it finishes: the cooling steps, iterations, cost evaluations, candidates each annealer accepted, exchanges, time taken and final temperature")
+	cachePtr := flags.Bool("cache", false, "Remember the solution of every puzzle of a -m csv dataset solved, and answer any puzzle seen again with it instead of annealing")
+	cacheFilePtr := flags.String("cache-file", "", "A file to keep the -cache in, so the solutions are remembered from one run to the next (implies -cache)")
+	strictPtr := flags.Bool("strict", true, "Exit with status 1 when no solution is found. -strict=false exits with 0 as long as the puzzle could be read, as before the exit statuses were added")
+	retryPolicyPtr := flags.String("retry-policy", "none", "How puzzles of a dataset or an ndjson stream that aren't solved are retried: none, or the number of retries, each with escalated parameters, optionally followed by how they escalate, eg. 3:iterations=2,temperature=1.5,annealers=2 (the defaults)")
+	perPuzzleTimeoutPtr := flags.Duration("per-puzzle-timeout", 0, "The longest any one puzzle of a -m csv dataset or an ndjson stream is worked on, retries and all, before it is recorded with the lowest cost it reached and the next is started (0 for no limit). Complements -timeout")
+	solutionsPtr := flags.Int("solutions", 1, "Keep searching until this many distinct solutions of the puzzle are found, each search kept away from those found before it, and report them all")
+	solutionsMissesPtr := flags.Int("solutions-misses", 3, "Give up on -solutions after this many searches in a row that find no new solution")
+	summaryFormatPtr := flags.String("summary-format", "text", "The format of the summary after solving a dataset, an ndjson stream or a distributed batch (text, or json)")
+	quietPtr := flags.Bool("q", false, "Print nothing but the solution, on one line in the format of the input, or nothing at all when no solution is found (the exit status tells which)")
+	seedPtr := flags.Int64("seed", 0, "The seed the random numbers of the solve are drawn with, every annealer's included, for solving the same way again (0 picks one from the time)")
+	recordPtr := flags.String("record", "", "A JSON file to record the solve in, with its seed, flags and puzzle hash and what came of it, for reproducing it exactly with -replay")
+	flags.String("replay", "", "A run recorded with -record to reproduce, with the same seed and flags (any given on the command line override them) on the same puzzle, saying whether the result was the same")
+	resumePtr := flags.String("resume", "", "A -checkpoint file to carry on a run of the same puzzle from, with the annealing parameters it was started with")
+	flags.String("preset", "", "A named bundle of -t, -c, -i, -s and -a tuned for the puzzle size given by -d ("+presetNames()+"). Those flags override it when they are given too")
+	flags.String("config", "", configUsage)
+	addLogFlags(flags)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
 
-		// If a hotter goroutine has a better solution than a colder one then we swap the solutions
-		for i := concurrentAnnealerCount - 1; i > 0; i-- {
-			if annealerCosts[i] < annealerCosts[i-1] {
-				annealerSolutions[i], annealerSolutions[i-1] = annealerSolutions[i-1], annealerSolutions[i]
-				annealerCosts[i], annealerCosts[i-1] = annealerCosts[i-1], annealerCosts[i]
-			}
+	// Failing to find a solution is only a failure of the program when -strict
+	defer func() {
+		if !*strictPtr && errors.Is(e, ErrNoSolution) {
+			e = nil
 		}
-
-		// If the coldest goroutine has cost zero then we have solved the puzzle
-		if annealerCosts[0] == 0 {
-			return annealerSolutions[0], true
+	}()
+
+	// Check every flag up front rather than annealing with a zero that stands in for a typo
+	puzzleLine, lineErr := checkPositiveInt("l", *linePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	params, paramsErr := annealingOptions(flags)
+	baseTemperature, coolingRate, internalIterations, swapCount, adaptiveSwaps, annealerCount := params.temperature, params.coolingRate, params.iterations, params.swaps, params.adaptiveSwaps, params.annealers
+	replicas, replicasErr := parsePositiveInt("replicas", *replicasPtr)
+	population, populationErr := parsePositiveInt("population", *populationPtr)
+	generations, generationsErr := parsePositiveInt("generations", *generationsPtr)
+	tenure, tenureErr := parsePositiveInt("tenure", *tenurePtr)
+	ladder, ladderTemperature, ladderAnnealers, ladderErr := parseLadder(*ladderPtr)
+
+	for _, err := range []error{lineErr, dimErr, paramsErr, replicasErr, populationErr, generationsErr, tenureErr, ladderErr} {
+		if err != nil {
+			return err
 		}
-
-		// Cool all of the goroutines
-		baseTemperature = baseTemperature * coolingRate
 	}
+	// A list of temperatures gives the annealers' temperatures outright
+	if ladderAnnealers > 0 {
+		baseTemperature, annealerCount = ladderTemperature, ladderAnnealers
+	}
+	warnAnnealerCount(annealerCount, ladder)
 
-	return annealerSolutions[0], false
-}
-
-// Gets a neighbouring candidate solution and runs the probibalistic steps of the annealing process as many times as
-// specified by the internalIterations count.
-func annealerInternalIterator(originalPuzzle [][]int, candidateSolution [][]int, blockXDim int, blockYDim int, temperature float64, internalIterations int, swapCount int, as chan [][]int, ac chan float64) {
-
-	// Set updatedSolution and updatedCost to the current values associated with candidateSolution
-	updatedSolution := copyPuzzle(candidateSolution)
-	updatedCost := costFunction(updatedSolution, blockXDim, blockYDim)
-
-	for i := 0; i < internalIterations; i++ {
-		newCandidateSolution := getNeighbour(updatedSolution, swapCount, originalPuzzle)
-		newCandidateCost := costFunction(newCandidateSolution, blockXDim, blockYDim)
+	if *modePtr != "solve" && *modePtr != "coordinator" && *modePtr != "worker" {
+		return flagErrorf("invalid value %q for -mode: must be solve, coordinator or worker", *modePtr)
+	}
+	if *timeoutPtr <= 0 {
+		return flagErrorf("invalid value %q for -timeout: must be greater than 0", timeoutPtr.String())
+	}
+	if *checkpointIntervalPtr <= 0 {
+		return flagErrorf("invalid value %q for -checkpoint-interval: must be greater than 0", checkpointIntervalPtr.String())
+	}
+	if solver.AcceptanceRules[*acceptPtr] == nil {
+		return flagErrorf("unknown acceptance rule %q for -accept (expected one of %s)", *acceptPtr, solver.AcceptanceRuleNames())
+	}
+	if solver.Algorithms[*algorithmPtr] == nil {
+		return flagErrorf("unknown algorithm %q for -algo (expected one of %s)", *algorithmPtr, solver.AlgorithmNames())
+	}
+	if (*checkpointPtr != "" || *resumePtr != "") && *algorithmPtr != "anneal" {
+		return flagErrorf("-checkpoint and -resume only work with -algo anneal")
+	}
+	if (*ladderReportPtr || *ladderSVGPtr != "") && *algorithmPtr != "anneal" {
+		return flagErrorf("-ladder-report and -ladder-svg only work with -algo anneal, the only search with a temperature ladder")
+	}
+	if *ladderSVGPtr != "" && !strings.HasSuffix(strings.ToLower(*ladderSVGPtr), ".svg") {
+		return flagErrorf("invalid value %q for -ladder-svg: the file must end in .svg", *ladderSVGPtr)
+	}
+	if (*cachePtr || *cacheFilePtr != "") && (*modePtr != "solve" || *inputModePtr != "csv") {
+		return flagErrorf("-cache and -cache-file only work when solving a -m csv dataset here")
+	}
+	if (*checkpointPtr != "" || *resumePtr != "") && *modePtr != "solve" {
+		return flagErrorf("-checkpoint and -resume only work when solving one puzzle here, not with -mode %s", *modePtr)
+	}
+	replaying := flags.Lookup("replay").Value.String() != ""
+	if (*recordPtr != "" || replaying) && (*modePtr != "solve" || *resumePtr != "") {
+		return flagErrorf("-record and -replay only work when solving one puzzle here from the start, not with -mode %s or -resume", *modePtr)
+	}
+	if *seedPtr != 0 {
+		randomSeed = *seedPtr
+	}
 
-		// If the cost is zero, then we found a viable solution. exit!
-		if newCandidateCost == 0 {
-			as <- newCandidateSolution
-			ac <- 0
-			return
+	// Workers take everything but the coordinator's address from the work they are handed
+	if *modePtr == "worker" {
+		if *coordinatorPtr == "" {
+			return flagErrorf("-mode worker needs the -coordinator to get work from")
 		}
-
-		// Otherwise, if the cost is less then switch to that solution
-		if newCandidateCost < updatedCost {
-			updatedSolution = newCandidateSolution
-			updatedCost = newCandidateCost
-
-		// And finally switch to a more costly solution randomly based on the acceptance probablity
-		} else {
-			ap := acceptanceProbability(updatedCost, newCandidateCost, temperature)
-
-			if ap > rand.Float64() {
-				updatedSolution = newCandidateSolution
-				updatedCost = newCandidateCost
+		if *pprofPtr != "" {
+			if err := startDebugServer(*pprofPtr); err != nil {
+				return err
 			}
 		}
+		return runWorker(*coordinatorPtr, *tokenPtr)
 	}
 
-	as <- updatedSolution
-	ac <- updatedCost
-	return
-}
-
-// Gets a neighbouring candidate solution to the current one by randomly swapping two numbers in the puzzle.
-// It also ensures that the neighbouring solution created does not modify or swap one of the clues in the
-// original puzzle.
-func getNeighbour(currentPuzzle [][]int, swapCount int, originalPuzzle [][]int) (neighbourPuzzle [][]int) {
-
-	puzzleDim := len(originalPuzzle)
-
-	// Copy the current puzzle into neighbourPuzzle
-	neighbourPuzzle = copyPuzzle(currentPuzzle)
-
-	for i := 0; i < swapCount; i++ {
-		randomXIndex1 := rand.Intn(puzzleDim)
-		randomYIndex1 := rand.Intn(puzzleDim)
-
-		randomXIndex2 := rand.Intn(puzzleDim)
-		randomYIndex2 := rand.Intn(puzzleDim)
+	if err := checkOutputFormat("output-format", *outputFormatPtr); err != nil {
+		return err
+	}
+	if *summaryFormatPtr != "text" && *summaryFormatPtr != "json" {
+		return flagErrorf("unknown summary format %q for -summary-format (expected text or json)", *summaryFormatPtr)
+	}
+	retry, err := parseRetryPolicy(*retryPolicyPtr)
+	if err != nil {
+		return err
+	}
+	if *perPuzzleTimeoutPtr < 0 {
+		return flagErrorf("invalid value %q for -per-puzzle-timeout: must not be negative", perPuzzleTimeoutPtr.String())
+	}
+	retry.budget = *perPuzzleTimeoutPtr
+	if *solutionsPtr < 1 {
+		return flagErrorf("invalid value %d for -solutions: must be at least 1", *solutionsPtr)
+	}
+	if *solutionsMissesPtr < 1 {
+		return flagErrorf("invalid value %d for -solutions-misses: must be at least 1", *solutionsMissesPtr)
+	}
+	if *plotPtr != "" {
+		if err := checkImageFile("plot", *plotPtr); err != nil {
+			return err
+		}
+	}
+	if *renderOutPtr != "" {
+		if err := checkImageFile("render-out", *renderOutPtr); err != nil {
+			return err
+		}
+	}
 
-		// Keep randomly reassigning the index until we get one that wasn't defined in the
-		// original puzzle.
-		for originalPuzzle[randomXIndex1][randomYIndex1] > 0 {
-			randomXIndex1 = rand.Intn(puzzleDim)
-			randomYIndex1 = rand.Intn(puzzleDim)
+	if *pprofPtr != "" {
+		if err := startDebugServer(*pprofPtr); err != nil {
+			return err
 		}
+	}
 
-		for originalPuzzle[randomXIndex2][randomYIndex2] > 0 {
-			randomXIndex2 = rand.Intn(puzzleDim)
-			randomYIndex2 = rand.Intn(puzzleDim)
+	var progress func(solver.AnnealProgress)
+	if *progressPtr {
+		var err error
+		progress, err = progressReporter(os.Stderr, *progressFormatPtr, *progressIntervalPtr)
+		if err != nil {
+			return err
 		}
+	}
+	progress = solver.CombineProgress(progress, logReporter())
 
-		// Swap the two randomly selected elements
-		neighbourPuzzle[randomXIndex1][randomYIndex1], neighbourPuzzle[randomXIndex2][randomYIndex2] = neighbourPuzzle[randomXIndex2][randomYIndex2], neighbourPuzzle[randomXIndex1][randomYIndex1]
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
 	}
 
-	return neighbourPuzzle
-}
+	// Read piped input when no file was named
+	fileGiven := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "f" {
+			fileGiven = true
+		}
+	})
+	if !fileGiven && stdinIsPipe() {
+		*filePtr = "-"
+	}
 
-// A cost function for the provided sudoku puzzle. The cost is defined as the sum over all rows, columns
-// and blocks of the  absolute difference between the occurances of a number in that row block or column
-// and it's expected occurance of 1. A cost of zero for the whole puzzle indicates that it has been solved.
-func costFunction(puzzle [][]int, blockXDim int, blockYDim int) (cost float64) {
+	// Recognise the formats of other sudoku programs by their file extension
+	modeGiven := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == "m" {
+			modeGiven = true
+		}
+	})
+	if !modeGiven {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+	if retry.budget > 0 && (*modePtr != "solve" || *inputModePtr != "csv" && *inputModePtr != "ndjson") {
+		return flagErrorf("-per-puzzle-timeout only works when solving a -m csv dataset or an ndjson stream here")
+	}
+	if *solutionsPtr > 1 && (*modePtr != "solve" || *inputModePtr == "csv" || *inputModePtr == "ndjson") {
+		return flagErrorf("-solutions only works when solving one puzzle here, not a dataset, a stream or a distributed batch")
+	}
+	if *solutionsPtr > 1 && (*checkpointPtr != "" || *resumePtr != "" || *recordPtr != "" || replaying) {
+		return flagErrorf("-checkpoint, -resume, -record and -replay follow a single search, so they don't work with -solutions")
+	}
 
-	// Figure out the full dimension of the puzzle from the passed block dimensions
-	puzzleDim := blockXDim * blockYDim
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
 
-	// Initialize the cost to zero
-	cost = 0.0
+	initialize, err := initialization(*initPtr)
+	if err != nil {
+		return err
+	}
 
-	// For each row and column: (takes advantage of the square nature of the puzzle by swapping the
-	// two iterators)
-	for dim1 := 0; dim1 < puzzleDim; dim1++ {
+	// The options puzzles are solved with, given the annealing parameters (which a resumed run takes from
+	// its checkpoint)
+	searchOptions := func(params annealParams) solver.Options {
+		options := params.options()
+		options.Initialization, options.DiverseStarts, options.Acceptance = initialize, *diversePtr, solver.AcceptanceRules[*acceptPtr]
+		options.Ladder, options.AutoLadder = ladder, *ladderPtr == "auto"
+		options.Algorithm, options.Population, options.Generations, options.TabuTenure = *algorithmPtr, population, generations, tenure
+		return options
+	}
 
-		// Create two slices to track the occurances of each number by row and by column.
-		// Numbers are shifted down by one, so 1 is stored in index 0, 2 in index 1, and so forth.
-		rowCounts := make([]int, puzzleDim, puzzleDim)
-		columnCounts := make([]int, puzzleDim, puzzleDim)
+	inFile, err := openInput(*filePtr, *fetchTimeoutPtr)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
 
-		// For each entry in this row or column
-		for dim2 := 0; dim2 < puzzleDim; dim2++ {
+	var outFile io.Writer
+	if *outputPtr != "" {
+		file, err := openOutput(*outputPtr)
+		if err != nil {
+			return inputError(err)
+		}
+		defer file.Close()
+		outFile = file
+	}
 
-			// rows
-			if puzzle[dim1][dim2] > 0 {
-				number := puzzle[dim1][dim2]
-				rowCounts[number-1]++
+	var training *trainingLog
+	if *trainingModePtr {
+		trainingOut := io.Writer(os.Stdout)
+		header := *trainingHeaderPtr
+		if *trainingOutPtr != "" {
+			file, err := openOutput(*trainingOutPtr)
+			if err != nil {
+				return inputError(err)
 			}
-
-			// columns
-			if puzzle[dim2][dim1] > 0 {
-				number := puzzle[dim2][dim1]
-				columnCounts[number-1]++
+			defer file.Close()
+			// A file that is being appended to already has its header
+			if info, err := file.Stat(); err == nil && info.Size() > 0 {
+				header = false
 			}
+			trainingOut = file
 		}
-
-		// Figure out the cost for this row
-		for _, count := range rowCounts {
-			cost += math.Abs(float64(count - 1))
+		training, err = newTrainingLog(trainingOut, *trainingFormatPtr, header, *inputModePtr == "csv")
+		if err != nil {
+			return err
 		}
+	}
 
-		// And the cost for this column
-		for _, count := range columnCounts {
-			cost += math.Abs(float64(count - 1))
+	var results *resultsStore
+	if *resultsPtr != "" {
+		results, err = openResultsStore(*resultsPtr)
+		if err != nil {
+			return err
 		}
+		defer results.Close()
 	}
 
-	// Also figure out the cost for each block in the puzzle (puzzleDim = number of blocks)
-	horizontalBlockCount := blockYDim
-	verticalBlockCount := blockXDim
-
-	// For each block in the horizontal
-	for i := 0; i < horizontalBlockCount; i++ {
+	solutionWriter := puzzleWriter{format: *outputFormatPtr, blockXDim: blockXDim, blockYDim: blockYDim, symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
 
-		// For each block in the vertical
-		for j := 0; j < verticalBlockCount; j++ {
-
-			// Keep track of the occurances of each number for the given block
-			blockCounts := make([]int, puzzleDim, puzzleDim)
+	// A distributed batch is handed out to the workers puzzle by puzzle
+	if *modePtr == "coordinator" {
+		if *inputModePtr != "one-line" {
+			return flagErrorf("-mode coordinator hands out one-line puzzles, not %s", *inputModePtr)
+		}
+		if *initPtr != "" || *diversePtr || *algorithmPtr != "anneal" || *acceptPtr != "metropolis" || adaptiveSwaps || *ladderPtr != "geometric" || retry.retries > 0 {
+			return flagErrorf("-init, -diverse, -algo, -accept, -ladder, -retry-policy and -s auto only work when solving puzzles here, not with -mode coordinator")
+		}
+		var tokens []apiToken
+		if *tokensPtr != "" {
+			if tokens, err = readAPITokens(*tokensPtr); err != nil {
+				return err
+			}
+		}
+		params := annealParams{baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, adaptiveSwaps}
+		batch := &batchResults{}
+		unsolved, err := runCoordinator(*addrPtr, tokens, inFile, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim, *variantPtr, params, replicas, *timeoutPtr, outFile, solutionWriter, batch)
+		if err != nil {
+			return err
+		}
+		if err := writeBatchSummary(os.Stdout, batch.summary(), *summaryFormatPtr); err != nil {
+			return inputError(err)
+		}
+		if unsolved > 0 {
+			return fmt.Errorf("%w for %d of the puzzles", ErrNoSolution, unsolved)
+		}
+		return nil
+	}
 
-			for k := 0; k < puzzleDim; k++ {
+	// Streams of JSON puzzles are solved line by line, each with a line of JSON for its result
+	if *inputModePtr == "ndjson" {
+		if *checkpointPtr != "" || *resumePtr != "" || *recordPtr != "" || replaying {
+			return flagErrorf("-checkpoint, -resume, -record and -replay only work when solving one puzzle, not a stream")
+		}
+		server := &solveServer{maxTimeout: *timeoutPtr, defaults: annealParams{baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, adaptiveSwaps}}
+		defaults := ndjsonPuzzle{solveRequest: solveRequest{Dim: *dimPtr, Variant: *variantPtr}}
+		batch := &batchResults{}
+		unsolved, err := solveNDJSON(inFile, puzzleLine, defaults, server, searchOptions, retry, os.Stdout, batch)
+		if err != nil {
+			return err
+		}
+		// The summary goes to standard error so that standard output stays one result per line
+		if err := writeBatchSummary(os.Stderr, batch.summary(), *summaryFormatPtr); err != nil {
+			return inputError(err)
+		}
+		if unsolved > 0 {
+			return fmt.Errorf("%w for %d of the puzzles", ErrNoSolution, unsolved)
+		}
+		return nil
+	}
 
-				horizontalIndex := i * blockXDim + k % blockXDim
-				verticalIndex := j * blockYDim + k / blockXDim
+	// Datasets of puzzles with known solutions are solved and checked row by row
+	if *inputModePtr == "csv" {
+		if *checkpointPtr != "" || *resumePtr != "" || *recordPtr != "" || replaying {
+			return flagErrorf("-checkpoint, -resume, -record and -replay only work when solving one puzzle, not a dataset")
+		}
+		var regionMap [][]int
+		if solver.HasBlocks(*variantPtr) {
+			regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+		}
 
-				if puzzle[horizontalIndex][verticalIndex] > 0 {
-					number := puzzle[horizontalIndex][verticalIndex]
-					blockCounts[number-1]++
-				}
+		var cache *solutionCache
+		if *cachePtr || *cacheFilePtr != "" {
+			cache, err = openSolutionCache(*cacheFilePtr)
+			if err != nil {
+				return err
 			}
+			defer cache.Close()
+		}
 
-			// The cost for this block
-			for _, count := range blockCounts {
-				cost += math.Abs(float64(count - 1))
+		batch := &batchResults{}
+		mismatches := solveDataset(inFile, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim, regionMap, variant, searchOptions(annealParams{baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, adaptiveSwaps}), retry, cache, training, results, outFile, solutionWriter, progress, batch)
+		if training == nil {
+			if err := writeBatchSummary(os.Stdout, batch.summary(), *summaryFormatPtr); err != nil {
+				return inputError(err)
 			}
 		}
+		if mismatches > 0 {
+			return fmt.Errorf("%w for %d of the dataset's puzzles", ErrNoSolution, mismatches)
+		}
+		return nil
 	}
 
-	return cost
-}
-
-
-func acceptanceProbability(oldCost float64, newCost float64, temperature float64) (probability float64) {
-	return math.Exp((oldCost - newCost) / temperature)
-}
-
-// Randomly sets all blank values in the original puzzle to number within the
-// dimension of the puzzle so the anneaing function has a complete (but incorrect)
-// base to start from. It ensures that the occurances of each number is correct
-// for the puzzle. Eg. for a standard sudoku, there will be 9 of each number.
-func randomInitialization(originalPuzzle [][]int) (initializedPuzzle [][]int) {
-
-	puzzleDim := len(originalPuzzle)
-
-	remainingNumbers := make(map[int]int)
-
-	var emptySpots [][]int
-	emptySpots = make([][]int,0)
-
-	initializedPuzzle = make([][]int, puzzleDim)
+	originalPuzzle, regionMap, cages, extra, err := readPuzzle(inFile, *inputModePtr, puzzleLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
 
-	// Set all of the remainingNumbers to the maximum possible (puzzleDim)
-	for i := 1; i <= puzzleDim; i++ {
-		remainingNumbers[i] = puzzleDim
+	// Puzzles without irregular regions use their rectangular blocks, unless they have none at all
+	if regionMap == nil && solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+	var constraints []solver.Constraint
+	if *inputModePtr == "samurai" {
+		constraints = append(samuraiConstraints(blockXDim), variant...)
+	} else {
+		constraints = solver.PuzzleConstraints(len(originalPuzzle), regionMap, append(variant, extra...), cages)
 	}
+	extraRegions := solver.ConstraintRegions(variant)
 
-	// For each occurance of a number in the originalPuzzle, subtract one from
-	// remainingNumbers and duplicate it in the initializedPuzzle
-	for i := 0; i < puzzleDim; i++ {
-		initializedPuzzle[i] = make([]int, puzzleDim)
-		for j := 0; j < puzzleDim; j++ {
-			if originalPuzzle[i][j] > 0 {
-				remainingNumbers[originalPuzzle[i][j]]--
-				initializedPuzzle[i][j] = originalPuzzle[i][j]
+	// A replay must be of the puzzle that was recorded, for all its flags say where to read it from
+	var recorded runRecord
+	if replaying {
+		if recorded, err = readRunRecord(flags.Lookup("replay").Value.String()); err != nil {
+			return err
+		}
+		if hash := solver.PuzzleHash(originalPuzzle); hash != recorded.PuzzleHash {
+			return puzzleErrorf("the puzzle read isn't the one that was recorded (its hash is %s, not %s)", hash, recorded.PuzzleHash)
+		}
+	}
 
-			} else {
-				emptySpots = append(emptySpots, []int{i, j})
-			}
+	// Clues that already break the rules can never be annealed into a solution
+	if conflicts := findClueConflicts(originalPuzzle, constraints); len(conflicts) > 0 {
+		message := "the clues of the puzzle conflict, so it has no solution:"
+		for _, conflict := range conflicts {
+			message += "\n" + conflict.String()
 		}
+		return puzzleErrorf("%s", message)
 	}
 
-	// For every remaining number, randomly assign it to one of the remaining empty spots
-	// then delete that empty spot from the slice
-	for remainingNumber, count := range remainingNumbers {
-		for i := 0; i < count; i++ {
-			spotIndex := rand.Intn(len(emptySpots))
-			spot := emptySpots[spotIndex]
-			initializedPuzzle[spot[0]][spot[1]] = remainingNumber
-			emptySpots[spotIndex] = emptySpots[len(emptySpots)-1]
-			emptySpots = emptySpots[0 : len(emptySpots)-1]
+	// Clues, annealed numbers and clashing numbers are told apart by colour when printing to a terminal
+	colours := func(puzzle [][]int) func(r int, c int, text string) string {
+		if *noColourPtr || !stdoutIsColourTerminal() {
+			return nil
 		}
+		decorate, _ := cellColours(originalPuzzle, puzzle, constraints)
+		return decorate
 	}
 
-	return initializedPuzzle
-}
+	// The puzzles are printed in full unless the output is for a program to read
+	verbose := !*trainingModePtr && !*quietPtr
 
+	if verbose {
+		fmt.Println()
+		fmt.Println("Original Puzzle:")
+		printPuzzle(originalPuzzle, regionMap, extraRegions, symbols, colours(originalPuzzle))
+		fmt.Printf("\nPuzzle cost: %v\n", solver.CostFunction(originalPuzzle, constraints))
+		if *analyzePtr {
+			fmt.Println()
+			analysis := analyzePuzzle(originalPuzzle, solver.RegionsFromMap(regionMap), solver.NumberCount(constraints))
+			analysis.Puzzle = puzzleLine
+			analysis.write(os.Stdout)
+		}
+	}
 
-func copyPuzzle(originalPuzzle [][]int) (copiedPuzzle [][]int) {
+	// Watching the grid converge draws it alongside any other progress reports
+	var watch, trace func(solver.AnnealProgress)
+	if *watchPtr {
+		watch = watchReporter(os.Stdout, originalPuzzle, constraints, regionMap, extraRegions, symbols)
+		fmt.Println()
+	}
+	if *tracePtr != "" {
+		traceFile, err := os.Create(*tracePtr)
+		if err != nil {
+			return inputError(err)
+		}
+		defer traceFile.Close()
+		trace = traceReporter(traceFile)
+	}
+	var plot *convergencePlot
+	var recordPlot func(solver.AnnealProgress)
+	if *plotPtr != "" {
+		plot = &convergencePlot{}
+		recordPlot = plot.record
+	}
+	// A resumed run carries on with the parameters and random numbers it was checkpointed with
+	var resume *solver.SolverState
+	var resumedElapsed time.Duration
+	if *resumePtr != "" {
+		checkpoint, err := readCheckpoint(*resumePtr)
+		if err != nil {
+			return err
+		}
+		if err := checkpoint.Check(originalPuzzle); err != nil {
+			return err
+		}
+		resume, resumedElapsed = &checkpoint, checkpoint.Elapsed
+		coolingRate, internalIterations, swapCount, annealerCount = checkpoint.CoolingRate, checkpoint.Iterations, checkpoint.Swaps, checkpoint.Annealers
+	}
+	// The ladder report is sized once a resumed run has set how many annealers there are
+	var exchanges *ladderReport
+	var recordExchanges func(solver.AnnealProgress)
+	if *ladderReportPtr || *ladderSVGPtr != "" {
+		exchanges = newLadderReport(annealerCount)
+		recordExchanges = exchanges.record
+	}
+	var checkpoint func(solver.SolverState)
+	var stop <-chan struct{}
+	if *checkpointPtr != "" {
+		checkpoint, stop = checkpointReporter(*checkpointPtr, *checkpointIntervalPtr, resumedElapsed)
+	}
 
-	puzzleDim := len(originalPuzzle)
+	counter, steps := stepCounter()
+	progress = solver.CombineProgress(progress, watch, trace, recordPlot, recordExchanges, counter)
 
-	copiedPuzzle = make([][]int, puzzleDim, puzzleDim)
+	options := searchOptions(annealParams{baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, adaptiveSwaps})
+	options.OnCoolingStep, options.OnState, options.Stop, options.Resume = progress, checkpoint, stop, resume
+	// Seeding the random numbers just before solving makes the solve the same every time with the seed
+	options.Seed = randomSeed
+	if resume != nil {
+		options.Seed = resume.Seed
+	}
+	if exchanges != nil {
+		options.OnExchange = exchanges.exchange
+	}
+	// A pool of solutions takes a search for each, the first of them standing for the pool below
+	var solutions [][][]int
+	var solvedPuzzle [][]int
+	var successfullySolved bool
+	var stats solver.Stats
+	if *solutionsPtr > 1 {
+		solutions, solvedPuzzle, stats = solutionPool(originalPuzzle, constraints, options, *solutionsPtr, *solutionsMissesPtr)
+		successfullySolved = len(solutions) > 0
+	} else {
+		solvedPuzzle, successfullySolved, stats = solver.Search(originalPuzzle, constraints, options)
+		if successfullySolved {
+			solutions = [][][]int{solvedPuzzle}
+		}
+	}
 
-	for i := 0; i < puzzleDim; i++ {
-		copiedPuzzle[i] = make([]int, puzzleDim, puzzleDim)
-		copy(copiedPuzzle[i], originalPuzzle[i])
+	select {
+	case <-stop:
+		if !successfullySolved {
+			return fmt.Errorf("annealing was interrupted")
+		}
+	default:
 	}
 
-	return copiedPuzzle
-}
+	if verbose {
+		if successfullySolved && *solutionsPtr > 1 {
+			for i, solution := range solutions {
+				fmt.Println()
+				fmt.Printf("Solution %d of %d:\n", i+1, len(solutions))
+				printPuzzle(solution, regionMap, extraRegions, symbols, colours(solution))
+			}
+			if len(solutions) < *solutionsPtr {
+				fmt.Printf("\nFound %d of the %d distinct solutions asked for before %d searches in a row found no more.\n", len(solutions), *solutionsPtr, *solutionsMissesPtr)
+			}
+		} else if successfullySolved {
+			fmt.Println()
+			fmt.Println("Solved Puzzle:")
+			printPuzzle(solvedPuzzle, regionMap, extraRegions, symbols, colours(solvedPuzzle))
+		} else {
+			fmt.Println()
+			fmt.Print("No viable solution to the puzzle was found.\n\n")
+			fmt.Printf("Final puzzle candidate:\n")
+			printPuzzle(solvedPuzzle, regionMap, extraRegions, symbols, colours(solvedPuzzle))
+			fmt.Println()
+			fmt.Printf("Cost at end: %v\n\n", solver.CostFunction(solvedPuzzle, constraints))
+			writeConflictReport(os.Stdout, solvedPuzzle, constraints, regionMap, extraRegions, symbols, colours(solvedPuzzle))
+			fmt.Println()
+		}
+	}
 
-func main() {
-	// Seed the random number generator for use throughout the program.
-	rand.Seed(time.Now().Unix())
+	if plot != nil {
+		if err := plot.writeFile(*plotPtr); err != nil {
+			return err
+		}
+	}
+	if *ladderSVGPtr != "" {
+		if err := exchanges.writeSVG(*ladderSVGPtr); err != nil {
+			return err
+		}
+	}
 
-	start := time.Now()
+	if *renderOutPtr != "" {
+		if err := renderPuzzleFile(*renderOutPtr, solvedPuzzle, originalPuzzle, regionMap, inequalitySigns(constraints), symbols); err != nil {
+			return err
+		}
+	}
 
-	inputModePtr := flag.String("m", "one-line", "An input mode used to interpret the input file")
-	delimiterPtr := flag.String("del", "", "The delimeter used to separate the puzzle squares in the input")
-	emptyValuePtr := flag.String("e", ".", "The character used to indicate an empty square in the puzzle")
-	dimPtr := flag.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
-	filePtr := flag.String("f", "puzzles.txt", "The filename to be checked")
-	linePtr := flag.String("l", "1", "The line of the puzzle to be solved")
-	temperaturePtr := flag.String("t", "1.0", "The lowest base temperature for the concurrent annealers (temperature increases by 2^i for each goroutine i)")
-	coolingRatePtr := flag.String("c", "0.9", "The rate of cooling for each step in the annealing process (a number greater than 0 and less than 1)")
-	iterationPtr := flag.String("i", "1000", "The number of iterations at each step of the annealing process")
-	swapPtr := flag.String("s", "1", "The number of swaps in each iteration of the anneling process")
-	concurrentAnnealerPtr := flag.String("a", "6", "The number of concurrent annealing goroutines")
-	trainingModePtr := flag.Bool("training-mode", false, "Enables a minimal output indicating only if a solution was found and how long that result took in seconds."+
-		" Intended for collecting data to determine the optimal combination of the other flags.")
+	if outFile != nil && len(solutions) > 1 {
+		for _, solution := range solutions {
+			if err := writeSolution(outFile, solutionWriter, originalPuzzle, solution, true); err != nil {
+				return inputError(err)
+			}
+		}
+	} else if outFile != nil {
+		if err := writeSolution(outFile, solutionWriter, originalPuzzle, solvedPuzzle, successfullySolved); err != nil {
+			return inputError(err)
+		}
+	}
 
-	flag.Parse()
+	elapsed := time.Since(start)
 
-	puzzleLine, _ := strconv.Atoi(*linePtr)
-	puzzleDim := strings.Split(*dimPtr, "x")
-	baseTemperature, _ := strconv.ParseFloat(*temperaturePtr, 64)
-	coolingRate,_ := strconv.ParseFloat(*coolingRatePtr, 64)
-	internalIterations, _ := strconv.Atoi(*iterationPtr)
-	swapCount,_ := strconv.Atoi(*swapPtr)
-	annealerCount, _ := strconv.Atoi(*concurrentAnnealerPtr)
-	blockXDim, _ := strconv.Atoi(puzzleDim[0])
-	blockYDim, _ := strconv.Atoi(puzzleDim[1])
+	// The result is recorded in the results store and in training mode as
+	// puzzleLine, baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, solved, time, hash, cost, iterations, restarts, seed
+	record := trainingRecord{puzzleLine, baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, successfullySolved, elapsed.Seconds(), nil,
+		solver.PuzzleHash(originalPuzzle), solver.CostFunction(solvedPuzzle, constraints), *steps * internalIterations, 0, randomSeed}
 
-	inFile, err := os.Open(*filePtr)
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+	if results != nil {
+		if err := results.write(record); err != nil {
+			return inputError(err)
+		}
 	}
 
-	var originalPuzzle [][]int
-
-	if *inputModePtr == "one-line" {
-		// Read the file into an array
-		originalPuzzle, err = readInOneLine(inFile, puzzleLine, *delimiterPtr, *emptyValuePtr, blockXDim, blockYDim)
-		if err != nil {
-			fmt.Println(err)
-			os.Exit(1)
+	if *recordPtr != "" || replaying {
+		run := newRunRecord(flags, randomSeed)
+		run.PuzzleHash, run.Solved, run.Cost = solver.PuzzleHash(originalPuzzle), successfullySolved, solver.CostFunction(solvedPuzzle, constraints)
+		run.Solution = puzzleWriter{symbols: symbols, emptyValue: "."}.oneLine(solvedPuzzle)
+		if *recordPtr != "" {
+			if err := writeRunRecord(*recordPtr, run); err != nil {
+				return err
+			}
+		}
+		if replaying {
+			recorded.reportReplay(run)
 		}
-	} else {
-		fmt.Println("No appropriate input mode for the puzzle was entered.")
-		os.Exit(1)
 	}
 
-	if !*trainingModePtr {
+	if *statsPtr && verbose {
+		fmt.Println()
+		stats.Print(os.Stdout)
+		fmt.Println()
+	}
+	if *ladderReportPtr && verbose {
+		exchanges.write(os.Stdout)
 		fmt.Println()
-		fmt.Println("Original Puzzle:")
-		printPuzzle(originalPuzzle, blockXDim, blockYDim)
-		fmt.Printf("\nPuzzle cost: %v\n", costFunction(originalPuzzle, blockXDim, blockYDim))
 	}
 
-	solvedPuzzle, successfullySolved := anneal(originalPuzzle, blockXDim, blockYDim, baseTemperature, coolingRate, internalIterations, swapCount, annealerCount)
-
-	if !*trainingModePtr {
-		if successfullySolved {
-			fmt.Println()
-			fmt.Println("Solved Puzzle:")
-			printPuzzle(solvedPuzzle, blockXDim, blockYDim)
-		} else {
-			fmt.Println()
-			fmt.Println("No viable solution to the puzzle was found.\n")
-			fmt.Printf("Final puzzle candidate:\n")
-			printPuzzle(solvedPuzzle, blockXDim, blockYDim)
-			fmt.Println()
-			fmt.Printf("Cost at end: %v\n\n", costFunction(solvedPuzzle, blockXDim, blockYDim))
+	if *trainingModePtr {
+		if err := training.write(record); err != nil {
+			return inputError(err)
 		}
+	} else if *quietPtr {
+		for _, solution := range solutions {
+			fmt.Println(solutionWriter.oneLine(solution))
+		}
+	} else {
+		fmt.Printf("Execution completed in %s \n", elapsed)
 	}
 
-	elapsed := time.Since(start)
-
-	if !*trainingModePtr {
-		fmt.Printf("Execution completed in %s \n", elapsed)
-	} else {
-		// Return a csv line of the form
-		// puzzleLine, baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, solved, time
-		fmt.Printf("%v,%v,%v,%v,%v,%v,%v,%v\n", puzzleLine, baseTemperature, coolingRate, internalIterations, swapCount, annealerCount, successfullySolved, elapsed.Seconds())
+	if !successfullySolved {
+		return ErrNoSolution
 	}
+	return nil
 }
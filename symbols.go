@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// The symbols used for puzzles too big for the digits 1 to 9 when no delimiter separates the squares.
+const alphanumericSymbols = "123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+
+// Returns the symbols to read and print a puzzle with. An explicitly given symbol set is checked and
+// used as is. Otherwise puzzles of up to 9x9, or with delimited squares, use plain numbers (signalled
+// by an empty symbol set) and bigger undelimited puzzles fall back to 1-9 followed by A-Z.
+func puzzleSymbols(symbols string, delimiter string, puzzleDim int) (string, error) {
+
+	if symbols == "" {
+		if delimiter != "" || puzzleDim <= 9 {
+			return "", nil
+		}
+		symbols = alphanumericSymbols
+	}
+
+	if utf8.RuneCountInString(symbols) < puzzleDim {
+		return "", flagErrorf("%d symbols are needed for a %dx%d puzzle, but only %q was given", puzzleDim, puzzleDim, puzzleDim, symbols)
+	}
+
+	symbolRunes := []rune(symbols)[:puzzleDim]
+	seen := make(map[rune]bool)
+	for _, symbol := range symbolRunes {
+		if seen[symbol] {
+			return "", flagErrorf("the symbol %q is used more than once in %q", symbol, symbols)
+		}
+		seen[symbol] = true
+	}
+
+	return string(symbolRunes), nil
+}
+
+// Returns the number represented by a symbol, counting from 1. Letters match regardless of case.
+func symbolValue(symbols string, element string) (value int, found bool) {
+
+	for i, symbol := range []rune(symbols) {
+		if strings.EqualFold(string(symbol), element) {
+			return i + 1, true
+		}
+	}
+
+	return 0, false
+}
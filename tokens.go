@@ -0,0 +1,171 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+)
+
+// The header clients send their API token in.
+const tokenHeader = "X-API-Token"
+
+// An API token, as read from the server's -tokens file. Requests sent with it are counted under its name
+// and may ask for no larger puzzles, no more iterations and annealers and no longer timeouts than its
+// caps, on top of the server's own (a cap of 0 adds none).
+type apiToken struct {
+	Name              string  `json:"name"`
+	Token             string  `json:"token"`
+	MaxDim            int     `json:"max_dim"`
+	MaxIterations     int     `json:"max_iterations"`
+	MaxAnnealers      int     `json:"max_annealers"`
+	MaxTimeoutSeconds float64 `json:"max_timeout_seconds"`
+}
+
+// Reads the API tokens in a JSON file: an array of tokens, each with a name and the token itself, and
+// any caps.
+func readAPITokens(filename string) ([]apiToken, error) {
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, inputError(err)
+	}
+
+	var tokens []apiToken
+	if err := json.Unmarshal(data, &tokens); err != nil {
+		return nil, inputError(fmt.Errorf("%s isn't a file of API tokens: %v", filename, err))
+	}
+
+	names := map[string]bool{}
+	for i, token := range tokens {
+		switch {
+		case token.Name == "" || token.Token == "":
+			return nil, inputError(fmt.Errorf("token %d of %s needs both a name and a token", i+1, filename))
+		case names[token.Name]:
+			return nil, inputError(fmt.Errorf("there is more than one token named %q in %s", token.Name, filename))
+		case token.MaxDim < 0 || token.MaxIterations < 0 || token.MaxAnnealers < 0 || token.MaxTimeoutSeconds < 0:
+			return nil, inputError(fmt.Errorf("the caps of token %q in %s must be 0 or more", token.Name, filename))
+		}
+		names[token.Name] = true
+	}
+	if len(tokens) == 0 {
+		return nil, inputError(fmt.Errorf("there are no tokens in %s", filename))
+	}
+
+	return tokens, nil
+}
+
+// Returns the server's token sent with a request, or nil when it sent none of them.
+func (s *solveServer) requestToken(r *http.Request) *apiToken {
+	return s.findToken(r.Header.Get(tokenHeader))
+}
+
+// Returns the server's token that was sent, or nil when it is none of them.
+func (s *solveServer) findToken(token string) *apiToken {
+
+	sent := []byte(token)
+	if len(sent) == 0 {
+		return nil
+	}
+
+	// Every token is compared, in constant time, so how long it takes gives nothing away about them
+	var found *apiToken
+	for i := range s.tokens {
+		if subtle.ConstantTimeCompare(sent, []byte(s.tokens[i].Token)) == 1 {
+			found = &s.tokens[i]
+		}
+	}
+
+	return found
+}
+
+// Returns the server's token with a name, or nil when it has none with the name.
+func (s *solveServer) namedToken(name string) *apiToken {
+
+	for i := range s.tokens {
+		if s.tokens[i].Name == name {
+			return &s.tokens[i]
+		}
+	}
+
+	return nil
+}
+
+// Returns an error when a puzzle read from a request asks for more than the token's caps allow, and
+// otherwise the puzzle with its timeout cut to the token's. A nil token has no caps.
+func (t *apiToken) capPuzzle(p serverPuzzle) (serverPuzzle, error) {
+
+	if t == nil {
+		return p, nil
+	}
+
+	switch {
+	case t.MaxDim > 0 && len(p.puzzle) > t.MaxDim:
+		return p, fmt.Errorf("invalid dim: token %q solves puzzles no larger than %dx%d", t.Name, t.MaxDim, t.MaxDim)
+	case t.MaxIterations > 0 && p.params.iterations > t.MaxIterations:
+		return p, fmt.Errorf("invalid iterations %d: token %q runs no more than %d at each step", p.params.iterations, t.Name, t.MaxIterations)
+	case t.MaxAnnealers > 0 && p.params.annealers > t.MaxAnnealers:
+		return p, fmt.Errorf("invalid annealers %d: token %q runs no more than %d at once", p.params.annealers, t.Name, t.MaxAnnealers)
+	}
+	p.timeout = t.capTimeout(p.timeout)
+
+	return p, nil
+}
+
+// Returns a timeout cut to the token's. A nil token has no cap.
+func (t *apiToken) capTimeout(timeout time.Duration) time.Duration {
+
+	if t == nil {
+		return timeout
+	}
+	if limit := time.Duration(t.MaxTimeoutSeconds * float64(time.Second)); limit > 0 && limit < timeout {
+		return limit
+	}
+
+	return timeout
+}
+
+// Returns an opaque id of the token for the metrics to count its requests under, so that whoever can read
+// them doesn't learn the names of the server's clients: the start of the SHA-256 hash of its name. The
+// server logs the id of each token as it starts.
+func (t *apiToken) id() string {
+
+	hash := sha256.Sum256([]byte(t.Name))
+
+	return hex.EncodeToString(hash[:6])
+}
+
+// Returns the name of the token, or an empty string for a nil token.
+func (t *apiToken) name() string {
+
+	if t == nil {
+		return ""
+	}
+
+	return t.Name
+}
+
+// Turns away requests that don't send one of the server's tokens, when it has any, with 401
+// Unauthorized, and counts those that do under their token's name.
+func (s *solveServer) authenticate(w http.ResponseWriter, r *http.Request) (*apiToken, bool) {
+
+	if len(s.tokens) == 0 {
+		return nil, true
+	}
+
+	token := s.requestToken(r)
+	if token == nil {
+		s.metrics.unauthorized()
+		w.Header().Set("WWW-Authenticate", `Token header="`+tokenHeader+`"`)
+		writeJSONError(w, http.StatusUnauthorized, errors.New("this server needs one of its API tokens in the "+tokenHeader+" header"))
+		return nil, false
+	}
+	s.metrics.tokenRequest(token.id())
+
+	return token, true
+}
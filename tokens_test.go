@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetricsCountTokensWithoutTheirNames(t *testing.T) {
+
+	server := &solveServer{tokens: []apiToken{{Name: "acme-corp", Token: "s3cret"}}, metrics: newServerMetrics()}
+	metrics := server.limited(server.handleMetrics)
+
+	recorder := httptest.NewRecorder()
+	metrics(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("/metrics without a token was answered with %d, not 401", recorder.Code)
+	}
+
+	request := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	request.Header.Set(tokenHeader, "s3cret")
+	recorder = httptest.NewRecorder()
+	metrics(recorder, request)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("/metrics with a token was answered with %d, not 200", recorder.Code)
+	}
+
+	body := recorder.Body.String()
+	if strings.Contains(body, "acme-corp") {
+		t.Error("the metrics give away the name of a token")
+	}
+	if want := `sudoku_token_requests_total{token="` + server.tokens[0].id() + `"} 1`; !strings.Contains(body, want) {
+		t.Errorf("the metrics don't count the request by the token's id, as %s", want)
+	}
+}
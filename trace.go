@@ -0,0 +1,37 @@
+package main
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns a progress function for anneal that records every annealer at every cooling step as a row of
+// CSV, for plotting how a run converges. The header is written before the first row.
+func traceReporter(w io.Writer) func(solver.AnnealProgress) {
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"step", "replica", "temperature", "best_cost", "current_cost", "acceptance_rate", "exchanges", "elapsed_seconds"})
+
+	formatFloat := func(f float64) string {
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	return func(p solver.AnnealProgress) {
+		for i, replica := range p.Replicas {
+			writer.Write([]string{
+				strconv.Itoa(p.Step),
+				strconv.Itoa(i),
+				formatFloat(replica.Temperature),
+				formatFloat(replica.BestCost),
+				formatFloat(replica.Cost),
+				formatFloat(replica.AcceptanceRate),
+				strconv.Itoa(replica.Exchanges),
+				formatFloat(p.Elapsed.Seconds()),
+			})
+		}
+		writer.Flush()
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The result of solving one puzzle in training mode, recorded for working out the best combination of
+// the annealing parameters.
+type trainingRecord struct {
+	Line               int     `json:"line"`
+	Temperature        float64 `json:"temperature"`
+	CoolingRate        float64 `json:"cooling_rate"`
+	Iterations         int     `json:"iterations"`
+	Swaps              int     `json:"swaps"`
+	Annealers          int     `json:"annealers"`
+	Solved             bool    `json:"solved"`
+	Seconds            float64 `json:"seconds"`
+	Matches            *bool   `json:"matches,omitempty"`
+	PuzzleHash         string  `json:"puzzle_hash"`
+	FinalCost          float64 `json:"final_cost"`
+	IterationsExecuted int     `json:"iterations_executed"`
+	Restarts           int     `json:"restarts"`
+	Seed               int64   `json:"seed"`
+}
+
+// Writes training mode records, either as CSV lines or as one JSON object per line (ndjson). The CSV
+// columns start with the original eight (and the ninth saying whether a dataset's known solution was
+// matched) so that existing scripts keep working, and the header is only written when asked for.
+type trainingLog struct {
+	w       io.Writer
+	format  string
+	header  bool
+	dataset bool
+}
+
+// Returns a training log writing to w in the given format (csv or ndjson). The CSV header is written
+// first when header is set, which is left to the caller so that files appended to only get one.
+func newTrainingLog(w io.Writer, format string, header bool, dataset bool) (*trainingLog, error) {
+
+	switch format {
+	case "csv", "ndjson":
+	default:
+		return nil, flagErrorf("unknown training format %q for -training-format (expected csv or ndjson)", format)
+	}
+
+	log := &trainingLog{w: w, format: format, dataset: dataset}
+
+	if header && format == "csv" {
+		columns := []string{"line", "temperature", "cooling_rate", "iterations", "swaps", "annealers", "solved", "seconds"}
+		if dataset {
+			columns = append(columns, "matches")
+		}
+		columns = append(columns, "puzzle_hash", "final_cost", "iterations_executed", "restarts", "seed")
+		if err := log.writeCSV(columns); err != nil {
+			return nil, inputError(err)
+		}
+	}
+
+	return log, nil
+}
+
+// Writes a row of CSV fields.
+func (l *trainingLog) writeCSV(fields []string) error {
+
+	writer := csv.NewWriter(l.w)
+	writer.Write(fields)
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Writes a single record.
+func (l *trainingLog) write(record trainingRecord) error {
+
+	if l.format == "ndjson" {
+		return json.NewEncoder(l.w).Encode(record)
+	}
+
+	fields := []string{
+		fmt.Sprint(record.Line),
+		fmt.Sprint(record.Temperature),
+		fmt.Sprint(record.CoolingRate),
+		fmt.Sprint(record.Iterations),
+		fmt.Sprint(record.Swaps),
+		fmt.Sprint(record.Annealers),
+		fmt.Sprint(record.Solved),
+		fmt.Sprint(record.Seconds),
+	}
+	if l.dataset {
+		fields = append(fields, fmt.Sprint(record.Matches != nil && *record.Matches))
+	}
+	fields = append(fields, record.PuzzleHash, fmt.Sprint(record.FinalCost), fmt.Sprint(record.IterationsExecuted), fmt.Sprint(record.Restarts), fmt.Sprint(record.Seed))
+
+	return l.writeCSV(fields)
+}
+
+// Returns a progress function counting the cooling steps annealing runs for, and a pointer to the count.
+func stepCounter() (func(solver.AnnealProgress), *int) {
+
+	steps := new(int)
+	return func(progress solver.AnnealProgress) { *steps = progress.Step }, steps
+}
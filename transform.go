@@ -0,0 +1,421 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A symmetry of sudoku, which turns a puzzle into an equivalent one with the same number of solutions.
+// The puzzle is first transposed if transpose is set, then rows[i] is the row that becomes row i and
+// cols[j] the column that becomes column j, and finally every number d becomes digits[d]. Rows may only be
+// reordered within their band of blocks and bands as a whole, and columns within their stack and stacks
+// as a whole, so that blocks stay blocks; puzzles can only be transposed when their blocks are square.
+type transformation struct {
+	transpose bool
+	rows      []int
+	cols      []int
+	digits    []int
+}
+
+// Returns the transformation of a puzzle of the given dimension that changes nothing.
+func identityTransformation(puzzleDim int) transformation {
+
+	t := transformation{rows: make([]int, puzzleDim), cols: make([]int, puzzleDim), digits: make([]int, puzzleDim+1)}
+	for i := 0; i < puzzleDim; i++ {
+		t.rows[i], t.cols[i] = i, i
+	}
+	for d := range t.digits {
+		t.digits[d] = d
+	}
+
+	return t
+}
+
+// Returns a transformation drawn at random from every symmetry of a puzzle with the given blocks.
+func randomTransformation(blockXDim int, blockYDim int, rng *rand.Rand) transformation {
+
+	puzzleDim := blockXDim * blockYDim
+	t := transformation{
+		transpose: blockXDim == blockYDim && rng.Intn(2) == 1,
+		rows:      randomGroupOrder(puzzleDim/blockYDim, blockYDim, rng),
+		cols:      randomGroupOrder(puzzleDim/blockXDim, blockXDim, rng),
+		digits:    append([]int{0}, rng.Perm(puzzleDim)...),
+	}
+	for d := 1; d <= puzzleDim; d++ {
+		t.digits[d]++
+	}
+
+	return t
+}
+
+// Returns the puzzle with the transformation applied, leaving the puzzle itself alone.
+func (t transformation) apply(puzzle [][]int) [][]int {
+
+	source := puzzle
+	if t.transpose {
+		source = transposed(puzzle)
+	}
+
+	transformed := make([][]int, len(puzzle))
+	for r := range transformed {
+		transformed[r] = make([]int, len(puzzle))
+		for c := range transformed[r] {
+			transformed[r][c] = t.digits[source[t.rows[r]][t.cols[c]]]
+		}
+	}
+
+	return transformed
+}
+
+// Returns the puzzle with its rows and columns swapped.
+func transposed(puzzle [][]int) [][]int {
+
+	flipped := make([][]int, len(puzzle))
+	for r := range flipped {
+		flipped[r] = make([]int, len(puzzle))
+		for c := range flipped[r] {
+			flipped[r][c] = puzzle[c][r]
+		}
+	}
+
+	return flipped
+}
+
+// Returns a random order of groupCount groups of groupSize lines (rows or columns), which reorders the
+// groups and the lines within each group but keeps every group's lines together.
+func randomGroupOrder(groupCount int, groupSize int, rng *rand.Rand) []int {
+
+	order := make([]int, 0, groupCount*groupSize)
+	for _, group := range rng.Perm(groupCount) {
+		for _, line := range rng.Perm(groupSize) {
+			order = append(order, group*groupSize+line)
+		}
+	}
+
+	return order
+}
+
+// Returns every order of groupCount groups of groupSize lines that keeps every group's lines together.
+func groupOrders(groupCount int, groupSize int) (orders [][]int) {
+
+	withinGroup := permutations(groupSize)
+
+	// Each group is placed in turn, taking its lines in each of their orders
+	var place func(groups []int, order []int)
+	place = func(groups []int, order []int) {
+		if len(order) == groupCount*groupSize {
+			orders = append(orders, append([]int(nil), order...))
+			return
+		}
+		group := groups[len(order)/groupSize]
+		for _, lines := range withinGroup {
+			next := order
+			for _, line := range lines {
+				next = append(next, group*groupSize+line)
+			}
+			place(groups, next)
+		}
+	}
+
+	for _, groups := range permutations(groupCount) {
+		place(groups, make([]int, 0, groupCount*groupSize))
+	}
+
+	return orders
+}
+
+// Returns every permutation of 0 to n-1.
+func permutations(n int) (perms [][]int) {
+
+	if n == 0 {
+		return [][]int{{}}
+	}
+
+	for _, perm := range permutations(n - 1) {
+		for i := 0; i <= len(perm); i++ {
+			next := make([]int, 0, n)
+			next = append(next, perm[:i]...)
+			next = append(next, n-1)
+			next = append(next, perm[i:]...)
+			perms = append(perms, next)
+		}
+	}
+
+	return perms
+}
+
+// The largest puzzles canonicalForm works on. Every symmetry is tried, and a 9x9 puzzle has over three
+// million of them (not counting relabelling), while a 16x16 one has far too many to try.
+const maxCanonicalDim = 9
+
+// Returns the canonical form of a puzzle with the given blocks: the smallest, read square by square, of
+// every puzzle it can be transformed into, with its numbers relabelled 1, 2, 3... in the order they first
+// appear. Every equivalent puzzle has the same canonical form, so it tells apart puzzles that are really
+// different from the same puzzle scrambled. Empty squares come before every number.
+func canonicalForm(puzzle [][]int, blockXDim int, blockYDim int) ([][]int, error) {
+
+	puzzleDim := len(puzzle)
+	if puzzleDim > maxCanonicalDim {
+		return nil, puzzleErrorf("canonical forms are only worked out for puzzles up to %dx%d, not %dx%d", maxCanonicalDim, maxCanonicalDim, puzzleDim, puzzleDim)
+	}
+
+	grids := [][][]int{puzzle}
+	if blockXDim == blockYDim {
+		grids = append(grids, transposed(puzzle))
+	}
+	rowOrders := groupOrders(puzzleDim/blockYDim, blockYDim)
+	colOrders := groupOrders(puzzleDim/blockXDim, blockXDim)
+
+	best := make([]int, puzzleDim*puzzleDim)
+	candidate := make([]int, puzzleDim*puzzleDim)
+	labels := make([]int, puzzleDim+1)
+	found := false
+
+	for _, grid := range grids {
+		for _, rows := range rowOrders {
+			for _, cols := range colOrders {
+				if relabelledLess(grid, rows, cols, labels, candidate, best, found) {
+					best, candidate = candidate, best
+					found = true
+				}
+			}
+		}
+	}
+
+	canonical := make([][]int, puzzleDim)
+	for r := range canonical {
+		canonical[r] = best[r*puzzleDim : (r+1)*puzzleDim]
+	}
+
+	return canonical, nil
+}
+
+// Writes the squares of the grid with its rows and columns reordered, and its numbers relabelled in the
+// order they first appear, into candidate, and reports whether they come before best (always, when there
+// is no best yet). It stops as soon as the candidate comes after best, so most reorderings are turned down
+// after a few squares.
+func relabelledLess(grid [][]int, rows []int, cols []int, labels []int, candidate []int, best []int, haveBest bool) bool {
+
+	for i := range labels {
+		labels[i] = 0
+	}
+	next := 1
+	less := !haveBest
+	puzzleDim := len(grid)
+
+	for k := range candidate {
+		number := grid[rows[k/puzzleDim]][cols[k%puzzleDim]]
+		if number > 0 {
+			if labels[number] == 0 {
+				labels[number] = next
+				next++
+			}
+			number = labels[number]
+		}
+		candidate[k] = number
+
+		if !less {
+			if number > best[k] {
+				return false
+			}
+			if number < best[k] {
+				less = true
+			}
+		}
+	}
+
+	return less
+}
+
+// Reads a comma separated list of the numbers 1 to n in some order, as given to one of the transform
+// subcommand's flags, and returns it counting from 0. An empty list gives nil.
+func parseOrder(name string, text string, n int) ([]int, error) {
+
+	if text == "" {
+		return nil, nil
+	}
+
+	fields := strings.Split(text, ",")
+	if len(fields) != n {
+		return nil, flagErrorf("invalid value %q for -%s: must list the numbers 1 to %d", text, name, n)
+	}
+
+	order := make([]int, n)
+	seen := make([]bool, n)
+	for i, field := range fields {
+		number, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || number < 1 || number > n || seen[number-1] {
+			return nil, flagErrorf("invalid value %q for -%s: must list the numbers 1 to %d once each", text, name, n)
+		}
+		seen[number-1] = true
+		order[i] = number - 1
+	}
+
+	return order, nil
+}
+
+// Reports whether an order of lines keeps every group of groupSize lines together.
+func keepsGroups(order []int, groupSize int) bool {
+
+	for i, line := range order {
+		if line/groupSize != order[i-i%groupSize]/groupSize {
+			return false
+		}
+	}
+	return true
+}
+
+// The transform subcommand. Reads every puzzle in a file and writes it out again transformed by the
+// symmetries of sudoku: transposed, with its rows and columns reordered within their bands and stacks,
+// with its numbers relabelled, scrambled at random, or in its canonical form. Puzzles that are the same
+// up to these symmetries can be dropped with -unique.
+func transformCommand(args []string) error {
+
+	flags := flag.NewFlagSet("transform", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, sdm, sdk, ss or csv). Detected from the file extension (.csv, .sdk, .sdm or .ss) when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "-", "The file of puzzles to transform (- reads standard input)")
+	formatPtr := flags.String("to", "one-line", "The output format ("+outputFormatNames+")")
+	transposePtr := flags.Bool("transpose", false, "Swap the rows and columns (only for puzzles with square blocks)")
+	rowsPtr := flags.String("rows", "", "The rows in their new order, counting from 1 and separated by commas (eg. 4,5,6,1,2,3,7,8,9). Rows may only move within their band of blocks, or with the whole band")
+	colsPtr := flags.String("cols", "", "The columns in their new order, like -rows. Columns may only move within their stack of blocks, or with the whole stack")
+	relabelPtr := flags.String("relabel", "", "The new number for each of the numbers 1, 2, 3... in turn, separated by commas (eg. 9,8,7,6,5,4,3,2,1)")
+	scramblePtr := flags.Int("scramble", 0, "Write this many copies of each puzzle, each scrambled by a random symmetry, instead of the puzzle itself")
+	seedPtr := flags.Int64("seed", 0, "The seed -scramble draws its symmetries with (0 picks one at random)")
+	canonicalPtr := flags.Bool("canonical", false, "Write each puzzle in its canonical form, which every equivalent puzzle shares (puzzles up to 9x9)")
+	uniquePtr := flags.Bool("unique", false, "Drop every puzzle equivalent to one before it (puzzles up to 9x9)")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	blockXDim, blockYDim, err := parseBlockDim("d", *dimPtr)
+	if err != nil {
+		return err
+	}
+	puzzleDim := blockXDim * blockYDim
+
+	if err := checkOutputFormat("to", *formatPtr); err != nil {
+		return err
+	}
+	if *scramblePtr < 0 {
+		return flagErrorf("invalid value %d for -scramble: must not be negative", *scramblePtr)
+	}
+	if *transposePtr && blockXDim != blockYDim {
+		return flagErrorf("puzzles with %s blocks can't be transposed, since their blocks would change shape", *dimPtr)
+	}
+
+	// The transformation given by the flags, which anything left out doesn't change
+	t := identityTransformation(puzzleDim)
+	t.transpose = *transposePtr
+	rows, rowsErr := parseOrder("rows", *rowsPtr, puzzleDim)
+	cols, colsErr := parseOrder("cols", *colsPtr, puzzleDim)
+	digits, digitsErr := parseOrder("relabel", *relabelPtr, puzzleDim)
+	for _, err := range []error{rowsErr, colsErr, digitsErr} {
+		if err != nil {
+			return err
+		}
+	}
+	if rows != nil {
+		if !keepsGroups(rows, blockYDim) {
+			return flagErrorf("invalid value %q for -rows: rows may only move within their band of blocks, or with the whole band", *rowsPtr)
+		}
+		t.rows = rows
+	}
+	if cols != nil {
+		if !keepsGroups(cols, blockXDim) {
+			return flagErrorf("invalid value %q for -cols: columns may only move within their stack of blocks, or with the whole stack", *colsPtr)
+		}
+		t.cols = cols
+	}
+	for d, number := range digits {
+		t.digits[d+1] = number + 1
+	}
+
+	seed := *seedPtr
+	if seed == 0 {
+		seed = solver.FreshSeed()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, puzzleDim)
+	if err != nil {
+		return err
+	}
+	writer := puzzleWriter{format: *formatPtr, blockXDim: blockXDim, blockYDim: blockYDim, symbols: symbols, delimiter: *delimiterPtr, emptyValue: *emptyValuePtr}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	puzzles, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	defer out.Flush()
+
+	if err := writer.writeHeader(out); err != nil {
+		return inputError(err)
+	}
+
+	seen := make(map[string]bool)
+
+	for _, puzzle := range puzzles {
+
+		if *uniquePtr {
+			canonical, err := canonicalForm(puzzle, blockXDim, blockYDim)
+			if err != nil {
+				return err
+			}
+			key := fmt.Sprint(canonical)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+		}
+
+		transformed := t.apply(puzzle)
+		if *canonicalPtr {
+			if transformed, err = canonicalForm(transformed, blockXDim, blockYDim); err != nil {
+				return err
+			}
+		}
+
+		copies := [][][]int{transformed}
+		if *scramblePtr > 0 {
+			copies = nil
+			for i := 0; i < *scramblePtr; i++ {
+				copies = append(copies, randomTransformation(blockXDim, blockYDim, rng).apply(transformed))
+			}
+		}
+
+		for _, p := range copies {
+			if err := writer.write(out, p); err != nil {
+				return inputError(err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,452 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// One combination of the parameters of the annealing process, as given to the solver with -t, -c, -i,
+// -s and -a. With adaptiveSwaps (-s auto) swaps is only where the annealers start from.
+type annealParams struct {
+	temperature   float64
+	coolingRate   float64
+	iterations    int
+	swaps         int
+	annealers     int
+	adaptiveSwaps bool
+}
+
+// Returns the parameters as the flags that would solve with them.
+func (p annealParams) String() string {
+	swaps := strconv.Itoa(p.swaps)
+	if p.adaptiveSwaps {
+		swaps = "auto"
+	}
+	return fmt.Sprintf("-t %v -c %v -i %d -s %s -a %d", p.temperature, p.coolingRate, p.iterations, swaps, p.annealers)
+}
+
+// The values of each parameter that the tune subcommand searches over.
+type tuneSpace struct {
+	temperatures []float64
+	coolingRates []float64
+	iterations   []int
+	swaps        []int
+	annealers    []int
+}
+
+// Returns every combination of the values of the parameters.
+func (s tuneSpace) grid() (params []annealParams) {
+
+	for _, t := range s.temperatures {
+		for _, c := range s.coolingRates {
+			for _, i := range s.iterations {
+				for _, swaps := range s.swaps {
+					for _, a := range s.annealers {
+						params = append(params, annealParams{t, c, i, swaps, a, false})
+					}
+				}
+			}
+		}
+	}
+
+	return params
+}
+
+// Returns n combinations drawn at random from the ranges the values of the parameters span, so that
+// eg. -t 0.5,4 tries temperatures anywhere between 0.5 and 4.
+func (s tuneSpace) sample(n int, rng *rand.Rand) (params []annealParams) {
+
+	uniformFloat := func(values []float64) float64 {
+		low, high := values[0], values[0]
+		for _, v := range values {
+			low, high = math.Min(low, v), math.Max(high, v)
+		}
+		// Rounded to three significant figures to keep the reported flags short, unless that leaves the range
+		value := low + rng.Float64()*(high-low)
+		if rounded, err := strconv.ParseFloat(fmt.Sprintf("%.3g", value), 64); err == nil && rounded >= low && rounded <= high {
+			value = rounded
+		}
+		return value
+	}
+
+	uniformInt := func(values []int) int {
+		low, high := values[0], values[0]
+		for _, v := range values {
+			if v < low {
+				low = v
+			}
+			if v > high {
+				high = v
+			}
+		}
+		return low + rng.Intn(high-low+1)
+	}
+
+	for len(params) < n {
+		params = append(params, annealParams{uniformFloat(s.temperatures), uniformFloat(s.coolingRates), uniformInt(s.iterations), uniformInt(s.swaps), uniformInt(s.annealers), false})
+	}
+
+	return params
+}
+
+// Parses a comma separated list of values given to a flag, checking each of them with parse.
+func parseFloatList(name string, text string, parse func(name string, text string) (float64, error)) (values []float64, e error) {
+
+	for _, field := range strings.Split(text, ",") {
+		value, err := parse(name, strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// Parses a comma separated list of whole numbers greater than 0 given to a flag.
+func parseIntList(name string, text string) (values []int, e error) {
+
+	for _, field := range strings.Split(text, ",") {
+		value, err := parsePositiveInt(name, strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+// A puzzle of the set being tuned on, with the constraints it is annealed under.
+type tunePuzzle struct {
+	puzzle      [][]int
+	constraints []solver.Constraint
+}
+
+// How one combination of parameters fared over the puzzle set.
+type tuneResult struct {
+	params annealParams
+	runs   int
+	solved int
+	times  []time.Duration
+	rounds int
+}
+
+// Adds the runs of another result with the same parameters to this one.
+func (r *tuneResult) add(other tuneResult) {
+	r.runs += other.runs
+	r.solved += other.solved
+	r.times = append(r.times, other.times...)
+}
+
+// Returns the fraction of runs that found a solution.
+func (r tuneResult) solveRate() float64 {
+	if r.runs == 0 {
+		return 0
+	}
+	return float64(r.solved) / float64(r.runs)
+}
+
+// Returns the median time of every run, whether it found a solution or not.
+func (r tuneResult) medianTime() time.Duration {
+
+	if len(r.times) == 0 {
+		return 0
+	}
+
+	times := append([]time.Duration(nil), r.times...)
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	middle := len(times) / 2
+	if len(times)%2 == 0 {
+		return (times[middle-1] + times[middle]) / 2
+	}
+	return times[middle]
+}
+
+// Reports whether one result is better than another: it has the higher solve rate, or failing that the
+// quicker median time.
+func betterResult(a tuneResult, b tuneResult) bool {
+	if a.solveRate() != b.solveRate() {
+		return a.solveRate() > b.solveRate()
+	}
+	return a.medianTime() < b.medianTime()
+}
+
+// Sorts results from best to worst.
+func rankResults(results []tuneResult) {
+	sort.SliceStable(results, func(i, j int) bool { return betterResult(results[i], results[j]) })
+}
+
+// Anneals every puzzle runs times with every combination of parameters, spreading the runs over the
+// given number of workers, and returns how each combination fared in the order they were given.
+func evaluateParams(params []annealParams, puzzles []tunePuzzle, runs int, workers int) []tuneResult {
+
+	type job struct {
+		config int
+		puzzle tunePuzzle
+	}
+
+	results := make([]tuneResult, len(params))
+	for i := range params {
+		results[i].params = params[i]
+	}
+
+	jobs := make(chan job)
+	var mutex sync.Mutex
+	var wait sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wait.Add(1)
+		go func() {
+			defer wait.Done()
+			for j := range jobs {
+				p := params[j.config]
+				start := time.Now()
+				_, solved, _ := solver.Search(j.puzzle.puzzle, j.puzzle.constraints, p.options())
+				elapsed := time.Since(start)
+
+				mutex.Lock()
+				results[j.config].runs++
+				results[j.config].times = append(results[j.config].times, elapsed)
+				if solved {
+					results[j.config].solved++
+				}
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	for config := range params {
+		for _, puzzle := range puzzles {
+			for run := 0; run < runs; run++ {
+				jobs <- job{config, puzzle}
+			}
+		}
+	}
+	close(jobs)
+	wait.Wait()
+
+	return results
+}
+
+// Searches by successive halving: every combination is annealed runs times on every puzzle, then the
+// better half are annealed twice as many times again, then the better half of those four times as many,
+// and so on until only one is left, so that most of the time goes on the combinations that look
+// promising. Returns every combination ranked by how many rounds it lasted (the winner counting one more
+// than the runner-up) and then by how it fared.
+func successiveHalving(params []annealParams, puzzles []tunePuzzle, runs int, workers int) []tuneResult {
+
+	results := make([]tuneResult, len(params))
+	survivors := make([]int, len(params))
+	for i := range params {
+		results[i].params = params[i]
+		survivors[i] = i
+	}
+
+	for round := 1; ; round++ {
+		fmt.Printf("Round %d: annealing each puzzle %d more time(s) with %d combinations\n", round, runs, len(survivors))
+
+		batch := make([]annealParams, len(survivors))
+		for i, survivor := range survivors {
+			batch[i] = params[survivor]
+		}
+		for i, result := range evaluateParams(batch, puzzles, runs, workers) {
+			results[survivors[i]].add(result)
+			results[survivors[i]].rounds = round
+		}
+
+		sort.SliceStable(survivors, func(i, j int) bool { return betterResult(results[survivors[i]], results[survivors[j]]) })
+		survivors = survivors[:(len(survivors)+1)/2]
+		runs *= 2
+
+		// The last one left has won, and needs no more runs to show it
+		if len(survivors) == 1 {
+			results[survivors[0]].rounds++
+			break
+		}
+	}
+	fmt.Println()
+
+	rankResults(results)
+	sort.SliceStable(results, func(i, j int) bool { return results[i].rounds > results[j].rounds })
+
+	return results
+}
+
+// Writes the best results as a table, with how many rounds each lasted when searching by successive
+// halving.
+func writeTuneResults(results []tuneResult, top int, halving bool) {
+
+	out := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	defer out.Flush()
+
+	rounds := func(result tuneResult) string {
+		if halving {
+			return fmt.Sprintf("%d\t", result.rounds)
+		}
+		return ""
+	}
+
+	header := "rank\tsolved\trate\tmedian time\t\tparameters"
+	if halving {
+		header = "rank\trounds\tsolved\trate\tmedian time\t\tparameters"
+	}
+	fmt.Fprintln(out, header)
+
+	for i, result := range results {
+		if i == top {
+			break
+		}
+		fmt.Fprintf(out, "%d\t%s%d/%d\t%.0f%%\t%s\t\t%v\n", i+1, rounds(result), result.solved, result.runs, 100*result.solveRate(), result.medianTime().Round(time.Millisecond), result.params)
+	}
+}
+
+// The tune subcommand. Searches for the annealing parameters that solve a set of puzzles most often and
+// most quickly, trying every combination of the values given for each parameter (grid search) or
+// combinations drawn at random from the ranges they span (random search), and reports the best of them.
+// Either can be narrowed down by successive halving rather than giving every combination the same runs.
+func tuneCommand(args []string) error {
+
+	flags := flag.NewFlagSet("tune", flag.ContinueOnError)
+	inputModePtr := flags.String("m", "", "The input mode (one-line, sdm, sdk, ss or csv). Detected from the file extension when left out")
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "puzzles.txt", "The file of puzzles to tune on (- reads standard input)")
+	puzzleCountPtr := flags.Int("n", 0, "Tune on only the first n puzzles of the file (0 uses them all)")
+	temperaturesPtr := flags.String("t", "0.5,1,2", "The base temperatures to try, separated by commas")
+	coolingRatesPtr := flags.String("c", "0.8,0.9,0.95", "The cooling rates to try, separated by commas")
+	iterationsPtr := flags.String("i", "500,1000", "The iteration counts to try, separated by commas")
+	swapsPtr := flags.String("s", "1,2", "The swap counts to try, separated by commas")
+	annealersPtr := flags.String("a", strconv.Itoa(solver.DefaultAnnealerCount()), "The annealer counts to try, separated by commas (one per CPU by default, between 4 and 8)")
+	searchPtr := flags.String("search", "grid", "How to choose the combinations to try: grid tries every one, random draws -samples of them from the ranges the values span")
+	samplesPtr := flags.String("samples", "20", "The number of combinations random search tries")
+	seedPtr := flags.Int64("seed", 0, "The seed random search draws combinations with (0 picks one from the time)")
+	runsPtr := flags.String("runs", "3", "The number of times each puzzle is annealed with each combination (in the first round with -halving)")
+	halvingPtr := flags.Bool("halving", false, "Search by successive halving, annealing the better half of the combinations twice as many times again each round until one is left")
+	workersPtr := flags.String("j", strconv.Itoa(runtime.NumCPU()), "The number of puzzles annealed at once")
+	pprofPtr := flags.String("pprof", "", "An address (eg. :6060) to serve net/http/pprof profiles and expvar counters on while running")
+	topPtr := flags.Int("top", 10, "The number of the best combinations to report")
+	flags.String("config", "", configUsage)
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	var space tuneSpace
+	var temperatureErr, coolingRateErr, iterationErr, swapErr, annealerErr error
+	space.temperatures, temperatureErr = parseFloatList("t", *temperaturesPtr, parsePositiveFloat)
+	space.coolingRates, coolingRateErr = parseFloatList("c", *coolingRatesPtr, func(name string, text string) (float64, error) { return parseCoolingRate(text) })
+	space.iterations, iterationErr = parseIntList("i", *iterationsPtr)
+	space.swaps, swapErr = parseIntList("s", *swapsPtr)
+	space.annealers, annealerErr = parseIntList("a", *annealersPtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+	samples, samplesErr := parsePositiveInt("samples", *samplesPtr)
+	runs, runsErr := parsePositiveInt("runs", *runsPtr)
+	workers, workersErr := parsePositiveInt("j", *workersPtr)
+
+	for _, err := range []error{temperatureErr, coolingRateErr, iterationErr, swapErr, annealerErr, dimErr, samplesErr, runsErr, workersErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *pprofPtr != "" {
+		if err := startDebugServer(*pprofPtr); err != nil {
+			return err
+		}
+	}
+
+	var params []annealParams
+	switch *searchPtr {
+	case "grid":
+		params = space.grid()
+	case "random":
+		seed := *seedPtr
+		if seed == 0 {
+			seed = time.Now().UnixNano()
+		}
+		params = space.sample(samples, rand.New(rand.NewSource(seed)))
+	default:
+		return flagErrorf("unknown search %q for -search (expected grid or random)", *searchPtr)
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	if *inputModePtr == "" && strings.ToLower(filepath.Ext(*filePtr)) == ".csv" {
+		*inputModePtr = "csv"
+	} else if *inputModePtr == "" {
+		*inputModePtr = inputModeForFile(*filePtr)
+	}
+
+	inFile, err := openInput(*filePtr, defaultFetchTimeout)
+	if err != nil {
+		return err
+	}
+	defer inFile.Close()
+
+	puzzles, err := readAllPuzzles(inFile, *inputModePtr, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+	if *puzzleCountPtr > 0 && *puzzleCountPtr < len(puzzles) {
+		puzzles = puzzles[:*puzzleCountPtr]
+	}
+	if len(puzzles) == 0 {
+		return puzzleErrorf("there are no puzzles to tune on in %s", *filePtr)
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	// Puzzles that can never be solved would only drag every combination down alike
+	var tunePuzzles []tunePuzzle
+	for n, puzzle := range puzzles {
+		constraints := solver.PuzzleConstraints(len(puzzle), regionMap, variant, nil)
+		if conflicts := findClueConflicts(puzzle, constraints); len(conflicts) > 0 {
+			fmt.Printf("skipping puzzle %d: the clues conflict, so it has no solution (%v)\n", n+1, conflicts[0])
+			continue
+		}
+		tunePuzzles = append(tunePuzzles, tunePuzzle{puzzle, constraints})
+	}
+
+	if *halvingPtr {
+		fmt.Printf("Halving %d combinations on %d puzzles\n\n", len(params), len(tunePuzzles))
+		writeTuneResults(successiveHalving(params, tunePuzzles, runs, workers), *topPtr, true)
+		return nil
+	}
+
+	fmt.Printf("Trying %d combinations on %d puzzles, annealing each puzzle %d time(s) with each\n\n", len(params), len(tunePuzzles), runs)
+
+	results := evaluateParams(params, tunePuzzles, runs, workers)
+	rankResults(results)
+	writeTuneResults(results, *topPtr, false)
+
+	return nil
+}
@@ -0,0 +1,186 @@
+package main
+
+import (
+	"flag"
+	"strconv"
+	"strings"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Parses the command line into the flag set. The flag package prints the problem and the usage itself,
+// and -h and -help return flag.ErrHelp, while arguments left over after the flags are turned away. The
+// flags left off the command line are then set from their SUDOKU_ environment variables, those still
+// unset from the -config file of flag sets that have one, then from the header of the puzzle file given
+// to -f, and finally the annealing flags from the -preset of flag sets with one. Logging is then set up
+// from the -v, -vv and -log-format flags of flag sets with them.
+func parseFlags(flags *flag.FlagSet, args []string) error {
+
+	err := flags.Parse(args)
+	if err != nil && err != flag.ErrHelp {
+		return &FlagError{errUsageShown}
+	}
+	// Every argument is a flag or its value, so one left over is a mistake, like a command after the flags
+	if err == nil && flags.NArg() > 0 {
+		return flagErrorf("unexpected argument %q: only flags may follow the command, which comes first (one of %s)", flags.Arg(0), commandNames())
+	}
+	// A replayed run's flags come before any other settings, so that it runs as it was recorded
+	if err == nil && flags.Lookup("replay") != nil {
+		if err := applyReplay(flags); err != nil {
+			return err
+		}
+	}
+	if err == nil {
+		if err := applyEnvironment(flags); err != nil {
+			return err
+		}
+	}
+	if err == nil && configFile(flags) != "" {
+		if err := applyConfig(flags, configFile(flags)); err != nil {
+			return err
+		}
+	}
+	if err == nil && flags.Lookup("f") != nil {
+		if err := applyHeader(flags); err != nil {
+			return err
+		}
+	}
+	if err == nil && flags.Lookup("preset") != nil {
+		if err := applyPreset(flags); err != nil {
+			return err
+		}
+	}
+	if err == nil && flags.Lookup("log-format") != nil {
+		return configureLogging(flags)
+	}
+
+	return err
+}
+
+// Adds the annealing flags -t, -c, -i, -s and -a to a flag set, with the solver's defaults, for
+// annealingOptions to read. The help of flag sets with a -ladder says that it spaces the temperatures.
+func addAnnealingFlags(flags *flag.FlagSet) {
+
+	spacing := "each twice as hot as the last"
+	if flags.Lookup("ladder") != nil {
+		spacing = "spaced by -ladder, each twice as hot as the last by default"
+	}
+
+	flags.Float64("t", 1.0, "The lowest base temperature for the concurrent annealers (the others are hotter, "+spacing+")")
+	flags.Float64("c", 0.9, "The rate of cooling for each step in the annealing process (a number greater than 0 and less than 1)")
+	flags.Int("i", 1000, "The number of iterations at each step of the annealing process")
+	flags.String("s", "1", "The number of swaps in each iteration of the annealing process, or auto to let each annealer go from up to 4 swaps down to 1 as its acceptance rate falls")
+	flags.Int("a", solver.DefaultAnnealerCount(), "The number of annealers, which all run at once (one per CPU by default, between 4 and 8). They form a temperature ladder, "+spacing+", so more annealers explore more widely but the hottest accept almost any move; far more than the CPUs slows every step")
+}
+
+// Reads the annealing parameters from the flags added by addAnnealingFlags, checking each of them. -s
+// auto adapts the swaps, starting from solver.MaxAdaptiveSwaps.
+func annealingOptions(flags *flag.FlagSet) (params annealParams, e error) {
+
+	value := func(name string) interface{} { return flags.Lookup(name).Value.(flag.Getter).Get() }
+
+	var temperatureErr, coolingRateErr, iterationErr, swapErr, annealerErr error
+	params.temperature, temperatureErr = checkPositiveFloat("t", value("t").(float64))
+	params.coolingRate, coolingRateErr = checkCoolingRate(value("c").(float64))
+	params.iterations, iterationErr = checkPositiveInt("i", value("i").(int))
+	params.swaps, params.adaptiveSwaps = solver.MaxAdaptiveSwaps, true
+	if swaps := value("s").(string); swaps != "auto" {
+		if params.swaps, swapErr = strconv.Atoi(swaps); swapErr != nil || params.swaps < 1 {
+			swapErr = flagErrorf("invalid value %q for -s: must be a whole number greater than 0, or auto", swaps)
+		}
+		params.adaptiveSwaps = false
+	}
+	params.annealers, annealerErr = checkPositiveInt("a", value("a").(int))
+
+	for _, err := range []error{temperatureErr, coolingRateErr, iterationErr, swapErr, annealerErr} {
+		if err != nil {
+			return params, err
+		}
+	}
+
+	return params, nil
+}
+
+// Parses block dimensions given to a flag such as -d, eg. 3x3 or 2x3, into the width and height of a block.
+func parseBlockDim(name string, text string) (blockXDim int, blockYDim int, e error) {
+
+	dims := strings.Split(text, "x")
+	if len(dims) != 2 {
+		return 0, 0, flagErrorf("invalid value %q for -%s: block dimensions are given as WIDTHxHEIGHT, eg. 3x3", text, name)
+	}
+
+	blockXDim, xErr := strconv.Atoi(dims[0])
+	blockYDim, yErr := strconv.Atoi(dims[1])
+	if xErr != nil || yErr != nil || blockXDim < 1 || blockYDim < 1 {
+		return 0, 0, flagErrorf("invalid value %q for -%s: block dimensions must be whole numbers greater than 0, eg. 3x3", text, name)
+	}
+
+	return blockXDim, blockYDim, nil
+}
+
+// Parses the value of a flag that must be a whole number greater than 0, such as a line number or an
+// iteration count.
+func parsePositiveInt(name string, text string) (int, error) {
+
+	value, err := strconv.Atoi(text)
+	if err != nil || value < 1 {
+		return 0, flagErrorf("invalid value %q for -%s: must be a whole number greater than 0", text, name)
+	}
+
+	return value, nil
+}
+
+// Parses the value of a flag that must be a number greater than 0, such as a temperature.
+func parsePositiveFloat(name string, text string) (float64, error) {
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || !(value > 0) {
+		return 0, flagErrorf("invalid value %q for -%s: must be a number greater than 0", text, name)
+	}
+
+	return value, nil
+}
+
+// Parses the cooling rate given to -c, which must lie strictly between 0 and 1 for the annealers to
+// cool down at all.
+func parseCoolingRate(text string) (float64, error) {
+
+	value, err := strconv.ParseFloat(text, 64)
+	if err != nil || !(value > 0 && value < 1) {
+		return 0, flagErrorf("invalid value %q for -c: the cooling rate must be a number greater than 0 and less than 1", text)
+	}
+
+	return value, nil
+}
+
+// Checks the value of a typed flag that must be a whole number greater than 0, such as a line number or
+// an iteration count.
+func checkPositiveInt(name string, value int) (int, error) {
+
+	if value < 1 {
+		return 0, flagErrorf("invalid value %d for -%s: must be a whole number greater than 0", value, name)
+	}
+
+	return value, nil
+}
+
+// Checks the value of a typed flag that must be a number greater than 0, such as a temperature.
+func checkPositiveFloat(name string, value float64) (float64, error) {
+
+	if !(value > 0) {
+		return 0, flagErrorf("invalid value %v for -%s: must be a number greater than 0", value, name)
+	}
+
+	return value, nil
+}
+
+// Checks the cooling rate given to -c, which must lie strictly between 0 and 1 for the annealers to cool
+// down at all.
+func checkCoolingRate(value float64) (float64, error) {
+
+	if !(value > 0 && value < 1) {
+		return 0, flagErrorf("invalid value %v for -c: the cooling rate must be a number greater than 0 and less than 1", value)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,15 @@
+package main
+
+import "github.com/evjrob/sudoku-annealing/solver"
+
+// Returns the extra constraints of the named puzzle variant. An unknown variant or one that doesn't fit the
+// blocks is a flag error.
+func variantConstraints(variant string, blockXDim int, blockYDim int) (constraints []solver.Constraint, e error) {
+
+	constraints, err := solver.VariantConstraints(variant, blockXDim, blockYDim)
+	if err != nil {
+		return nil, flagErrorf("%w", err)
+	}
+
+	return constraints, nil
+}
@@ -0,0 +1,300 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// A number that does not appear exactly once in a region. Kind names the region ("row", "column",
+// "block", "window", ...), index is its 1-based number, and count is how many times number appears in that
+// region (zero when the number is missing altogether).
+type constraintViolation struct {
+	kind   string
+	index  int
+	number int
+	count  int
+}
+
+func (v constraintViolation) String() string {
+	if v.count == 0 {
+		return fmt.Sprintf("%s %d: %d is missing", v.kind, v.index, v.number)
+	}
+	return fmt.Sprintf("%s %d: %d appears %d times", v.kind, v.index, v.number, v.count)
+}
+
+// A clue from the original puzzle that was changed in the grid being verified. Row and column are 1-based.
+type alteredClue struct {
+	row   int
+	col   int
+	clue  int
+	value int
+}
+
+func (a alteredClue) String() string {
+	if a.value == 0 {
+		return fmt.Sprintf("clue %d at row %d, column %d was removed", a.clue, a.row, a.col)
+	}
+	return fmt.Sprintf("clue %d at row %d, column %d was changed to %d", a.clue, a.row, a.col, a.value)
+}
+
+// A broken killer sudoku cage. Index is the 1-based cage number in the order the cages were given.
+type cageViolation struct {
+	index int
+	sum   int
+	cage  solver.Cage
+}
+
+func (v cageViolation) String() string {
+	if v.sum == v.cage.Sum {
+		return fmt.Sprintf("cage %d: repeats a number", v.index)
+	}
+	return fmt.Sprintf("cage %d: sums to %d instead of %d", v.index, v.sum, v.cage.Sum)
+}
+
+// Any other broken constraint, reported only by its total cost. Constraints can name themselves by
+// implementing fmt.Stringer.
+type constraintCost struct {
+	constraint solver.Constraint
+	cost       float64
+}
+
+func (v constraintCost) String() string {
+	if named, ok := v.constraint.(fmt.Stringer); ok {
+		return fmt.Sprintf("%v: cost %v", named, v.cost)
+	}
+	return fmt.Sprintf("%T: cost %v", v.constraint, v.cost)
+}
+
+// Checks a filled grid against every constraint and returns what is broken. Regions that must hold
+// every number once report each number that does not appear exactly once, killer cages report their
+// wrong sums and repeats, pair constraints like anti-knight report each pair that breaks their rule, the
+// parity constraint reports each square of the wrong parity, sandwich clues report each line adding up
+// to something else, arrows each shaft that doesn't add up to its circle, and any other constraint
+// reports its cost. A solved puzzle has no violations.
+func findViolations(puzzle [][]int, constraints []solver.Constraint) (violations []fmt.Stringer) {
+
+	for _, constraint := range constraints {
+		switch c := constraint.(type) {
+
+		case solver.UniqueConstraint:
+			// Record a violation for every number whose count in the region is not exactly one
+			for index, region := range c.Groups {
+				counts := make([]int, len(region))
+				for _, cell := range region {
+					if number := puzzle[cell.Row][cell.Col]; number > 0 && number <= len(region) {
+						counts[number-1]++
+					}
+				}
+				for n, count := range counts {
+					if count != 1 {
+						violations = append(violations, constraintViolation{c.Kind, index + 1, n + 1, count})
+					}
+				}
+			}
+
+		case solver.CageConstraint:
+			for index, k := range c.Cages {
+				if cost := solver.CageCost(puzzle, []solver.Cage{k}); cost > 0 {
+					sum := 0
+					for _, cell := range k.Cells {
+						sum += puzzle[cell.Row][cell.Col]
+					}
+					violations = append(violations, cageViolation{index + 1, sum, k})
+				}
+			}
+
+		case solver.PairConstraint:
+			for _, v := range c.Violations(puzzle) {
+				violations = append(violations, v)
+			}
+
+		case solver.ParityConstraint:
+			for _, v := range c.Violations(puzzle) {
+				violations = append(violations, v)
+			}
+
+		case solver.SandwichConstraint:
+			for _, v := range c.Violations(puzzle) {
+				violations = append(violations, v)
+			}
+
+		case solver.ArrowConstraint:
+			for _, v := range c.Violations(puzzle) {
+				violations = append(violations, v)
+			}
+
+		default:
+			if cost := c.Cost(puzzle); cost > 0 {
+				violations = append(violations, constraintCost{c, cost})
+			}
+		}
+	}
+
+	return violations
+}
+
+// Compares a filled grid against the original puzzle and returns every clue that was not preserved.
+func findAlteredClues(puzzle [][]int, originalPuzzle [][]int) (altered []alteredClue) {
+
+	for r := range originalPuzzle {
+		for c := range originalPuzzle[r] {
+			if originalPuzzle[r][c] > 0 && puzzle[r][c] != originalPuzzle[r][c] {
+				altered = append(altered, alteredClue{r + 1, c + 1, originalPuzzle[r][c], puzzle[r][c]})
+			}
+		}
+	}
+
+	return altered
+}
+
+// The verify subcommand. Reads a completed grid (and optionally the puzzle it was meant to solve, whose
+// cages, regions and markings are checked too), prints every broken constraint and returns
+// ErrInvalidSolution if there were any.
+func verifyCommand(args []string) error {
+
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	delimiterPtr := flags.String("del", "", "The delimeter used to separate the puzzle squares in the input")
+	emptyValuePtr := flags.String("e", ".", "The character used to indicate an empty square in the puzzle")
+	variantPtr := flags.String("variant", "standard", "The puzzle variant, which adds extra constraints ("+solver.VariantNames()+")")
+	symbolsPtr := flags.String("symbols", "", "The symbols used for the numbers 1, 2, 3... when squares aren't delimited (eg. 0123456789ABCDEF). Defaults to 1-9 then A-Z for puzzles bigger than 9x9")
+	dimPtr := flags.String("d", "3x3", "The dimensions of one of the puzzle blocks (eg. standard sudoku is 3x3)")
+	filePtr := flags.String("f", "", "The file containing the completed grid to be verified (- reads standard input)")
+	linePtr := flags.Int("l", 1, "The line of the completed grid in the file")
+	originalFilePtr := flags.String("orig", "", "An optional file containing the original puzzle, used to check that no clues were altered")
+	originalLinePtr := flags.String("orig-l", "1", "The line of the original puzzle in the -orig file")
+	originalModePtr := flags.String("orig-m", "", "The input mode of the -orig file (one-line, json, killer, consecutive or jigsaw). Detected from the file extension when left out")
+
+	if err := parseFlags(flags, args); err != nil {
+		return err
+	}
+
+	gridLine, lineErr := checkPositiveInt("l", *linePtr)
+	originalLine, originalLineErr := parsePositiveInt("orig-l", *originalLinePtr)
+	blockXDim, blockYDim, dimErr := parseBlockDim("d", *dimPtr)
+
+	for _, err := range []error{lineErr, originalLineErr, dimErr} {
+		if err != nil {
+			return err
+		}
+	}
+
+	if *filePtr == "" {
+		return flagErrorf("a completed grid must be given with -f")
+	}
+
+	symbols, err := puzzleSymbols(*symbolsPtr, *delimiterPtr, blockXDim*blockYDim)
+	if err != nil {
+		return err
+	}
+
+	variant, err := variantConstraints(*variantPtr, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	var regionMap [][]int
+	if solver.HasBlocks(*variantPtr) {
+		regionMap = solver.BlockRegionMap(blockXDim, blockYDim)
+	}
+
+	grid, err := readPuzzleFile(*filePtr, gridLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return err
+	}
+
+	valid := true
+
+	for r := range grid {
+		for c := range grid[r] {
+			if grid[r][c] == 0 {
+				fmt.Printf("cell at row %d, column %d is empty\n", r+1, c+1)
+				valid = false
+			}
+		}
+	}
+
+	// The original puzzle may bring cages, irregular regions or markings the grid must also satisfy
+	var originalPuzzle [][]int
+	var cages []solver.Cage
+	if *originalFilePtr != "" {
+		var originalRegionMap [][]int
+		var extra []solver.Constraint
+		originalPuzzle, originalRegionMap, cages, extra, err = readOriginalPuzzle(*originalFilePtr, *originalModePtr, originalLine, *delimiterPtr, *emptyValuePtr, symbols, blockXDim, blockYDim)
+		if err != nil {
+			return err
+		}
+		if originalRegionMap != nil {
+			regionMap = originalRegionMap
+		}
+		variant = append(variant, extra...)
+	}
+
+	for _, v := range findViolations(grid, solver.PuzzleConstraints(len(grid), regionMap, variant, cages)) {
+		fmt.Println(v)
+		valid = false
+	}
+
+	for _, a := range findAlteredClues(grid, originalPuzzle) {
+		fmt.Println(a)
+		valid = false
+	}
+
+	if !valid {
+		return ErrInvalidSolution
+	}
+
+	fmt.Println("The grid is a valid solution.")
+	return nil
+}
+
+// Opens the named file (or standard input for "-", or a URL) and reads the original puzzle on the given
+// line in the given input mode, which is detected from the file extension when empty. Samurai puzzles
+// can't be verified this way, as their grids overlap, nor can ndjson streams.
+func readOriginalPuzzle(filename string, mode string, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, regionMap [][]int, cages []solver.Cage, extra []solver.Constraint, e error) {
+
+	if mode == "" {
+		mode = inputModeForFile(filename)
+	}
+	if mode == "samurai" || mode == "ndjson" {
+		return nil, nil, nil, nil, flagErrorf("the original puzzle can't be read in the %q input mode for -orig-m", mode)
+	}
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	defer inFile.Close()
+
+	puzzle, regionMap, cages, extra, err = readPuzzle(inFile, mode, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	if puzzle == nil {
+		return nil, nil, nil, nil, puzzleErrorf("%s has no puzzle on line %d", filename, line)
+	}
+
+	return puzzle, regionMap, cages, extra, nil
+}
+
+// Opens the named file (or standard input for "-", or a URL) and reads the puzzle on the given line in the
+// one-line format.
+func readPuzzleFile(filename string, line int, delimiter string, emptyValue string, symbols string, blockXDim int, blockYDim int) (puzzle [][]int, e error) {
+
+	inFile, err := openInput(filename, defaultFetchTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer inFile.Close()
+
+	puzzle, err = readInOneLine(inFile, line, delimiter, emptyValue, symbols, blockXDim, blockYDim)
+	if err != nil {
+		return nil, err
+	}
+	if puzzle == nil {
+		return nil, puzzleErrorf("%s has no puzzle on line %d", filename, line)
+	}
+
+	return puzzle, nil
+}
@@ -0,0 +1,95 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"encoding/json"
+	"syscall/js"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// The longest a solve run from JavaScript may take, whatever its options ask for.
+const wasmMaxTimeout = 10 * time.Minute
+
+// Built for WebAssembly, the solver has no command line: main exports solve(puzzle, options) to
+// JavaScript and waits to be called. The options take the same names as the HTTP API's solve requests
+// (dim, variant, temperature, cooling_rate, iterations, swaps, annealers and timeout_seconds), plus an
+// onProgress function that is called with a progress event after every cooling step. solve returns a
+// promise of the result, which is rejected when the puzzle or options are invalid.
+func main() {
+	js.Global().Set("solve", js.FuncOf(solveJS))
+	select {}
+}
+
+// Starts solving a puzzle for JavaScript and returns a promise of the result. The solve runs on its own
+// goroutine, so the page stays responsive (and its timers keep firing) while it anneals.
+func solveJS(this js.Value, args []js.Value) interface{} {
+
+	var puzzle, options js.Value = js.Undefined(), js.Undefined()
+	if len(args) > 0 {
+		puzzle = args[0]
+	}
+	if len(args) > 1 {
+		options = args[1]
+	}
+
+	executor := js.FuncOf(func(this js.Value, promise []js.Value) interface{} {
+		resolve, reject := promise[0], promise[1]
+		go func() {
+			result, err := solveWithOptions(puzzle, options)
+			if err != nil {
+				reject.Invoke(js.Global().Get("Error").New(err.Error()))
+				return
+			}
+			resolve.Invoke(result)
+		}()
+		return nil
+	})
+	defer executor.Release()
+
+	return js.Global().Get("Promise").New(executor)
+}
+
+// Reads the puzzle and options passed to solve, anneals the puzzle and returns the result as a
+// JavaScript object.
+func solveWithOptions(puzzle js.Value, options js.Value) (js.Value, error) {
+
+	var request solveRequest
+	var onProgress js.Value = js.Undefined()
+	if options.Type() == js.TypeObject {
+		// The options are read through JSON, the same way as the HTTP API's requests
+		text := js.Global().Get("JSON").Call("stringify", options).String()
+		if err := json.Unmarshal([]byte(text), &request); err != nil {
+			return js.Undefined(), err
+		}
+		onProgress = options.Get("onProgress")
+	}
+	if puzzle.Type() == js.TypeString {
+		request.Puzzle = puzzle.String()
+	}
+
+	server := &solveServer{maxTimeout: wasmMaxTimeout, metrics: newServerMetrics()}
+	p, err := server.readRequest(request)
+	if err != nil {
+		return js.Undefined(), err
+	}
+
+	var progress func(solver.AnnealProgress)
+	if onProgress.Type() == js.TypeFunction {
+		writer := puzzleWriter{symbols: p.symbols, emptyValue: "."}
+		progress = func(step solver.AnnealProgress) {
+			event := progressEvent{step.Step, step.Temperature, step.BestCost, step.AcceptanceRate, step.Elapsed.Seconds(), writer.oneLine(step.Candidate)}
+			onProgress.Invoke(jsObject(event))
+		}
+	}
+
+	return jsObject(server.solve(p, progress, nil)), nil
+}
+
+// Converts a value to a plain JavaScript object with the same fields as its JSON.
+func jsObject(value interface{}) js.Value {
+	text, _ := json.Marshal(value)
+	return js.Global().Get("JSON").Call("parse", string(text))
+}
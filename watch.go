@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/evjrob/sudoku-annealing/solver"
+)
+
+// Returns a progress function for anneal that draws the best candidate solution to w after every cooling
+// step, redrawing it in place with ANSI cursor movement and colouring its cells with cellColours. A status
+// line with the temperature and cost is drawn beneath the grid.
+func watchReporter(w io.Writer, originalPuzzle [][]int, constraints []solver.Constraint, regionMap [][]int, extraRegions [][]solver.Cell, symbols string) func(solver.AnnealProgress) {
+
+	drawnLines := 0
+
+	return func(p solver.AnnealProgress) {
+
+		decorate, conflictCount := cellColours(originalPuzzle, p.Candidate, constraints)
+		grid := formatPuzzle(p.Candidate, regionMap, extraRegions, symbols, decorate)
+
+		var b strings.Builder
+		if drawnLines > 0 {
+			fmt.Fprintf(&b, ansiLineUp, drawnLines)
+		}
+		for _, line := range strings.SplitAfter(grid, "\n") {
+			if line != "" {
+				b.WriteString(ansiClearLn + line)
+			}
+		}
+		fmt.Fprintf(&b, "%s\n%sstep %d: temperature %.6g, cost %v, %d conflicting cells, elapsed %s\n", ansiClearLn, ansiClearLn, p.Step, p.Temperature, p.BestCost, conflictCount, p.Elapsed.Round(time.Millisecond))
+
+		drawnLines = strings.Count(b.String(), "\n")
+		io.WriteString(w, b.String())
+	}
+}
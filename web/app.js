@@ -0,0 +1,142 @@
+// The symbols the server uses for the numbers 1, 2, 3... in one-line puzzles.
+const SYMBOLS = "123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ";
+
+const grid = document.getElementById("grid");
+const dim = document.getElementById("dim");
+const line = document.getElementById("line");
+const statusLine = document.getElementById("status");
+const solveButton = document.getElementById("solve");
+const download = document.getElementById("download");
+
+let blockWidth, blockHeight, size, squares = [], events = null;
+
+// Draws an empty grid for the chosen block dimensions.
+function buildGrid() {
+  [blockWidth, blockHeight] = dim.value.split("x").map(Number);
+  size = blockWidth * blockHeight;
+
+  grid.innerHTML = "";
+  grid.style.gridTemplateColumns = `repeat(${size}, auto)`;
+  squares = [];
+
+  for (let r = 0; r < size; r++) {
+    for (let c = 0; c < size; c++) {
+      const square = document.createElement("input");
+      square.maxLength = 1;
+      if (c < size - 1 && (c + 1) % blockWidth === 0) square.classList.add("right");
+      if (r < size - 1 && (r + 1) % blockHeight === 0) square.classList.add("bottom");
+      square.addEventListener("input", () => {
+        square.value = square.value.toUpperCase();
+        if (!SYMBOLS.slice(0, size).includes(square.value)) square.value = "";
+        square.classList.remove("annealed");
+        square.classList.toggle("clue", square.value !== "");
+        line.value = puzzleLine();
+      });
+      grid.appendChild(square);
+      squares.push(square);
+    }
+  }
+  download.hidden = true;
+}
+
+// Returns the clues in the grid as a one-line puzzle.
+function puzzleLine() {
+  return squares.map((square) => square.classList.contains("annealed") ? "." : (square.value || ".")).join("");
+}
+
+// Fills the grid with a one-line puzzle, marking the squares as clues.
+function showPuzzle(text) {
+  squares.forEach((square, i) => {
+    const symbol = text[i] && text[i] !== "." && text[i] !== "0" ? text[i].toUpperCase() : "";
+    square.value = symbol;
+    square.classList.toggle("clue", symbol !== "");
+    square.classList.remove("annealed");
+  });
+}
+
+// Shows a candidate solution, keeping the clues as they were.
+function showCandidate(text) {
+  squares.forEach((square, i) => {
+    if (!square.classList.contains("clue")) {
+      square.value = text[i] === "." ? "" : text[i];
+      square.classList.add("annealed");
+    }
+  });
+}
+
+dim.addEventListener("change", buildGrid);
+
+line.addEventListener("input", () => {
+  const text = line.value.trim();
+  if (text.length === size * size) showPuzzle(text);
+});
+
+document.getElementById("clear").addEventListener("click", () => {
+  if (events) events.close();
+  line.value = "";
+  buildGrid();
+  statusLine.textContent = "Enter a puzzle.";
+  solveButton.disabled = false;
+});
+
+document.getElementById("controls").addEventListener("submit", async (e) => {
+  e.preventDefault();
+  if (events) events.close();
+
+  const puzzle = puzzleLine();
+  showPuzzle(puzzle);
+  download.hidden = true;
+
+  const request = {puzzle, dim: dim.value};
+  for (const name of ["temperature", "cooling_rate", "timeout_seconds"]) {
+    request[name] = parseFloat(document.getElementById(name).value) || 0;
+  }
+  for (const name of ["iterations", "swaps", "annealers"]) {
+    request[name] = parseInt(document.getElementById(name).value, 10) || 0;
+  }
+
+  const response = await fetch("/solves", {method: "POST", body: JSON.stringify(request)});
+  const body = await response.json();
+  if (!response.ok) {
+    statusLine.textContent = body.error;
+    return;
+  }
+
+  solveButton.disabled = true;
+  statusLine.textContent = "Annealing...";
+
+  events = new EventSource(body.events);
+  events.addEventListener("progress", (message) => {
+    const progress = JSON.parse(message.data);
+    showCandidate(progress.candidate);
+    statusLine.textContent = `Step ${progress.step}: temperature ${progress.temperature.toPrecision(3)}, best cost ${progress.best_cost}, ${progress.elapsed_seconds.toFixed(1)}s`;
+  });
+  events.addEventListener("result", (message) => {
+    const result = JSON.parse(message.data);
+    events.close();
+    events = null;
+    solveButton.disabled = false;
+
+    showCandidate(result.solution);
+    if (result.solved) {
+      statusLine.textContent = `Solved in ${result.seconds.toFixed(2)}s.`;
+    } else if (result.timed_out) {
+      statusLine.textContent = `Ran out of time with a cost of ${result.cost}.`;
+    } else {
+      statusLine.textContent = `No solution found; the best candidate has a cost of ${result.cost}.`;
+    }
+
+    download.href = URL.createObjectURL(new Blob([result.solution + "\n"], {type: "text/plain"}));
+    download.hidden = false;
+  });
+  events.onerror = () => {
+    if (events) {
+      events.close();
+      events = null;
+      solveButton.disabled = false;
+      statusLine.textContent = "Lost the connection to the server.";
+    }
+  };
+});
+
+buildGrid();
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// The web frontend served by the serve subcommand, built into the binary so that it runs on its own.
+//
+//go:embed web
+var webFiles embed.FS
+
+// Returns a handler serving the web frontend.
+func webHandler() http.Handler {
+
+	files, err := fs.Sub(webFiles, "web")
+	if err != nil {
+		panic(err)
+	}
+
+	return http.FileServer(http.FS(files))
+}